@@ -0,0 +1,112 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResourceIPAMConfigs the name of the ipamconfigs resources.
+var ResourceIPAMConfigs = "ipamconfigs"
+
+// IPAMConfigSpec defines the desired state of IPAMConfig.
+type IPAMConfigSpec struct {
+	// Network pools used to map a cluster network into another one in order to prevent conflicts,
+	// in addition to the default private ones and to the ones configured at network manager startup.
+	// +kubebuilder:validation:Optional
+	AdditionalPools []string `json:"additionalPools,omitempty"`
+	// Networks used by the Kubernetes infrastructure (e.g., the node subnet), which shall not be used to map remote networks,
+	// in addition to the ones configured at network manager startup.
+	// +kubebuilder:validation:Optional
+	ReservedSubnets []string `json:"reservedSubnets,omitempty"`
+}
+
+// IPAMConfigPhase describes the phase of the IPAMConfig.
+// +kubebuilder:validation:Enum="Applied";"PartiallyApplied"
+type IPAMConfigPhase string
+
+const (
+	// IPAMConfigPhaseApplied means that all the entries of the IPAMConfig have been applied.
+	IPAMConfigPhaseApplied IPAMConfigPhase = "Applied"
+	// IPAMConfigPhasePartiallyApplied means that some entries of the IPAMConfig have been rejected.
+	IPAMConfigPhasePartiallyApplied IPAMConfigPhase = "PartiallyApplied"
+)
+
+// IPAMConfigEntryType describes the type of an IPAMConfig entry.
+// +kubebuilder:validation:Enum="AdditionalPool";"ReservedSubnet"
+type IPAMConfigEntryType string
+
+const (
+	// IPAMConfigEntryAdditionalPool identifies an additional network pool.
+	IPAMConfigEntryAdditionalPool IPAMConfigEntryType = "AdditionalPool"
+	// IPAMConfigEntryReservedSubnet identifies a reserved subnet.
+	IPAMConfigEntryReservedSubnet IPAMConfigEntryType = "ReservedSubnet"
+)
+
+// IPAMConfigRejectedEntry describes a network whose addition or removal has been refused by the IPAM.
+type IPAMConfigRejectedEntry struct {
+	// The network the entry refers to.
+	Network string `json:"network"`
+	// Whether the network is an additional pool or a reserved subnet.
+	Type IPAMConfigEntryType `json:"type"`
+	// The reason why the addition or the removal of the network has been refused.
+	Reason string `json:"reason"`
+}
+
+// IPAMConfigStatus defines the observed state of IPAMConfig.
+type IPAMConfigStatus struct {
+	// The generation of the IPAMConfig last processed by the network manager.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Whether all the entries have been applied, or some of them have been rejected.
+	Phase IPAMConfigPhase `json:"phase,omitempty"`
+	// The additional network pools currently in use, including the ones configured at network manager startup.
+	AdditionalPools []string `json:"additionalPools,omitempty"`
+	// The reserved subnets currently in use, including the ones configured at network manager startup.
+	ReservedSubnets []string `json:"reservedSubnets,omitempty"`
+	// The networks whose addition or removal has been refused, either because conflicting with the
+	// other networks known by the IPAM, or because still in use.
+	Rejected []IPAMConfigRejectedEntry `json:"rejected,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,categories=liqo
+// +kubebuilder:subresource:status
+
+// IPAMConfig is the Schema for the ipamconfigs API, which allows to configure at runtime the additional network pools
+// and the reserved subnets of the IPAM. It is a singleton, and only the resource named "ipam" is taken into account.
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Pools",type=string,JSONPath=`.status.additionalPools`,priority=1
+// +kubebuilder:printcolumn:name="Reserved",type=string,JSONPath=`.status.reservedSubnets`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type IPAMConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPAMConfigSpec   `json:"spec,omitempty"`
+	Status IPAMConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPAMConfigList contains a list of IPAMConfig.
+type IPAMConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPAMConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IPAMConfig{}, &IPAMConfigList{})
+}
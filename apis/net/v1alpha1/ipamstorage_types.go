@@ -34,6 +34,8 @@ type Subnets struct {
 	LocalNATExternalCIDR string `json:"localNATExternalCIDR"`
 	// Network used in local cluster for remote service endpoints.
 	RemoteExternalCIDR string `json:"remoteExternalCIDR"`
+	// Networks used in local cluster for the additional networks exported by the remote cluster, keyed by the original network.
+	RemoteExportedCIDRs map[string]string `json:"remoteExportedCIDRs,omitempty"`
 }
 
 // ClusterMapping is an empty struct.
@@ -37,12 +37,35 @@ type NetworkConfigSpec struct {
 	PodCIDR string `json:"podCIDR"`
 	// Network used for local service endpoints.
 	ExternalCIDR string `json:"externalCIDR"`
+	// Additional networks exported to the remote cluster, besides the PodCIDR and the ExternalCIDR
+	// (e.g., the node subnet, the service CIDR, or on-premise subnets reachable from the local cluster).
+	// +kubebuilder:validation:Optional
+	ExportedCIDRs []string `json:"exportedCIDRs,omitempty"`
+	// Networks of third clusters reachable from the remote cluster through the local one, which forwards the traffic
+	// between the two tunnels. They are handled by the remote cluster as additional exported networks.
+	// +kubebuilder:validation:Optional
+	TransitRoutes []TransitRoute `json:"transitRoutes,omitempty"`
 	// Public IP of the node where the VPN tunnel is created.
 	EndpointIP string `json:"endpointIP"`
+	// Additional public IPs (or hostnames) the VPN tunnel can be created towards, in decreasing order of priority, in case the
+	// EndpointIP stops being reachable (e.g., for multi-homed sites). They are reachable at the same port as the EndpointIP.
+	// +kubebuilder:validation:Optional
+	FallbackEndpointIPs []string `json:"fallbackEndpointIPs,omitempty"`
 	// Vpn technology used to interconnect two clusters.
 	BackendType string `json:"backendType"`
 	// Connection parameters
 	BackendConfig map[string]string `json:"backend_config"`
+	// Bandwidth limits and priority of the traffic exchanged with the remote cluster, enforced by the local gateway only.
+	// +kubebuilder:validation:Optional
+	QoS *QoS `json:"qos,omitempty"`
+}
+
+// TransitRoute describes the networks of a third cluster reachable through the cluster advertising them.
+type TransitRoute struct {
+	// The identity of the cluster the networks belong to.
+	Cluster discoveryv1alpha1.ClusterIdentity `json:"cluster"`
+	// The networks of the cluster, as seen by the cluster advertising them.
+	CIDRs []string `json:"cidrs"`
 }
 
 // NetworkConfigStatus defines the observed state of NetworkConfig.
@@ -59,6 +82,9 @@ type NetworkConfigStatus struct {
 	// The new subnet used to NAT the externalCIDR of the remote cluster. The original ExternalCIDR may have been mapped
 	// to this network by the remote cluster.
 	ExternalCIDRNAT string `json:"externalCIDRNAT,omitempty"`
+	// The new subnets used to NAT the ExportedCIDRs of the remote cluster, keyed by the original network.
+	// The "None" value means that the given network has not been remapped.
+	ExportedCIDRsNAT map[string]string `json:"exportedCIDRsNAT,omitempty"`
 }
 
 // +kubebuilder:object:root=true
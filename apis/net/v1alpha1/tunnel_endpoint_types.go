@@ -15,6 +15,7 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	discv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
@@ -56,13 +57,67 @@ type TunnelEndpointSpec struct {
 	// +kubebuilder:default="None"
 	// +kubebuilder:validation:Optional
 	RemoteNATExternalCIDR string `json:"remoteNATExternalCIDR"`
+	// Additional networks of the local cluster exported to the remote one, and the networks used in the remote cluster to map them.
+	// +kubebuilder:validation:Optional
+	LocalExportedCIDRs []ExportedCIDR `json:"localExportedCIDRs,omitempty"`
+	// Additional networks exported by the remote cluster, and the networks used in the local cluster to map them.
+	// +kubebuilder:validation:Optional
+	RemoteExportedCIDRs []ExportedCIDR `json:"remoteExportedCIDRs,omitempty"`
+	// Networks of third clusters reachable through the remote cluster, as seen by the local cluster (i.e., remapped in case
+	// of conflicts). They are also included in RemoteExportedCIDRs, to be configured as the other networks of the remote cluster.
+	// +kubebuilder:validation:Optional
+	TransitRoutes []TransitRoute `json:"transitRoutes,omitempty"`
 
 	// Public IP of the node where the VPN tunnel is created.
 	EndpointIP string `json:"endpointIP"`
+	// Additional public IPs (or hostnames) the VPN tunnel can be created towards, in decreasing order of priority, in case the
+	// EndpointIP stops being reachable (e.g., for multi-homed sites). They are reachable at the same port as the EndpointIP.
+	// +kubebuilder:validation:Optional
+	FallbackEndpointIPs []string `json:"fallbackEndpointIPs,omitempty"`
 	// Vpn technology used to interconnect two clusters.
 	BackendType string `json:"backendType"`
 	// Connection parameters.
 	BackendConfig map[string]string `json:"backend_config"`
+	// Bandwidth limits and priority of the traffic exchanged with the remote cluster, enforced by the local gateway.
+	// +kubebuilder:validation:Optional
+	QoS *QoS `json:"qos,omitempty"`
+}
+
+// ExportedCIDR describes an additional network exported by a cluster, besides the PodCIDR and the ExternalCIDR.
+type ExportedCIDR struct {
+	// The exported network.
+	CIDR string `json:"cidr"`
+	// Network used in the importing cluster to map the exported one, in case of conflicts.
+	// +kubebuilder:default="None"
+	// +kubebuilder:validation:Optional
+	NATCIDR string `json:"natCIDR"`
+}
+
+// TrafficPriority is the priority of the traffic exchanged with a remote cluster, when the gateway uplink is congested.
+// +kubebuilder:validation:Enum=High;Normal;Low
+type TrafficPriority string
+
+const (
+	// HighTrafficPriority is the priority of the traffic served before the one of the other clusters.
+	HighTrafficPriority TrafficPriority = "High"
+	// NormalTrafficPriority is the default priority of the traffic.
+	NormalTrafficPriority TrafficPriority = "Normal"
+	// LowTrafficPriority is the priority of the traffic served after the one of the other clusters.
+	LowTrafficPriority TrafficPriority = "Low"
+)
+
+// QoS describes the bandwidth limits and the priority of the traffic exchanged with a remote cluster.
+type QoS struct {
+	// The maximum rate of the traffic sent to the remote cluster, in bits per second (e.g., 100M). Unlimited if not set.
+	// +kubebuilder:validation:Optional
+	EgressBandwidth *resource.Quantity `json:"egressBandwidth,omitempty"`
+	// The maximum rate of the traffic received from the remote cluster, in bits per second (e.g., 100M). Unlimited if not set.
+	// +kubebuilder:validation:Optional
+	IngressBandwidth *resource.Quantity `json:"ingressBandwidth,omitempty"`
+	// The priority of the traffic exchanged with the remote cluster, compared to the one of the other clusters.
+	// +kubebuilder:default=Normal
+	// +kubebuilder:validation:Optional
+	Priority TrafficPriority `json:"priority,omitempty"`
 }
 
 // TunnelEndpointStatus defines the observed state of TunnelEndpoint.
@@ -74,6 +129,9 @@ type TunnelEndpointStatus struct {
 	VethIP           string     `json:"vethIP,omitempty"`
 	GatewayIP        string     `json:"gatewayIP,omitempty"`
 	Connection       Connection `json:"connection,omitempty"`
+	// MTU is the maximum transmission unit negotiated for the traffic towards the remote cluster,
+	// according to the configured one and the path MTU discovered towards the remote endpoint.
+	MTU int `json:"mtu,omitempty"`
 }
 
 // Connection holds the configuration and status of a vpn tunnel connecting to remote cluster.
@@ -81,6 +139,15 @@ type Connection struct {
 	Status            ConnectionStatus  `json:"status,omitempty"`
 	StatusMessage     string            `json:"statusMessage,omitempty"`
 	PeerConfiguration map[string]string `json:"peerConfiguration,omitempty"`
+	Latency           ConnectionLatency `json:"latency,omitempty"`
+}
+
+// ConnectionLatency represents the most recent round-trip time measured towards the remote gateway.
+type ConnectionLatency struct {
+	// Value is the measured round-trip time.
+	Value string `json:"value,omitempty"`
+	// Timestamp is the time the round-trip time has been measured.
+	Timestamp metav1.Time `json:"timestamp,omitempty"`
 }
 
 // ConnectionStatus type that describes the status of vpn connection with a remote cluster.
@@ -108,6 +175,8 @@ const (
 // +kubebuilder:printcolumn:name="Endpoint IP",type=string,JSONPath=`.spec.endpointIP`,priority=1
 // +kubebuilder:printcolumn:name="Backend type",type=string,JSONPath=`.spec.backendType`
 // +kubebuilder:printcolumn:name="Connection status",type=string,JSONPath=`.status.connection.status`
+// +kubebuilder:printcolumn:name="Latency",type=string,JSONPath=`.status.connection.latency.value`,priority=1
+// +kubebuilder:printcolumn:name="MTU",type=integer,JSONPath=`.status.mtu`,priority=1
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type TunnelEndpoint struct {
 	metav1.TypeMeta   `json:",inline"`
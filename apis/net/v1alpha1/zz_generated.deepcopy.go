@@ -63,6 +63,7 @@ func (in *Connection) DeepCopyInto(out *Connection) {
 			(*out)[key] = val
 		}
 	}
+	in.Latency.DeepCopyInto(&out.Latency)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Connection.
@@ -75,6 +76,22 @@ func (in *Connection) DeepCopy() *Connection {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionLatency) DeepCopyInto(out *ConnectionLatency) {
+	*out = *in
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionLatency.
+func (in *ConnectionLatency) DeepCopy() *ConnectionLatency {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionLatency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EndpointMapping) DeepCopyInto(out *EndpointMapping) {
 	*out = *in
@@ -97,6 +114,150 @@ func (in *EndpointMapping) DeepCopy() *EndpointMapping {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportedCIDR) DeepCopyInto(out *ExportedCIDR) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportedCIDR.
+func (in *ExportedCIDR) DeepCopy() *ExportedCIDR {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportedCIDR)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAMConfig) DeepCopyInto(out *IPAMConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMConfig.
+func (in *IPAMConfig) DeepCopy() *IPAMConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPAMConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAMConfigList) DeepCopyInto(out *IPAMConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IPAMConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMConfigList.
+func (in *IPAMConfigList) DeepCopy() *IPAMConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPAMConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAMConfigRejectedEntry) DeepCopyInto(out *IPAMConfigRejectedEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMConfigRejectedEntry.
+func (in *IPAMConfigRejectedEntry) DeepCopy() *IPAMConfigRejectedEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMConfigRejectedEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAMConfigSpec) DeepCopyInto(out *IPAMConfigSpec) {
+	*out = *in
+	if in.AdditionalPools != nil {
+		in, out := &in.AdditionalPools, &out.AdditionalPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReservedSubnets != nil {
+		in, out := &in.ReservedSubnets, &out.ReservedSubnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMConfigSpec.
+func (in *IPAMConfigSpec) DeepCopy() *IPAMConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAMConfigStatus) DeepCopyInto(out *IPAMConfigStatus) {
+	*out = *in
+	if in.AdditionalPools != nil {
+		in, out := &in.AdditionalPools, &out.AdditionalPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReservedSubnets != nil {
+		in, out := &in.ReservedSubnets, &out.ReservedSubnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rejected != nil {
+		in, out := &in.Rejected, &out.Rejected
+		*out = make([]IPAMConfigRejectedEntry, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMConfigStatus.
+func (in *IPAMConfigStatus) DeepCopy() *IPAMConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IpamSpec) DeepCopyInto(out *IpamSpec) {
 	*out = *in
@@ -129,7 +290,7 @@ func (in *IpamSpec) DeepCopyInto(out *IpamSpec) {
 		in, out := &in.ClusterSubnets, &out.ClusterSubnets
 		*out = make(map[string]Subnets, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 	if in.EndpointMappings != nil {
@@ -339,7 +500,7 @@ func (in *NetworkConfig) DeepCopyInto(out *NetworkConfig) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkConfig.
@@ -396,6 +557,23 @@ func (in *NetworkConfigList) DeepCopyObject() runtime.Object {
 func (in *NetworkConfigSpec) DeepCopyInto(out *NetworkConfigSpec) {
 	*out = *in
 	out.RemoteCluster = in.RemoteCluster
+	if in.ExportedCIDRs != nil {
+		in, out := &in.ExportedCIDRs, &out.ExportedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TransitRoutes != nil {
+		in, out := &in.TransitRoutes, &out.TransitRoutes
+		*out = make([]TransitRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FallbackEndpointIPs != nil {
+		in, out := &in.FallbackEndpointIPs, &out.FallbackEndpointIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.BackendConfig != nil {
 		in, out := &in.BackendConfig, &out.BackendConfig
 		*out = make(map[string]string, len(*in))
@@ -403,6 +581,11 @@ func (in *NetworkConfigSpec) DeepCopyInto(out *NetworkConfigSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(QoS)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkConfigSpec.
@@ -418,6 +601,13 @@ func (in *NetworkConfigSpec) DeepCopy() *NetworkConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkConfigStatus) DeepCopyInto(out *NetworkConfigStatus) {
 	*out = *in
+	if in.ExportedCIDRsNAT != nil {
+		in, out := &in.ExportedCIDRsNAT, &out.ExportedCIDRsNAT
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkConfigStatus.
@@ -430,9 +620,41 @@ func (in *NetworkConfigStatus) DeepCopy() *NetworkConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QoS) DeepCopyInto(out *QoS) {
+	*out = *in
+	if in.EgressBandwidth != nil {
+		in, out := &in.EgressBandwidth, &out.EgressBandwidth
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.IngressBandwidth != nil {
+		in, out := &in.IngressBandwidth, &out.IngressBandwidth
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QoS.
+func (in *QoS) DeepCopy() *QoS {
+	if in == nil {
+		return nil
+	}
+	out := new(QoS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Subnets) DeepCopyInto(out *Subnets) {
 	*out = *in
+	if in.RemoteExportedCIDRs != nil {
+		in, out := &in.RemoteExportedCIDRs, &out.RemoteExportedCIDRs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Subnets.
@@ -445,6 +667,27 @@ func (in *Subnets) DeepCopy() *Subnets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransitRoute) DeepCopyInto(out *TransitRoute) {
+	*out = *in
+	out.Cluster = in.Cluster
+	if in.CIDRs != nil {
+		in, out := &in.CIDRs, &out.CIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransitRoute.
+func (in *TransitRoute) DeepCopy() *TransitRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(TransitRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TunnelEndpoint) DeepCopyInto(out *TunnelEndpoint) {
 	*out = *in
@@ -508,6 +751,28 @@ func (in *TunnelEndpointList) DeepCopyObject() runtime.Object {
 func (in *TunnelEndpointSpec) DeepCopyInto(out *TunnelEndpointSpec) {
 	*out = *in
 	out.ClusterIdentity = in.ClusterIdentity
+	if in.LocalExportedCIDRs != nil {
+		in, out := &in.LocalExportedCIDRs, &out.LocalExportedCIDRs
+		*out = make([]ExportedCIDR, len(*in))
+		copy(*out, *in)
+	}
+	if in.RemoteExportedCIDRs != nil {
+		in, out := &in.RemoteExportedCIDRs, &out.RemoteExportedCIDRs
+		*out = make([]ExportedCIDR, len(*in))
+		copy(*out, *in)
+	}
+	if in.TransitRoutes != nil {
+		in, out := &in.TransitRoutes, &out.TransitRoutes
+		*out = make([]TransitRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FallbackEndpointIPs != nil {
+		in, out := &in.FallbackEndpointIPs, &out.FallbackEndpointIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.BackendConfig != nil {
 		in, out := &in.BackendConfig, &out.BackendConfig
 		*out = make(map[string]string, len(*in))
@@ -515,6 +780,11 @@ func (in *TunnelEndpointSpec) DeepCopyInto(out *TunnelEndpointSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(QoS)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TunnelEndpointSpec.
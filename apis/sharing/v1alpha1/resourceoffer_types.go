@@ -30,6 +30,21 @@ type StorageType struct {
 	Default bool `json:"default,omitempty"`
 }
 
+// PodFeature identifies a privileged pod feature, which offloaded pods may leverage only if permitted by the provider cluster.
+// +kubebuilder:validation:Enum="HostNetwork";"HostIPC";"HostPID";"HostPort"
+type PodFeature string
+
+const (
+	// PodFeatureHostNetwork -> the pod shares the network namespace of the node it is running on.
+	PodFeatureHostNetwork PodFeature = "HostNetwork"
+	// PodFeatureHostIPC -> the pod shares the IPC namespace of the node it is running on.
+	PodFeatureHostIPC PodFeature = "HostIPC"
+	// PodFeatureHostPID -> the pod shares the PID namespace of the node it is running on.
+	PodFeatureHostPID PodFeature = "HostPID"
+	// PodFeatureHostPort -> at least one container of the pod binds a port on the node it is running on.
+	PodFeatureHostPort PodFeature = "HostPort"
+)
+
 // ResourceOfferSpec defines the desired state of ResourceOffer.
 type ResourceOfferSpec struct {
 	// ClusterID is the identifier of the cluster that is sending this ResourceOffer.
@@ -47,6 +62,9 @@ type ResourceOfferSpec struct {
 	WithdrawalTimestamp *metav1.Time `json:"withdrawalTimestamp,omitempty"`
 	// StorageClasses contains the list of the storage classes offered by the cluster.
 	StorageClasses []StorageType `json:"storageClasses,omitempty"`
+	// AllowedPodFeatures contains the list of privileged pod features that offloaded pods are permitted to use.
+	// Pods requiring any feature not included in this list are rejected by the provider cluster.
+	AllowedPodFeatures []PodFeature `json:"allowedPodFeatures,omitempty"`
 }
 
 // OfferPhase describes the phase of the ResourceOffer.
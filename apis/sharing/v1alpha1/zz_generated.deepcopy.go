@@ -117,6 +117,11 @@ func (in *ResourceOfferSpec) DeepCopyInto(out *ResourceOfferSpec) {
 		*out = make([]StorageType, len(*in))
 		copy(*out, *in)
 	}
+	if in.AllowedPodFeatures != nil {
+		in, out := &in.AllowedPodFeatures, &out.AllowedPodFeatures
+		*out = make([]PodFeature, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceOfferSpec.
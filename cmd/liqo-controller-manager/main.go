@@ -50,6 +50,7 @@ import (
 	virtualkubeletv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
 	"github.com/liqotech/liqo/pkg/consts"
 	identitymanager "github.com/liqotech/liqo/pkg/identityManager"
+	"github.com/liqotech/liqo/pkg/liqo-controller-manager/crossclusterdns"
 	foreignclusteroperator "github.com/liqotech/liqo/pkg/liqo-controller-manager/foreign-cluster-operator"
 	mapsctrl "github.com/liqotech/liqo/pkg/liqo-controller-manager/namespacemap-controller"
 	nsoffctrl "github.com/liqotech/liqo/pkg/liqo-controller-manager/namespaceoffloading-controller"
@@ -63,6 +64,7 @@ import (
 	nsoffwh "github.com/liqotech/liqo/pkg/liqo-controller-manager/webhooks/namespaceoffloading"
 	podwh "github.com/liqotech/liqo/pkg/liqo-controller-manager/webhooks/pod"
 	shadowpodswh "github.com/liqotech/liqo/pkg/liqo-controller-manager/webhooks/shadowpod"
+	workloadwh "github.com/liqotech/liqo/pkg/liqo-controller-manager/webhooks/workload"
 	peeringroles "github.com/liqotech/liqo/pkg/peering-roles"
 	tenantnamespace "github.com/liqotech/liqo/pkg/tenantNamespace"
 	argsutils "github.com/liqotech/liqo/pkg/utils/args"
@@ -91,6 +93,7 @@ func main() {
 	var clusterLabels argsutils.StringMap
 	var kubeletExtraAnnotations, kubeletExtraLabels argsutils.StringMap
 	var kubeletExtraArgs argsutils.StringList
+	var allowedPodFeatures argsutils.StringList
 	var nodeExtraAnnotations, nodeExtraLabels argsutils.StringMap
 	var kubeletCPURequests, kubeletCPULimits = argsutils.NewQuantity("250m"), argsutils.NewQuantity("1000m")
 	var kubeletRAMRequests, kubeletRAMLimits = argsutils.NewQuantity("100M"), argsutils.NewQuantity("250M")
@@ -133,6 +136,8 @@ func main() {
 	offerUpdateThreshold := argsutils.Percentage{Val: 5}
 	flag.Var(&offerUpdateThreshold, "offer-update-threshold-percentage",
 		"The threshold (in percentage) of resources quantity variation which triggers a ResourceOffer update")
+	flag.Var(&allowedPodFeatures, "allowed-pod-features",
+		"The privileged pod features (HostNetwork, HostIPC, HostPID, HostPort) that pods offloaded by remote clusters are permitted to use")
 
 	// Virtual-kubelet parameters
 	kubeletImage := flag.String("kubelet-image", "liqo/virtual-kubelet", "The image of the virtual kubelet to be deployed")
@@ -152,6 +157,10 @@ func main() {
 	realStorageClassName := flag.String("real-storage-class-name", "", "Name of the real storage class to use for the actual volumes")
 	storageNamespace := flag.String("storage-namespace", "liqo-storage", "Namespace where the liqo storage-related resources are stored")
 
+	// Cross-cluster DNS parameters
+	crossClusterDNSAddress := flag.String("cross-cluster-dns-address", "",
+		"The address the resolver for the services hosted by remote clusters binds to, both UDP and TCP (empty to disable it)")
+
 	liqoerrors.InitFlags(nil)
 	restcfg.InitFlags(nil)
 	klog.InitFlags(nil)
@@ -159,6 +168,17 @@ func main() {
 
 	clusterIdentity := clusterIdentityFlags.ReadOrDie()
 
+	podFeatures := make([]sharingv1alpha1.PodFeature, 0, len(allowedPodFeatures.StringList))
+	for _, feature := range allowedPodFeatures.StringList {
+		switch podFeature := sharingv1alpha1.PodFeature(feature); podFeature {
+		case sharingv1alpha1.PodFeatureHostNetwork, sharingv1alpha1.PodFeatureHostIPC,
+			sharingv1alpha1.PodFeatureHostPID, sharingv1alpha1.PodFeatureHostPort:
+			podFeatures = append(podFeatures, podFeature)
+		default:
+			klog.Fatalf("Unknown pod feature %q", feature)
+		}
+	}
+
 	ctx := ctrl.SetupSignalHandler()
 
 	config := restcfg.SetRateLimiter(ctrl.GetConfigOrDie())
@@ -207,6 +227,8 @@ func main() {
 	// Register the webhooks.
 	mgr.GetWebhookServer().Register("/validate/foreign-cluster", fcwh.New())
 	mgr.GetWebhookServer().Register("/validate/shadowpods", &webhook.Admission{Handler: spv})
+	mgr.GetWebhookServer().Register("/validate/workloads", &webhook.Admission{
+		Handler: workloadwh.NewValidator(mgr.GetClient(), *enableResourceValidation)})
 	mgr.GetWebhookServer().Register("/validate/namespace-offloading", nsoffwh.New())
 	mgr.GetWebhookServer().Register("/mutate/pod", podwh.New(mgr.GetClient()))
 
@@ -266,7 +288,7 @@ func main() {
 		}
 	}
 	offerUpdater := resourceRequestOperator.NewOfferUpdater(ctx, mgr.GetClient(), clusterIdentity,
-		clusterLabels.StringMap, monitor, uint(offerUpdateThreshold.Val), *realStorageClassName, *enableStorage)
+		clusterLabels.StringMap, monitor, uint(offerUpdateThreshold.Val), *realStorageClassName, *enableStorage, podFeatures)
 	resourceRequestReconciler = &resourceRequestOperator.ResourceRequestReconciler{
 		Client:                mgr.GetClient(),
 		Scheme:                mgr.GetScheme(),
@@ -372,6 +394,13 @@ func main() {
 		}
 	}
 
+	if *crossClusterDNSAddress != "" {
+		resolver := crossclusterdns.NewResolver(mgr.GetClient(), idManager, *crossClusterDNSAddress)
+		if err = mgr.Add(resolver); err != nil {
+			klog.Fatal(err)
+		}
+	}
+
 	klog.Info("starting manager as controller manager")
 	if err := mgr.Start(ctx); err != nil {
 		klog.Error(err)
@@ -0,0 +1,94 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/liqotech/liqo/pkg/liqoctl/completion"
+	"github.com/liqotech/liqo/pkg/liqoctl/factory"
+	"github.com/liqotech/liqo/pkg/liqoctl/netcheck"
+	"github.com/liqotech/liqo/pkg/liqoctl/output"
+)
+
+const liqoctlNetworkCheckLongHelp = `Check the connectivity towards a remote cluster.
+
+The command probes, one hop at a time, the path followed by the traffic from a
+node of the local cluster towards a pod hosted by the given remote cluster:
+from the node to the local gateway, from the gateway through the tunnel, up to
+the remote gateway and finally to the remote pod. The outcome of each check is
+reported, along with the hop where the path breaks, if any.
+
+The probes are performed by the diagnostics agents embedded in the gateway and
+route components, which need to be enabled through the corresponding
+*gateway.diagnostics.enabled* and *route.diagnostics.enabled* Helm values.
+
+Unless specified, the probes originate from a node different from the one hosting
+the gateway, and target one of the pods currently offloaded to the remote cluster.
+
+The given cluster may also be reached in transit through a peered one (e.g., a
+hub cluster), in which case the resulting path is shown and probed up to the
+transit gateway, and up to the target pod if specified.
+
+Examples:
+  $ {{ .Executable }} network check eternal-donkey
+or
+  $ {{ .Executable }} network check eternal-donkey --node worker-1 --target 10.204.0.12
+or (output the routes, rules and NAT entries concerning the remote cluster)
+  $ {{ .Executable }} network check eternal-donkey --dump
+`
+
+func newNetworkCommand(ctx context.Context, f *factory.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "Inspect the network fabric towards remote clusters",
+		Long:  "Inspect the network fabric towards remote clusters.",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newNetworkCheckCommand(ctx, f))
+	return cmd
+}
+
+func newNetworkCheckCommand(ctx context.Context, f *factory.Factory) *cobra.Command {
+	options := netcheck.Options{Factory: f}
+	cmd := &cobra.Command{
+		Use:   "check cluster-name",
+		Short: "Check the connectivity towards a remote cluster",
+		Long:  WithTemplate(liqoctlNetworkCheckLongHelp),
+
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completion.ForeignClusters(ctx, f, 1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			options.ClusterName = args[0]
+			output.ExitOnErr(options.Run(ctx))
+		},
+	}
+
+	f.AddLiqoNamespaceFlag(cmd.Flags())
+	cmd.Flags().StringVar(&options.Node, "node", "", "The node the probes originate from (default: automatically selected)")
+	cmd.Flags().StringVar(&options.Target, "target", "",
+		"The IP address of the remote pod to be probed (default: one of the pods offloaded to the remote cluster)")
+	cmd.Flags().BoolVar(&options.Dump, "dump", false, "Output the routes, rules and NAT entries concerning the remote cluster")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", 30*time.Second, "Timeout for the completion of the checks")
+
+	f.Printer.CheckErr(cmd.RegisterFlagCompletionFunc(factory.FlagNamespace, completion.Namespaces(ctx, f, completion.NoLimit)))
+	f.Printer.CheckErr(cmd.RegisterFlagCompletionFunc("node", completion.Nodes(ctx, f, completion.NoLimit)))
+	return cmd
+}
@@ -0,0 +1,72 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/liqotech/liqo/pkg/liqoctl/completion"
+	"github.com/liqotech/liqo/pkg/liqoctl/factory"
+	"github.com/liqotech/liqo/pkg/liqoctl/output"
+	"github.com/liqotech/liqo/pkg/liqoctl/prepull"
+)
+
+const liqoctlPrepullLongHelp = `Pre-pull a set of container images in the remote clusters.
+
+This command warms the container images cache of the provider clusters, to
+speed up the startup of the offloaded pods (e.g., before large rollouts). To
+this end, it creates a temporary DaemonSet in the given namespace (which must be
+enabled for offloading), scheduled on the virtual nodes only (optionally, only
+on the one associated with the given remote cluster), and pulling the specified
+images. The DaemonSet is deleted once all images have been pulled, or the
+timeout expired.
+
+By default, each image is pulled on a single node of each provider cluster. To
+pull the images on all the nodes of the provider clusters, the virtual kubelet
+shall be configured with the --enable-daemonset-expansion flag.
+
+Examples:
+  $ {{ .Executable }} prepull nginx:1.23 redis:7.0 --namespace foo
+or
+  $ {{ .Executable }} prepull nginx:1.23 --namespace foo --remote-cluster-id 8ee9ba6b-2ad8-4e3b-9d43-4b8fbb2cf4b5
+`
+
+func newPrepullCommand(ctx context.Context, f *factory.Factory) *cobra.Command {
+	options := &prepull.Options{Factory: f}
+	var cmd = &cobra.Command{
+		Use:   "prepull image...",
+		Short: "Pre-pull a set of container images in the remote clusters",
+		Long:  WithTemplate(liqoctlPrepullLongHelp),
+
+		Args: cobra.MinimumNArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Images = args
+			output.ExitOnErr(options.Run(ctx))
+		},
+	}
+
+	f.AddNamespaceFlag(cmd.Flags())
+	f.Printer.CheckErr(cmd.RegisterFlagCompletionFunc(factory.FlagNamespace, completion.OffloadedNamespaces(ctx, f, completion.NoLimit)))
+
+	cmd.Flags().StringVar(&options.RemoteClusterID, "remote-cluster-id", "",
+		"The ID of the remote cluster the images are pulled in (default: all the clusters the namespace is offloaded to)")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", 10*time.Minute, "Timeout for the pre-pull operation")
+
+	return cmd
+}
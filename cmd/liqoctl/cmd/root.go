@@ -103,7 +103,9 @@ func NewRootCommand(ctx context.Context) *cobra.Command {
 	cmd.AddCommand(newOffloadCommand(ctx, f))
 	cmd.AddCommand(newUnoffloadCommand(ctx, f))
 	cmd.AddCommand(newStatusCommand(ctx, f))
+	cmd.AddCommand(newNetworkCommand(ctx, f))
 	cmd.AddCommand(newMoveCommand(ctx, f))
+	cmd.AddCommand(newPrepullCommand(ctx, f))
 	cmd.AddCommand(newVersionCommand(ctx, f))
 	cmd.AddCommand(newDocsCommand(ctx))
 	return cmd
@@ -17,17 +17,29 @@ package main
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
 )
 
+const (
+	// diagnosticsProbeTimeout is the time waited for the reply to each probe requested through the diagnostics agent.
+	diagnosticsProbeTimeout = time.Second
+	// diagnosticsProbeAttempts is the number of attempts before considering a probe requested through the diagnostics agent failed.
+	diagnosticsProbeAttempts = 3
+)
+
 type liqonetCommonFlags struct {
-	metricsAddr string
-	runAs       string
+	metricsAddr     string
+	diagnosticsAddr string
+	runAs           string
 }
 
 func addCommonFlags(liqonet *liqonetCommonFlags) {
 	flag.StringVar(&liqonet.metricsAddr, "metrics-bind-addr", ":0", "The address the metric endpoint binds to.")
+	flag.StringVar(&liqonet.diagnosticsAddr, "diagnostics-bind-addr", "",
+		"The address the diagnostics agent, queried by liqoctl through port-forward to check the connectivity, binds to "+
+			"(empty to disable, and expected to be a loopback one since the API is not authenticated).")
 	flag.StringVar(&liqonet.runAs, "run-as", liqoconst.LiqoGatewayOperatorName,
 		fmt.Sprintf("The accepted values are: %q, %q, %q.",
 			liqoconst.LiqoGatewayOperatorName, liqoconst.LiqoRouteOperatorName, liqoconst.LiqoNetworkManagerName))
@@ -15,23 +15,40 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
+	"golang.org/x/sys/unix"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	tunneloperator "github.com/liqotech/liqo/internal/liqonet/tunnel-operator"
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/diagnostics"
+	"github.com/liqotech/liqo/pkg/liqonet/ebpfnat"
+	"github.com/liqotech/liqo/pkg/liqonet/endpointresolver"
 	liqonetns "github.com/liqotech/liqo/pkg/liqonet/netns"
+	"github.com/liqotech/liqo/pkg/liqonet/qos"
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
+	// Register the plain GRE tunnel driver.
+	_ "github.com/liqotech/liqo/pkg/liqonet/tunnel/gre"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/mtu"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/prober"
+	tunnelwg "github.com/liqotech/liqo/pkg/liqonet/tunnel/wireguard"
 	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
 	"github.com/liqotech/liqo/pkg/liqonet/utils/links"
+	"github.com/liqotech/liqo/pkg/utils"
+	"github.com/liqotech/liqo/pkg/utils/args"
 	"github.com/liqotech/liqo/pkg/utils/mapper"
 	"github.com/liqotech/liqo/pkg/utils/restcfg"
 )
@@ -43,8 +60,28 @@ type gatewayOperatorFlags struct {
 	retryPeriod          time.Duration
 	tunnelMTU            uint
 	tunnelListeningPort  uint
+	probeInterval        time.Duration
+	keyRotationPeriod    time.Duration
+	keyRotationOverlap   time.Duration
+	activeActive         bool
+	mtuDiscovery         bool
+	accountingInterval   time.Duration
+	ebpfNAT              bool
+	uplinkBandwidth      args.Quantity
+	endpointResolver     *args.StringEnum
+	endpointResolverURL  string
+	endpointInterval     time.Duration
 }
 
+const (
+	// mtuProbeTimeout is the time waited for the reply to each probe during the path MTU discovery.
+	mtuProbeTimeout = time.Second
+	// mtuProbeAttempts is the number of attempts before considering a probe failed during the path MTU discovery.
+	mtuProbeAttempts = 2
+	// endpointResolverNone disables the endpoint detection, hence using the address inferred from the gateway service.
+	endpointResolverNone = "none"
+)
+
 func addGatewayOperatorFlags(liqonet *gatewayOperatorFlags) {
 	flag.BoolVar(&liqonet.enableLeaderElection, "gateway.leader-elect", false,
 		"leader-elect enables leader election for controller manager.")
@@ -58,11 +95,40 @@ func addGatewayOperatorFlags(liqonet *gatewayOperatorFlags) {
 		"mtu is the maximum transmission unit for interfaces managed by the gateway operator")
 	flag.UintVar(&liqonet.tunnelListeningPort, "gateway.listening-port", liqoconst.GatewayListeningPort,
 		"listening-port is the port used by the vpn tunnel")
+	flag.DurationVar(&liqonet.probeInterval, "gateway.probe-interval", 0,
+		"probe-interval is the interval between the probes measuring the latency and packet loss towards the remote gateways (0 to disable)")
+	flag.DurationVar(&liqonet.keyRotationPeriod, "gateway.key-rotation-period", 0,
+		"key-rotation-period is the period after which the keys of the vpn tunnel are rotated (0 to disable)")
+	flag.DurationVar(&liqonet.keyRotationOverlap, "gateway.key-rotation-overlap", 5*time.Minute,
+		"key-rotation-overlap is the duration the next keys are advertised to the remote clusters before switching over")
+	flag.BoolVar(&liqonet.mtuDiscovery, "gateway.mtu-discovery", false,
+		"mtu-discovery enables the discovery of the path MTU towards the remote endpoints, possibly lowering the MTU towards each remote cluster")
+	flag.DurationVar(&liqonet.accountingInterval, "gateway.accounting-interval", 0,
+		"accounting-interval is the interval the rules accounting the traffic towards the remote clusters per namespace are updated (0 to disable)")
+	flag.BoolVar(&liqonet.activeActive, "gateway.active-active", false,
+		"active-active enables all the replicas to be simultaneously active, each one handling a shard of the remote clusters")
+	flag.BoolVar(&liqonet.ebpfNAT, "gateway.ebpf-nat", false,
+		"ebpf-nat enables the eBPF datapath to perform the stateless NAT translations towards the remote clusters, in place of iptables")
+	liqonet.uplinkBandwidth = args.NewQuantity("10G")
+	flag.Var(&liqonet.uplinkBandwidth, "gateway.uplink-bandwidth",
+		"uplink-bandwidth is the bandwidth of the gateway uplink in bits per second, shared according to the priorities of the remote clusters")
+	liqonet.endpointResolver = args.NewEnum(append([]string{endpointResolverNone}, endpointresolver.Kinds...), endpointResolverNone)
+	flag.Var(liqonet.endpointResolver, "gateway.endpoint-resolver",
+		"endpoint-resolver is the resolver detecting the address the gateway is reachable at, in place of the one inferred from the service "+
+			"(one of none, node, echo, aws, gcp, azure)")
+	flag.StringVar(&liqonet.endpointResolverURL, "gateway.endpoint-resolver-url", "",
+		"endpoint-resolver-url is the URL of the echo service, or the base URL of the cloud metadata service, queried by the endpoint resolver")
+	flag.DurationVar(&liqonet.endpointInterval, "gateway.endpoint-resolver-interval", time.Minute,
+		"endpoint-resolver-interval is the interval the address of the gateway is resolved again, to detect changes")
 }
 
 func runGatewayOperator(commonFlags *liqonetCommonFlags, gatewayFlags *gatewayOperatorFlags) {
 	metricsAddr := commonFlags.metricsAddr
 	enableLeaderElection := gatewayFlags.enableLeaderElection
+	if gatewayFlags.activeActive && enableLeaderElection {
+		klog.Warning("leader election is not supported in active-active mode, hence it is disabled")
+		enableLeaderElection = false
+	}
 	leaseDuration := gatewayFlags.leaseDuration
 	renewDeadLine := gatewayFlags.renewDeadline
 	retryPeriod := gatewayFlags.retryPeriod
@@ -72,6 +138,12 @@ func runGatewayOperator(commonFlags *liqonetCommonFlags, gatewayFlags *gatewayOp
 		klog.Errorf("port %d should be greater than %d and minor than %d", gatewayFlags.tunnelListeningPort, liqoconst.UDPMinPort, liqoconst.UDPMaxPort)
 		os.Exit(1)
 	}
+	// If the key rotation is enabled, then the overlap period shall be shorter than the rotation period.
+	if gatewayFlags.keyRotationPeriod > 0 && gatewayFlags.keyRotationOverlap >= gatewayFlags.keyRotationPeriod {
+		klog.Errorf("key rotation overlap %s should be shorter than the key rotation period %s",
+			gatewayFlags.keyRotationOverlap, gatewayFlags.keyRotationPeriod)
+		os.Exit(1)
+	}
 	port := gatewayFlags.tunnelListeningPort
 	MTU := gatewayFlags.tunnelMTU
 
@@ -129,13 +201,79 @@ func runGatewayOperator(commonFlags *liqonetCommonFlags, gatewayFlags *gatewayOp
 	}
 	klog.Infof("created custom network namespace {%s}", liqoconst.GatewayNetnsName)
 
-	labelController := tunneloperator.NewLabelerController(podIP.String(), main.GetClient())
+	// Create the prober measuring the latency towards the remote gateways, if enabled.
+	// The corresponding socket is opened in the gateway network namespace, to exchange the probes through the vpn tunnel.
+	// The probes are sourced from a link-local address derived from the local cluster ID, which the remote gateways target.
+	var latencyProber *prober.Prober
+	if gatewayFlags.probeInterval > 0 {
+		clusterIdentity, err := utils.GetClusterIdentityWithNativeClient(context.Background(), clientset, podNamespace)
+		if err != nil {
+			klog.Errorf("unable to get the local cluster identity: %v", err)
+			os.Exit(1)
+		}
+
+		var conn net.PacketConn
+		err = gatewayNetns.Do(func(netNamespace ns.NetNS) (err error) {
+			conn, err = net.ListenPacket("udp4", fmt.Sprintf(":%d", liqoconst.GatewayProbePort))
+			return err
+		})
+		if err != nil {
+			klog.Errorf("unable to create the latency prober socket: %v", err)
+			os.Exit(1)
+		}
+		latencyProber = prober.New(conn, liqonetutils.GetTunnelProbeIP(clusterIdentity.ClusterID),
+			liqoconst.GatewayProbePort, gatewayFlags.probeInterval)
+		if err = main.Add(latencyProber); err != nil {
+			klog.Errorf("unable to add the latency prober to the manager: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// In active-active mode, the remote clusters are sharded across all the active replicas. This requires each replica
+	// to be reachable individually, otherwise the remote clusters would be directed to the address shared by all of them.
+	var sharder *sharding.Sharder
+	if gatewayFlags.activeActive {
+		if gatewayFlags.endpointResolver.Value != endpointResolverNone {
+			klog.Errorf("active-active mode cannot be enabled together with the %q endpoint resolver", gatewayFlags.endpointResolver.Value)
+			os.Exit(1)
+		}
+		if err = sharding.CheckPreconditions(context.Background(), main.GetAPIReader(), podNamespace); err != nil {
+			klog.Errorf("active-active mode cannot be enabled: %v", err)
+			os.Exit(1)
+		}
+		sharder = sharding.NewSharder(main.GetClient(), podNamespace, podIP.String())
+	}
+
+	// The path MTU towards the remote endpoints is discovered through ICMP echo requests, if enabled.
+	var mtuProber mtu.Prober
+	if gatewayFlags.mtuDiscovery {
+		mtuProber = mtu.NewICMPProber(mtuProbeTimeout, mtuProbeAttempts)
+	}
+
+	// The stateless NAT translations are performed through eBPF, if enabled.
+	var statelessNAT *ebpfnat.NAT
+	if gatewayFlags.ebpfNAT {
+		if statelessNAT, err = ebpfnat.New(); err != nil {
+			klog.Errorf("unable to load the eBPF NAT datapath: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// The traffic exchanged with the remote clusters is shaped according to the QoS configuration of each peering.
+	if gatewayFlags.uplinkBandwidth.Quantity.Sign() <= 0 {
+		klog.Errorf("uplink bandwidth %s should be greater than zero", gatewayFlags.uplinkBandwidth.String())
+		os.Exit(1)
+	}
+	shaper := qos.NewShaper(gatewayNetns, uint64(gatewayFlags.uplinkBandwidth.Quantity.Value()))
+
+	labelController := tunneloperator.NewLabelerController(podIP.String(), main.GetClient(), sharder)
 	if err = labelController.SetupWithManager(main); err != nil {
 		klog.Errorf("unable to setup labeler controller: %s", err)
 		os.Exit(1)
 	}
 	tunnelController, err := tunneloperator.NewTunnelController(podIP.String(), podNamespace, eventRecorder,
-		clientset, main.GetClient(), &readyClustersMutex, readyClusters, gatewayNetns, hostNetns, int(MTU), int(port))
+		clientset, main.GetClient(), &readyClustersMutex, readyClusters, gatewayNetns, hostNetns, int(MTU), int(port),
+		latencyProber, sharder, mtuProber, statelessNAT, shaper)
 	// If something goes wrong while creating and configuring the tunnel controller
 	// then make sure that we remove all the resources created during the create process.
 	if err != nil {
@@ -154,8 +292,56 @@ func runGatewayOperator(commonFlags *liqonetCommonFlags, gatewayFlags *gatewayOp
 		klog.Errorf("unable to setup tunnel controller: %s", err)
 		os.Exit(1)
 	}
+	if gatewayFlags.keyRotationPeriod > 0 {
+		keyRotator := tunnelwg.NewKeyRotator(clientset, podNamespace, tunnelController.WireguardDriver(),
+			gatewayFlags.keyRotationPeriod, gatewayFlags.keyRotationOverlap)
+		if err = main.Add(keyRotator); err != nil {
+			klog.Errorf("unable to add the key rotator to the manager: %v", err)
+			os.Exit(1)
+		}
+	}
+	// The address the gateway is reachable at is detected by the configured resolver, if enabled.
+	if gatewayFlags.endpointResolver.Value != endpointResolverNone {
+		podName, err := liqonetutils.GetPodName()
+		if err != nil {
+			klog.Errorf("unable to get pod name: %v", err)
+			os.Exit(1)
+		}
+		resolver, err := endpointresolver.New(gatewayFlags.endpointResolver.Value, &endpointresolver.Options{
+			Clientset: clientset, PodNamespace: podNamespace, PodName: podName, URL: gatewayFlags.endpointResolverURL,
+		})
+		if err != nil {
+			klog.Errorf("unable to create the endpoint resolver: %v", err)
+			os.Exit(1)
+		}
+		endpointDetector := tunneloperator.NewEndpointDetector(main.GetClient(), resolver, podIP.String(), gatewayFlags.endpointInterval)
+		if err = main.Add(endpointDetector); err != nil {
+			klog.Errorf("unable to add the endpoint detector to the manager: %v", err)
+			os.Exit(1)
+		}
+	}
+	// The diagnostics agent exposes the configuration of the gateway network namespace and performs the probes requested by liqoctl, if enabled.
+	if commonFlags.diagnosticsAddr != "" {
+		diagnosticsAgent := diagnostics.NewAgent(main.GetClient(), &diagnostics.Options{
+			Address:      commonFlags.diagnosticsAddr,
+			Netns:        gatewayNetns,
+			Tables:       []int{unix.RT_TABLE_MAIN},
+			Prober:       mtu.NewICMPProber(diagnosticsProbeTimeout, diagnosticsProbeAttempts),
+			IsConfigured: tunnelController.IsClusterReady,
+			NATRules:     tunnelController.IPTHandler.ListRulesPerCluster,
+		})
+		if err = main.Add(diagnosticsAgent); err != nil {
+			klog.Errorf("unable to add the diagnostics agent to the manager: %v", err)
+			os.Exit(1)
+		}
+	}
+	if err = main.Add(shaper); err != nil {
+		klog.Errorf("unable to add the traffic shaper to the manager: %v", err)
+		os.Exit(1)
+	}
+	metrics.Registry.MustRegister(shaper)
 	natMappingController, err := tunneloperator.NewNatMappingController(main.GetClient(), &readyClustersMutex,
-		readyClusters, gatewayNetns)
+		readyClusters, gatewayNetns, sharder, statelessNAT)
 	if err != nil {
 		klog.Errorf("an error occurred while creating the natmapping controller: %v", err)
 		os.Exit(1)
@@ -165,6 +351,20 @@ func runGatewayOperator(commonFlags *liqonetCommonFlags, gatewayFlags *gatewayOp
 		os.Exit(1)
 	}
 
+	// The traffic towards the remote clusters is accounted per source namespace, if enabled.
+	if gatewayFlags.accountingInterval > 0 {
+		trafficAccountant, err := tunneloperator.NewTrafficAccountant(main.GetClient(), clientset, gatewayNetns, gatewayFlags.accountingInterval)
+		if err != nil {
+			klog.Errorf("an error occurred while creating the traffic accountant: %v", err)
+			os.Exit(1)
+		}
+		if err = main.Add(trafficAccountant); err != nil {
+			klog.Errorf("unable to add the traffic accountant to the manager: %v", err)
+			os.Exit(1)
+		}
+		metrics.Registry.MustRegister(trafficAccountant)
+	}
+
 	klog.Info("Starting manager as Tunnel-Operator")
 	if err := main.Start(tunnelController.SetupSignalHandlerForTunnelOperator()); err != nil {
 		klog.Errorf("unable to start tunnel controller: %s", err)
@@ -16,7 +16,9 @@ package main
 
 import (
 	"flag"
+	"net"
 	"os"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -24,7 +26,10 @@ import (
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	"github.com/liqotech/liqo/internal/liqonet/network-manager/ipamconfig"
+	"github.com/liqotech/liqo/internal/liqonet/network-manager/ipamgc"
 	"github.com/liqotech/liqo/internal/liqonet/network-manager/netcfgcreator"
 	"github.com/liqotech/liqo/internal/liqonet/network-manager/tunnelendpointcreator"
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
@@ -33,6 +38,7 @@ import (
 	"github.com/liqotech/liqo/pkg/utils/args"
 	"github.com/liqotech/liqo/pkg/utils/mapper"
 	"github.com/liqotech/liqo/pkg/utils/restcfg"
+	"github.com/liqotech/liqo/pkg/utils/slice"
 )
 
 type networkManagerFlags struct {
@@ -41,6 +47,14 @@ type networkManagerFlags struct {
 
 	additionalPools args.CIDRList
 	reservedPools   args.CIDRList
+	exportedCIDRs   args.CIDRList
+
+	gatewayActiveActive bool
+	rendezvousAddress   string
+	fallbackEndpoints   args.StringList
+
+	ipamGCInterval time.Duration
+	ipamGCDryRun   bool
 }
 
 func addNetworkManagerFlags(managerFlags *networkManagerFlags) {
@@ -50,6 +64,19 @@ func addNetworkManagerFlags(managerFlags *networkManagerFlags) {
 		"Private CIDRs slices used by the Kubernetes infrastructure, in addition to the pod and service CIDR (e.g., the node subnet).")
 	flag.Var(&managerFlags.additionalPools, "manager.additional-pools",
 		"Network pools used to map a cluster network into another one in order to prevent conflicts, in addition to standard private CIDRs.")
+	flag.Var(&managerFlags.exportedCIDRs, "manager.exported-cidrs",
+		"Additional CIDRs exported to the remote clusters, in addition to the pod CIDR (e.g., the node subnet, or on-premise subnets).")
+	flag.BoolVar(&managerFlags.gatewayActiveActive, "manager.gateway-active-active", false,
+		"Whether the gateway replicas are all active, each one handling a shard of the remote clusters.")
+	flag.StringVar(&managerFlags.rendezvousAddress, "manager.rendezvous-address", "",
+		"The address of the rendezvous server (e.g., http://host:port) advertised to establish the tunnels with no publicly reachable endpoint.")
+	flag.Var(&managerFlags.fallbackEndpoints, "manager.fallback-endpoints",
+		"Additional addresses the gateway is reachable at, in decreasing order of priority, advertised to the remote clusters "+
+			"to fail over in case the main one stops being reachable (e.g., for multi-homed sites).")
+	flag.DurationVar(&managerFlags.ipamGCInterval, "manager.ipam-gc-interval", 10*time.Minute,
+		"The interval between the checks for orphaned IPAM resources, which are freed if detected by two consecutive checks (0 to disable)")
+	flag.BoolVar(&managerFlags.ipamGCDryRun, "manager.ipam-gc-dry-run", true,
+		"Whether the orphaned IPAM resources should be only reported, without being freed (set to false to free them)")
 }
 
 func runNetworkManager(commonFlags *liqonetCommonFlags, managerFlags *networkManagerFlags) {
@@ -67,6 +94,7 @@ func runNetworkManager(commonFlags *liqonetCommonFlags, managerFlags *networkMan
 			SelectorsByObject: cache.SelectorsByObject{
 				&corev1.Secret{}:  {Field: fields.OneTermEqualSelector("metadata.namespace", podNamespace)},
 				&corev1.Service{}: {Field: fields.OneTermEqualSelector("metadata.namespace", podNamespace)},
+				&corev1.Pod{}:     {Field: fields.OneTermEqualSelector("metadata.namespace", podNamespace)},
 			},
 		}),
 	})
@@ -95,11 +123,18 @@ func runNetworkManager(commonFlags *liqonetCommonFlags, managerFlags *networkMan
 	}
 
 	ncc := &netcfgcreator.NetworkConfigCreator{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor(liqoconst.LiqoNetworkManagerName),
 
 		PodCIDR:      managerFlags.podCIDR.String(),
 		ExternalCIDR: externalCIDR,
+
+		ExportedCIDRs: managerFlags.exportedCIDRs.StringList.StringList,
+
+		GatewayActiveActive: managerFlags.gatewayActiveActive,
+		RendezvousAddress:   managerFlags.rendezvousAddress,
+		FallbackEndpoints:   managerFlags.fallbackEndpoints.StringList,
 	}
 
 	if err = tec.SetupWithManager(mgr); err != nil {
@@ -112,6 +147,29 @@ func runNetworkManager(commonFlags *liqonetCommonFlags, managerFlags *networkMan
 		os.Exit(1)
 	}
 
+	icr := &ipamconfig.IPAMConfigReconciler{
+		Client: mgr.GetClient(),
+		IPAM:   ipam,
+
+		StaticPools:           managerFlags.additionalPools.StringList.StringList,
+		StaticReservedSubnets: staticReservedSubnets(managerFlags),
+	}
+
+	if err = icr.SetupWithManager(mgr); err != nil {
+		klog.Errorf("unable to create controller IPAMConfigReconciler: %s", err)
+		os.Exit(1)
+	}
+
+	if managerFlags.ipamGCInterval > 0 {
+		gc := ipamgc.NewGarbageCollector(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetEventRecorderFor(liqoconst.LiqoNetworkManagerName),
+			ipam, podNamespace, managerFlags.ipamGCInterval, managerFlags.ipamGCDryRun)
+		if err = mgr.Add(gc); err != nil {
+			klog.Errorf("unable to add the IPAM garbage collector to the manager: %s", err)
+			os.Exit(1)
+		}
+		metrics.Registry.MustRegister(gc)
+	}
+
 	klog.Info("starting manager as liqo-network-manager")
 	if err := mgr.Start(tec.SetupSignalHandlerForTunEndCreator()); err != nil {
 		klog.Errorf("an error occurred while starting manager: %s", err)
@@ -133,15 +191,50 @@ func initializeIPAM(client dynamic.Interface, managerFlags *networkManagerFlags)
 		return nil, err
 	}
 
+	// The pools already configured (e.g., before a restart) are skipped, while the ones no longer
+	// present are removed by the IPAMConfig reconciler, which also takes into account the IPAMConfig resource.
 	for _, pool := range managerFlags.additionalPools.StringList.StringList {
+		if slice.ContainsString(ipam.GetNetworkPools(), pool) {
+			continue
+		}
 		if err := ipam.AddNetworkPool(pool); err != nil {
 			return nil, err
 		}
 	}
 
-	if err := ipam.SetReservedSubnets(managerFlags.reservedPools.StringList.StringList); err != nil {
+	// Similarly, the reserved subnets currently configured are preserved at startup, and freed if
+	// no longer necessary by the IPAMConfig reconciler.
+	reserved := ipam.GetReservedSubnets()
+	for _, subnet := range staticReservedSubnets(managerFlags) {
+		if !slice.ContainsString(reserved, subnet) {
+			reserved = append(reserved, subnet)
+		}
+	}
+	if err := ipam.SetReservedSubnets(reserved); err != nil {
 		return nil, err
 	}
 
 	return ipam, nil
 }
+
+// staticReservedSubnets returns the subnets reserved through the command line flags. The exported CIDRs are reserved as well,
+// to prevent them from being assigned to (or overlapping with the networks of) the remote clusters, unless they overlap
+// with the pod and service CIDRs, which are already acquired by the IPAM.
+func staticReservedSubnets(managerFlags *networkManagerFlags) []string {
+	reserved := append([]string{}, managerFlags.reservedPools.StringList.StringList...)
+	for _, subnet := range managerFlags.exportedCIDRs.StringList.StringList {
+		_, network, err := net.ParseCIDR(subnet)
+		if err != nil || slice.ContainsString(reserved, subnet) ||
+			overlaps(network, managerFlags.podCIDR.String()) || overlaps(network, managerFlags.serviceCIDR.String()) {
+			continue
+		}
+		reserved = append(reserved, subnet)
+	}
+	return reserved
+}
+
+// overlaps returns whether the given networks overlap.
+func overlaps(network *net.IPNet, cidr string) bool {
+	_, other, err := net.ParseCIDR(cidr)
+	return err == nil && (network.Contains(other.IP) || other.Contains(network.IP))
+}
@@ -15,9 +15,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -27,40 +29,49 @@ import (
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	routeoperator "github.com/liqotech/liqo/internal/liqonet/route-operator"
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/diagnostics"
 	"github.com/liqotech/liqo/pkg/liqonet/overlay"
 	liqorouting "github.com/liqotech/liqo/pkg/liqonet/routing"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/mtu"
 	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+	"github.com/liqotech/liqo/pkg/utils/args"
 	"github.com/liqotech/liqo/pkg/utils/mapper"
 	"github.com/liqotech/liqo/pkg/utils/restcfg"
 )
 
+const (
+	// routeModeOverlay forwards the traffic towards the gateway through the VXLAN overlay network set up by the route operator.
+	routeModeOverlay = "overlay"
+	// routeModeNative forwards the traffic towards the gateway through the routes configured by the CNI, without any overlay.
+	routeModeNative = "native"
+)
+
 type routeOperatorFlags struct {
-	vni      int
-	mtu      int
-	vtepPort int
+	mode               *args.StringEnum
+	vni                int
+	mtu                int
+	vtepPort           int
+	driftCheckInterval time.Duration
 }
 
 func addRouteOperatorFlags(liqonet *routeOperatorFlags) {
+	liqonet.mode = args.NewEnum([]string{routeModeOverlay, routeModeNative}, routeModeOverlay)
+	flag.Var(liqonet.mode, "route.mode",
+		"The mode the traffic is forwarded towards the gateway: through a VXLAN overlay network (overlay), "+
+			"or leveraging the routes configured by the CNI (native)")
 	flag.IntVar(&liqonet.vni, "route.vxlan-vni", 18952, "VXLAN Virtual Network Identifier (VNI) for the Liqonet intra-cluster overlay network")
 	flag.IntVar(&liqonet.mtu, "route.vxlan-mtu", liqoconst.DefaultMTU, "VXLAN Max Transmit Unit (MTU) for the Liqonet intra-cluster overlay network")
 	flag.IntVar(&liqonet.vtepPort, "route.vxlan-vtep-port", 4879,
 		"VXLAN Virtual Tunnel Endpoints (VTEP) port for the Liqonet intra-cluster overlay network")
+	flag.DurationVar(&liqonet.driftCheckInterval, "route.drift-check-interval", time.Minute,
+		"The interval between the checks restoring the routes, rules and fdb entries drifted from the desired state (0 to disable)")
 }
 
 func runRouteOperator(commonFlags *liqonetCommonFlags, routeFlags *routeOperatorFlags) {
-	vxlanConfig := &overlay.VxlanDeviceAttrs{
-		Vni:      routeFlags.vni,
-		Name:     liqoconst.VxlanDeviceName,
-		VtepPort: routeFlags.vtepPort,
-		VtepAddr: nil,
-		MTU:      routeFlags.mtu,
-	}
-
-	mutex := &sync.RWMutex{}
-	nodeMap := map[string]string{}
 	// Get the pod ip and parse to net.IP.
 	podIP, err := liqonetutils.GetPodIP()
 	if err != nil {
@@ -110,6 +121,82 @@ func runRouteOperator(commonFlags *liqonetCommonFlags, routeFlags *routeOperator
 		klog.Errorf("unable to get manager: %s", err)
 		os.Exit(1)
 	}
+	var vxlanDevice *overlay.VxlanDevice
+	var routingManager liqorouting.Routing
+	if routeFlags.mode.Value == routeModeNative {
+		// The traffic is forwarded towards the gateway through the same next hop the CNI uses to reach the corresponding node.
+		routingManager, err = liqorouting.NewNativeRoutingManager(liqoconst.RoutingTableID,
+			podIP.String(), routeoperator.NewGatewayAddressResolver(context.Background(), mainMgr.GetClient()))
+		if err != nil {
+			klog.Errorf("an error occurred while creating the native routing manager: %v", err)
+			os.Exit(1)
+		}
+	} else {
+		vxlanDevice, err = overlay.NewVxlanDevice(&overlay.VxlanDeviceAttrs{
+			Vni:      routeFlags.vni,
+			Name:     liqoconst.VxlanDeviceName,
+			VtepPort: routeFlags.vtepPort,
+			VtepAddr: podIP,
+			MTU:      routeFlags.mtu,
+		})
+		if err != nil {
+			klog.Errorf("an error occurred while creating vxlan device : %v", err)
+			os.Exit(1)
+		}
+		routingManager, err = liqorouting.NewVxlanRoutingManager(liqoconst.RoutingTableID,
+			podIP.String(), liqoconst.OverlayNetPrefix, vxlanDevice)
+		if err != nil {
+			klog.Errorf("an error occurred while creating the vxlan routing manager: %v", err)
+			os.Exit(1)
+		}
+	}
+	eventRecorder := mainMgr.GetEventRecorderFor(liqoconst.LiqoRouteOperatorName + "." + podIP.String())
+	routeController := routeoperator.NewRouteController(podIP.String(), vxlanDevice, routingManager, eventRecorder, mainMgr.GetClient())
+	if err = routeController.SetupWithManager(mainMgr); err != nil {
+		klog.Errorf("unable to setup controller: %s", err)
+		os.Exit(1)
+	}
+	driftCheckers := map[string]routeoperator.DriftChecker{
+		routeoperator.DriftComponentRoute: routeController,
+	}
+	if vxlanDevice != nil {
+		setupOverlay(mainMgr, routeController, vxlanDevice, podIP.String(), nodeName, podNamespace, driftCheckers)
+	}
+	// The drift detector restores the configuration removed by third parties (e.g., a CNI restart), without waiting for
+	// the next event concerning the corresponding resources.
+	if routeFlags.driftCheckInterval > 0 {
+		driftDetector := routeoperator.NewDriftDetector(routeFlags.driftCheckInterval, liqoconst.RoutingTableID, vxlanDevice, driftCheckers)
+		if err := mainMgr.Add(driftDetector); err != nil {
+			klog.Errorf("unable to add the drift detector to the manager: %s", err)
+			os.Exit(1)
+		}
+		metrics.Registry.MustRegister(driftDetector)
+	}
+	// The diagnostics agent exposes the routing configuration and performs the probes requested by liqoctl, if enabled.
+	if commonFlags.diagnosticsAddr != "" {
+		diagnosticsAgent := diagnostics.NewAgent(mainMgr.GetClient(), &diagnostics.Options{
+			Address:       commonFlags.diagnosticsAddr,
+			Tables:        []int{liqoconst.RoutingTableID},
+			Prober:        mtu.NewICMPProber(diagnosticsProbeTimeout, diagnosticsProbeAttempts),
+			NativeRouting: routeFlags.mode.Value == routeModeNative,
+		})
+		if err := mainMgr.Add(diagnosticsAgent); err != nil {
+			klog.Errorf("unable to add the diagnostics agent to the manager: %s", err)
+			os.Exit(1)
+		}
+	}
+	if err := mainMgr.Start(routeController.SetupSignalHandlerForRouteOperator()); err != nil {
+		klog.Errorf("unable to start controller: %s", err)
+		os.Exit(1)
+	}
+}
+
+// setupOverlay configures the controllers managing the VXLAN overlay network, as well as the symmetric routing
+// of the traffic towards the local pods, adding the corresponding drift checkers to the given map.
+func setupOverlay(mainMgr ctrl.Manager, routeController *routeoperator.RouteController, vxlanDevice *overlay.VxlanDevice,
+	podIP, nodeName, podNamespace string, driftCheckers map[string]routeoperator.DriftChecker) {
+	mutex := &sync.RWMutex{}
+	nodeMap := map[string]string{}
 	// Asking the api-server to only inform the operator for the pods that are part of the route component.
 	ovcLabelSelector := labels.SelectorFromSet(labels.Set{
 		podNameLabelKey:     routeNameLabelValue,
@@ -134,29 +221,11 @@ func runRouteOperator(commonFlags *liqonetCommonFlags, routeFlags *routeOperator
 		klog.Errorf("unable to get manager: %s", err)
 		os.Exit(1)
 	}
-	vxlanConfig.VtepAddr = podIP
-	vxlanDevice, err := overlay.NewVxlanDevice(vxlanConfig)
-	if err != nil {
-		klog.Errorf("an error occurred while creating vxlan device : %v", err)
-		os.Exit(1)
-	}
-	vxlanRoutingManager, err := liqorouting.NewVxlanRoutingManager(liqoconst.RoutingTableID,
-		podIP.String(), liqoconst.OverlayNetPrefix, vxlanDevice)
-	if err != nil {
-		klog.Errorf("an error occurred while creating the vxlan routing manager: %v", err)
-		os.Exit(1)
-	}
-	eventRecorder := mainMgr.GetEventRecorderFor(liqoconst.LiqoRouteOperatorName + "." + podIP.String())
-	routeController := routeoperator.NewRouteController(podIP.String(), vxlanDevice, vxlanRoutingManager, eventRecorder, mainMgr.GetClient())
-	if err = routeController.SetupWithManager(mainMgr); err != nil {
-		klog.Errorf("unable to setup controller: %s", err)
-		os.Exit(1)
-	}
 	if err = routeController.ConfigureFirewall(); err != nil {
 		klog.Errorf("unable to start go routine that configures firewall rules for the route controller: %v", err)
 		os.Exit(1)
 	}
-	overlayController, err := routeoperator.NewOverlayController(podIP.String(), vxlanDevice, mutex, nodeMap, overlayMgr.GetClient())
+	overlayController, err := routeoperator.NewOverlayController(podIP, vxlanDevice, mutex, nodeMap, overlayMgr.GetClient())
 	if err != nil {
 		klog.Errorf("an error occurred while creating overlay controller: %v", err)
 		os.Exit(3)
@@ -175,12 +244,10 @@ func runRouteOperator(commonFlags *liqonetCommonFlags, routeFlags *routeOperator
 		klog.Errorf("unable to setup overlay controller: %s", err)
 		os.Exit(1)
 	}
+	driftCheckers[routeoperator.DriftComponentOverlay] = overlayController
+	driftCheckers[routeoperator.DriftComponentSymmetricRouting] = symmetricRoutingController
 	if err := mainMgr.Add(overlayMgr); err != nil {
 		klog.Errorf("unable to add the overlay manager to the main manager: %s", err)
 		os.Exit(1)
 	}
-	if err := mainMgr.Start(routeController.SetupSignalHandlerForRouteOperator()); err != nil {
-		klog.Errorf("unable to start controller: %s", err)
-		os.Exit(1)
-	}
 }
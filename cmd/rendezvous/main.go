@@ -0,0 +1,70 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/rendezvous"
+)
+
+func main() {
+	port := flag.Int("port", 8080, "Port to listen on for the requests to join the sessions")
+	publicHost := flag.String("public-host", "", "The publicly reachable address of the server, advertised for the relay ports")
+	idleTimeout := flag.Duration("idle-timeout", 5*time.Minute, "The period of inactivity after which the sessions are released")
+
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	if *publicHost == "" {
+		klog.Fatal("The --public-host flag must be set")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	server, err := rendezvous.NewServer(*publicHost, *idleTimeout)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	go wait.UntilWithContext(ctx, func(ctx context.Context) { server.Sweep() }, *idleTimeout/2)
+
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", *port), Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		if err := httpServer.Close(); err != nil {
+			klog.Errorf("Failed to close the HTTP server: %v", err)
+		}
+	}()
+
+	klog.Infof("Rendezvous server listening on port %d, advertising the relay ports through %q", *port, *publicHost)
+	err = httpServer.ListenAndServe()
+	cancel()
+	server.Close()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		klog.Errorf("ListenAndServe: %v", err)
+		os.Exit(1)
+	}
+}
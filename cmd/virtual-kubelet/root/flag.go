@@ -50,18 +50,26 @@ func InstallFlags(flags *pflag.FlagSet, o *Opts) {
 	flags.UintVar(&o.SecretWorkers, "secret-reflection-workers", o.SecretWorkers, "The number of secret reflection workers")
 	flags.UintVar(&o.PersistentVolumeClaimWorkers, "persistentvolumeclaim-reflection-workers", o.PersistentVolumeClaimWorkers,
 		"The number of persistentvolumeclaim reflection workers")
+	flags.UintVar(&o.WorkloadWorkers, "workload-reflection-workers", o.WorkloadWorkers,
+		"The number of deployment and statefulset reflection workers (only used if remote workloads are enabled)")
 
 	flags.DurationVar(&o.NodeLeaseDuration, "node-lease-duration", o.NodeLeaseDuration, "The duration of the node leases")
 	flags.DurationVar(&o.NodePingInterval, "node-ping-interval", o.NodePingInterval,
 		"The interval the reachability of the remote API server is verified to assess node readiness, 0 to disable")
 	flags.DurationVar(&o.NodePingTimeout, "node-ping-timeout", o.NodePingTimeout,
 		"The timeout of the remote API server reachability check")
+	flags.DurationVar(&o.NodeDisconnectionTolerance, "node-disconnection-tolerance", o.NodeDisconnectionTolerance,
+		"The duration the remote API server is tolerated to be unreachable before marking the node as NotReady, 0 to disable")
 
 	flags.Var(&o.NodeExtraAnnotations, "node-extra-annotations", "Extra annotations to add to the Virtual Node")
 	flags.Var(&o.NodeExtraLabels, "node-extra-labels", "Extra labels to add to the Virtual Node")
 
 	flags.BoolVar(&o.EnableAPIServerSupport, "enable-apiserver-support", false,
 		"Enable offloaded pods to interact back with the local Kubernetes API server")
+	flags.BoolVar(&o.EnableDaemonSetExpansion, "enable-daemonset-expansion", false,
+		"Enable the expansion of DaemonSet pods scheduled on the virtual node into one pod for each remote node")
+	flags.BoolVar(&o.EnableRemoteWorkloads, "enable-remote-workloads", false,
+		"Enable the reflection as a whole of the Deployments and StatefulSets annotated to be offloaded as remote workloads")
 	flags.BoolVar(&o.EnableStorage, "enable-storage", false, "Enable the Liqo storage reflection")
 	flags.StringVar(&o.VirtualStorageClassName, "virtual-storage-class-name", "liqo", "Name of the virtual storage class")
 	flags.StringVar(&o.RemoteRealStorageClassName, "remote-real-storage-class-name", "", "Name of the real storage class to use for the actual volumes")
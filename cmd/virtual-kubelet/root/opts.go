@@ -49,6 +49,7 @@ const (
 	DefaultConfigMapWorkers            = 3
 	DefaultSecretWorkers               = 3
 	DefaultPersistenVolumeClaimWorkers = 3
+	DefaultWorkloadWorkers             = 3
 
 	DefaultNodePingTimeout = 1 * time.Second
 )
@@ -81,15 +82,20 @@ type Opts struct {
 	ConfigMapWorkers             uint
 	SecretWorkers                uint
 	PersistentVolumeClaimWorkers uint
+	WorkloadWorkers              uint
 
 	NodeLeaseDuration time.Duration
 	NodePingInterval  time.Duration
 	NodePingTimeout   time.Duration
 
+	NodeDisconnectionTolerance time.Duration
+
 	NodeExtraAnnotations argsutils.StringMap
 	NodeExtraLabels      argsutils.StringMap
 
 	EnableAPIServerSupport     bool
+	EnableDaemonSetExpansion   bool
+	EnableRemoteWorkloads      bool
 	EnableStorage              bool
 	VirtualStorageClassName    string
 	RemoteRealStorageClassName string
@@ -116,6 +122,7 @@ func NewOpts() *Opts {
 		ConfigMapWorkers:             DefaultConfigMapWorkers,
 		SecretWorkers:                DefaultSecretWorkers,
 		PersistentVolumeClaimWorkers: DefaultPersistenVolumeClaimWorkers,
+		WorkloadWorkers:              DefaultWorkloadWorkers,
 
 		NodeLeaseDuration: node.DefaultLeaseDuration * time.Second,
 		NodePingInterval:  node.DefaultPingInterval,
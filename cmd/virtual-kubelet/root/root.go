@@ -103,8 +103,11 @@ func runRootCommand(ctx context.Context, c *Opts) error {
 		ConfigMapWorkers:            c.ConfigMapWorkers,
 		SecretWorkers:               c.SecretWorkers,
 		PersistenVolumeClaimWorkers: c.PersistentVolumeClaimWorkers,
+		WorkloadWorkers:             c.WorkloadWorkers,
 
 		EnableAPIServerSupport:     c.EnableAPIServerSupport,
+		EnableDaemonSetExpansion:   c.EnableDaemonSetExpansion,
+		EnableRemoteWorkloads:      c.EnableRemoteWorkloads,
 		EnableStorage:              c.EnableStorage,
 		VirtualStorageClassName:    c.VirtualStorageClassName,
 		RemoteRealStorageClassName: c.RemoteRealStorageClassName,
@@ -135,9 +138,13 @@ func runRootCommand(ctx context.Context, c *Opts) error {
 
 		InformerResyncPeriod: c.InformerResyncPeriod,
 		PingDisabled:         c.NodePingInterval == 0,
+
+		DisconnectionTolerance: c.NodeDisconnectionTolerance,
 	}
 
 	nodeProvider := nodeprovider.NewLiqoNodeProvider(&nodecfg)
+	// Trigger the full resynchronization of the reflected objects when the remote cluster becomes reachable after a disconnection.
+	nodeProvider.NotifyReconnection(podProvider.Resync)
 	nodeReady := nodeProvider.StartProvider(ctx)
 
 	nodeRunner, err := node.NewNodeController(
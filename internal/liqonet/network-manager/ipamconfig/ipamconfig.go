@@ -0,0 +1,211 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipamconfig
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	liqonetIpam "github.com/liqotech/liqo/pkg/liqonet/ipam"
+	"github.com/liqotech/liqo/pkg/utils/slice"
+)
+
+// rejectedRetryPeriod is the period after which the rejected entries (e.g., pools still in use, or networks
+// conflicting with the ones of remote clusters) are enforced again, as the conflicts may have been resolved meanwhile.
+const rejectedRetryPeriod = time.Minute
+
+// IPAMConfigReconciler reconciles the IPAMConfig resource, to apply the additional network pools and reserved subnets to the IPAM.
+type IPAMConfigReconciler struct {
+	client.Client
+	IPAM liqonetIpam.Ipam
+
+	// StaticPools are the additional network pools configured at startup, which are always enforced.
+	StaticPools []string
+	// StaticReservedSubnets are the reserved subnets configured at startup, which are always enforced.
+	StaticReservedSubnets []string
+}
+
+// +kubebuilder:rbac:groups=net.liqo.io,resources=ipamconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=net.liqo.io,resources=ipamconfigs/status,verbs=get;update;patch
+
+// Reconcile applies the network pools and reserved subnets of the IPAMConfig resource, in addition to the static ones.
+// In case the resource does not exist, only the static ones are enforced.
+func (r *IPAMConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	klog.V(4).Infof("Reconciling IPAMConfig %q", req.Name)
+
+	var config netv1alpha1.IPAMConfig
+	found := true
+	if err := r.Get(ctx, req.NamespacedName, &config); err != nil {
+		if !kerrors.IsNotFound(err) {
+			klog.Errorf("Failed retrieving IPAMConfig %q: %v", req.Name, err)
+			return ctrl.Result{}, err
+		}
+		klog.V(4).Infof("IPAMConfig %q not found, enforcing the static configuration only", req.Name)
+		found = false
+	}
+
+	pools := union(r.StaticPools, config.Spec.AdditionalPools)
+	reserved := union(r.StaticReservedSubnets, config.Spec.ReservedSubnets)
+	rejected, err := r.enforce(pools, reserved)
+	if err != nil {
+		klog.Errorf("Failed enforcing IPAMConfig %q: %v", req.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+	if len(rejected) > 0 {
+		result.RequeueAfter = rejectedRetryPeriod
+	}
+	if !found {
+		return result, nil
+	}
+
+	config.Status = netv1alpha1.IPAMConfigStatus{
+		ObservedGeneration: config.Generation,
+		Phase:              netv1alpha1.IPAMConfigPhaseApplied,
+		AdditionalPools:    r.additionalPools(),
+		ReservedSubnets:    r.IPAM.GetReservedSubnets(),
+		Rejected:           rejected,
+	}
+	if len(rejected) > 0 {
+		config.Status.Phase = netv1alpha1.IPAMConfigPhasePartiallyApplied
+	}
+	if err := r.Status().Update(ctx, &config); err != nil {
+		klog.Errorf("Failed updating the status of IPAMConfig %q: %v", req.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	klog.Infof("IPAMConfig %q correctly enforced (phase: %s)", req.Name, config.Status.Phase)
+	return result, nil
+}
+
+// enforce configures the IPAM with the given network pools and reserved subnets, returning the entries whose addition
+// or removal has been refused. Removals are processed first, so that networks can be moved from one set to the other.
+func (r *IPAMConfigReconciler) enforce(pools, reserved []string) ([]netv1alpha1.IPAMConfigRejectedEntry, error) {
+	var rejected []netv1alpha1.IPAMConfigRejectedEntry
+	reject := func(network string, entryType netv1alpha1.IPAMConfigEntryType, err error) {
+		klog.Warningf("Rejected %s %s: %v", entryType, network, err)
+		rejected = append(rejected, netv1alpha1.IPAMConfigRejectedEntry{Network: network, Type: entryType, Reason: err.Error()})
+	}
+
+	// Free the reserved subnets no longer present.
+	accepted := intersection(r.IPAM.GetReservedSubnets(), reserved)
+	if len(accepted) != len(r.IPAM.GetReservedSubnets()) {
+		if err := r.IPAM.SetReservedSubnets(accepted); err != nil {
+			return nil, fmt.Errorf("failed to free the reserved subnets no longer present: %w", err)
+		}
+	}
+
+	// Remove the network pools no longer present, unless still in use.
+	for _, pool := range r.additionalPools() {
+		if !slice.ContainsString(pools, pool) {
+			if err := r.IPAM.RemoveNetworkPool(pool); err != nil {
+				reject(pool, netv1alpha1.IPAMConfigEntryAdditionalPool, err)
+			}
+		}
+	}
+
+	// Add the new network pools, if not conflicting with other networks.
+	for _, pool := range pools {
+		if slice.ContainsString(r.IPAM.GetNetworkPools(), pool) {
+			continue
+		}
+		if err := r.IPAM.ValidateNetworkPool(pool); err != nil {
+			reject(pool, netv1alpha1.IPAMConfigEntryAdditionalPool, err)
+			continue
+		}
+		if err := r.IPAM.AddNetworkPool(pool); err != nil {
+			reject(pool, netv1alpha1.IPAMConfigEntryAdditionalPool, err)
+		}
+	}
+
+	// Reserve the new subnets one at a time, so that each one is validated also against the previous ones.
+	for _, subnet := range reserved {
+		if slice.ContainsString(accepted, subnet) {
+			continue
+		}
+		if err := r.IPAM.ValidateReservedSubnet(subnet); err != nil {
+			reject(subnet, netv1alpha1.IPAMConfigEntryReservedSubnet, err)
+			continue
+		}
+		accepted = append(accepted, subnet)
+		if err := r.IPAM.SetReservedSubnets(accepted); err != nil {
+			return nil, fmt.Errorf("failed to reserve subnet %s: %w", subnet, err)
+		}
+	}
+
+	return rejected, nil
+}
+
+// additionalPools returns the network pools currently configured, excluding the default ones.
+func (r *IPAMConfigReconciler) additionalPools() []string {
+	var additional []string
+	for _, pool := range r.IPAM.GetNetworkPools() {
+		if !slice.ContainsString(liqonetIpam.Pools, pool) {
+			additional = append(additional, pool)
+		}
+	}
+	return additional
+}
+
+// SetupWithManager registers a new controller for the IPAMConfig resource. An initial reconciliation is
+// always triggered, to enforce the static configuration even in case the resource does not exist.
+func (r *IPAMConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	initial := make(chan event.GenericEvent, 1)
+	initial <- event.GenericEvent{Object: &netv1alpha1.IPAMConfig{ObjectMeta: metav1.ObjectMeta{Name: liqoconst.IPAMConfigName}}}
+
+	singleton := predicate.NewPredicateFuncs(func(obj client.Object) bool { return obj.GetName() == liqoconst.IPAMConfigName })
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&netv1alpha1.IPAMConfig{}, builder.WithPredicates(singleton, predicate.GenerationChangedPredicate{})).
+		Watches(&source.Channel{Source: initial}, &handler.EnqueueRequestForObject{}).
+		Complete(r)
+}
+
+// union returns the elements of the given slices, without duplicates and preserving their order.
+func union(first, second []string) []string {
+	var result []string
+	for _, element := range append(append([]string{}, first...), second...) {
+		if !slice.ContainsString(result, element) {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// intersection returns the elements of the first slice also present in the second one.
+func intersection(first, second []string) []string {
+	result := []string{}
+	for _, element := range first {
+		if slice.ContainsString(second, element) {
+			result = append(result, element)
+		}
+	}
+	return result
+}
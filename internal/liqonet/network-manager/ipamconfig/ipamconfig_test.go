@@ -0,0 +1,211 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipamconfig
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	liqonetIpam "github.com/liqotech/liqo/pkg/liqonet/ipam"
+)
+
+// fakeIPAM mocks the network pools and reserved subnets management functions of the IPAM.
+type fakeIPAM struct {
+	liqonetIpam.Ipam
+	pools    []string
+	reserved []string
+	// conflicts are the networks considered as conflicting by the validation functions.
+	conflicts map[string]error
+	// inUse are the network pools which cannot be removed.
+	inUse map[string]error
+}
+
+func (f *fakeIPAM) GetNetworkPools() []string { return append([]string{}, f.pools...) }
+
+func (f *fakeIPAM) ValidateNetworkPool(network string) error { return f.conflicts[network] }
+
+func (f *fakeIPAM) AddNetworkPool(network string) error {
+	f.pools = append(f.pools, network)
+	return nil
+}
+
+func (f *fakeIPAM) RemoveNetworkPool(network string) error {
+	if err := f.inUse[network]; err != nil {
+		return err
+	}
+	var pools []string
+	for _, pool := range f.pools {
+		if pool != network {
+			pools = append(pools, pool)
+		}
+	}
+	f.pools = pools
+	return nil
+}
+
+func (f *fakeIPAM) GetReservedSubnets() []string { return append([]string{}, f.reserved...) }
+
+func (f *fakeIPAM) ValidateReservedSubnet(network string) error { return f.conflicts[network] }
+
+func (f *fakeIPAM) SetReservedSubnets(subnets []string) error {
+	f.reserved = append([]string{}, subnets...)
+	return nil
+}
+
+var _ = Describe("IPAMConfigReconciler", func() {
+	var (
+		ctx    context.Context
+		cl     client.Client
+		ipam   *fakeIPAM
+		r      *IPAMConfigReconciler
+		config *netv1alpha1.IPAMConfig
+		res    ctrl.Result
+		err    error
+	)
+
+	key := types.NamespacedName{Name: liqoconst.IPAMConfigName}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ipam = &fakeIPAM{
+			pools:     append(append([]string{}, liqonetIpam.Pools...), "11.0.0.0/16", "12.0.0.0/16"),
+			reserved:  []string{"172.16.0.0/24", "172.16.1.0/24"},
+			conflicts: map[string]error{},
+			inUse:     map[string]error{},
+		}
+		config = &netv1alpha1.IPAMConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: liqoconst.IPAMConfigName, Generation: 3},
+			Spec: netv1alpha1.IPAMConfigSpec{
+				AdditionalPools: []string{"12.0.0.0/16", "13.0.0.0/16"},
+				ReservedSubnets: []string{"172.16.1.0/24", "172.16.2.0/24"},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(config).Build()
+		r = &IPAMConfigReconciler{
+			Client:                cl,
+			IPAM:                  ipam,
+			StaticPools:           []string{"11.0.0.0/16"},
+			StaticReservedSubnets: []string{"172.16.0.0/24"},
+		}
+		res, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	})
+
+	getConfig := func() *netv1alpha1.IPAMConfig {
+		var updated netv1alpha1.IPAMConfig
+		Expect(cl.Get(ctx, key, &updated)).To(Succeed())
+		return &updated
+	}
+
+	When("the configuration does not conflict with the other networks", func() {
+		BeforeEach(func() { config.Spec.AdditionalPools = []string{"13.0.0.0/16"} })
+
+		It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+		It("should not requeue the request", func() { Expect(res.RequeueAfter).To(BeZero()) })
+		It("should add the new network pools and remove the ones no longer present", func() {
+			Expect(ipam.pools).To(ConsistOf(append(append([]string{}, liqonetIpam.Pools...), "11.0.0.0/16", "13.0.0.0/16")))
+		})
+		It("should reserve the new subnets and free the ones no longer present", func() {
+			Expect(ipam.reserved).To(ConsistOf("172.16.0.0/24", "172.16.1.0/24", "172.16.2.0/24"))
+		})
+		It("should report the applied configuration in the status", func() {
+			status := getConfig().Status
+			Expect(status.ObservedGeneration).To(BeNumerically("==", 3))
+			Expect(status.Phase).To(Equal(netv1alpha1.IPAMConfigPhaseApplied))
+			Expect(status.AdditionalPools).To(ConsistOf("11.0.0.0/16", "13.0.0.0/16"))
+			Expect(status.ReservedSubnets).To(ConsistOf("172.16.0.0/24", "172.16.1.0/24", "172.16.2.0/24"))
+			Expect(status.Rejected).To(BeEmpty())
+		})
+	})
+
+	When("some entries conflict with the other networks", func() {
+		BeforeEach(func() {
+			ipam.conflicts["13.0.0.0/16"] = errors.New("network pool 13.0.0.0/16 overlaps with the local podCIDR 13.0.0.0/24")
+			ipam.conflicts["172.16.2.0/24"] = errors.New("network 172.16.2.0/24 cannot be reserved")
+		})
+
+		It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+		It("should requeue the request to retry the rejected entries", func() {
+			Expect(res.RequeueAfter).To(Equal(rejectedRetryPeriod))
+		})
+		It("should not apply the conflicting entries", func() {
+			Expect(ipam.pools).ToNot(ContainElement("13.0.0.0/16"))
+			Expect(ipam.reserved).To(ConsistOf("172.16.0.0/24", "172.16.1.0/24"))
+		})
+		It("should report the rejected entries in the status", func() {
+			status := getConfig().Status
+			Expect(status.Phase).To(Equal(netv1alpha1.IPAMConfigPhasePartiallyApplied))
+			Expect(status.Rejected).To(ConsistOf(
+				netv1alpha1.IPAMConfigRejectedEntry{Network: "13.0.0.0/16", Type: netv1alpha1.IPAMConfigEntryAdditionalPool,
+					Reason: "network pool 13.0.0.0/16 overlaps with the local podCIDR 13.0.0.0/24"},
+				netv1alpha1.IPAMConfigRejectedEntry{Network: "172.16.2.0/24", Type: netv1alpha1.IPAMConfigEntryReservedSubnet,
+					Reason: "network 172.16.2.0/24 cannot be reserved"},
+			))
+		})
+	})
+
+	When("a network pool no longer present is still in use", func() {
+		BeforeEach(func() {
+			config.Spec.AdditionalPools = nil
+			ipam.inUse["12.0.0.0/16"] = errors.New("cannot remove network pool 12.0.0.0/16 because it is in use")
+		})
+
+		It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+		It("should requeue the request to retry the removal", func() {
+			Expect(res.RequeueAfter).To(Equal(rejectedRetryPeriod))
+		})
+		It("should retain the network pool", func() { Expect(ipam.pools).To(ContainElement("12.0.0.0/16")) })
+		It("should report the refused removal in the status", func() {
+			status := getConfig().Status
+			Expect(status.Phase).To(Equal(netv1alpha1.IPAMConfigPhasePartiallyApplied))
+			Expect(status.AdditionalPools).To(ConsistOf("11.0.0.0/16", "12.0.0.0/16"))
+			Expect(status.Rejected).To(ConsistOf(netv1alpha1.IPAMConfigRejectedEntry{Network: "12.0.0.0/16",
+				Type: netv1alpha1.IPAMConfigEntryAdditionalPool, Reason: "cannot remove network pool 12.0.0.0/16 because it is in use"}))
+		})
+	})
+
+	When("the IPAMConfig does not exist", func() {
+		BeforeEach(func() { config.SetName("other") })
+
+		It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+		It("should enforce the static configuration only", func() {
+			Expect(ipam.pools).To(ConsistOf(append(append([]string{}, liqonetIpam.Pools...), "11.0.0.0/16")))
+			Expect(ipam.reserved).To(ConsistOf("172.16.0.0/24"))
+		})
+	})
+})
+
+var _ = Describe("Slice helpers", func() {
+	It("union should return the elements of both slices without duplicates", func() {
+		Expect(union([]string{"a", "b"}, []string{"b", "c"})).To(Equal([]string{"a", "b", "c"}))
+	})
+	It("intersection should return the elements of the first slice present in the second one", func() {
+		Expect(intersection([]string{"a", "b"}, []string{"b", "c"})).To(Equal([]string{"b"}))
+		Expect(intersection(nil, []string{"a"})).To(BeEmpty())
+	})
+})
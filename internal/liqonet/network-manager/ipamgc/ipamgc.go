@@ -0,0 +1,277 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipamgc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	liqonetIpam "github.com/liqotech/liqo/pkg/liqonet/ipam"
+	foreigncluster "github.com/liqotech/liqo/pkg/utils/foreignCluster"
+)
+
+const (
+	// EventReasonGarbageCollected is the reason of the events generated when orphaned IPAM resources are freed.
+	EventReasonGarbageCollected = "IPAMGarbageCollected"
+	// EventReasonGarbageDetected is the reason of the events generated when orphaned IPAM resources are detected in dry-run mode.
+	EventReasonGarbageDetected = "IPAMGarbageDetected"
+
+	resourceTypeCluster         = "cluster"
+	resourceTypeEndpointMapping = "endpoint_mapping"
+)
+
+var (
+	// orphanedResources is the metric that counts the number of orphaned IPAM resources detected by the last run.
+	orphanedResources = prometheus.NewDesc(
+		"liqo_ipam_orphaned_resources",
+		"Number of orphaned IPAM resources detected by the last garbage collection run.",
+		[]string{"type"},
+		nil,
+	)
+
+	// freedResources is the metric that counts the number of orphaned IPAM resources freed.
+	freedResources = prometheus.NewDesc(
+		"liqo_ipam_freed_resources_total",
+		"Number of orphaned IPAM resources freed by the garbage collection.",
+		[]string{"type"},
+		nil,
+	)
+)
+
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get
+
+// GarbageCollector periodically frees the IPAM resources no longer in use, that is the networks and NAT mappings
+// associated with remote clusters no longer peered, and the endpoint mappings whose IP is no longer part of any EndpointSlice.
+type GarbageCollector struct {
+	client.Client
+	apiReader client.Reader
+	recorder  record.EventRecorder
+	ipam      liqonetIpam.Ipam
+	namespace string
+	interval  time.Duration
+	dryRun    bool
+
+	// candidates is the garbage detected by the previous run, which is freed only if still detected by the current one.
+	candidates *liqonetIpam.Garbage
+
+	mutex    sync.Mutex
+	orphaned map[string]int
+	freed    map[string]int
+}
+
+// NewGarbageCollector returns a new GarbageCollector, which checks the IPAM configuration with the given interval.
+// The namespace is the one hosting the liqo services, whose IPs are possibly mapped for the remote clusters.
+// In dry-run mode, the orphaned resources are only reported, without being freed.
+func NewGarbageCollector(cl client.Client, apiReader client.Reader, recorder record.EventRecorder,
+	ipam liqonetIpam.Ipam, namespace string, interval time.Duration, dryRun bool) *GarbageCollector {
+	return &GarbageCollector{
+		Client:    cl,
+		apiReader: apiReader,
+		recorder:  recorder,
+		ipam:      ipam,
+		namespace: namespace,
+		interval:  interval,
+		dryRun:    dryRun,
+
+		candidates: liqonetIpam.NewGarbage(),
+		orphaned:   map[string]int{resourceTypeCluster: 0, resourceTypeEndpointMapping: 0},
+		freed:      map[string]int{resourceTypeCluster: 0, resourceTypeEndpointMapping: 0},
+	}
+}
+
+// Start periodically checks the IPAM configuration, until the given context is canceled.
+// It implements the manager.Runnable interface.
+func (gc *GarbageCollector) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := gc.run(ctx); err != nil {
+			klog.Errorf("Failed to collect the orphaned IPAM resources: %v", err)
+		}
+	}, gc.interval)
+	return nil
+}
+
+// run detects the orphaned IPAM resources, and frees the ones already detected by the previous run.
+func (gc *GarbageCollector) run(ctx context.Context) error {
+	activeClusters, err := gc.getActiveClusters(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the active remote clusters: %w", err)
+	}
+	activeEndpoints, err := gc.getActiveEndpoints(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the active endpoints: %w", err)
+	}
+
+	// Resources are freed only if detected as orphaned by two consecutive runs, to prevent races with the ones being
+	// allocated in the meanwhile (e.g., by peerings being established, or for EndpointSlices not yet observed).
+	garbage := gc.ipam.FindGarbage(activeClusters, activeEndpoints)
+	confirmed := garbage.Intersect(gc.candidates)
+	gc.candidates = garbage
+
+	gc.mutex.Lock()
+	gc.orphaned[resourceTypeCluster] = confirmed.Clusters.Len()
+	gc.orphaned[resourceTypeEndpointMapping] = confirmed.CountEndpointMappings()
+	gc.mutex.Unlock()
+
+	if confirmed.IsEmpty() {
+		klog.V(4).Info("No orphaned IPAM resources detected")
+		return nil
+	}
+
+	if gc.dryRun {
+		gc.report(ctx, confirmed, corev1.EventTypeWarning, EventReasonGarbageDetected, "Detected")
+		return nil
+	}
+
+	if err := gc.ipam.CollectGarbage(confirmed); err != nil {
+		return err
+	}
+	gc.report(ctx, confirmed, corev1.EventTypeNormal, EventReasonGarbageCollected, "Freed")
+
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+	gc.freed[resourceTypeCluster] += confirmed.Clusters.Len()
+	gc.freed[resourceTypeEndpointMapping] += confirmed.CountEndpointMappings()
+	return nil
+}
+
+// getActiveClusters returns the IDs of the remote clusters either associated with a NetworkConfig,
+// or with a ForeignCluster whose peering is not completely torn down.
+func (gc *GarbageCollector) getActiveClusters(ctx context.Context) (sets.String, error) {
+	clusters := sets.NewString()
+
+	var netcfgs netv1alpha1.NetworkConfigList
+	if err := gc.List(ctx, &netcfgs); err != nil {
+		return nil, err
+	}
+	for i := range netcfgs.Items {
+		// The cluster ID in the spec of remote NetworkConfigs is the one of the local cluster.
+		if origin, ok := netcfgs.Items[i].GetLabels()[liqoconst.ReplicationOriginLabel]; ok {
+			clusters.Insert(origin)
+			continue
+		}
+		clusters.Insert(netcfgs.Items[i].Spec.RemoteCluster.ClusterID)
+	}
+
+	var fcs discoveryv1alpha1.ForeignClusterList
+	if err := gc.List(ctx, &fcs); err != nil {
+		return nil, err
+	}
+	for i := range fcs.Items {
+		if !foreigncluster.IsUnpeered(&fcs.Items[i]) {
+			clusters.Insert(fcs.Items[i].Spec.ClusterIdentity.ClusterID)
+		}
+	}
+
+	return clusters, nil
+}
+
+// getActiveEndpoints returns the IPs of the endpoints possibly reflected to the remote clusters, that is the addresses
+// part of any EndpointSlice, as well as the ones of the kubernetes.default service (mapped by the virtual kubelets)
+// and of the services in the liqo namespace (e.g., the authentication and proxy services mapped for in-band peerings).
+func (gc *GarbageCollector) getActiveEndpoints(ctx context.Context) (sets.String, error) {
+	endpoints := sets.NewString()
+
+	var slices discoveryv1.EndpointSliceList
+	if err := gc.List(ctx, &slices); err != nil {
+		return nil, err
+	}
+	for i := range slices.Items {
+		for j := range slices.Items[i].Endpoints {
+			endpoints.Insert(slices.Items[i].Endpoints[j].Addresses...)
+		}
+	}
+
+	// The API reader is leveraged, since the cache of the manager is restricted to the services in the liqo namespace.
+	var svc corev1.Service
+	err := gc.apiReader.Get(ctx, types.NamespacedName{Namespace: corev1.NamespaceDefault, Name: "kubernetes"}, &svc)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	endpoints.Insert(svc.Spec.ClusterIPs...)
+
+	var services corev1.ServiceList
+	if err := gc.List(ctx, &services, client.InNamespace(gc.namespace)); err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		endpoints.Insert(services.Items[i].Spec.ClusterIPs...)
+	}
+
+	return endpoints, nil
+}
+
+// report logs the given garbage, and generates the corresponding events on the IpamStorage resource.
+func (gc *GarbageCollector) report(ctx context.Context, garbage *liqonetIpam.Garbage, eventType, reason, action string) {
+	for clusterID := range garbage.Clusters {
+		klog.Infof("%s orphaned IPAM configuration of remote cluster %q", action, clusterID)
+	}
+	for ip, clusters := range garbage.EndpointMappings {
+		klog.Infof("%s leaked mapping of endpoint %q for remote clusters %v", action, ip, clusters.List())
+	}
+
+	var storages netv1alpha1.IpamStorageList
+	err := gc.apiReader.List(ctx, &storages, client.MatchingLabels{liqoconst.IpamStorageResourceLabelKey: liqoconst.IpamStorageResourceLabelValue})
+	if err != nil {
+		klog.Warningf("Failed to retrieve the IpamStorage resource to generate the garbage collection events: %v", err)
+		return
+	}
+	if len(storages.Items) != 1 {
+		klog.Warningf("Expected exactly one IpamStorage resource to generate the garbage collection events, found %d", len(storages.Items))
+		return
+	}
+
+	storage := &storages.Items[0]
+	for clusterID := range garbage.Clusters {
+		gc.recorder.Eventf(storage, eventType, reason, "%s orphaned IPAM configuration of remote cluster %q", action, clusterID)
+	}
+	if count := garbage.CountEndpointMappings(); count > 0 {
+		gc.recorder.Eventf(storage, eventType, reason, "%s %d leaked endpoint mappings", action, count)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (gc *GarbageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- orphanedResources
+	ch <- freedResources
+}
+
+// Collect implements prometheus.Collector.
+func (gc *GarbageCollector) Collect(ch chan<- prometheus.Metric) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	for resource, count := range gc.orphaned {
+		ch <- prometheus.MustNewConstMetric(orphanedResources, prometheus.GaugeValue, float64(count), resource)
+	}
+	for resource, count := range gc.freed {
+		ch <- prometheus.MustNewConstMetric(freedResources, prometheus.CounterValue, float64(count), resource)
+	}
+}
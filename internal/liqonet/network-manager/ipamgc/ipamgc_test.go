@@ -0,0 +1,164 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipamgc
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	liqonetIpam "github.com/liqotech/liqo/pkg/liqonet/ipam"
+	peeringconditionsutils "github.com/liqotech/liqo/pkg/utils/peeringConditions"
+)
+
+// fakeIPAM mocks the garbage collection functions of the IPAM.
+type fakeIPAM struct {
+	liqonetIpam.Ipam
+	garbage   *liqonetIpam.Garbage
+	collected []*liqonetIpam.Garbage
+}
+
+func (f *fakeIPAM) FindGarbage(_, _ sets.String) *liqonetIpam.Garbage { return f.garbage }
+
+func (f *fakeIPAM) CollectGarbage(garbage *liqonetIpam.Garbage) error {
+	f.collected = append(f.collected, garbage)
+	return nil
+}
+
+var _ = Describe("GarbageCollector", func() {
+	var (
+		ctx      context.Context
+		cl       client.Client
+		ipam     *fakeIPAM
+		recorder *record.FakeRecorder
+		gc       *GarbageCollector
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		peered := &discoveryv1alpha1.ForeignCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "peered"},
+			Spec:       discoveryv1alpha1.ForeignClusterSpec{ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: "peered-id"}},
+		}
+		peeringconditionsutils.EnsureStatus(peered, discoveryv1alpha1.OutgoingPeeringCondition,
+			discoveryv1alpha1.PeeringConditionStatusEstablished, "", "")
+		unpeered := &discoveryv1alpha1.ForeignCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "unpeered"},
+			Spec:       discoveryv1alpha1.ForeignClusterSpec{ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: "unpeered-id"}},
+		}
+
+		local := &netv1alpha1.NetworkConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "tenant",
+				Labels: map[string]string{liqoconst.ReplicationRequestedLabel: "true"}},
+			Spec: netv1alpha1.NetworkConfigSpec{RemoteCluster: discoveryv1alpha1.ClusterIdentity{ClusterID: "local-netcfg-id"}},
+		}
+		remote := &netv1alpha1.NetworkConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "tenant",
+				Labels: map[string]string{liqoconst.ReplicationOriginLabel: "remote-netcfg-id"}},
+			Spec: netv1alpha1.NetworkConfigSpec{RemoteCluster: discoveryv1alpha1.ClusterIdentity{ClusterID: "home-id"}},
+		}
+
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta:  metav1.ObjectMeta{Name: "slice", Namespace: "foo"},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}}, {Addresses: []string{"10.0.0.2"}}},
+		}
+		kubernetes := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: corev1.NamespaceDefault},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.1", ClusterIPs: []string{"10.96.0.1"}},
+		}
+		auth := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "liqo-auth", Namespace: "liqo"},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.10", ClusterIPs: []string{"10.96.0.10"}},
+		}
+		other := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "foo"},
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.20", ClusterIPs: []string{"10.96.0.20"}},
+		}
+		storage := &netv1alpha1.IpamStorage{
+			ObjectMeta: metav1.ObjectMeta{Name: "ipamstorage",
+				Labels: map[string]string{liqoconst.IpamStorageResourceLabelKey: liqoconst.IpamStorageResourceLabelValue}},
+		}
+
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(peered, unpeered, local, remote, slice, kubernetes, auth, other, storage).Build()
+		ipam = &fakeIPAM{garbage: liqonetIpam.NewGarbage()}
+		recorder = record.NewFakeRecorder(10)
+		gc = NewGarbageCollector(cl, cl, recorder, ipam, "liqo", 0, false)
+	})
+
+	Describe("the getActiveClusters function", func() {
+		It("should return the clusters associated with NetworkConfigs or peered ForeignClusters", func() {
+			clusters, err := gc.getActiveClusters(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(clusters.List()).To(ConsistOf("peered-id", "local-netcfg-id", "remote-netcfg-id"))
+		})
+	})
+
+	Describe("the getActiveEndpoints function", func() {
+		It("should return the endpoint addresses, the kubernetes service IP and the ones of the liqo services", func() {
+			endpoints, err := gc.getActiveEndpoints(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints.List()).To(ConsistOf("10.0.0.1", "10.0.0.2", "10.96.0.1", "10.96.0.10"))
+		})
+	})
+
+	Describe("the run function", func() {
+		BeforeEach(func() {
+			ipam.garbage.Clusters.Insert("orphaned-id")
+			ipam.garbage.EndpointMappings["10.0.0.3"] = sets.NewString("peered-id")
+		})
+
+		It("should free the garbage only once detected by two consecutive runs", func() {
+			Expect(gc.run(ctx)).To(Succeed())
+			Expect(ipam.collected).To(BeEmpty())
+			Expect(recorder.Events).ToNot(Receive())
+
+			Expect(gc.run(ctx)).To(Succeed())
+			Expect(ipam.collected).To(HaveLen(1))
+			Expect(ipam.collected[0].Clusters.List()).To(ConsistOf("orphaned-id"))
+			Expect(ipam.collected[0].CountEndpointMappings()).To(Equal(1))
+			Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonGarbageCollected)))
+			Expect(gc.freed).To(HaveKeyWithValue(resourceTypeCluster, 1))
+			Expect(gc.freed).To(HaveKeyWithValue(resourceTypeEndpointMapping, 1))
+		})
+
+		When("the dry-run mode is enabled", func() {
+			BeforeEach(func() { gc.dryRun = true })
+
+			It("should only report the garbage", func() {
+				Expect(gc.run(ctx)).To(Succeed())
+				Expect(gc.run(ctx)).To(Succeed())
+				Expect(ipam.collected).To(BeEmpty())
+				Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonGarbageDetected)))
+				Expect(gc.orphaned).To(HaveKeyWithValue(resourceTypeCluster, 1))
+				Expect(gc.freed).To(HaveKeyWithValue(resourceTypeCluster, 0))
+			})
+		})
+	})
+})
@@ -0,0 +1,110 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcfgcreator
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
+	liqolabels "github.com/liqotech/liqo/pkg/utils/labels"
+)
+
+// GatewayWatcher reconciles the gateway Pod objects to retrieve the active replicas (in active-active mode).
+type GatewayWatcher struct {
+	sync.RWMutex
+	// replicas maps the name of each active gateway pod to the corresponding IP address.
+	replicas map[string]string
+	members  []string
+
+	enqueuefn func(workqueue.RateLimitingInterface)
+}
+
+// NewGatewayWatcher returns a new initialized GatewayWatcher instance.
+func NewGatewayWatcher(enqueuefn func(workqueue.RateLimitingInterface)) *GatewayWatcher {
+	return &GatewayWatcher{
+		replicas:  map[string]string{},
+		enqueuefn: enqueuefn,
+	}
+}
+
+// Owner returns the IP address of the gateway replica owning the given remote cluster (empty if no replica is active).
+func (gw *GatewayWatcher) Owner(clusterID string) string {
+	gw.RLock()
+	defer gw.RUnlock()
+
+	return sharding.Owner(clusterID, gw.members)
+}
+
+// Handlers returns the set of handlers used for the Watch configuration.
+func (gw *GatewayWatcher) Handlers() handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(ce event.CreateEvent, rli workqueue.RateLimitingInterface) {
+			gw.handle(ce.Object.(*corev1.Pod), false, rli)
+		},
+		UpdateFunc: func(ue event.UpdateEvent, rli workqueue.RateLimitingInterface) {
+			gw.handle(ue.ObjectNew.(*corev1.Pod), false, rli)
+		},
+		DeleteFunc: func(de event.DeleteEvent, rli workqueue.RateLimitingInterface) {
+			gw.handle(de.Object.(*corev1.Pod), true, rli)
+		},
+	}
+}
+
+// Predicates returns the set of predicates used for the Watch configuration.
+func (gw *GatewayWatcher) Predicates() predicate.Predicate {
+	podsPredicate, err := predicate.LabelSelectorPredicate(liqolabels.GatewayPodLabelSelector)
+	utilruntime.Must(err)
+
+	return podsPredicate
+}
+
+// handle processes the events concerning a gateway Pod object.
+func (gw *GatewayWatcher) handle(pod *corev1.Pod, deleted bool, rli workqueue.RateLimitingInterface) {
+	klog.V(4).Infof("Handling gateway Pod %q", klog.KObj(pod))
+
+	gw.Lock()
+	defer gw.Unlock()
+
+	if !deleted && sharding.IsActiveMember(pod) {
+		gw.replicas[pod.GetName()] = pod.Status.PodIP
+	} else {
+		delete(gw.replicas, pod.GetName())
+	}
+
+	members := sets.NewString()
+	for _, ip := range gw.replicas {
+		members.Insert(ip)
+	}
+
+	// The set of active replicas did not change, nothing to do
+	if members.Equal(sets.NewString(gw.members...)) {
+		return
+	}
+
+	klog.Infof("Active gateway replicas changed: %v", members.List())
+	gw.members = members.List()
+
+	// Enqueue all foreign clusters for update (which in turn update the respective network configs)
+	gw.enqueuefn(rli)
+}
@@ -0,0 +1,86 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcfgcreator
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
+)
+
+var _ = Describe("Gateway Watcher functions", func() {
+	var (
+		handled int
+
+		gw *GatewayWatcher
+	)
+
+	forgePod := func(name, ip, status string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "liqo", Labels: map[string]string{liqoconst.GatewayPodLabelKey: status}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+		}
+	}
+
+	BeforeEach(func() {
+		handled = 0
+		gw = NewGatewayWatcher(func(rli workqueue.RateLimitingInterface) { handled++ })
+	})
+
+	Describe("The handle function", func() {
+		When("no replicas are active", func() {
+			It("should not return any owner", func() { Expect(gw.Owner("cluster")).To(BeEmpty()) })
+		})
+
+		When("a standby replica is observed", func() {
+			BeforeEach(func() { gw.handle(forgePod("standby", "1.1.1.1", "standby"), false, nil) })
+			It("should not execute the handle function", func() { Expect(handled).To(BeZero()) })
+			It("should not return any owner", func() { Expect(gw.Owner("cluster")).To(BeEmpty()) })
+		})
+
+		When("multiple replicas are active", func() {
+			BeforeEach(func() {
+				gw.handle(forgePod("first", "1.1.1.1", liqoconst.GatewayActivePodLabelValue), false, nil)
+				gw.handle(forgePod("second", "2.2.2.2", liqoconst.GatewayActivePodLabelValue), false, nil)
+			})
+
+			It("should execute the handle function for each change", func() { Expect(handled).To(Equal(2)) })
+			It("should return the owner according to the sharding algorithm", func() {
+				for _, id := range []string{"foo", "bar", "baz"} {
+					Expect(gw.Owner(id)).To(Equal(sharding.Owner(id, []string{"1.1.1.1", "2.2.2.2"})))
+				}
+			})
+
+			When("an active replica is updated without changes", func() {
+				BeforeEach(func() { gw.handle(forgePod("first", "1.1.1.1", liqoconst.GatewayActivePodLabelValue), false, nil) })
+				It("should not execute the handle function again", func() { Expect(handled).To(Equal(2)) })
+			})
+
+			When("an active replica is deleted", func() {
+				BeforeEach(func() { gw.handle(forgePod("first", "1.1.1.1", liqoconst.GatewayActivePodLabelValue), true, nil) })
+				It("should execute the handle function", func() { Expect(handled).To(Equal(3)) })
+				It("should assign all clusters to the remaining replica", func() {
+					Expect(gw.Owner("foo")).To(Equal("2.2.2.2"))
+					Expect(gw.Owner("bar")).To(Equal("2.2.2.2"))
+				})
+			})
+		})
+	})
+})
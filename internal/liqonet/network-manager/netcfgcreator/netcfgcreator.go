@@ -22,6 +22,7 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/trace"
@@ -44,22 +45,37 @@ const componentName = "netcfgCreator"
 // NetworkConfigCreator reconciles ForeignCluster objects to enforce the respective NetworkConfigs.
 type NetworkConfigCreator struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
 
 	foreignClusters *syncset.SyncSet
 	secretWatcher   *SecretWatcher
 	serviceWatcher  *ServiceWatcher
+	gatewayWatcher  *GatewayWatcher
+	transitWatcher  *TransitWatcher
 
 	PodCIDR      string
 	ExternalCIDR string
+
+	// ExportedCIDRs are the additional networks exported to the remote clusters, besides the PodCIDR and the ExternalCIDR.
+	ExportedCIDRs []string
+
+	// GatewayActiveActive is true if all the gateway replicas are simultaneously active, each one handling a shard of the remote clusters.
+	GatewayActiveActive bool
+	// RendezvousAddress is the address of the rendezvous server advertised to the remote clusters, if any.
+	RendezvousAddress string
+	// FallbackEndpoints are the additional addresses the gateway is reachable at, in decreasing order of priority.
+	FallbackEndpoints []string
 }
 
 // cluster-roles
 // +kubebuilder:rbac:groups=discovery.liqo.io,resources=foreignclusters,verbs=get;list;watch
 // +kubebuilder:rbac:groups=net.liqo.io,resources=networkconfigs,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups=net.liqo.io,resources=tunnelendpoints,verbs=get;list;watch
 // roles
 // +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=pods,verbs=get;list;watch
 
 // Reconcile reconciles the state of ForeignCluster resources to enforce the respective NetworkConfigs.
 func (ncc *NetworkConfigCreator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -118,15 +134,24 @@ func (ncc *NetworkConfigCreator) SetupWithManager(mgr ctrl.Manager) error {
 	ncc.foreignClusters = syncset.New()
 	ncc.secretWatcher = NewSecretWatcher(enqueuefn)
 	ncc.serviceWatcher = NewServiceWatcher(enqueuefn)
+	ncc.gatewayWatcher = NewGatewayWatcher(enqueuefn)
+	ncc.transitWatcher = NewTransitWatcher(enqueuefn)
 
 	localNetcfg, err := predicate.LabelSelectorPredicate(reflection.LocalResourcesLabelSelector())
 	utilruntime.Must(err)
 
-	return ctrl.NewControllerManagedBy(mgr).
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(&discoveryv1alpha1.ForeignCluster{}).
 		Owns(&netv1alpha1.NetworkConfig{}, builder.WithPredicates(
 			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}), localNetcfg)).
 		Watches(&source.Kind{Type: &corev1.Secret{}}, ncc.secretWatcher.Handlers(), builder.WithPredicates(ncc.secretWatcher.Predicates())).
 		Watches(&source.Kind{Type: &corev1.Service{}}, ncc.serviceWatcher.Handlers(), builder.WithPredicates(ncc.serviceWatcher.Predicates())).
-		Complete(ncc)
+		Watches(&source.Kind{Type: &netv1alpha1.TunnelEndpoint{}}, ncc.transitWatcher.Handlers(),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	if ncc.GatewayActiveActive {
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: &corev1.Pod{}},
+			ncc.gatewayWatcher.Handlers(), builder.WithPredicates(ncc.gatewayWatcher.Predicates()))
+	}
+	return controllerBuilder.Complete(ncc)
 }
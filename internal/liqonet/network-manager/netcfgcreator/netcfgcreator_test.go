@@ -23,6 +23,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/utils/pointer"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -82,6 +83,7 @@ var _ = Describe("NetworkConfigCreator Controller", func() {
 				endpointPort: "9999",
 				configured:   true,
 			},
+			transitWatcher: NewTransitWatcher(func(workqueue.RateLimitingInterface) {}),
 		}
 
 		// The deletion of namespaces in the test environment does not work.
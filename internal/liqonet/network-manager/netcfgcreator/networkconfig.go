@@ -21,7 +21,9 @@ import (
 	"sort"
 	"strconv"
 
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/klog/v2"
@@ -149,6 +151,8 @@ func (ncc *NetworkConfigCreator) createNetworkConfig(ctx context.Context, fc *di
 		return err
 	}
 	klog.Infof("NetworkConfig %q successfully created", klog.KObj(&netcfg))
+	ncc.reportActiveActiveFallback(&netcfg)
+	ncc.reportUnsupportedBackendFeatures(&netcfg)
 	return nil
 }
 
@@ -161,6 +165,8 @@ func (ncc *NetworkConfigCreator) updateNetworkConfig(ctx context.Context, netcfg
 		klog.Errorf("An error occurred while updating NetworkConfig %q: %v", klog.KObj(netcfg), err)
 		return err
 	}
+	ncc.reportActiveActiveFallback(netcfg)
+	ncc.reportUnsupportedBackendFeatures(netcfg)
 
 	if reflect.DeepEqual(original, netcfg) {
 		klog.V(4).Infof("NetworkConfig %q already up-to-date", klog.KObj(netcfg))
@@ -187,22 +193,133 @@ func (ncc *NetworkConfigCreator) populateNetworkConfig(netcfg *netv1alpha1.Netwo
 	netcfg.Labels[consts.ReplicationDestinationLabel] = clusterIdentity.ClusterID
 
 	wgEndpointIP, wgEndpointPort := ncc.serviceWatcher.WiregardEndpoint()
+	// In active-active mode, the remote cluster is directed to the gateway replica owning it, if directly reachable.
+	if ncc.GatewayActiveActive && ncc.serviceWatcher.NodeAddressable() {
+		if owner := ncc.gatewayWatcher.Owner(clusterIdentity.ClusterID); owner != "" {
+			wgEndpointIP = owner
+		}
+	}
 
 	netcfg.Spec.RemoteCluster = fc.Spec.ClusterIdentity
 	netcfg.Spec.PodCIDR = ncc.PodCIDR
 	netcfg.Spec.ExternalCIDR = ncc.ExternalCIDR
+	netcfg.Spec.ExportedCIDRs = ncc.ExportedCIDRs
+	netcfg.Spec.TransitRoutes = ncc.transitWatcher.Routes(fc)
 	netcfg.Spec.EndpointIP = wgEndpointIP
-	netcfg.Spec.BackendType = consts.DriverName
+	netcfg.Spec.FallbackEndpointIPs = ncc.FallbackEndpoints
+	netcfg.Spec.BackendType = forgeBackendType(fc)
+	netcfg.Spec.QoS = forgeQoS(fc)
 
 	if netcfg.Spec.BackendConfig == nil {
 		netcfg.Spec.BackendConfig = map[string]string{}
 	}
 	netcfg.Spec.BackendConfig[consts.PublicKey] = ncc.secretWatcher.WiregardPublicKey()
 	netcfg.Spec.BackendConfig[consts.ListeningPort] = wgEndpointPort
+	if nextPublicKey := ncc.secretWatcher.WiregardNextPublicKey(); nextPublicKey != "" {
+		netcfg.Spec.BackendConfig[consts.NextPublicKey] = nextPublicKey
+	} else {
+		delete(netcfg.Spec.BackendConfig, consts.NextPublicKey)
+	}
+	if ncc.RendezvousAddress != "" {
+		netcfg.Spec.BackendConfig[consts.RendezvousAddress] = ncc.RendezvousAddress
+	} else {
+		delete(netcfg.Spec.BackendConfig, consts.RendezvousAddress)
+	}
+
+	// Plain GRE tunnels support neither the failover to the fallback endpoints, nor the rendezvous server.
+	if netcfg.Spec.BackendType == consts.GreDriverName {
+		netcfg.Spec.FallbackEndpointIPs = nil
+		delete(netcfg.Spec.BackendConfig, consts.RendezvousAddress)
+	}
 
 	return controllerutil.SetControllerReference(fc, netcfg, ncc.Scheme)
 }
 
+// reportActiveActiveFallback warns, through an event associated with the given NetworkConfig, in case the active-active
+// mode is enabled, but the gateway replicas cannot be reached individually (e.g., the gateway service is not of type NodePort,
+// or its address is overridden or detected). In this case, the remote cluster is directed to the address shared by all replicas.
+func (ncc *NetworkConfigCreator) reportActiveActiveFallback(netcfg *netv1alpha1.NetworkConfig) {
+	if !ncc.GatewayActiveActive || ncc.serviceWatcher.NodeAddressable() {
+		return
+	}
+
+	klog.Warningf("Active-active mode enabled, but the gateway replicas cannot be reached individually: NetworkConfig %q "+
+		"directs the remote cluster to the shared endpoint %s", klog.KObj(netcfg), netcfg.Spec.EndpointIP)
+	ncc.EventRecorder.Eventf(netcfg, corev1.EventTypeWarning, "ActiveActiveUnsupported",
+		"The gateway replicas cannot be reached individually, hence the remote cluster is directed to the shared endpoint %s",
+		netcfg.Spec.EndpointIP)
+}
+
+// reportUnsupportedBackendFeatures warns, through an event associated with the given NetworkConfig, in case the fallback
+// endpoints or the rendezvous server are configured, but not advertised since unsupported by the selected tunnel backend.
+func (ncc *NetworkConfigCreator) reportUnsupportedBackendFeatures(netcfg *netv1alpha1.NetworkConfig) {
+	if netcfg.Spec.BackendType != consts.GreDriverName || (len(ncc.FallbackEndpoints) == 0 && ncc.RendezvousAddress == "") {
+		return
+	}
+
+	klog.Warningf("The %s tunnel backend supports neither fallback endpoints nor rendezvous: NetworkConfig %q "+
+		"directs the remote cluster to the endpoint %s only", consts.GreDriverName, klog.KObj(netcfg), netcfg.Spec.EndpointIP)
+	ncc.EventRecorder.Eventf(netcfg, corev1.EventTypeWarning, "BackendFeaturesUnsupported",
+		"The %s tunnel backend supports neither fallback endpoints nor rendezvous, hence the remote cluster is directed to the endpoint %s only",
+		consts.GreDriverName, netcfg.Spec.EndpointIP)
+}
+
+// forgeBackendType returns the backend type requested for the tunnel towards the given ForeignCluster,
+// that is WireGuard unless a plain GRE tunnel is explicitly selected through the corresponding annotation.
+func forgeBackendType(fc *discoveryv1alpha1.ForeignCluster) string {
+	switch backend := fc.GetAnnotations()[consts.TunnelBackendAnnotation]; backend {
+	case "", consts.DriverName:
+		return consts.DriverName
+	case consts.GreDriverName:
+		return consts.GreDriverName
+	default:
+		klog.Warningf("Unknown tunnel backend %q requested for ForeignCluster %q, falling back to %s", backend, klog.KObj(fc), consts.DriverName)
+		return consts.DriverName
+	}
+}
+
+// forgeQoS returns the bandwidth limits and the priority requested for the traffic exchanged with the given ForeignCluster,
+// or nil if none is requested through the corresponding annotations. Invalid values are ignored.
+func forgeQoS(fc *discoveryv1alpha1.ForeignCluster) *netv1alpha1.QoS {
+	annotations := fc.GetAnnotations()
+	qos := &netv1alpha1.QoS{Priority: netv1alpha1.NormalTrafficPriority}
+	configured := false
+
+	parseBandwidth := func(annotation string) *resource.Quantity {
+		value, found := annotations[annotation]
+		if !found {
+			return nil
+		}
+		bandwidth, err := resource.ParseQuantity(value)
+		if err != nil || bandwidth.Sign() <= 0 {
+			klog.Warningf("Invalid bandwidth %q requested through annotation %q for ForeignCluster %q, ignoring it", value, annotation, klog.KObj(fc))
+			return nil
+		}
+		configured = true
+		// Parse again the canonical representation, to be consistent with the one retrieved from the API server.
+		canonical := resource.MustParse(bandwidth.String())
+		return &canonical
+	}
+
+	qos.EgressBandwidth = parseBandwidth(consts.EgressBandwidthAnnotation)
+	qos.IngressBandwidth = parseBandwidth(consts.IngressBandwidthAnnotation)
+
+	if value, found := annotations[consts.TrafficPriorityAnnotation]; found {
+		switch priority := netv1alpha1.TrafficPriority(value); priority {
+		case netv1alpha1.HighTrafficPriority, netv1alpha1.NormalTrafficPriority, netv1alpha1.LowTrafficPriority:
+			qos.Priority = priority
+			configured = true
+		default:
+			klog.Warningf("Unknown traffic priority %q requested for ForeignCluster %q, ignoring it", value, klog.KObj(fc))
+		}
+	}
+
+	if !configured {
+		return nil
+	}
+	return qos
+}
+
 // EnforceNetworkConfigAbsence ensures the absence of local NetworkConfigs associated with the given ForeignCluster.
 func (ncc *NetworkConfigCreator) EnforceNetworkConfigAbsence(ctx context.Context, fc *discoveryv1alpha1.ForeignCluster) error {
 	clusterIdentity := fc.Spec.ClusterIdentity
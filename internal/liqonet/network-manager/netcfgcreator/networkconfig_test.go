@@ -20,10 +20,14 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
@@ -44,6 +48,8 @@ var _ = Describe("Network config functions", func() {
 		ctx           context.Context
 		clientBuilder fake.ClientBuilder
 		fcw           *NetworkConfigCreator
+		recorder      *record.FakeRecorder
+		activeActive  bool
 		labels        = client.MatchingLabels{
 			consts.LocalResourceOwnership: componentName,
 		}
@@ -52,18 +58,26 @@ var _ = Describe("Network config functions", func() {
 	BeforeEach(func() {
 		ctx = context.Background()
 		clientBuilder = *fake.NewClientBuilder().WithScheme(scheme.Scheme)
+		recorder = record.NewFakeRecorder(10)
+		activeActive = false
 	})
 
 	JustBeforeEach(func() {
 		fcw = &NetworkConfigCreator{
-			Client: clientBuilder.Build(),
-			Scheme: scheme.Scheme,
+			Client:        clientBuilder.Build(),
+			Scheme:        scheme.Scheme,
+			EventRecorder: recorder,
 
 			PodCIDR:      "192.168.0.0/24",
 			ExternalCIDR: "192.168.1.0/24",
 
+			ExportedCIDRs:       []string{"172.16.0.0/24"},
+			FallbackEndpoints:   []string{"2.2.2.2", "gateway.example.com"},
+			GatewayActiveActive: activeActive,
+
 			secretWatcher:  &SecretWatcher{wiregardPublicKey: "public-key"},
 			serviceWatcher: &ServiceWatcher{endpointIP: "1.1.1.1", endpointPort: "9999"},
+			transitWatcher: NewTransitWatcher(func(workqueue.RateLimitingInterface) {}),
 		}
 	})
 
@@ -255,10 +269,14 @@ var _ = Describe("Network config functions", func() {
 				Expect(netcfg.Spec.RemoteCluster.ClusterID).To(BeIdenticalTo(clusterID))
 				Expect(netcfg.Spec.PodCIDR).To(BeIdenticalTo("192.168.0.0/24"))
 				Expect(netcfg.Spec.ExternalCIDR).To(BeIdenticalTo("192.168.1.0/24"))
+				Expect(netcfg.Spec.ExportedCIDRs).To(ConsistOf("172.16.0.0/24"))
 				Expect(netcfg.Spec.EndpointIP).To(BeIdenticalTo("1.1.1.1"))
+				Expect(netcfg.Spec.FallbackEndpointIPs).To(Equal([]string{"2.2.2.2", "gateway.example.com"}))
 				Expect(netcfg.Spec.BackendType).To(BeIdenticalTo(consts.DriverName))
+				Expect(netcfg.Spec.QoS).To(BeNil())
 				Expect(netcfg.Spec.BackendConfig).To(HaveKeyWithValue(consts.PublicKey, "public-key"))
 				Expect(netcfg.Spec.BackendConfig).To(HaveKeyWithValue(consts.ListeningPort, "9999"))
+				Expect(netcfg.Spec.BackendConfig).ToNot(HaveKey(consts.RendezvousAddress))
 			}
 
 			When("the network config associated with the given foreign cluster does not exist", func() {
@@ -277,6 +295,61 @@ var _ = Describe("Network config functions", func() {
 				})
 			})
 
+			When("a plain GRE tunnel is requested through the foreign cluster annotation", func() {
+				BeforeEach(func() {
+					fc.SetAnnotations(map[string]string{consts.TunnelBackendAnnotation: consts.GreDriverName})
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the network config should have the GRE backend type", func() {
+					netcfg, err := GetLocalNetworkConfig(ctx, fcw.Client, labels, clusterID, namespace)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(netcfg.Spec.BackendType).To(BeIdenticalTo(consts.GreDriverName))
+				})
+				It("the network config should not advertise the fallback endpoints", func() {
+					netcfg, err := GetLocalNetworkConfig(ctx, fcw.Client, labels, clusterID, namespace)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(netcfg.Spec.FallbackEndpointIPs).To(BeEmpty())
+				})
+				It("should output a warning event", func() {
+					Expect(recorder.Events).To(Receive(ContainSubstring("BackendFeaturesUnsupported")))
+				})
+			})
+
+			When("the active-active mode is enabled, but the gateway replicas cannot be reached individually", func() {
+				BeforeEach(func() { activeActive = true })
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the network config should direct the remote cluster to the shared endpoint", func() {
+					netcfg, err := GetLocalNetworkConfig(ctx, fcw.Client, labels, clusterID, namespace)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(netcfg.Spec.EndpointIP).To(BeIdenticalTo("1.1.1.1"))
+				})
+				It("should output a warning event", func() {
+					Expect(recorder.Events).To(Receive(ContainSubstring("ActiveActiveUnsupported")))
+				})
+			})
+
+			When("the QoS configuration is requested through the foreign cluster annotations", func() {
+				BeforeEach(func() {
+					fc.SetAnnotations(map[string]string{
+						consts.EgressBandwidthAnnotation:  "100000000",
+						consts.IngressBandwidthAnnotation: "invalid",
+						consts.TrafficPriorityAnnotation:  string(netv1alpha1.HighTrafficPriority),
+					})
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the network config should have the valid QoS parameters", func() {
+					netcfg, err := GetLocalNetworkConfig(ctx, fcw.Client, labels, clusterID, namespace)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(netcfg.Spec.QoS).ToNot(BeNil())
+					Expect(netcfg.Spec.QoS.EgressBandwidth).To(PointTo(Equal(resource.MustParse("100M"))))
+					Expect(netcfg.Spec.QoS.IngressBandwidth).To(BeNil())
+					Expect(netcfg.Spec.QoS.Priority).To(Equal(netv1alpha1.HighTrafficPriority))
+				})
+			})
+
 			When("the network config associated with the given foreign cluster does already exist", func() {
 				BeforeEach(func() {
 					clientBuilder.WithObjects(
@@ -34,7 +34,8 @@ import (
 // SecretWatcher reconciles Secret objects to retrieve the Wireguard public key.
 type SecretWatcher struct {
 	sync.RWMutex
-	wiregardPublicKey string
+	wiregardPublicKey     string
+	wiregardNextPublicKey string
 
 	configured bool
 	wait       chan struct{}
@@ -60,6 +61,15 @@ func (sw *SecretWatcher) WiregardPublicKey() string {
 	return sw.wiregardPublicKey
 }
 
+// WiregardNextPublicKey returns the retrieved Wireguard public key which is going to replace the current one,
+// in case a key rotation is in progress (empty otherwise).
+func (sw *SecretWatcher) WiregardNextPublicKey() string {
+	sw.RLock()
+	defer sw.RUnlock()
+
+	return sw.wiregardNextPublicKey
+}
+
 // WaitForConfigured waits until a valid key is retrieved for the first time.
 func (sw *SecretWatcher) WaitForConfigured(ctx context.Context) bool {
 	sw.RLock()
@@ -117,14 +127,29 @@ func (sw *SecretWatcher) handle(secret *corev1.Secret, rli workqueue.RateLimitin
 		return
 	}
 
-	// The key did not change, nothing to do
-	if pubKey.String() == sw.wiregardPublicKey {
+	// The next key is present only in case a key rotation is in progress
+	var nextPubKey string
+	if _, found := secret.Data[consts.NextPublicKey]; found {
+		key, err := getters.RetrieveWGPubKeyFromSecret(secret, consts.NextPublicKey)
+		if err != nil {
+			klog.Error(err)
+			return
+		}
+		nextPubKey = key.String()
+	}
+
+	// The keys did not change, nothing to do
+	if pubKey.String() == sw.wiregardPublicKey && nextPubKey == sw.wiregardNextPublicKey {
 		return
 	}
 
-	// Configure the new key, and set as configured if not yet done
+	// Configure the new keys, and set as configured if not yet done
 	klog.Infof("Wiregard public key correctly retrieved")
+	if nextPubKey != "" {
+		klog.Infof("Wiregard key rotation in progress, next public key correctly retrieved")
+	}
 	sw.wiregardPublicKey = pubKey.String()
+	sw.wiregardNextPublicKey = nextPubKey
 	if !sw.configured {
 		close(sw.wait)
 		sw.configured = true
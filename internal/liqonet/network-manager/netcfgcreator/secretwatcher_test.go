@@ -69,6 +69,31 @@ var _ = Describe("Secret Watcher functions", func() {
 			})
 		})
 
+		When("given a secret with a key rotation in progress", func() {
+			const nextKey = "bmV4dC1wdWJsaWMta2V5LW9mLXRoZS1jb3JyZWN0LWw="
+
+			BeforeEach(func() {
+				secret.Data = map[string][]byte{consts.PublicKey: []byte(key), consts.NextPublicKey: []byte(nextKey)}
+				sw.wiregardPublicKey = key
+				sw.configured = true
+			})
+
+			It("should retrieve the correct public key", func() { Expect(sw.WiregardPublicKey()).To(BeIdenticalTo(key)) })
+			It("should retrieve the correct next public key", func() { Expect(sw.WiregardNextPublicKey()).To(BeIdenticalTo(nextKey)) })
+			It("should execute the handle function", func() { Expect(handled).To(BeClosed()) })
+
+			When("the key rotation completes", func() {
+				BeforeEach(func() {
+					secret.Data = map[string][]byte{consts.PublicKey: []byte(nextKey)}
+					sw.wiregardNextPublicKey = nextKey
+				})
+
+				It("should retrieve the correct public key", func() { Expect(sw.WiregardPublicKey()).To(BeIdenticalTo(nextKey)) })
+				It("should reset the next public key", func() { Expect(sw.WiregardNextPublicKey()).To(BeEmpty()) })
+				It("should execute the handle function", func() { Expect(handled).To(BeClosed()) })
+			})
+		})
+
 		When("given an invalid secret", func() {
 			BeforeEach(func() {
 				secret.Data = map[string][]byte{"incorrect-key": []byte(key)}
@@ -27,6 +27,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
 	"github.com/liqotech/liqo/pkg/utils/getters"
 	liqolabels "github.com/liqotech/liqo/pkg/utils/labels"
 )
@@ -36,6 +37,8 @@ type ServiceWatcher struct {
 	sync.RWMutex
 	endpointIP   string
 	endpointPort string
+	// nodeAddressable is true if the gateway replicas can be reached through the IP of the hosting nodes.
+	nodeAddressable bool
 
 	configured bool
 	wait       chan struct{}
@@ -61,6 +64,15 @@ func (sw *ServiceWatcher) WiregardEndpoint() (ip, port string) {
 	return sw.endpointIP, sw.endpointPort
 }
 
+// NodeAddressable returns whether the gateway replicas can be reached through the IP of the hosting nodes,
+// that is the service is of type NodePort and the address is neither overridden nor detected by an endpoint resolver.
+func (sw *ServiceWatcher) NodeAddressable() bool {
+	sw.RLock()
+	defer sw.RUnlock()
+
+	return sw.nodeAddressable
+}
+
 // WaitForConfigured waits until a valid key is retrieved for the first time.
 func (sw *ServiceWatcher) WaitForConfigured(ctx context.Context) bool {
 	sw.RLock()
@@ -121,8 +133,10 @@ func (sw *ServiceWatcher) handle(service *corev1.Service, rli workqueue.RateLimi
 		return
 	}
 
+	nodeAddressable := sharding.IsNodeAddressable(service)
+
 	// The endpoint did not change, nothing to do
-	if ip == sw.endpointIP && port == sw.endpointPort {
+	if ip == sw.endpointIP && port == sw.endpointPort && nodeAddressable == sw.nodeAddressable {
 		return
 	}
 
@@ -130,6 +144,7 @@ func (sw *ServiceWatcher) handle(service *corev1.Service, rli workqueue.RateLimi
 	klog.Infof("Wiregard endpoint correctly retrieved: %s:%s", ip, port)
 	sw.endpointIP = ip
 	sw.endpointPort = port
+	sw.nodeAddressable = nodeAddressable
 	if !sw.configured {
 		close(sw.wait)
 		sw.configured = true
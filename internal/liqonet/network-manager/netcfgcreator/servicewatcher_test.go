@@ -57,6 +57,17 @@ var _ = Describe("Service Watcher functions", func() {
 				})
 				It("should execute the handle function", func() { Expect(handled).To(BeClosed()) })
 				It("should be initialized", func() { Expect(sw.configured).To(BeTrue()) })
+				It("should be node addressable", func() { Expect(sw.NodeAddressable()).To(BeTrue()) })
+			})
+
+			When("given a valid service with a detected address", func() {
+				BeforeEach(func() { service.Annotations["net.liqo.io/detected-address"] = "2.2.2.2" })
+				It("should retrieve the detected endpoint", func() {
+					ip, port := sw.WiregardEndpoint()
+					Expect(ip).To(BeIdenticalTo("2.2.2.2"))
+					Expect(port).To(BeIdenticalTo("9999"))
+				})
+				It("should not be node addressable", func() { Expect(sw.NodeAddressable()).To(BeFalse()) })
 			})
 
 			When("given an invalid service (missing the annotation)", func() {
@@ -0,0 +1,127 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcfgcreator
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	"github.com/liqotech/liqo/pkg/consts"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// TransitWatcher reconciles the TunnelEndpoint objects to retrieve the networks of the peered clusters
+// which can be advertised as transit routes to the other ones.
+type TransitWatcher struct {
+	sync.RWMutex
+	// routes maps the ID of each peered cluster to the networks reachable through the corresponding tunnel.
+	routes map[string]netv1alpha1.TransitRoute
+
+	enqueuefn func(workqueue.RateLimitingInterface)
+}
+
+// NewTransitWatcher returns a new initialized TransitWatcher instance.
+func NewTransitWatcher(enqueuefn func(workqueue.RateLimitingInterface)) *TransitWatcher {
+	return &TransitWatcher{
+		routes:    map[string]netv1alpha1.TransitRoute{},
+		enqueuefn: enqueuefn,
+	}
+}
+
+// Routes returns the transit routes to be advertised to the remote cluster identified by the given ForeignCluster, according
+// to the clusters listed in the corresponding annotation. The remote cluster itself is never included.
+func (tw *TransitWatcher) Routes(fc *discoveryv1alpha1.ForeignCluster) []netv1alpha1.TransitRoute {
+	value, found := fc.GetAnnotations()[consts.TransitClustersAnnotation]
+	if !found {
+		return nil
+	}
+
+	allowed := map[string]bool{}
+	for _, cluster := range strings.Split(value, ",") {
+		if cluster = strings.TrimSpace(cluster); cluster != "" {
+			allowed[cluster] = true
+		}
+	}
+
+	tw.RLock()
+	defer tw.RUnlock()
+
+	var routes []netv1alpha1.TransitRoute
+	for clusterID, route := range tw.routes {
+		if clusterID == fc.Spec.ClusterIdentity.ClusterID {
+			continue
+		}
+		if allowed[consts.TransitClustersWildcard] || allowed[clusterID] || allowed[route.Cluster.ClusterName] {
+			routes = append(routes, *route.DeepCopy())
+		}
+	}
+
+	// Sort the routes, to prevent unnecessary updates of the NetworkConfigs.
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Cluster.ClusterID < routes[j].Cluster.ClusterID })
+	return routes
+}
+
+// Handlers returns the set of handlers used for the Watch configuration.
+func (tw *TransitWatcher) Handlers() handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(ce event.CreateEvent, rli workqueue.RateLimitingInterface) {
+			tw.handle(ce.Object.(*netv1alpha1.TunnelEndpoint), false, rli)
+		},
+		UpdateFunc: func(ue event.UpdateEvent, rli workqueue.RateLimitingInterface) {
+			tw.handle(ue.ObjectNew.(*netv1alpha1.TunnelEndpoint), false, rli)
+		},
+		DeleteFunc: func(de event.DeleteEvent, rli workqueue.RateLimitingInterface) {
+			tw.handle(de.Object.(*netv1alpha1.TunnelEndpoint), true, rli)
+		},
+	}
+}
+
+// handle processes the events concerning a TunnelEndpoint object.
+func (tw *TransitWatcher) handle(tep *netv1alpha1.TunnelEndpoint, deleted bool, rli workqueue.RateLimitingInterface) {
+	klog.V(4).Infof("Handling TunnelEndpoint %q", klog.KObj(tep))
+	clusterID := tep.Spec.ClusterIdentity.ClusterID
+
+	tw.Lock()
+	defer tw.Unlock()
+
+	current, found := tw.routes[clusterID]
+	if deleted || liqonetutils.CheckTep(tep) != nil {
+		if !found {
+			return
+		}
+		delete(tw.routes, clusterID)
+	} else {
+		// Only the PodCIDR is advertised, as seen by the local cluster (i.e., the network the traffic is forwarded to).
+		_, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+		route := netv1alpha1.TransitRoute{Cluster: tep.Spec.ClusterIdentity, CIDRs: []string{remotePodCIDR}}
+		if found && reflect.DeepEqual(current, route) {
+			return
+		}
+		tw.routes[clusterID] = route
+	}
+
+	klog.Infof("Networks reachable through the tunnel towards cluster %v changed", tep.Spec.ClusterIdentity)
+	// Enqueue all foreign clusters for update (which in turn update the respective network configs)
+	tw.enqueuefn(rli)
+}
@@ -0,0 +1,120 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcfgcreator
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+)
+
+var _ = Describe("Transit Watcher functions", func() {
+	var (
+		handled int
+
+		tw *TransitWatcher
+	)
+
+	forgeTep := func(id, name, podCIDR, natPodCIDR string) *netv1alpha1.TunnelEndpoint {
+		return &netv1alpha1.TunnelEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "liqo-tenant-" + name},
+			Spec: netv1alpha1.TunnelEndpointSpec{
+				ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: id, ClusterName: name},
+				LocalPodCIDR:    "10.0.0.0/16", LocalNATPodCIDR: liqoconst.DefaultCIDRValue,
+				LocalExternalCIDR: "10.1.0.0/16", LocalNATExternalCIDR: liqoconst.DefaultCIDRValue,
+				RemotePodCIDR: podCIDR, RemoteNATPodCIDR: natPodCIDR,
+				RemoteExternalCIDR: "10.201.0.0/16", RemoteNATExternalCIDR: liqoconst.DefaultCIDRValue,
+			},
+		}
+	}
+
+	forgeForeignCluster := func(id, transit string) *discoveryv1alpha1.ForeignCluster {
+		fc := &discoveryv1alpha1.ForeignCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: id},
+			Spec:       discoveryv1alpha1.ForeignClusterSpec{ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: id}},
+		}
+		if transit != "" {
+			fc.Annotations = map[string]string{liqoconst.TransitClustersAnnotation: transit}
+		}
+		return fc
+	}
+
+	BeforeEach(func() {
+		handled = 0
+		tw = NewTransitWatcher(func(rli workqueue.RateLimitingInterface) { handled++ })
+	})
+
+	Describe("The handle and Routes functions", func() {
+		BeforeEach(func() {
+			tw.handle(forgeTep("id-a", "spoke-a", "10.100.0.0/16", liqoconst.DefaultCIDRValue), false, nil)
+			tw.handle(forgeTep("id-b", "spoke-b", "10.100.0.0/16", "10.102.0.0/16"), false, nil)
+			tw.handle(forgeTep("id-c", "spoke-c", "10.103.0.0/16", liqoconst.DefaultCIDRValue), false, nil)
+		})
+
+		It("should execute the handle function for each change", func() { Expect(handled).To(Equal(3)) })
+
+		When("a tunnel endpoint is updated without changes", func() {
+			BeforeEach(func() {
+				tw.handle(forgeTep("id-a", "spoke-a", "10.100.0.0/16", liqoconst.DefaultCIDRValue), false, nil)
+			})
+			It("should not execute the handle function again", func() { Expect(handled).To(Equal(3)) })
+		})
+
+		When("the foreign cluster is not annotated", func() {
+			It("should return no routes", func() { Expect(tw.Routes(forgeForeignCluster("id-a", ""))).To(BeEmpty()) })
+		})
+
+		When("the foreign cluster is granted access to a cluster through its name", func() {
+			It("should return the remapped PodCIDR of that cluster", func() {
+				Expect(tw.Routes(forgeForeignCluster("id-a", "spoke-b"))).To(ConsistOf(netv1alpha1.TransitRoute{
+					Cluster: discoveryv1alpha1.ClusterIdentity{ClusterID: "id-b", ClusterName: "spoke-b"},
+					CIDRs:   []string{"10.102.0.0/16"},
+				}))
+			})
+		})
+
+		When("the foreign cluster is granted access to a cluster through its ID", func() {
+			It("should return the PodCIDR of that cluster", func() {
+				Expect(tw.Routes(forgeForeignCluster("id-a", " foo, id-c "))).To(ConsistOf(netv1alpha1.TransitRoute{
+					Cluster: discoveryv1alpha1.ClusterIdentity{ClusterID: "id-c", ClusterName: "spoke-c"},
+					CIDRs:   []string{"10.103.0.0/16"},
+				}))
+			})
+		})
+
+		When("the foreign cluster is granted access to all clusters", func() {
+			It("should return the routes of all the other clusters, sorted by cluster ID", func() {
+				routes := tw.Routes(forgeForeignCluster("id-a", liqoconst.TransitClustersWildcard))
+				Expect(routes).To(HaveLen(2))
+				Expect(routes[0].Cluster.ClusterID).To(Equal("id-b"))
+				Expect(routes[1].Cluster.ClusterID).To(Equal("id-c"))
+			})
+		})
+
+		When("a tunnel endpoint is deleted", func() {
+			BeforeEach(func() { tw.handle(forgeTep("id-b", "spoke-b", "10.100.0.0/16", "10.102.0.0/16"), true, nil) })
+			It("should execute the handle function", func() { Expect(handled).To(Equal(4)) })
+			It("should no longer return the routes of that cluster", func() {
+				Expect(tw.Routes(forgeForeignCluster("id-a", liqoconst.TransitClustersWildcard))).To(ConsistOf(
+					HaveField("Cluster.ClusterID", "id-c")))
+			})
+		})
+	})
+})
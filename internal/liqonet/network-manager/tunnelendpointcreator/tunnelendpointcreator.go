@@ -20,6 +20,8 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"sort"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,12 +44,14 @@ import (
 	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
 	"github.com/liqotech/liqo/pkg/utils"
 	foreignclusterutils "github.com/liqotech/liqo/pkg/utils/foreignCluster"
+	"github.com/liqotech/liqo/pkg/utils/slice"
 	traceutils "github.com/liqotech/liqo/pkg/utils/trace"
 )
 
 type networkParam struct {
 	remoteCluster         discoveryv1alpha1.ClusterIdentity
 	remoteEndpointIP      string
+	remoteFallbackIPs     []string
 	remotePodCIDR         string
 	remoteNatPodCIDR      string
 	remoteExternalCIDR    string
@@ -57,8 +61,12 @@ type networkParam struct {
 	localPodCIDR          string
 	localExternalCIDR     string
 	localNatExternalCIDR  string
+	localExportedCIDRs    []netv1alpha1.ExportedCIDR
+	remoteExportedCIDRs   []netv1alpha1.ExportedCIDR
+	transitRoutes         []netv1alpha1.TransitRoute
 	backendType           string
 	backendConfig         map[string]string
+	qos                   *netv1alpha1.QoS
 }
 
 // TunnelEndpointCreator manages the most of liqo networking.
@@ -286,6 +294,11 @@ func (tec *TunnelEndpointCreator) enforceRemoteNetConfigStatus(ctx context.Conte
 		klog.Errorf("An error occurred while getting a new subnet for resource %q: %v", klog.KObj(netcfg), err)
 		return err
 	}
+	exportedCIDRs, err := tec.IPManager.GetExportedSubnetsPerCluster(advertisedCIDRs(&netcfg.Spec), clusterID)
+	if err != nil {
+		klog.Errorf("An error occurred while getting the subnets for the exported CIDRs of resource %q: %v", klog.KObj(netcfg), err)
+		return err
+	}
 	tracer.Step("CIDR remappings retrieval")
 
 	// Set the default values in case the CIDRs have not been remapped
@@ -295,12 +308,23 @@ func (tec *TunnelEndpointCreator) enforceRemoteNetConfigStatus(ctx context.Conte
 	if externalCIDR == netcfg.Spec.ExternalCIDR {
 		externalCIDR = liqoconst.DefaultCIDRValue
 	}
+	var exportedCIDRsNAT map[string]string
+	if len(exportedCIDRs) > 0 {
+		exportedCIDRsNAT = make(map[string]string, len(exportedCIDRs))
+	}
+	for original, mapped := range exportedCIDRs {
+		if mapped == original {
+			mapped = liqoconst.DefaultCIDRValue
+		}
+		exportedCIDRsNAT[original] = mapped
+	}
 
 	// Update the status fields
 	original := netcfg.Status.DeepCopy()
 	netcfg.Status.Processed = true
 	netcfg.Status.PodCIDRNAT = podCIDR
 	netcfg.Status.ExternalCIDRNAT = externalCIDR
+	netcfg.Status.ExportedCIDRsNAT = exportedCIDRsNAT
 
 	// Avoid performing updates in case it is not necessary
 	if !reflect.DeepEqual(original, netcfg.Status) {
@@ -320,6 +344,7 @@ func (tec *TunnelEndpointCreator) enforceTunnelEndpoint(ctx context.Context, loc
 	param := &networkParam{
 		remoteCluster:         local.Spec.RemoteCluster,
 		remoteEndpointIP:      remote.Spec.EndpointIP,
+		remoteFallbackIPs:     remote.Spec.FallbackEndpointIPs,
 		remotePodCIDR:         remote.Spec.PodCIDR,
 		remoteNatPodCIDR:      remote.Status.PodCIDRNAT,
 		remoteExternalCIDR:    remote.Spec.ExternalCIDR,
@@ -329,8 +354,12 @@ func (tec *TunnelEndpointCreator) enforceTunnelEndpoint(ctx context.Context, loc
 		localPodCIDR:          local.Spec.PodCIDR,
 		localExternalCIDR:     local.Spec.ExternalCIDR,
 		localNatExternalCIDR:  local.Status.ExternalCIDRNAT,
-		backendType:           remote.Spec.BackendType,
-		backendConfig:         remote.Spec.BackendConfig,
+		localExportedCIDRs:    forgeExportedCIDRs(advertisedCIDRs(&local.Spec), local.Status.ExportedCIDRsNAT),
+		remoteExportedCIDRs:   forgeExportedCIDRs(advertisedCIDRs(&remote.Spec), remote.Status.ExportedCIDRsNAT),
+		transitRoutes:         forgeTransitRoutes(remote.Spec.TransitRoutes, remote.Status.ExportedCIDRsNAT),
+		backendType:           forgeBackendType(local, remote),
+		backendConfig:         forgeBackendConfig(local, remote),
+		qos:                   local.Spec.QoS,
 	}
 
 	// Try to get the tunnelEndpoint, which may not exist
@@ -432,9 +461,108 @@ func (tec *TunnelEndpointCreator) fillTunnelEndpointSpec(tep *netv1alpha1.Tunnel
 	tep.Spec.RemoteNATPodCIDR = param.remoteNatPodCIDR
 	tep.Spec.RemoteExternalCIDR = param.remoteExternalCIDR
 	tep.Spec.RemoteNATExternalCIDR = param.remoteNatExternalCIDR
+	tep.Spec.LocalExportedCIDRs = param.localExportedCIDRs
+	tep.Spec.RemoteExportedCIDRs = param.remoteExportedCIDRs
+	tep.Spec.TransitRoutes = param.transitRoutes
 	tep.Spec.EndpointIP = param.remoteEndpointIP
+	tep.Spec.FallbackEndpointIPs = param.remoteFallbackIPs
 	tep.Spec.BackendType = param.backendType
 	tep.Spec.BackendConfig = param.backendConfig
+	tep.Spec.QoS = param.qos
+}
+
+// forgeExportedCIDRs returns the exported CIDRs, along with the networks used to remap them (if any).
+func forgeExportedCIDRs(cidrs []string, nat map[string]string) []netv1alpha1.ExportedCIDR {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	exported := make([]netv1alpha1.ExportedCIDR, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		natCIDR, found := nat[cidr]
+		if !found {
+			natCIDR = liqoconst.DefaultCIDRValue
+		}
+		exported = append(exported, netv1alpha1.ExportedCIDR{CIDR: cidr, NATCIDR: natCIDR})
+	}
+	return exported
+}
+
+// advertisedCIDRs returns the additional networks advertised by a cluster, that is the exported ones and the ones of the
+// third clusters reachable in transit. The latter are handled as the former, as the advertising cluster forwards the
+// traffic between the tunnels, masquerading it with its own address.
+func advertisedCIDRs(spec *netv1alpha1.NetworkConfigSpec) []string {
+	if len(spec.TransitRoutes) == 0 {
+		return spec.ExportedCIDRs
+	}
+
+	cidrs := append([]string{}, spec.ExportedCIDRs...)
+	for i := range spec.TransitRoutes {
+		for _, cidr := range spec.TransitRoutes[i].CIDRs {
+			if !slice.ContainsString(cidrs, cidr) {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+	}
+	return cidrs
+}
+
+// forgeTransitRoutes returns the transit routes advertised by the remote cluster, translated according to the networks
+// used in the local cluster to map them (if any).
+func forgeTransitRoutes(routes []netv1alpha1.TransitRoute, nat map[string]string) []netv1alpha1.TransitRoute {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	forged := make([]netv1alpha1.TransitRoute, 0, len(routes))
+	for i := range routes {
+		route := netv1alpha1.TransitRoute{Cluster: routes[i].Cluster, CIDRs: make([]string, 0, len(routes[i].CIDRs))}
+		for _, cidr := range routes[i].CIDRs {
+			if natCIDR, found := nat[cidr]; found && natCIDR != liqoconst.DefaultCIDRValue {
+				cidr = natCIDR
+			}
+			route.CIDRs = append(route.CIDRs, cidr)
+		}
+		forged = append(forged, route)
+	}
+	return forged
+}
+
+// forgeBackendType returns the backend type of the tunnel towards the remote cluster. Unencrypted backends are selected
+// only if requested by both clusters, falling back to WireGuard otherwise.
+func forgeBackendType(local, remote *netv1alpha1.NetworkConfig) string {
+	if local.Spec.BackendType != remote.Spec.BackendType {
+		return liqoconst.DriverName
+	}
+	return remote.Spec.BackendType
+}
+
+// forgeBackendConfig returns the backend configuration of the tunnel, that is the one advertised by the remote cluster,
+// complemented by the rendezvous parameters in case any of the two clusters advertised a rendezvous server.
+func forgeBackendConfig(local, remote *netv1alpha1.NetworkConfig) map[string]string {
+	// The same rendezvous server shall be selected by both clusters, hence we prefer the lowest address if both are set.
+	address := remote.Spec.BackendConfig[liqoconst.RendezvousAddress]
+	if localAddress := local.Spec.BackendConfig[liqoconst.RendezvousAddress]; localAddress != "" && (address == "" || localAddress < address) {
+		address = localAddress
+	}
+	if address == "" {
+		return remote.Spec.BackendConfig
+	}
+
+	config := make(map[string]string, len(remote.Spec.BackendConfig)+3)
+	for key, value := range remote.Spec.BackendConfig {
+		config[key] = value
+	}
+
+	// The remote cluster of the local network config is the remote one, and vice versa.
+	localClusterID, remoteClusterID := remote.Spec.RemoteCluster.ClusterID, local.Spec.RemoteCluster.ClusterID
+	members := []string{localClusterID, remoteClusterID}
+	sort.Strings(members)
+
+	config[liqoconst.RendezvousAddress] = address
+	config[liqoconst.RendezvousSession] = strings.Join(members, "_")
+	config[liqoconst.RendezvousMember] = localClusterID
+	return config
 }
 
 // GetTunnelEndpoint retrieves the tunnelEndpoint resource related to a cluster.
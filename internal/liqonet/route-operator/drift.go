@@ -0,0 +1,239 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routeoperator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	"github.com/liqotech/liqo/pkg/liqonet/overlay"
+)
+
+const (
+	// DriftComponentRoute identifies the routes and policy routing rules towards the remote clusters.
+	DriftComponentRoute = "route"
+	// DriftComponentOverlay identifies the fdb entries of the overlay network.
+	DriftComponentOverlay = "overlay"
+	// DriftComponentSymmetricRouting identifies the routes towards the local pods, leveraged for symmetric routing.
+	DriftComponentSymmetricRouting = "symmetric_routing"
+
+	// driftDebounce is the delay between the notification of a kernel change and the subsequent check, so that
+	// bursts of changes (e.g., a routing table being flushed) are handled by a single check.
+	driftDebounce = time.Second
+)
+
+var (
+	// driftCorrections is the metric that counts the number of configuration entries restored after drifting.
+	driftCorrections = prometheus.NewDesc(
+		"liqo_route_drift_corrections_total",
+		"Number of configuration entries restored after drifting from the desired state (e.g., removed by a third party).",
+		[]string{"component"},
+		nil,
+	)
+
+	// driftFailures is the metric that counts the number of failed drift checks.
+	driftFailures = prometheus.NewDesc(
+		"liqo_route_drift_check_failures_total",
+		"Number of drift checks which failed to restore the desired configuration.",
+		[]string{"component"},
+		nil,
+	)
+)
+
+// DriftChecker is implemented by the controllers whose desired configuration can be compared with the kernel state.
+type DriftChecker interface {
+	// CheckDrift re-applies the configuration missing from the kernel, and returns the number of restored entries.
+	CheckDrift(ctx context.Context) (int, error)
+}
+
+// DriftDetector verifies that the configuration enforced by the route operator is still present in the kernel, and
+// restores it otherwise (e.g., in case the routing table is flushed by a CNI restart or by an administrator).
+// The check is performed periodically, as well as whenever a relevant entry is removed from the kernel.
+type DriftDetector struct {
+	checkers  map[string]DriftChecker
+	interval  time.Duration
+	tableID   int
+	linkIndex int
+
+	mutex       sync.Mutex
+	corrections map[string]int
+	failures    map[string]int
+}
+
+// NewDriftDetector returns a new DriftDetector, which performs the checks through the given checkers (keyed by component)
+// with the given interval. The checks are additionally triggered by the removal of routes and policy routing rules
+// concerning the given routing table, as well as of routes and fdb entries concerning the given vxlan device (if any).
+func NewDriftDetector(interval time.Duration, tableID int, vxlanDevice *overlay.VxlanDevice, checkers map[string]DriftChecker) *DriftDetector {
+	dd := &DriftDetector{
+		checkers:    checkers,
+		interval:    interval,
+		tableID:     tableID,
+		linkIndex:   -1,
+		corrections: map[string]int{},
+		failures:    map[string]int{},
+	}
+	if vxlanDevice != nil {
+		dd.linkIndex = vxlanDevice.Link.Index
+	}
+	for component := range checkers {
+		dd.corrections[component] = 0
+		dd.failures[component] = 0
+	}
+	return dd
+}
+
+// Start checks the configuration, until the given context is canceled.
+// It implements the manager.Runnable interface.
+func (dd *DriftDetector) Start(ctx context.Context) error {
+	trigger := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	if err := dd.subscribe(ctx, notify); err != nil {
+		klog.Warningf("Failed to subscribe to the kernel changes, drift detection relies on periodic checks only: %v", err)
+	}
+
+	ticker := time.NewTicker(dd.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-trigger:
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(driftDebounce):
+			}
+		}
+		dd.check(ctx)
+	}
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, as the check is performed on each node.
+func (dd *DriftDetector) NeedLeaderElection() bool {
+	return false
+}
+
+// check verifies the configuration of all the components, restoring the missing entries.
+func (dd *DriftDetector) check(ctx context.Context) {
+	for component, checker := range dd.checkers {
+		corrected, err := checker.CheckDrift(ctx)
+		if err != nil {
+			klog.Errorf("Failed to restore the %s configuration drifted from the desired state: %v", component, err)
+		}
+		if corrected > 0 {
+			klog.Warningf("Restored %d %s configuration entries drifted from the desired state", corrected, component)
+		}
+
+		dd.mutex.Lock()
+		dd.corrections[component] += corrected
+		if err != nil {
+			dd.failures[component]++
+		}
+		dd.mutex.Unlock()
+	}
+}
+
+// subscribe registers for the kernel notifications concerning routes, policy routing rules and fdb entries,
+// and invokes the given function whenever a relevant entry is removed.
+func (dd *DriftDetector) subscribe(ctx context.Context, notify func()) error {
+	routes := make(chan netlink.RouteUpdate)
+	if err := netlink.RouteSubscribe(routes, ctx.Done()); err != nil {
+		return err
+	}
+	go func() {
+		for update := range routes {
+			if update.Type == unix.RTM_DELROUTE && (update.Table == dd.tableID || update.LinkIndex == dd.linkIndex) {
+				klog.V(4).Infof("Route {%s} removed, checking the configuration", update.Route.String())
+				notify()
+			}
+		}
+	}()
+
+	neighs := make(chan netlink.NeighUpdate)
+	if err := netlink.NeighSubscribe(neighs, ctx.Done()); err != nil {
+		return err
+	}
+	go func() {
+		for update := range neighs {
+			if update.Type == unix.RTM_DELNEIGH && update.LinkIndex == dd.linkIndex {
+				klog.V(4).Infof("Fdb entry {%s} removed, checking the configuration", update.Neigh.String())
+				notify()
+			}
+		}
+	}()
+
+	// The netlink library does not support subscribing to the policy routing rules, hence the raw socket is used.
+	// The rules are not parsed, since any removal triggers a check (and the unrelated ones are infrequent).
+	rules, err := nl.Subscribe(unix.NETLINK_ROUTE, unix.RTNLGRP_IPV4_RULE)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		rules.Close()
+	}()
+	go func() {
+		for {
+			msgs, _, err := rules.Receive()
+			if err != nil {
+				if ctx.Err() == nil {
+					klog.Warningf("Failed to receive the policy routing rules notifications: %v", err)
+				}
+				return
+			}
+			for i := range msgs {
+				if msgs[i].Header.Type == unix.RTM_DELRULE {
+					klog.V(4).Info("Policy routing rule removed, checking the configuration")
+					notify()
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (dd *DriftDetector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- driftCorrections
+	ch <- driftFailures
+}
+
+// Collect implements prometheus.Collector.
+func (dd *DriftDetector) Collect(ch chan<- prometheus.Metric) {
+	dd.mutex.Lock()
+	defer dd.mutex.Unlock()
+
+	for component, value := range dd.corrections {
+		ch <- prometheus.MustNewConstMetric(driftCorrections, prometheus.CounterValue, float64(value), component)
+	}
+	for component, value := range dd.failures {
+		ch <- prometheus.MustNewConstMetric(driftFailures, prometheus.CounterValue, float64(value), component)
+	}
+}
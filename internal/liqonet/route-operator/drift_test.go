@@ -0,0 +1,76 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routeoperator
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeDriftChecker is a fake DriftChecker returning the configured values.
+type fakeDriftChecker struct {
+	corrected int
+	err       error
+	calls     int
+}
+
+func (fdc *fakeDriftChecker) CheckDrift(_ context.Context) (int, error) {
+	fdc.calls++
+	return fdc.corrected, fdc.err
+}
+
+var _ = Describe("DriftDetector", func() {
+	var (
+		route, overlay *fakeDriftChecker
+		dd             *DriftDetector
+	)
+
+	BeforeEach(func() {
+		route = &fakeDriftChecker{corrected: 2}
+		overlay = &fakeDriftChecker{err: errors.New("fake error")}
+		dd = NewDriftDetector(driftDebounce, 1000, vxlanDevice,
+			map[string]DriftChecker{DriftComponentRoute: route, DriftComponentOverlay: overlay})
+	})
+
+	JustBeforeEach(func() {
+		dd.check(context.Background())
+		dd.check(context.Background())
+	})
+
+	It("should invoke all the checkers", func() {
+		Expect(route.calls).To(Equal(2))
+		Expect(overlay.calls).To(Equal(2))
+	})
+
+	It("should account for the corrections and the failures", func() {
+		Expect(dd.corrections).To(HaveKeyWithValue(DriftComponentRoute, 4))
+		Expect(dd.corrections).To(HaveKeyWithValue(DriftComponentOverlay, 0))
+		Expect(dd.failures).To(HaveKeyWithValue(DriftComponentRoute, 0))
+		Expect(dd.failures).To(HaveKeyWithValue(DriftComponentOverlay, 2))
+	})
+
+	It("should expose the corresponding metrics", func() {
+		Expect(testutil.CollectAndCount(dd)).To(Equal(4))
+	})
+
+	It("should not match any link in case the vxlan device is not provided", func() {
+		Expect(dd.linkIndex).To(Equal(vxlanDevice.Link.Index))
+		Expect(NewDriftDetector(driftDebounce, 1000, nil, nil).linkIndex).To(Equal(-1))
+	})
+})
@@ -0,0 +1,57 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routeoperator
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	liqorouting "github.com/liqotech/liqo/pkg/liqonet/routing"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// NewGatewayAddressResolver returns the function leveraged by the native routing manager to retrieve the address
+// to be resolved through the routes configured by the CNI in order to reach the gateway. Given that the gateway runs
+// in the host network, the address is the first one of the pod CIDR assigned to the node hosting the gateway, which
+// is typically assigned by the CNI to the node itself (e.g., to the flannel.1 or cni0 interfaces).
+// The gateway IP is returned as is in case the node is not found, or it is not characterized by a pod CIDR.
+func NewGatewayAddressResolver(ctx context.Context, cl client.Reader) liqorouting.GatewayAddressFunc {
+	return func(gatewayIP string) (string, error) {
+		var nodes corev1.NodeList
+		if err := cl.List(ctx, &nodes); err != nil {
+			return "", err
+		}
+
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			for _, address := range node.Status.Addresses {
+				if address.Address != gatewayIP {
+					continue
+				}
+				if node.Spec.PodCIDR == "" {
+					klog.Warningf("Node %q hosting the gateway has no pod CIDR, falling back to the gateway IP %s", node.Name, gatewayIP)
+					return gatewayIP, nil
+				}
+				return liqonetutils.GetFirstIP(node.Spec.PodCIDR)
+			}
+		}
+
+		klog.Warningf("Node hosting the gateway with IP %s not found, falling back to the gateway IP", gatewayIP)
+		return gatewayIP, nil
+	}
+}
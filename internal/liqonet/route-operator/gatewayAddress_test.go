@@ -0,0 +1,66 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routeoperator
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	liqorouting "github.com/liqotech/liqo/pkg/liqonet/routing"
+)
+
+var _ = Describe("GatewayAddressResolver", func() {
+	var (
+		resolver liqorouting.GatewayAddressFunc
+		address  string
+		err      error
+	)
+
+	node := func(name, ip, podCIDR string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{PodCIDR: podCIDR},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: ip},
+			}},
+		}
+	}
+
+	BeforeEach(func() {
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			node("node-1", "192.168.0.1", "10.244.1.0/24"),
+			node("node-2", "192.168.0.2", ""),
+		).Build()
+		resolver = NewGatewayAddressResolver(context.Background(), cl)
+	})
+
+	DescribeTable("resolving the gateway address",
+		func(gatewayIP, expected string) {
+			address, err = resolver(gatewayIP)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(address).To(Equal(expected))
+		},
+		Entry("the node hosting the gateway has a pod CIDR", "192.168.0.1", "10.244.1.0"),
+		Entry("the node hosting the gateway has no pod CIDR", "192.168.0.2", "192.168.0.2"),
+		Entry("the node hosting the gateway is not found", "192.168.0.3", "192.168.0.3"),
+	)
+})
@@ -16,6 +16,7 @@ package routeoperator
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"sync"
 	"syscall"
@@ -23,6 +24,7 @@ import (
 	"github.com/vishvananda/netlink"
 	corev1 "k8s.io/api/core/v1"
 	k8sApiErrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -142,7 +144,21 @@ func (ovc *OverlayController) addPeer(req ctrl.Request, pod *corev1.Pod) (bool,
 		IP:  ip,
 	}
 
-	added, err := ovc.vxlanDev.AddFDB(peer)
+	added, err := ovc.ensureFDBs(&peer)
+	if err != nil {
+		return added, err
+	}
+	ovc.vxlanPeers[req.String()] = &peer
+	ovc.vxlanNodes[pod.Spec.NodeName] = peerIP
+	ovc.podToNode[req.String()] = pod.Spec.NodeName
+	return added, nil
+}
+
+// ensureFDBs adds the fdb entries for the given peer on the current vxlan device.
+// It returns true if at least one entry does not exist and is added, false if all the entries do already exist,
+// and error if something goes wrong.
+func (ovc *OverlayController) ensureFDBs(peer *overlay.Neighbor) (bool, error) {
+	added, err := ovc.vxlanDev.AddFDB(*peer)
 	if err != nil {
 		return added, err
 	}
@@ -154,19 +170,36 @@ func (ovc *OverlayController) addPeer(req ctrl.Request, pod *corev1.Pod) (bool,
 	}
 	peerZero := overlay.Neighbor{
 		MAC: macZeros,
-		IP:  ip,
+		IP:  peer.IP,
 	}
 	addedZeros, err := ovc.vxlanDev.AddFDB(peerZero)
 	if err != nil {
 		return false, err
 	}
-	ovc.vxlanPeers[req.String()] = &peer
-	ovc.vxlanNodes[pod.Spec.NodeName] = peerIP
-	ovc.podToNode[req.String()] = pod.Spec.NodeName
-	if added || addedZeros {
-		return true, nil
+	return added || addedZeros, nil
+}
+
+// CheckDrift re-applies the fdb entries of the known peers which are missing from the vxlan device (e.g., because
+// removed by a third party), and returns the number of peers whose entries have been restored.
+// It implements the DriftChecker interface.
+func (ovc *OverlayController) CheckDrift(_ context.Context) (int, error) {
+	ovc.nodesLock.Lock()
+	defer ovc.nodesLock.Unlock()
+
+	var corrected int
+	var errs []error
+	for key, peer := range ovc.vxlanPeers {
+		added, err := ovc.ensureFDBs(peer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to restore the fdb entries for peer {%s}: %w", key, err))
+			continue
+		}
+		if added {
+			klog.Warningf("fdb entries for peer {%s} with IP address {%s} drifted from the desired state, restored", key, peer.IP.String())
+			corrected++
+		}
 	}
-	return false, nil
+	return corrected, utilerrors.NewAggregate(errs)
 }
 
 // delPeer for a given pod it removes all the fdb entries for the current peer on the vxlan device.
@@ -24,6 +24,7 @@ import (
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/vishvananda/netlink"
 	k8sApiErrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -70,7 +71,7 @@ func NewRouteController(podIP string, vxlanDevice *overlay.VxlanDevice, router l
 // +kubebuilder:rbac:groups=net.liqo.io,resources=tunnelendpoints,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=net.liqo.io,resources=tunnelendpoints/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
-// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 // role
 // +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=secrets,verbs=create;update;patch;get;list;watch;delete
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=update;patch;get;list;watch
@@ -80,8 +81,7 @@ func NewRouteController(podIP string, vxlanDevice *overlay.VxlanDevice, router l
 func (rc *RouteController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	tep := new(netv1alpha1.TunnelEndpoint)
 	var err error
-	// Name of our finalizer set on every tep instance processed by the operator.
-	routeOperatorFinalizer := strings.Join([]string{liqoconst.LiqoRouteOperatorName, rc.podIP, "net.liqo.io"}, ".")
+	routeOperatorFinalizer := rc.finalizer()
 	if err = rc.Get(ctx, req.NamespacedName, tep); err != nil && !k8sApiErrors.IsNotFound(err) {
 		klog.Errorf("unable to fetch resource {%s} :%v", req.String(), err)
 		return result, err
@@ -159,6 +159,42 @@ func (rc *RouteController) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	return result, nil
 }
 
+// CheckDrift re-applies the routes and policy routing rules towards the remote clusters which are missing from the kernel
+// (e.g., because flushed by a third party), and returns the number of remote clusters whose configuration has been restored.
+// It implements the DriftChecker interface.
+func (rc *RouteController) CheckDrift(ctx context.Context) (int, error) {
+	var teps netv1alpha1.TunnelEndpointList
+	if err := rc.List(ctx, &teps); err != nil {
+		return 0, err
+	}
+
+	var corrected int
+	var errs []error
+	for i := range teps.Items {
+		tep := &teps.Items[i]
+		// Only the resources already processed, and not being deleted, are considered, to prevent races with the reconciliation.
+		if tep.Status.GatewayIP == "" || !tep.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(tep, rc.finalizer()) {
+			continue
+		}
+		added, err := rc.EnsureRoutesPerCluster(tep)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if added {
+			klog.Warningf("%s -> routes towards the remote cluster drifted from the desired state, restored", tep.Spec.ClusterIdentity)
+			rc.Event(tep, "Warning", "Drift", "routes drifted from the desired state and have been restored")
+			corrected++
+		}
+	}
+	return corrected, utilerrors.NewAggregate(errs)
+}
+
+// finalizer returns the name of the finalizer set on every tep instance processed by the operator.
+func (rc *RouteController) finalizer() string {
+	return strings.Join([]string{liqoconst.LiqoRouteOperatorName, rc.podIP, "net.liqo.io"}, ".")
+}
+
 // ConfigureFirewall launches a long-running go routine that ensures the firewall configuration.
 func (rc *RouteController) ConfigureFirewall() error {
 	iptHandler, err := iptables.New()
@@ -23,6 +23,9 @@ import (
 	"golang.org/x/sys/unix"
 	corev1 "k8s.io/api/core/v1"
 	k8sApiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -43,6 +46,7 @@ type SymmetricRoutingController struct {
 	routingTableID int
 	nodesLock      *sync.RWMutex
 	vxlanNodes     map[string]string
+	routesLock     sync.Mutex
 	routes         map[string]string
 }
 
@@ -131,6 +135,8 @@ func (src *SymmetricRoutingController) addRoute(req ctrl.Request, p *corev1.Pod)
 	if err != nil {
 		return added, err
 	}
+	src.routesLock.Lock()
+	defer src.routesLock.Unlock()
 	src.routes[req.String()] = dstNet
 	return added, err
 }
@@ -139,6 +145,8 @@ func (src *SymmetricRoutingController) addRoute(req ctrl.Request, p *corev1.Pod)
 // and is removed. False if the route does not exist. An error if something goes
 // wrong.
 func (src *SymmetricRoutingController) delRoute(req ctrl.Request) (bool, error) {
+	src.routesLock.Lock()
+	defer src.routesLock.Unlock()
 	dstNet, ok := src.routes[req.String()]
 	if !ok {
 		return false, nil
@@ -151,6 +159,47 @@ func (src *SymmetricRoutingController) delRoute(req ctrl.Request) (bool, error)
 	return deleted, nil
 }
 
+// CheckDrift re-applies the routes towards the known pods which are missing from the routing table (e.g., because
+// flushed by a third party), and returns the number of restored routes.
+// It implements the DriftChecker interface.
+func (src *SymmetricRoutingController) CheckDrift(ctx context.Context) (int, error) {
+	src.routesLock.Lock()
+	keys := make([]string, 0, len(src.routes))
+	for key := range src.routes {
+		keys = append(keys, key)
+	}
+	src.routesLock.Unlock()
+
+	var corrected int
+	var errs []error
+	for _, key := range keys {
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+		var p corev1.Pod
+		if err := src.Get(ctx, req.NamespacedName, &p); err != nil {
+			// Pods no longer existing are handled by the reconciliation.
+			if !k8sApiErrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		added, err := src.addRoute(req, &p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to restore the route for pod {%s}: %w", key, err))
+			continue
+		}
+		if added {
+			klog.Warningf("route for pod {%s} with IP address {%s} drifted from the desired state, restored", key, p.Status.PodIP)
+			corrected++
+		}
+	}
+	return corrected, utilerrors.NewAggregate(errs)
+}
+
 // SetupWithManager used to set up the controller with a given manager.
 func (src *SymmetricRoutingController) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -215,6 +215,53 @@ var _ = Describe("SymmetricRoutingOperator", func() {
 		})
 	})
 
+	Describe("testing CheckDrift function", func() {
+		BeforeEach(func() {
+			srcTestPod.Name = "drift-route"
+			srcTestPod.Spec.NodeName = srcNodeName
+			srcReq.Name = "drift-route"
+			Eventually(func() error { return k8sClient.Create(context.TODO(), srcTestPod) }).Should(BeNil())
+			newPod := &corev1.Pod{}
+			Eventually(func() error { return k8sClient.Get(context.TODO(), srcReq.NamespacedName, newPod) }).Should(BeNil())
+			newPod.Status.PodIP = "10.1.12.1"
+			Eventually(func() error { return k8sClient.Status().Update(context.TODO(), newPod) }).Should(BeNil())
+			Eventually(func() error {
+				if err := k8sClient.Get(context.TODO(), srcReq.NamespacedName, newPod); err != nil {
+					return err
+				}
+				if newPod.Status.PodIP != "10.1.12.1" {
+					return fmt.Errorf("pod ip has not been updated yet on the testing api-server")
+				}
+				return nil
+			}).Should(BeNil())
+			Eventually(func() error { _, err := src.Reconcile(context.TODO(), srcReq); return err }).Should(BeNil())
+		})
+
+		Context("when the route is still configured", func() {
+			It("should not restore anything", func() {
+				corrected, err := src.CheckDrift(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(corrected).To(BeZero())
+			})
+		})
+
+		Context("when the route has been removed by a third party", func() {
+			It("should restore the route", func() {
+				_, dstNet, err := net.ParseCIDR("10.1.12.1/32")
+				Expect(err).To(BeNil())
+				filter := &netlink.Route{Table: srcRoutingTableID, Dst: dstNet}
+				Expect(netlink.RouteDel(filter)).To(Succeed())
+
+				corrected, err := src.CheckDrift(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(corrected).To(Equal(1))
+				routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_DST)
+				Expect(err).Should(BeNil())
+				Expect(routes).To(HaveLen(1))
+			})
+		})
+	})
+
 	Describe("testing addRoute function", func() {
 		Context("when ip of the node where the pod runs has not been set", func() {
 			It("should return false and error", func() {
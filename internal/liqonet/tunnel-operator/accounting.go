@@ -0,0 +1,196 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunneloperator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	k8s "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/iptables"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+var (
+	// namespaceTransmittedBytes is the metric that counts the number of bytes transmitted by a given namespace to a given peer.
+	namespaceTransmittedBytes = prometheus.NewDesc(
+		"liqo_namespace_transmit_bytes_total",
+		"Number of bytes transmitted by the pods of a given namespace to a given peer.",
+		[]string{"namespace", "cluster_id"},
+		nil,
+	)
+
+	// namespaceTransmittedPackets is the metric that counts the number of packets transmitted by a given namespace to a given peer.
+	namespaceTransmittedPackets = prometheus.NewDesc(
+		"liqo_namespace_transmit_packets_total",
+		"Number of packets transmitted by the pods of a given namespace to a given peer.",
+		[]string{"namespace", "cluster_id"},
+		nil,
+	)
+)
+
+// TrafficAccountant accounts the traffic generated by the local pods towards each remote cluster, per source namespace.
+// The counters are maintained by iptables in the gateway network namespace, and exposed as prometheus metrics.
+type TrafficAccountant struct {
+	client.Client
+	iptables.IPTHandler
+	gatewayNetns ns.NetNS
+	interval     time.Duration
+
+	factory informers.SharedInformerFactory
+	pods    corev1listers.PodLister
+
+	mutex sync.Mutex
+	// clusterCIDRs associates each remote cluster ID to the CIDRs it is reachable through from the home cluster.
+	clusterCIDRs map[string][]string
+}
+
+// NewTrafficAccountant returns a new TrafficAccountant, which reconfigures the accounting rules with the given interval.
+// Pods are retrieved through a dedicated informer, since the cache of the manager is restricted to the gateway pods.
+func NewTrafficAccountant(cl client.Client, clientset k8s.Interface, gatewayNetns ns.NetNS, interval time.Duration) (*TrafficAccountant, error) {
+	iptHandler, err := iptables.NewIPTHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithTweakListOptions(localRunningPodsFilter))
+	pods := factory.Core().V1().Pods()
+	// Instantiate the informer, to be started by the factory.
+	pods.Informer()
+
+	return &TrafficAccountant{
+		Client:       cl,
+		IPTHandler:   iptHandler,
+		gatewayNetns: gatewayNetns,
+		interval:     interval,
+		factory:      factory,
+		pods:         pods.Lister(),
+	}, nil
+}
+
+// Start periodically configures the accounting rules, until the given context is canceled.
+// It implements the manager.Runnable interface.
+func (ta *TrafficAccountant) Start(ctx context.Context) error {
+	ta.factory.Start(ctx.Done())
+	ta.factory.WaitForCacheSync(ctx.Done())
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := ta.sync(ctx); err != nil {
+			klog.Errorf("Failed to configure the traffic accounting rules: %v", err)
+		}
+	}, ta.interval)
+	return nil
+}
+
+// sync configures the accounting rules according to the current local pods and remote clusters.
+func (ta *TrafficAccountant) sync(ctx context.Context) error {
+	var teps netv1alpha1.TunnelEndpointList
+	if err := ta.List(ctx, &teps); err != nil {
+		return err
+	}
+	clusterCIDRs := make(map[string][]string, len(teps.Items))
+	for i := range teps.Items {
+		clusterCIDRs[teps.Items[i].Spec.ClusterIdentity.ClusterID] = getRemoteCIDRs(&teps.Items[i])
+	}
+
+	pods, err := ta.pods.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	podNamespaces := make(map[string]string, len(pods))
+	for _, pod := range pods {
+		// Pods in the host network are not distinguishable, and they are not accounted.
+		if pod.Spec.HostNetwork || pod.Status.PodIP == "" {
+			continue
+		}
+		podNamespaces[pod.Status.PodIP] = pod.GetNamespace()
+	}
+
+	err = ta.gatewayNetns.Do(func(netNamespace ns.NetNS) error {
+		return ta.EnsureAccountingRules(podNamespaces, clusterCIDRs)
+	})
+	if err != nil {
+		return err
+	}
+
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+	ta.clusterCIDRs = clusterCIDRs
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (ta *TrafficAccountant) Describe(ch chan<- *prometheus.Desc) {
+	ch <- namespaceTransmittedBytes
+	ch <- namespaceTransmittedPackets
+}
+
+// Collect implements prometheus.Collector.
+func (ta *TrafficAccountant) Collect(ch chan<- prometheus.Metric) {
+	ta.mutex.Lock()
+	clusterCIDRs := ta.clusterCIDRs
+	ta.mutex.Unlock()
+
+	var counters map[iptables.TrafficKey]iptables.TrafficCounter
+	err := ta.gatewayNetns.Do(func(netNamespace ns.NetNS) (err error) {
+		counters, err = ta.GetAccountingCounters(clusterCIDRs)
+		return err
+	})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(namespaceTransmittedBytes, err)
+		ch <- prometheus.NewInvalidMetric(namespaceTransmittedPackets, err)
+		return
+	}
+
+	for key, counter := range counters {
+		ch <- prometheus.MustNewConstMetric(namespaceTransmittedBytes, prometheus.CounterValue,
+			float64(counter.Bytes), key.Namespace, key.ClusterID)
+		ch <- prometheus.MustNewConstMetric(namespaceTransmittedPackets, prometheus.CounterValue,
+			float64(counter.Packets), key.Namespace, key.ClusterID)
+	}
+}
+
+// getRemoteCIDRs returns the CIDRs the given remote cluster is reachable through from the home cluster.
+func getRemoteCIDRs(tep *netv1alpha1.TunnelEndpoint) []string {
+	_, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, remoteExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+	return append([]string{remotePodCIDR, remoteExternalCIDR}, remoteExportedCIDRs...)
+}
+
+// localRunningPodsFilter filters the running pods, excluding the ones offloaded to remote clusters.
+func localRunningPodsFilter(options *metav1.ListOptions) {
+	req, err := labels.NewRequirement(liqoconst.LocalPodLabelKey, selection.NotEquals, []string{liqoconst.LocalPodLabelValue})
+	utilruntime.Must(err)
+	options.LabelSelector = labels.NewSelector().Add(*req).String()
+	options.FieldSelector = fields.OneTermEqualSelector("status.phase", string(corev1.PodRunning)).String()
+}
@@ -0,0 +1,83 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunneloperator
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/endpointresolver"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get
+
+// EndpointDetector periodically resolves the address the local gateway is reachable at from the remote clusters, and
+// annotates the gateway service accordingly. The network manager, in turn, updates the endpoint advertised to the remote
+// clusters through the NetworkConfigs, and the corresponding tunnels are reconnected.
+type EndpointDetector struct {
+	client.Client
+	resolver endpointresolver.Resolver
+	podIP    string
+	interval time.Duration
+}
+
+// NewEndpointDetector returns a new EndpointDetector, which resolves the address with the given interval.
+func NewEndpointDetector(cl client.Client, resolver endpointresolver.Resolver, podIP string, interval time.Duration) *EndpointDetector {
+	return &EndpointDetector{Client: cl, resolver: resolver, podIP: podIP, interval: interval}
+}
+
+// Start periodically resolves the address of the local gateway, until the given context is canceled.
+// It implements the manager.Runnable interface.
+func (ed *EndpointDetector) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := ed.sync(ctx); err != nil {
+			klog.Errorf("Failed to detect the gateway endpoint address: %v", err)
+		}
+	}, ed.interval)
+	return nil
+}
+
+// sync resolves the address of the local gateway, and updates the gateway service in case it changed. The address is
+// resolved only if the service refers to the current replica, as otherwise it would not be reachable through it.
+func (ed *EndpointDetector) sync(ctx context.Context) error {
+	svc, err := getGatewayService(ctx, ed.Client)
+	if err != nil {
+		return err
+	}
+	if svc.GetAnnotations()[serviceAnnotationKey] != ed.podIP {
+		klog.V(4).Infof("Gateway service %q does not refer to the current replica, skipping the endpoint detection", klog.KObj(svc))
+		return nil
+	}
+
+	address, err := ed.resolver.Resolve(ctx)
+	if err != nil {
+		// The previously detected address (if any) is preserved, as likely still valid.
+		return err
+	}
+
+	if liqonetutils.AddAnnotationToObj(svc, liqoconst.DetectedAddressAnnotation, address) {
+		if err := ed.Update(ctx, svc); err != nil {
+			return err
+		}
+		klog.Infof("Gateway endpoint address detected: %s", address)
+	}
+	return nil
+}
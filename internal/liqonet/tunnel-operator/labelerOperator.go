@@ -25,7 +25,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
 	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+	"github.com/liqotech/liqo/pkg/utils/slice"
 )
 
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update
@@ -61,13 +63,16 @@ var (
 type LabelerController struct {
 	client.Client
 	PodIP string
+	// sharder retrieves the active replicas of the gateway (nil if not in active-active mode).
+	sharder *sharding.Sharder
 }
 
 // NewLabelerController  returns a new controller ready to be setup and started with the controller manager.
-func NewLabelerController(podIP string, cl client.Client) *LabelerController {
+func NewLabelerController(podIP string, cl client.Client, sharder *sharding.Sharder) *LabelerController {
 	return &LabelerController{
-		Client: cl,
-		PodIP:  podIP,
+		Client:  cl,
+		PodIP:   podIP,
+		sharder: sharder,
 	}
 }
 
@@ -76,6 +81,10 @@ func NewLabelerController(podIP string, cl client.Client) *LabelerController {
 // active replica of the gateway. It ensures that the label "net.liqo.io/gateway=active" is present.
 // If the pod is not the current one, we make sure that the pod has the label "net.liqo.io/gateway=standby".
 func (lbc *LabelerController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if lbc.sharder != nil {
+		return ctrl.Result{}, lbc.reconcileActiveActive(ctx, req)
+	}
+
 	pod := new(corev1.Pod)
 	err := lbc.Get(ctx, req.NamespacedName, pod)
 	if err != nil {
@@ -93,7 +102,7 @@ func (lbc *LabelerController) Reconcile(ctx context.Context, req ctrl.Request) (
 			klog.Infof("successfully updated label {%s: %s} for pod {%s}",
 				gatewayLabelKey, gatewayStatusActive, req.String())
 		}
-		if err := lbc.annotateGatewayService(ctx); err != nil {
+		if err := lbc.annotateGatewayService(ctx, nil); err != nil {
 			// Do not log here, already done in annotateGatewayService.
 			return ctrl.Result{}, err
 		}
@@ -114,26 +123,46 @@ func (lbc *LabelerController) Reconcile(ctx context.Context, req ctrl.Request) (
 	return ctrl.Result{}, nil
 }
 
-func (lbc *LabelerController) annotateGatewayService(ctx context.Context) error {
-	const expectedNumOfServices = 1
-	svcList := new(corev1.ServiceList)
-	labelsSelector := client.MatchingLabels{
-		podComponentLabelKey: podComponentLabelValue,
-		podNameLabelKey:      podNameLabelValue,
+// reconcileActiveActive handles the gateway pods in active-active mode. Each replica labels its own pod as active,
+// without demoting the other ones, while the gateway service is annotated with the IP of one of the active replicas.
+func (lbc *LabelerController) reconcileActiveActive(ctx context.Context, req ctrl.Request) error {
+	pod := new(corev1.Pod)
+	err := lbc.Get(ctx, req.NamespacedName, pod)
+	if client.IgnoreNotFound(err) != nil {
+		klog.Errorf("an error occurred while getting pod {%s}: %v", req.NamespacedName, err)
+		return err
 	}
-	err := lbc.List(ctx, svcList, labelsSelector)
+	if err == nil && lbc.PodIP == pod.Status.PodIP && liqonetutils.AddLabelToObj(pod, gatewayLabelKey, gatewayStatusActive) {
+		if err := lbc.Update(ctx, pod); err != nil {
+			klog.Errorf("an error occurred while updating value of label {%s} to {%s} for pod {%s}: %v",
+				gatewayLabelKey, gatewayStatusActive, req.String(), err)
+			return err
+		}
+		klog.Infof("successfully updated label {%s: %s} for pod {%s}",
+			gatewayLabelKey, gatewayStatusActive, req.String())
+	}
+
+	members, err := lbc.sharder.Members(ctx)
 	if err != nil {
+		klog.Errorf("an error occurred while retrieving the active gateway replicas: %v", err)
 		return err
 	}
-	if len(svcList.Items) != expectedNumOfServices {
-		klog.Errorf("an error occurred while getting gateway service: expected number of services for the gateway is {%d}, "+
-			"instead we found {%d}", expectedNumOfServices, len(svcList.Items))
-		return fmt.Errorf("expected number of services for the gateway is {%d}, instead we found {%d}",
-			expectedNumOfServices, len(svcList.Items))
+	return lbc.annotateGatewayService(ctx, members)
+}
+
+// annotateGatewayService annotates the gateway service with the IP of the current replica. In active-active mode
+// (i.e., members is not nil), the annotation is replaced only if it does not refer to an active replica, to prevent conflicts.
+func (lbc *LabelerController) annotateGatewayService(ctx context.Context, members []string) error {
+	svc, err := getGatewayService(ctx, lbc.Client)
+	if err != nil {
+		return err
+	}
+	if members != nil && slice.ContainsString(members, svc.GetAnnotations()[serviceAnnotationKey]) {
+		return nil
 	}
-	// We come here only if one service has been found.
-	svc := &svcList.Items[0]
 	if liqonetutils.AddAnnotationToObj(svc, serviceAnnotationKey, lbc.PodIP) {
+		// The address detected by the previously advertised replica (if any) is preserved, as likely still valid (e.g., in
+		// case of a load balancer), until overwritten by the endpoint detector of the current replica.
 		if err := lbc.Update(ctx, svc); err != nil {
 			klog.Errorf("an error occurred while annotating gateway service {%s/%s}: %v",
 				svc.Namespace, svc.Name, serviceAnnotationKey, err)
@@ -145,6 +174,28 @@ func (lbc *LabelerController) annotateGatewayService(ctx context.Context) error
 	return nil
 }
 
+// getGatewayService returns the gateway service, which is expected to be unique.
+func getGatewayService(ctx context.Context, cl client.Client) (*corev1.Service, error) {
+	const expectedNumOfServices = 1
+	svcList := new(corev1.ServiceList)
+	labelsSelector := client.MatchingLabels{
+		podComponentLabelKey: podComponentLabelValue,
+		podNameLabelKey:      podNameLabelValue,
+	}
+	err := cl.List(ctx, svcList, labelsSelector)
+	if err != nil {
+		return nil, err
+	}
+	if len(svcList.Items) != expectedNumOfServices {
+		klog.Errorf("an error occurred while getting gateway service: expected number of services for the gateway is {%d}, "+
+			"instead we found {%d}", expectedNumOfServices, len(svcList.Items))
+		return nil, fmt.Errorf("expected number of services for the gateway is {%d}, instead we found {%d}",
+			expectedNumOfServices, len(svcList.Items))
+	}
+	// We come here only if one service has been found.
+	return &svcList.Items[0], nil
+}
+
 // SetupWithManager used to set up the controller with a given manager.
 func (lbc *LabelerController) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).For(&corev1.Pod{}).
@@ -137,7 +137,7 @@ var _ = Describe("LabelerOperator", func() {
 	Describe("testing NewOverlayOperator function", func() {
 		Context("when input parameters are correct", func() {
 			It("should return labeler controller ", func() {
-				lbc1 := NewLabelerController(labelerCurrentPodIP, k8sClient)
+				lbc1 := NewLabelerController(labelerCurrentPodIP, k8sClient, nil)
 				Expect(lbc1).ShouldNot(BeNil())
 			})
 		})
@@ -327,7 +327,7 @@ var _ = Describe("LabelerOperator", func() {
 			It("only one service exists, should annotate it", func() {
 				// Create the service.
 				Eventually(func() error { return k8sClient.Create(context.TODO(), labelerTestSvc) }).Should(BeNil())
-				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO()); return err }).Should(BeNil())
+				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO(), nil); return err }).Should(BeNil())
 				// Check that the service has been annotated
 				Eventually(func() error {
 					labelerReq.Name = labelerSvcName
@@ -350,7 +350,7 @@ var _ = Describe("LabelerOperator", func() {
 				})
 				// Create the service.
 				Eventually(func() error { return k8sClient.Create(context.TODO(), labelerTestSvc) }).Should(BeNil())
-				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO()); return err }).Should(BeNil())
+				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO(), nil); return err }).Should(BeNil())
 				// Check that the service has been annotated
 				Eventually(func() error {
 					labelerReq.Name = labelerSvcName
@@ -374,14 +374,14 @@ var _ = Describe("LabelerOperator", func() {
 				// Create a second service.
 				secondSvc.Name = "second-svc-test"
 				Eventually(func() error { return k8sClient.Create(context.TODO(), secondSvc) }).Should(BeNil())
-				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO()); return err }).
+				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO(), nil); return err }).
 					Should(MatchError("expected number of services for the gateway is {1}, instead we found {2}"))
 			})
 		})
 
 		Context("svc does not exist", func() {
 			It("should return error", func() {
-				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO()); return err }).
+				Eventually(func() error { err := lbc.annotateGatewayService(context.TODO(), nil); return err }).
 					Should(MatchError("expected number of services for the gateway is {1}, instead we found {0}"))
 			})
 		})
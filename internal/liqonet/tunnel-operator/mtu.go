@@ -0,0 +1,154 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunneloperator
+
+import (
+	"context"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	liqorouting "github.com/liqotech/liqo/pkg/liqonet/routing"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/mtu"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/resolver"
+	tunnelwg "github.com/liqotech/liqo/pkg/liqonet/tunnel/wireguard"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// pathMTU holds the MTU negotiated towards a remote cluster, along with the endpoint the path MTU has been discovered for.
+type pathMTU struct {
+	endpoint string
+	mtu      int
+}
+
+// negotiateMTU returns the MTU to be used for the traffic towards the given remote cluster, that is the configured one,
+// possibly lowered according to the path MTU towards the endpoint configured by the driver (which differs from the one
+// advertised in case of failover or rendezvous). The discovery is performed asynchronously at connect time (i.e., when the
+// configured endpoint changes), and the tunnelendpoint is enqueued again once completed.
+func (tc *TunnelController) negotiateMTU(tep *netv1alpha1.TunnelEndpoint, con *netv1alpha1.Connection) int {
+	if tc.mtuProber == nil {
+		return tc.mtu
+	}
+
+	endpoint := configuredEndpoint(tep, con)
+
+	tc.pathMTUsMutex.Lock()
+	defer tc.pathMTUsMutex.Unlock()
+
+	current, found := tc.pathMTUs[tep.Spec.ClusterIdentity.ClusterID]
+	if found && current.endpoint == endpoint {
+		return current.mtu
+	}
+
+	// Keep using the previous value (if any) until the discovery completes.
+	if current.mtu == 0 {
+		current.mtu = tc.mtu
+	}
+	tc.pathMTUs[tep.Spec.ClusterIdentity.ClusterID] = pathMTU{endpoint: endpoint, mtu: current.mtu}
+	go tc.discoverPathMTU(tep.DeepCopy(), endpoint)
+	return current.mtu
+}
+
+// configuredEndpoint returns the remote endpoint configured by the driver for the given connection, falling back to
+// the one advertised in the tunnelendpoint in case the driver does not report it.
+func configuredEndpoint(tep *netv1alpha1.TunnelEndpoint, con *netv1alpha1.Connection) string {
+	if con != nil {
+		if endpoint := con.PeerConfiguration[tunnelwg.EndpointIP]; endpoint != "" {
+			return endpoint
+		}
+	}
+	return tep.Spec.EndpointIP
+}
+
+// discoverPathMTU discovers the path MTU towards the given endpoint of the remote cluster, and stores the resulting MTU.
+// In case of errors (e.g., the remote endpoint does not reply to the probes), the configured MTU is used. Since the
+// probes are ICMP echo requests, this is always the case if the endpoint is exposed through a load balancer dropping them.
+func (tc *TunnelController) discoverPathMTU(tep *netv1alpha1.TunnelEndpoint, endpoint string) {
+	klog.Infof("%s -> discovering the path MTU towards the remote endpoint {%s}", tep.Spec.ClusterIdentity, endpoint)
+
+	negotiated := tc.mtu
+	address, err := resolver.Resolve(context.Background(), endpoint)
+	if err == nil {
+		var discovered int
+		overhead := tunnelOverhead(tep)
+		discovered, err = mtu.Discover(tc.mtuProber, address.IP, mtu.MinPathMTU, tc.mtu+overhead)
+		negotiated = discovered - overhead
+	}
+	if err != nil {
+		negotiated = tc.mtu
+		klog.Warningf("%s -> unable to discover the path MTU towards the remote endpoint {%s}, falling back to %d: %v",
+			tep.Spec.ClusterIdentity, endpoint, negotiated, err)
+		tc.Eventf(tep, "Warning", "Processing", "unable to discover the path MTU towards %s, falling back to %d "+
+			"(the ICMP echo requests may be dropped along the path, e.g., by load balancers): %v", endpoint, negotiated, err)
+	} else {
+		klog.Infof("%s -> negotiated MTU {%d} towards the remote endpoint {%s}", tep.Spec.ClusterIdentity, negotiated, endpoint)
+		tc.Eventf(tep, "Normal", "Processing", "negotiated MTU %d towards %s", negotiated, endpoint)
+	}
+
+	tc.pathMTUsMutex.Lock()
+	current, found := tc.pathMTUs[tep.Spec.ClusterIdentity.ClusterID]
+	if found && current.endpoint == endpoint {
+		tc.pathMTUs[tep.Spec.ClusterIdentity.ClusterID] = pathMTU{endpoint: current.endpoint, mtu: negotiated}
+	}
+	tc.pathMTUsMutex.Unlock()
+
+	tc.mtuEvents <- event.GenericEvent{Object: tep}
+}
+
+// forgetPathMTU removes the MTU negotiated towards the given remote cluster.
+func (tc *TunnelController) forgetPathMTU(clusterID string) {
+	tc.pathMTUsMutex.Lock()
+	defer tc.pathMTUsMutex.Unlock()
+	delete(tc.pathMTUs, clusterID)
+}
+
+// ensureRoutesMTU configures the MTU of the routes towards the given remote cluster, in case it is lower than the one
+// of the tunnel interface. It must be executed in the gateway network namespace.
+func (tc *TunnelController) ensureRoutesMTU(tep *netv1alpha1.TunnelEndpoint, negotiated int) error {
+	if negotiated >= tc.mtu {
+		negotiated = 0
+	}
+
+	_, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, remoteExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+	link, err := tc.tunnelLink(tep)
+	if err != nil {
+		return err
+	}
+	linkIndex := link.Attrs().Index
+	for _, cidr := range append([]string{remotePodCIDR, remoteExternalCIDR}, remoteExportedCIDRs...) {
+		updated, err := liqorouting.SetRouteMTU(cidr, linkIndex, unix.RT_TABLE_MAIN, negotiated)
+		if err != nil {
+			klog.Errorf("%s -> unable to configure the MTU of the route for destination {%s}: %v", tep.Spec.ClusterIdentity, cidr, err)
+			return err
+		}
+		if updated {
+			klog.Infof("%s -> MTU of the route for destination {%s} set to {%d}", tep.Spec.ClusterIdentity, cidr, negotiated)
+		}
+	}
+	return nil
+}
+
+// tunnelOverhead returns the overhead introduced by the encapsulation of the tunnel towards the given remote cluster.
+func tunnelOverhead(tep *netv1alpha1.TunnelEndpoint) int {
+	if tep.Spec.BackendType == liqoconst.GreDriverName {
+		return liqoconst.GreOverhead
+	}
+	return liqoconst.WgOverhead
+}
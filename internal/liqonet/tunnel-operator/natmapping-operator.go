@@ -20,12 +20,17 @@ import (
 	"sync"
 
 	"github.com/containernetworking/plugins/pkg/ns"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	"github.com/liqotech/liqo/pkg/liqonet/ebpfnat"
 	"github.com/liqotech/liqo/pkg/liqonet/iptables"
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
 )
 
 // NatMappingController reconciles a NatMapping object.
@@ -35,12 +40,16 @@ type NatMappingController struct {
 	readyClustersMutex *sync.Mutex
 	readyClusters      map[string]struct{}
 	gatewayNetns       ns.NetNS
+	// sharder determines the remote clusters owned by the current replica (nil if not in active-active mode).
+	sharder *sharding.Sharder
+	// statelessNAT performs the stateless NAT translations through eBPF, in place of iptables (nil if disabled).
+	statelessNAT *ebpfnat.NAT
 }
 
 //+kubebuilder:rbac:groups=net.liqo.io,resources=natmappings,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile function handles requests made on NatMapping resource
-// by guaranteeing the proper set of DNAT rules are updated.
+// by guaranteeing the proper set of DNAT rules (or eBPF NAT translations) are updated.
 func (npc *NatMappingController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var nm netv1alpha1.NatMapping
 
@@ -50,6 +59,14 @@ func (npc *NatMappingController) Reconcile(ctx context.Context, req ctrl.Request
 	// There's no need of a pre-delete logic since IPTables rules for cluster are removed by the
 	// tunnel-operator after the un-peer.
 
+	// In active-active mode, the rules are configured only by the replica owning the remote cluster.
+	if npc.sharder != nil {
+		local, err := npc.sharder.IsLocal(ctx, nm.Spec.ClusterID)
+		if err != nil || !local {
+			return result, err
+		}
+	}
+
 	// The following logic has to be executed in the custom network namespace,
 	// and not on the root namespace. Therefore it must be defined in a closure
 	// and then used as parameter of method Do of netNs
@@ -64,6 +81,11 @@ func (npc *NatMappingController) Reconcile(ctx context.Context, req ctrl.Request
 			return fmt.Errorf("unable to ensure prerouting rules for cluster {%s}: %w",
 				nm.Spec.ClusterID, err)
 		}
+		if npc.statelessNAT != nil {
+			if err := npc.statelessNAT.EnsureEndpointMappings(nm.Spec.ClusterID, nm.Spec.ClusterMappings); err != nil {
+				return fmt.Errorf("unable to ensure eBPF NAT translations for cluster {%s}: %w", nm.Spec.ClusterID, err)
+			}
+		}
 		return nil
 	}); err != nil {
 		klog.Error(err)
@@ -74,23 +96,33 @@ func (npc *NatMappingController) Reconcile(ctx context.Context, req ctrl.Request
 
 // SetupWithManager sets up the controller with the Manager.
 func (npc *NatMappingController) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&netv1alpha1.NatMapping{}).
-		Complete(npc)
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
+		For(&netv1alpha1.NatMapping{})
+	// In active-active mode, the natmappings reassigned when the set of replicas changes are reprocessed, to rebalance the shards.
+	if npc.sharder != nil {
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: &corev1.Pod{}},
+			enqueueReassigned(npc.Client, npc.sharder, &netv1alpha1.NatMappingList{}, func(obj client.Object) string {
+				return obj.(*netv1alpha1.NatMapping).Spec.ClusterID
+			}), builder.WithPredicates(gatewayPodsPredicate(npc.sharder.Namespace())))
+	}
+	return controllerBuilder.Complete(npc)
 }
 
 // NewNatMappingController returns a NAT mapping controller istance.
 func NewNatMappingController(cl client.Client, readyClustersMutex *sync.Mutex,
-	readyClusters map[string]struct{}, gatewayNetns ns.NetNS) (*NatMappingController, error) {
+	readyClusters map[string]struct{}, gatewayNetns ns.NetNS, sharder *sharding.Sharder, statelessNAT *ebpfnat.NAT) (*NatMappingController, error) {
 	iptablesHandler, err := iptables.NewIPTHandler()
 	if err != nil {
 		return nil, err
 	}
+	iptablesHandler.StatelessNATOffloaded = statelessNAT != nil
 	return &NatMappingController{
 		Client:             cl,
 		IPTHandler:         iptablesHandler,
 		readyClustersMutex: readyClustersMutex,
 		readyClusters:      readyClusters,
 		gatewayNetns:       gatewayNetns,
+		sharder:            sharder,
+		statelessNAT:       statelessNAT,
 	}, nil
 }
@@ -0,0 +1,107 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunneloperator
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
+)
+
+// clusterIDGetter returns the identifier of the remote cluster the given object refers to.
+type clusterIDGetter func(obj client.Object) string
+
+// gatewayPodsPredicate returns a predicate selecting the gateway pods hosted in the given namespace.
+func gatewayPodsPredicate(namespace string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == namespace &&
+			obj.GetLabels()[podComponentLabelKey] == podComponentLabelValue && obj.GetLabels()[podNameLabelKey] == podNameLabelValue
+	})
+}
+
+// changedMembers returns the identifiers of the gateway replicas joining or leaving the set of active members
+// as a consequence of the given pod transition (either pod might be nil, in case of creation or deletion).
+func changedMembers(previous, current *corev1.Pod) []string {
+	members := func(pod *corev1.Pod) sets.String {
+		if pod == nil || !sharding.IsActiveMember(pod) {
+			return sets.NewString()
+		}
+		return sets.NewString(pod.Status.PodIP)
+	}
+
+	// The symmetric difference is computed, so that no members are returned if nothing changed.
+	before, after := members(previous), members(current)
+	return before.Difference(after).Union(after.Difference(before)).List()
+}
+
+// enqueueReassigned returns an event handler enqueuing the objects of the given list type referring to the remote clusters
+// reassigned as a consequence of a gateway replica joining or leaving the set of active members. It is leveraged in
+// active-active mode to reprocess only the objects whose owner changed when the set of gateway replicas changes.
+func enqueueReassigned(cl client.Client, sharder *sharding.Sharder, list client.ObjectList, getter clusterIDGetter) handler.EventHandler {
+	enqueue := func(rli workqueue.RateLimitingInterface, previous, current client.Object) {
+		previousPod, _ := previous.(*corev1.Pod)
+		currentPod, _ := current.(*corev1.Pod)
+		members := changedMembers(previousPod, currentPod)
+		if len(members) == 0 {
+			return
+		}
+
+		ctx := context.Background()
+		objects := list.DeepCopyObject().(client.ObjectList)
+		if err := cl.List(ctx, objects); err != nil {
+			klog.Errorf("an error occurred while listing objects to be reprocessed: %v", err)
+			return
+		}
+
+		if err := meta.EachListItem(objects, func(obj runtime.Object) error {
+			o, ok := obj.(client.Object)
+			if !ok {
+				return nil
+			}
+			for _, member := range members {
+				owned, err := sharder.IsOwnedBy(ctx, getter(o), member)
+				if err != nil {
+					return err
+				}
+				if owned {
+					rli.Add(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+					return nil
+				}
+			}
+			return nil
+		}); err != nil {
+			klog.Errorf("an error occurred while enqueuing objects to be reprocessed: %v", err)
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc:  func(e event.CreateEvent, rli workqueue.RateLimitingInterface) { enqueue(rli, nil, e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent, rli workqueue.RateLimitingInterface) { enqueue(rli, e.ObjectOld, e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent, rli workqueue.RateLimitingInterface) { enqueue(rli, e.Object, nil) },
+		GenericFunc: func(e event.GenericEvent, rli workqueue.RateLimitingInterface) { enqueue(rli, nil, e.Object) },
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunneloperator
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/liqotech/liqo/pkg/consts"
+)
+
+var _ = Describe("Sharding", func() {
+	forgePod := func(namespace, ip, status string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway", Namespace: namespace, Labels: map[string]string{
+				podComponentLabelKey: podComponentLabelValue, podNameLabelKey: podNameLabelValue, consts.GatewayPodLabelKey: status,
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+		}
+	}
+
+	Describe("The gatewayPodsPredicate function", func() {
+		It("should select only the gateway pods in the given namespace", func() {
+			pred := gatewayPodsPredicate("liqo")
+			Expect(pred.Create(event.CreateEvent{Object: forgePod("liqo", "10.0.0.1", consts.GatewayActivePodLabelValue)})).To(BeTrue())
+			Expect(pred.Create(event.CreateEvent{Object: forgePod("other", "10.0.0.1", consts.GatewayActivePodLabelValue)})).To(BeFalse())
+
+			pod := forgePod("liqo", "10.0.0.1", consts.GatewayActivePodLabelValue)
+			delete(pod.Labels, podNameLabelKey)
+			Expect(pred.Create(event.CreateEvent{Object: pod})).To(BeFalse())
+		})
+	})
+
+	Describe("The changedMembers function", func() {
+		active := forgePod("liqo", "10.0.0.1", consts.GatewayActivePodLabelValue)
+		standby := forgePod("liqo", "10.0.0.1", gatewayStatusStandby)
+
+		It("should return the members joining", func() {
+			Expect(changedMembers(nil, active)).To(ConsistOf("10.0.0.1"))
+			Expect(changedMembers(standby, active)).To(ConsistOf("10.0.0.1"))
+		})
+
+		It("should return the members leaving", func() {
+			Expect(changedMembers(active, nil)).To(ConsistOf("10.0.0.1"))
+			Expect(changedMembers(active, standby)).To(ConsistOf("10.0.0.1"))
+		})
+
+		It("should return both members if the IP changed", func() {
+			Expect(changedMembers(active, forgePod("liqo", "10.0.0.2", consts.GatewayActivePodLabelValue))).To(ConsistOf("10.0.0.1", "10.0.0.2"))
+		})
+
+		It("should return no members if the membership did not change", func() {
+			Expect(changedMembers(active, active.DeepCopy())).To(BeEmpty())
+			Expect(changedMembers(standby, standby.DeepCopy())).To(BeEmpty())
+			Expect(changedMembers(nil, standby)).To(BeEmpty())
+		})
+	})
+})
@@ -16,6 +16,7 @@ package tunneloperator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -27,23 +28,33 @@ import (
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
 	k8sApiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/ebpfnat"
 	"github.com/liqotech/liqo/pkg/liqonet/iptables"
 	liqonetns "github.com/liqotech/liqo/pkg/liqonet/netns"
+	"github.com/liqotech/liqo/pkg/liqonet/qos"
 	liqorouting "github.com/liqotech/liqo/pkg/liqonet/routing"
+	"github.com/liqotech/liqo/pkg/liqonet/sharding"
 	"github.com/liqotech/liqo/pkg/liqonet/tunnel"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/mtu"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/prober"
 	tunnelwg "github.com/liqotech/liqo/pkg/liqonet/tunnel/wireguard"
 	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
 )
@@ -70,8 +81,34 @@ type TunnelController struct {
 	gatewayVeth        net.Interface
 	readyClustersMutex *sync.Mutex
 	readyClusters      map[string]struct{}
+	// prober measures the latency towards the remote gateways (nil if disabled).
+	prober *prober.Prober
+	// sharder determines the remote clusters owned by the current replica (nil if not in active-active mode).
+	sharder *sharding.Sharder
+	// mtu is the configured MTU, possibly lowered for each remote cluster according to the discovered path MTU.
+	mtu int
+	// mtuProber probes the path towards the remote endpoints to discover the path MTU (nil if disabled).
+	mtuProber     mtu.Prober
+	pathMTUsMutex sync.Mutex
+	pathMTUs      map[string]pathMTU
+	mtuEvents     chan event.GenericEvent
+	// statelessNAT performs the stateless NAT translations through eBPF, in place of iptables (nil if disabled).
+	statelessNAT *ebpfnat.NAT
+	// shaper enforces the bandwidth limits and the priority of the traffic exchanged with the remote clusters.
+	shaper *qos.Shaper
 }
 
+// latencyUpdatePeriod is the period the latency measured by the prober is updated in the TunnelEndpoint status.
+const latencyUpdatePeriod = 30 * time.Second
+
+// traversalUpdatePeriod is the period the connections established through a rendezvous server are reprocessed,
+// to progress with the NAT traversal (e.g., attempting a direct connection through hole punching).
+const traversalUpdatePeriod = 5 * time.Second
+
+// failoverCheckPeriod is the period the connections towards remote clusters advertising multiple endpoints are reprocessed,
+// to fail over to the next endpoint in case the current one stops handshaking.
+const failoverCheckPeriod = 10 * time.Second
+
 // cluster-role
 // +kubebuilder:rbac:groups=net.liqo.io,resources=tunnelendpoints,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=net.liqo.io,resources=tunnelendpoints/status,verbs=get;update;patch
@@ -79,10 +116,13 @@ type TunnelController struct {
 // role
 // +kubebuilder:rbac:groups=coordination.k8s.io,namespace="do-not-care",resources=leases,verbs=get;create;update
 // +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,namespace="do-not-care",resources=configmaps,verbs=get;list
 
 // NewTunnelController instantiates and initializes the tunnel controller.
 func NewTunnelController(podIP, namespace string, er record.EventRecorder, k8sClient k8s.Interface, cl client.Client,
-	readyClustersMutex *sync.Mutex, readyClusters map[string]struct{}, gatewayNetns, hostNetns ns.NetNS, mtu, port int) (*TunnelController, error) {
+	readyClustersMutex *sync.Mutex, readyClusters map[string]struct{}, gatewayNetns, hostNetns ns.NetNS, mtu, port int,
+	latencyProber *prober.Prober, sharder *sharding.Sharder, mtuProber mtu.Prober, statelessNAT *ebpfnat.NAT,
+	shaper *qos.Shaper) (*TunnelController, error) {
 	tunnelEndpointFinalizer := liqoconst.LiqoGatewayOperatorName + "." + liqoconst.FinalizersSuffix
 	tc := &TunnelController{
 		Client:             cl,
@@ -95,6 +135,14 @@ func NewTunnelController(podIP, namespace string, er record.EventRecorder, k8sCl
 		readyClusters:      readyClusters,
 		gatewayNetns:       gatewayNetns,
 		hostNetns:          hostNetns,
+		prober:             latencyProber,
+		sharder:            sharder,
+		mtu:                mtu,
+		mtuProber:          mtuProber,
+		pathMTUs:           make(map[string]pathMTU),
+		mtuEvents:          make(chan event.GenericEvent),
+		statelessNAT:       statelessNAT,
+		shaper:             shaper,
 	}
 
 	err := tc.SetUpTunnelDrivers(tunnel.Config{
@@ -148,6 +196,9 @@ func NewTunnelController(podIP, namespace string, er record.EventRecorder, k8sCl
 	for _, d := range tc.drivers {
 		metrics.Registry.MustRegister(d)
 	}
+	if tc.prober != nil {
+		metrics.Registry.MustRegister(tc.prober)
+	}
 
 	return tc, nil
 }
@@ -158,20 +209,32 @@ func (tc *TunnelController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	var err error
 	var remotePodCIDR string
 	var con *netv1alpha1.Connection
+	var negotiatedMTU int
 
 	var configGWNetns = func(netNamespace ns.NetNS) error {
 		con, err = tc.connectToPeer(tep)
 		if err != nil {
 			return err
 		}
+		negotiatedMTU = tc.negotiateMTU(tep, con)
 		if err = tc.EnsureIPTablesRulesPerCluster(tep); err != nil {
 			return err
 		}
+		if err = tc.ensureStatelessNAT(tep); err != nil {
+			return err
+		}
+		if err = tc.ensureQoS(tep); err != nil {
+			return err
+		}
 		// Set cluster tunnel as ready
 		tc.readyClustersMutex.Lock()
 		defer tc.readyClustersMutex.Unlock()
 		tc.readyClusters[tep.Spec.ClusterIdentity.ClusterID] = struct{}{}
-		added, err := tc.EnsureRoutesPerCluster(tep)
+		routing, err := tc.routingManager(tep)
+		if err != nil {
+			return err
+		}
+		added, err := routing.EnsureRoutesPerCluster(tep)
 		if err != nil {
 			klog.Errorf("%s -> unable to configure route '%s': %s", tep.Spec.ClusterIdentity, remotePodCIDR, err)
 			tc.Eventf(tep, "Warning", "Processing", "unable to remove outdated route: %s", err.Error())
@@ -181,7 +244,10 @@ func (tc *TunnelController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			tc.Event(tep, "Normal", "Processing", "route configured")
 			klog.Infof("%s -> route for destination {%s} correctly configured", tep.Spec.ClusterIdentity, remotePodCIDR)
 		}
-		return nil
+		if err := tc.ensureRoutesMTU(tep, negotiatedMTU); err != nil {
+			return err
+		}
+		return tc.ensureProbing(tep)
 	}
 	var unconfigGWNetns = func(netNamespace ns.NetNS) error {
 		if err := tc.IPTHandler.RemoveIPTablesConfigurationPerCluster(tep); err != nil {
@@ -189,10 +255,36 @@ func (tc *TunnelController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 				tep.Spec.ClusterIdentity, err.Error())
 			return err
 		}
+		if tc.statelessNAT != nil {
+			if err := tc.statelessNAT.RemoveCluster(tep.Spec.ClusterIdentity.ClusterID); err != nil {
+				klog.Errorf("%s -> unable to remove eBPF NAT configuration: %v", tep.Spec.ClusterIdentity, err)
+				return err
+			}
+		}
+		if tc.shaper != nil {
+			if err := tc.shaper.RemovePeer(tep.Spec.ClusterIdentity.ClusterID); err != nil {
+				klog.Errorf("%s -> unable to remove QoS configuration: %v", tep.Spec.ClusterIdentity, err)
+				return err
+			}
+		}
+		// The routing manager is retrieved before disconnecting, as the link towards the remote cluster may be removed.
+		// In this case, the corresponding routes are removed along with the link.
+		routing, routingErr := tc.routingManager(tep)
+		if err := tc.removeProbing(tep); err != nil {
+			return err
+		}
 		if err := tc.disconnectFromPeer(tep); err != nil {
 			return err
 		}
-		deleted, err := tc.RemoveRoutesPerCluster(tep)
+		tc.readyClustersMutex.Lock()
+		delete(tc.readyClusters, tep.Spec.ClusterIdentity.ClusterID)
+		tc.readyClustersMutex.Unlock()
+		tc.forgetPathMTU(tep.Spec.ClusterIdentity.ClusterID)
+		if routingErr != nil {
+			klog.V(4).Infof("%s -> no tunnel link found, skipping the removal of the routes: %v", tep.Spec.ClusterIdentity, routingErr)
+			return nil
+		}
+		deleted, err := routing.RemoveRoutesPerCluster(tep)
 		if err != nil {
 			tc.Eventf(tep, "Warning", "Processing", "unable to remove route: %s", err.Error())
 			klog.Errorf("%s -> unable to remove route for destination '%s': %v", tep.Spec.ClusterIdentity, remotePodCIDR, err)
@@ -215,6 +307,23 @@ func (tc *TunnelController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	_, remotePodCIDR = liqonetutils.GetPodCIDRS(tep)
+
+	// In active-active mode, only the replica owning the remote cluster configures the corresponding tunnel,
+	// while the other ones make sure that no stale configuration is present (e.g., after the shards have been rebalanced).
+	if tc.sharder != nil {
+		local, err := tc.sharder.IsLocal(ctx, tep.Spec.ClusterIdentity.ClusterID)
+		if err != nil {
+			klog.Errorf("%s -> unable to determine the gateway replica owning the remote cluster: %v", tep.Spec.ClusterIdentity, err)
+			return result, err
+		}
+		if !local {
+			if !tc.IsClusterReady(tep.Spec.ClusterIdentity.ClusterID) {
+				return result, nil
+			}
+			klog.Infof("%s -> remote cluster no longer owned by the current replica, releasing the tunnel", tep.Spec.ClusterIdentity)
+			return result, tc.gatewayNetns.Do(unconfigGWNetns)
+		}
+	}
 	// Examine DeletionTimestamp to determine if object is under deletion.
 	if tep.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !controllerutil.ContainsFinalizer(tep, tc.finalizer) {
@@ -262,8 +371,28 @@ func (tc *TunnelController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			RequeueAfter: 2 * time.Second,
 		}
 	}
+	// When a key rotation is in progress, we requeue the tunnelendpoint resource in order to promptly detect
+	// the switch-over of the remote peer to the next key.
+	if _, found := tep.Spec.BackendConfig[liqoconst.NextPublicKey]; found && con.Status != netv1alpha1.Connecting {
+		return ctrl.Result{RequeueAfter: time.Second}, tc.updateStatus(con, negotiatedMTU, tep)
+	}
+	// When the connection is established through a rendezvous server, we periodically requeue the tunnelendpoint resource
+	// in order to progress with the NAT traversal.
+	if _, found := tep.Spec.BackendConfig[liqoconst.RendezvousAddress]; found && con.Status != netv1alpha1.Connecting {
+		return ctrl.Result{RequeueAfter: traversalUpdatePeriod}, tc.updateStatus(con, negotiatedMTU, tep)
+	}
+	// When multiple endpoints are advertised by the remote cluster, we periodically requeue the tunnelendpoint resource
+	// in order to detect whether the current one stops handshaking, or the ones with higher priority handshake again.
+	if len(tep.Spec.FallbackEndpointIPs) > 0 && con.Status != netv1alpha1.Connecting {
+		return ctrl.Result{RequeueAfter: failoverCheckPeriod}, tc.updateStatus(con, negotiatedMTU, tep)
+	}
+	// When the latency prober is enabled, we periodically requeue the tunnelendpoint resource in order to
+	// refresh the latency recorded in its status.
+	if tc.prober != nil && con.Status == netv1alpha1.Connected {
+		return ctrl.Result{RequeueAfter: latencyUpdatePeriod}, tc.updateStatus(con, negotiatedMTU, tep)
+	}
 
-	return result, tc.updateStatus(con, tep)
+	return result, tc.updateStatus(con, negotiatedMTU, tep)
 }
 
 func (tc *TunnelController) connectToPeer(ep *netv1alpha1.TunnelEndpoint) (*netv1alpha1.Connection, error) {
@@ -287,6 +416,87 @@ func (tc *TunnelController) connectToPeer(ep *netv1alpha1.TunnelEndpoint) (*netv
 	return con, nil
 }
 
+// ensureProbing configures the link-local addresses used to exchange the probes through the tunnel, and starts probing
+// the remote gateway (if the prober is enabled). Dedicated addresses are leveraged, rather than ones belonging to the pod
+// CIDRs, to prevent conflicts with the addresses assigned to the pods. It must be executed in the gateway network namespace.
+func (tc *TunnelController) ensureProbing(tep *netv1alpha1.TunnelEndpoint) error {
+	if tc.prober == nil {
+		return nil
+	}
+
+	link, err := tc.tunnelLink(tep)
+	if err != nil {
+		return err
+	}
+
+	localProbeIP := tc.prober.Address()
+	address := &netlink.Addr{IPNet: &net.IPNet{IP: localProbeIP, Mask: net.CIDRMask(32, 32)}, Scope: unix.RT_SCOPE_LINK}
+	if err := netlink.AddrReplace(link, address); err != nil {
+		klog.Errorf("%s -> unable to configure the probe IP {%s}: %v", tep.Spec.ClusterIdentity, localProbeIP, err)
+		return err
+	}
+
+	remoteProbeIP := liqonetutils.GetTunnelProbeIP(tep.Spec.ClusterIdentity.ClusterID)
+	route := &netlink.Route{LinkIndex: link.Attrs().Index, Dst: &net.IPNet{IP: remoteProbeIP, Mask: net.CIDRMask(32, 32)},
+		Src: localProbeIP, Scope: netlink.SCOPE_LINK}
+	if err := netlink.RouteReplace(route); err != nil {
+		klog.Errorf("%s -> unable to configure the route towards the remote probe IP {%s}: %v", tep.Spec.ClusterIdentity, remoteProbeIP, err)
+		return err
+	}
+
+	tc.prober.AddPeer(tep.Spec.ClusterIdentity, remoteProbeIP)
+	return nil
+}
+
+// removeProbing stops probing the remote gateway, and removes the route towards the corresponding probe IP (if the link
+// still exists, as otherwise already removed along with it). It must be executed in the gateway network namespace.
+func (tc *TunnelController) removeProbing(tep *netv1alpha1.TunnelEndpoint) error {
+	if tc.prober == nil {
+		return nil
+	}
+
+	tc.prober.RemovePeer(tep.Spec.ClusterIdentity)
+	link, err := tc.tunnelLink(tep)
+	if err != nil {
+		klog.V(4).Infof("%s -> no tunnel link found, skipping the removal of the probe route: %v", tep.Spec.ClusterIdentity, err)
+		return nil
+	}
+
+	remoteProbeIP := liqonetutils.GetTunnelProbeIP(tep.Spec.ClusterIdentity.ClusterID)
+	route := &netlink.Route{LinkIndex: link.Attrs().Index, Dst: &net.IPNet{IP: remoteProbeIP, Mask: net.CIDRMask(32, 32)}}
+	if err := netlink.RouteDel(route); err != nil && !errors.Is(err, unix.ESRCH) {
+		klog.Errorf("%s -> unable to remove the route towards the remote probe IP {%s}: %v", tep.Spec.ClusterIdentity, remoteProbeIP, err)
+		return err
+	}
+	return nil
+}
+
+// tunnelLink returns the link the traffic towards the given remote cluster is routed through, depending on the tunnel driver.
+// It must be executed in the gateway network namespace.
+func (tc *TunnelController) tunnelLink(tep *netv1alpha1.TunnelEndpoint) (netlink.Link, error) {
+	driver, ok := tc.drivers[tep.Spec.BackendType]
+	if !ok {
+		return nil, fmt.Errorf("no registered driver of type %s found", tep.Spec.BackendType)
+	}
+	if peerLinkDriver, ok := driver.(tunnel.PeerLinkDriver); ok {
+		return peerLinkDriver.GetPeerLink(tep)
+	}
+	return driver.GetLink(), nil
+}
+
+// routingManager returns the routing manager configuring the routes towards the given remote cluster, through the link
+// of the corresponding tunnel driver. It must be executed in the gateway network namespace.
+func (tc *TunnelController) routingManager(tep *netv1alpha1.TunnelEndpoint) (liqorouting.Routing, error) {
+	if tep.Spec.BackendType == liqoconst.DriverName {
+		return tc.Routing, nil
+	}
+	link, err := tc.tunnelLink(tep)
+	if err != nil {
+		return nil, err
+	}
+	return liqorouting.NewGatewayRoutingManager(unix.RT_TABLE_MAIN, link)
+}
+
 func (tc *TunnelController) disconnectFromPeer(ep *netv1alpha1.TunnelEndpoint) error {
 	// retrieve driver based on backend type
 	driver, ok := tc.drivers[ep.Spec.BackendType]
@@ -305,6 +515,19 @@ func (tc *TunnelController) disconnectFromPeer(ep *netv1alpha1.TunnelEndpoint) e
 	return nil
 }
 
+// IsClusterReady returns whether the tunnel towards the given remote cluster is configured by the current replica.
+func (tc *TunnelController) IsClusterReady(clusterID string) bool {
+	tc.readyClustersMutex.Lock()
+	defer tc.readyClustersMutex.Unlock()
+	_, ready := tc.readyClusters[clusterID]
+	return ready
+}
+
+// WireguardDriver returns the WireGuard tunnel driver.
+func (tc *TunnelController) WireguardDriver() *tunnelwg.Wireguard {
+	return tc.drivers[liqoconst.DriverName].(*tunnelwg.Wireguard)
+}
+
 // RemoveAllTunnels used to remove all the tunnel interfaces when the controller is closed.
 // It does not return an error, but just logs them, cause we can not recover from
 // them at exit time.
@@ -346,6 +569,61 @@ func (tc *TunnelController) EnsureIPTablesRulesPerCluster(tep *netv1alpha1.Tunne
 	return nil
 }
 
+// ensureStatelessNAT attaches the eBPF NAT programs to the link towards the given remote cluster, and configures the
+// corresponding translations. It is a no-op in case the stateless NAT translations are performed by iptables.
+func (tc *TunnelController) ensureStatelessNAT(tep *netv1alpha1.TunnelEndpoint) error {
+	if tc.statelessNAT == nil {
+		return nil
+	}
+	link, err := tc.tunnelLink(tep)
+	if err != nil {
+		return err
+	}
+	// The programs are attached to the tunnel link only, hence the egress traffic is translated after the masquerading
+	// performed in the postrouting chain, and the ingress one before the conntrack lookup.
+	if err := tc.statelessNAT.Attach(link); err != nil {
+		klog.Errorf("%s -> an error occurred while attaching the eBPF NAT programs: %v", tep.Spec.ClusterIdentity, err)
+		tc.Eventf(tep, "Warning", "Processing", "unable to attach eBPF NAT programs: %v", err)
+		return err
+	}
+	if err := tc.statelessNAT.EnsureCluster(tep); err != nil {
+		klog.Errorf("%s -> an error occurred while configuring the eBPF NAT translations: %v", tep.Spec.ClusterIdentity, err)
+		tc.Eventf(tep, "Warning", "Processing", "unable to configure eBPF NAT translations: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ensureQoS enforces the bandwidth limits and the priority of the traffic exchanged with the given remote cluster, shaping
+// the egress traffic on the tunnel link, and the ingress one on the veth towards the host network namespace (where the
+// networks of the remote cluster have already been remapped, hence they are unique).
+// It must be executed in the gateway network namespace.
+func (tc *TunnelController) ensureQoS(tep *netv1alpha1.TunnelEndpoint) error {
+	if tc.shaper == nil {
+		return nil
+	}
+
+	links := make(map[qos.Direction]netlink.Link, 2)
+	if tep.Spec.QoS != nil {
+		tunnelLink, err := tc.tunnelLink(tep)
+		if err != nil {
+			return err
+		}
+		vethLink, err := netlink.LinkByIndex(tc.gatewayVeth.Index)
+		if err != nil {
+			return fmt.Errorf("unable to retrieve the gateway veth: %w", err)
+		}
+		links[qos.Egress], links[qos.Ingress] = tunnelLink, vethLink
+	}
+
+	if err := tc.shaper.EnsurePeer(tep.Spec.ClusterIdentity.ClusterID, tep.Spec.QoS, links, getRemoteCIDRs(tep)); err != nil {
+		klog.Errorf("%s -> an error occurred while enforcing the QoS configuration: %v", tep.Spec.ClusterIdentity, err)
+		tc.Eventf(tep, "Warning", "Processing", "unable to enforce QoS configuration: %v", err)
+		return err
+	}
+	return nil
+}
+
 // SetupSignalHandlerForTunnelOperator registers for SIGTERM, SIGINT, SIGKILL. A context is returned
 // which is closed on one of these signals.
 func (tc *TunnelController) SetupSignalHandlerForTunnelOperator() context.Context {
@@ -371,9 +649,20 @@ func (tc *TunnelController) SetupWithManager(mgr ctrl.Manager) error {
 			return false
 		},
 	}
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&netv1alpha1.TunnelEndpoint{}).WithEventFilter(resourceToBeProccesedPredicate).
-		Complete(tc)
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
+		For(&netv1alpha1.TunnelEndpoint{}, builder.WithPredicates(resourceToBeProccesedPredicate))
+	// The tunnelendpoints are reprocessed once the path MTU discovery completed.
+	if tc.mtuProber != nil {
+		controllerBuilder = controllerBuilder.Watches(&source.Channel{Source: tc.mtuEvents}, &handler.EnqueueRequestForObject{})
+	}
+	// In active-active mode, the tunnelendpoints reassigned when the set of replicas changes are reprocessed, to rebalance the shards.
+	if tc.sharder != nil {
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: &corev1.Pod{}},
+			enqueueReassigned(tc.Client, tc.sharder, &netv1alpha1.TunnelEndpointList{}, func(obj client.Object) string {
+				return obj.(*netv1alpha1.TunnelEndpoint).Spec.ClusterIdentity.ClusterID
+			}), builder.WithPredicates(gatewayPodsPredicate(tc.sharder.Namespace())))
+	}
+	return controllerBuilder.Complete(tc)
 }
 
 // SetUpTunnelDrivers for each registered tunnel implementation it creates and initializes the driver.
@@ -402,6 +691,7 @@ func (tc *TunnelController) SetUpIPTablesHandler() error {
 	if err != nil {
 		return err
 	}
+	iptHandler.StatelessNATOffloaded = tc.statelessNAT != nil
 	var init = func(netNamespace ns.NetNS) error {
 		if err = iptHandler.Init(); err != nil {
 			klog.Errorf("an error occurred while creating iptables handler: %v", err)
@@ -466,8 +756,15 @@ func (tc *TunnelController) setUpGWNetns(hostVethName, gatewayVethName string, v
 	})
 }
 
-func (tc *TunnelController) updateStatus(con *netv1alpha1.Connection, tep *netv1alpha1.TunnelEndpoint) error {
-	if reflect.DeepEqual(*con, tep.Status.Connection) && tep.Status.GatewayIP == tc.podIP &&
+func (tc *TunnelController) updateStatus(con *netv1alpha1.Connection, negotiatedMTU int, tep *netv1alpha1.TunnelEndpoint) error {
+	con.Latency = tep.Status.Connection.Latency
+	if tc.prober != nil {
+		if latency, timestamp, found := tc.prober.Latency(tep.Spec.ClusterIdentity.ClusterID); found {
+			con.Latency = netv1alpha1.ConnectionLatency{Value: latency.Round(10 * time.Microsecond).String(), Timestamp: metav1.NewTime(timestamp)}
+		}
+	}
+
+	if reflect.DeepEqual(*con, tep.Status.Connection) && tep.Status.GatewayIP == tc.podIP && tep.Status.MTU == negotiatedMTU &&
 		tep.Status.VethIFaceIndex == tc.hostVeth.Index && tep.Status.VethIP == liqoconst.GatewayVethIPAddr {
 		return nil
 	}
@@ -477,6 +774,7 @@ func (tc *TunnelController) updateStatus(con *netv1alpha1.Connection, tep *netv1
 	tep.Status.VethIFaceIndex = tc.hostVeth.Index
 	tep.Status.VethIFaceName = tc.hostVeth.Name
 	tep.Status.VethIP = liqoconst.GatewayVethIPAddr
+	tep.Status.MTU = negotiatedMTU
 
 	if err := tc.Status().Update(context.Background(), tep); err != nil {
 		if k8sApiErrors.IsConflict(err) {
@@ -129,7 +129,7 @@ var _ = BeforeSuite(func() {
 		MetricsBindAddress: "0",
 	})
 	Expect(err).ShouldNot(HaveOccurred())
-	controller, err = NewNatMappingController(mgr.GetClient(), &readyClustersMutex, readyClusters, iptNetns)
+	controller, err = NewNatMappingController(mgr.GetClient(), &readyClustersMutex, readyClusters, iptNetns, nil, nil)
 	Expect(err).ShouldNot(HaveOccurred())
 	go func() {
 		if err = mgr.Start(ctx); err != nil {
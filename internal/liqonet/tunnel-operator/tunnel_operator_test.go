@@ -20,7 +20,9 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/vishvananda/netlink"
 
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	tunnelwg "github.com/liqotech/liqo/pkg/liqonet/tunnel/wireguard"
 )
 
 var _ = Describe("TunnelOperator", func() {
@@ -69,4 +71,22 @@ var _ = Describe("TunnelOperator", func() {
 			})
 		})
 	})
+
+	Describe("the configuredEndpoint function", func() {
+		var tep *netv1alpha1.TunnelEndpoint
+
+		BeforeEach(func() {
+			tep = &netv1alpha1.TunnelEndpoint{Spec: netv1alpha1.TunnelEndpointSpec{EndpointIP: "1.1.1.1"}}
+		})
+
+		It("should return the endpoint configured by the driver, if any", func() {
+			con := &netv1alpha1.Connection{PeerConfiguration: map[string]string{tunnelwg.EndpointIP: "2.2.2.2"}}
+			Expect(configuredEndpoint(tep, con)).To(Equal("2.2.2.2"))
+		})
+
+		It("should fallback to the advertised endpoint otherwise", func() {
+			Expect(configuredEndpoint(tep, nil)).To(Equal("1.1.1.1"))
+			Expect(configuredEndpoint(tep, &netv1alpha1.Connection{})).To(Equal("1.1.1.1"))
+		})
+	})
 })
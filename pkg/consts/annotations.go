@@ -23,4 +23,26 @@ const (
 	OverrideAddressAnnotation = "liqo.io/override-address"
 	// OverridePortAnnotation is the annotation used to override the port of a service.
 	OverridePortAnnotation = "liqo.io/override-port"
+	// DetectedAddressAnnotation is the annotation used by the gateway to advertise the address it has been detected to be
+	// reachable at by the configured endpoint resolver. It takes precedence over the address inferred from the gateway service,
+	// while it is overridden by the OverrideAddressAnnotation.
+	DetectedAddressAnnotation = "net.liqo.io/detected-address"
+	// TunnelBackendAnnotation is the annotation used to select the backend type of the tunnel towards the remote cluster
+	// identified by the annotated ForeignCluster (e.g., "gre" for a plain unencrypted tunnel). Defaults to WireGuard.
+	TunnelBackendAnnotation = "net.liqo.io/tunnel-backend"
+	// EgressBandwidthAnnotation is the annotation used to limit the rate of the traffic sent to the remote cluster
+	// identified by the annotated ForeignCluster, in bits per second (e.g., "100M").
+	EgressBandwidthAnnotation = "net.liqo.io/egress-bandwidth"
+	// IngressBandwidthAnnotation is the annotation used to limit the rate of the traffic received from the remote cluster
+	// identified by the annotated ForeignCluster, in bits per second (e.g., "100M").
+	IngressBandwidthAnnotation = "net.liqo.io/ingress-bandwidth"
+	// TrafficPriorityAnnotation is the annotation used to select the priority (i.e., "High", "Normal" or "Low") of the
+	// traffic exchanged with the remote cluster identified by the annotated ForeignCluster, when the gateway is congested.
+	TrafficPriorityAnnotation = "net.liqo.io/traffic-priority"
+	// TransitClustersAnnotation is the annotation used to grant the remote cluster identified by the annotated ForeignCluster
+	// access to the pods of other peered clusters through the local one, as a comma-separated list of cluster IDs or names
+	// ("*" to grant access to all of them).
+	TransitClustersAnnotation = "net.liqo.io/transit-clusters"
+	// TransitClustersWildcard is the value of the TransitClustersAnnotation granting access to all peered clusters.
+	TransitClustersWildcard = "*"
 )
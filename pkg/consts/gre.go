@@ -0,0 +1,26 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consts
+
+const (
+	// GreDriverName name of the driver establishing plain (i.e., unencrypted) GRE tunnels,
+	// which is also used as the type of the backend in tunnelendpoint CRD.
+	GreDriverName = "gre"
+	// GreDevicePrefix prefix used to name the GRE devices created on the custom network namespace, one for each remote cluster.
+	GreDevicePrefix = "liqo.gre."
+	// GreOverhead is the overhead introduced by the GRE encapsulation over IPv4, that is the IP header (20 bytes)
+	// and the GRE header (4 bytes).
+	GreOverhead = 24
+)
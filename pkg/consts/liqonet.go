@@ -39,6 +39,8 @@ const (
 	LiqoGatewayOperatorName = "liqo-gateway"
 	// LiqoNetworkManagerName name of the operator.
 	LiqoNetworkManagerName = "liqo-network-manager"
+	// IPAMConfigName is the name of the IPAMConfig resource taken into account by the network manager.
+	IPAMConfigName = "ipam"
 	// GatewayLeaderElectionID used as name for the lease.coordination.k8s.io resource.
 	GatewayLeaderElectionID = "1d5hml1.gateway.net.liqo.io"
 	// GatewayNetnsName name of the custom network namespace used by liqo-gateway.
@@ -93,12 +95,18 @@ const (
 	DefaultMTU = 1440
 	// GatewayListeningPort port used by the vpn tunnel.
 	GatewayListeningPort = 5871
+	// GatewayProbePort port used by the gateway to exchange the latency probes with the remote gateways, through the vpn tunnel.
+	GatewayProbePort = 5873
 
 	// **** Liqo Gateway Service ****.
 
 	// GatewayServiceAnnotationKey used to annotate the Gateway service with the IP of the node where the
 	// active gateway is running.
 	GatewayServiceAnnotationKey = "net.liqo.io/gatewayNodeIP"
+	// GatewayPodLabelKey key of the label identifying whether a gateway replica is active or standby.
+	GatewayPodLabelKey = "net.liqo.io/gateway"
+	// GatewayActivePodLabelValue value of the label identifying the active gateway replicas.
+	GatewayActivePodLabelValue = "active"
 	// NetworkConfigNamePrefix prefix used to generate the names of the networkconfigs.
 	NetworkConfigNamePrefix = "net-config-"
 )
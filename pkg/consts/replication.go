@@ -56,4 +56,8 @@ const (
 
 	// SkipReflectionAnnotationKey is the annotation key used to indicate that a given object should not be reflected into a remote cluster.
 	SkipReflectionAnnotationKey = "liqo.io/skip-reflection"
+
+	// RemoteWorkloadAnnotationKey is the annotation key used to indicate that a given Deployment or StatefulSet should be
+	// reflected as a whole into the remote cluster, rather than offloading each pod individually.
+	RemoteWorkloadAnnotationKey = "liqo.io/remote-workload"
 )
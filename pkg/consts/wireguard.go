@@ -17,12 +17,25 @@ package consts
 const (
 	// PublicKey is the key of publicKey entry in back-end map and also for the secret containing the wireguard keys.
 	PublicKey = "publicKey"
+	// NextPublicKey is the key of the nextPublicKey entry in back-end map and also for the secret containing the wireguard keys.
+	// It is set during a key rotation, and contains the public key which is going to replace the current one.
+	NextPublicKey = "nextPublicKey"
 	// ListeningPort is the key of the listeningPort entry in the back-end map.
 	ListeningPort = "port"
+	// RendezvousAddress is the key of the rendezvousAddress entry in the back-end map. It is set if the connection
+	// shall be established through the given rendezvous server (e.g., since the gateways are behind NAT).
+	RendezvousAddress = "rendezvousAddress"
+	// RendezvousSession is the key of the rendezvousSession entry in the back-end map, identifying the pair of clusters.
+	RendezvousSession = "rendezvousSession"
+	// RendezvousMember is the key of the rendezvousMember entry in the back-end map, identifying the local cluster in the session.
+	RendezvousMember = "rendezvousMember"
 	// DeviceName name of wireguard tunnel created on the custom network namespace.
 	DeviceName = "liqo.tunnel"
 	// DriverName  name of the driver which is also used as the type of the backend in tunnelendpoint CRD.
 	DriverName = "wireguard"
+	// WgOverhead is the overhead introduced by the wireguard encapsulation over IPv4, that is the IP header (20 bytes),
+	// the UDP header (8 bytes), and the wireguard header and authentication tag (32 bytes).
+	WgOverhead = 60
 	// KeysLabel label for the secret that contains the public key.
 	KeysLabel = "net.liqo.io/key"
 )
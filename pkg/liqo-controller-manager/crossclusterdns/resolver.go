@@ -0,0 +1,418 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crossclusterdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	vkv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	identitymanager "github.com/liqotech/liqo/pkg/identityManager"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+	foreignclusterutils "github.com/liqotech/liqo/pkg/utils/foreignCluster"
+	"github.com/liqotech/liqo/pkg/utils/restcfg"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+const (
+	// Zone is the DNS zone served by the resolver.
+	Zone = "liqo."
+
+	// ttl is the time-to-live of the returned records, kept short since the endpoints may change frequently.
+	ttl = 5
+	// resolveTimeout is the maximum time spent to resolve a query.
+	resolveTimeout = 3 * time.Second
+	// cacheIdleTimeout is the time after which the caches of a remote namespace not queried anymore are stopped.
+	cacheIdleTimeout = 10 * time.Minute
+)
+
+var (
+	// errNotFound is returned when the queried name does not correspond to any remote service.
+	errNotFound = errors.New("not found")
+	// errOutOfZone is returned when the queried name is not part of the served zone.
+	errOutOfZone = errors.New("out of zone")
+)
+
+// +kubebuilder:rbac:groups=discovery.liqo.io,resources=foreignclusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=virtualkubelet.liqo.io,resources=namespacemaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=net.liqo.io,resources=tunnelendpoints,verbs=get;list;watch
+
+// Resolver answers the DNS queries concerning the services hosted by the remote clusters (i.e., services existing
+// in the remote namespaces associated with the local offloaded ones, and not reflected from other clusters).
+// The returned addresses are the ones of the ready endpoints, remapped according to the NAT configuration of the
+// corresponding peering, since the remote service IPs are not reachable from the local cluster.
+type Resolver struct {
+	client.Client
+	address        string
+	identityReader identitymanager.IdentityReader
+
+	// newRemoteClient is the function used to create the clients towards the remote clusters (overridden in tests).
+	newRemoteClient func(fc *discoveryv1alpha1.ForeignCluster) (kubernetes.Interface, error)
+	mutex           sync.Mutex
+	remoteClients   map[string]kubernetes.Interface
+	// remoteCaches are the informer-backed caches of the services and endpointslices of the queried remote namespaces.
+	remoteCaches map[string]*remoteCache
+}
+
+// remoteCache caches the services and endpointslices existing in a given remote namespace.
+type remoteCache struct {
+	services  corelisters.ServiceNamespaceLister
+	slices    discoverylisters.EndpointSliceNamespaceLister
+	hasSynced []cache.InformerSynced
+	cancel    context.CancelFunc
+	lastUsed  time.Time
+}
+
+// NewResolver returns a new Resolver, which serves the queries on the given address (both UDP and TCP),
+// leveraging the identities retrieved through the given reader to interact with the remote clusters.
+func NewResolver(cl client.Client, identityReader identitymanager.IdentityReader, address string) *Resolver {
+	res := &Resolver{
+		Client:         cl,
+		address:        address,
+		identityReader: identityReader,
+		remoteClients:  map[string]kubernetes.Interface{},
+		remoteCaches:   map[string]*remoteCache{},
+	}
+	res.newRemoteClient = res.forgeRemoteClient
+	return res
+}
+
+// Start serves the DNS queries, until the given context is canceled.
+// It implements the manager.Runnable interface.
+func (res *Resolver) Start(ctx context.Context) error {
+	servers := []*dns.Server{
+		{Addr: res.address, Net: "udp", Handler: res},
+		{Addr: res.address, Net: "tcp", Handler: res},
+	}
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			klog.Infof("Starting the cross-cluster DNS resolver on %s/%s", server.Addr, server.Net)
+			errs <- server.ListenAndServe()
+		}(server)
+	}
+
+	// The caches of the remote namespaces not queried anymore are periodically stopped.
+	ticker := time.NewTicker(cacheIdleTimeout / 2)
+	defer ticker.Stop()
+
+	var err error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case err = <-errs:
+			err = fmt.Errorf("cross-cluster DNS resolver failed: %w", err)
+			break loop
+		case <-ticker.C:
+			res.stopIdleCaches(time.Now().Add(-cacheIdleTimeout))
+		}
+	}
+
+	for _, server := range servers {
+		// The error is ignored, as returned in case the server is not started (e.g., because the other failed).
+		_ = server.Shutdown()
+	}
+	res.stopIdleCaches(time.Now())
+	return err
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, so that all the replicas serve the queries.
+func (res *Resolver) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeDNS implements the dns.Handler interface.
+func (res *Resolver) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	msg.Authoritative = true
+
+	if len(r.Question) != 1 {
+		msg.Rcode = dns.RcodeFormatError
+		res.write(w, msg)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	question := r.Question[0]
+	ips, err := res.resolve(ctx, question.Name)
+	switch {
+	case errors.Is(err, errOutOfZone):
+		msg.Authoritative = false
+		msg.Rcode = dns.RcodeRefused
+	case errors.Is(err, errNotFound):
+		msg.Rcode = dns.RcodeNameError
+	case err != nil:
+		klog.Errorf("Failed to resolve %q: %v", question.Name, err)
+		msg.Rcode = dns.RcodeServerFailure
+	case question.Qtype == dns.TypeA:
+		for _, ip := range ips {
+			msg.Answer = append(msg.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+				A:   ip,
+			})
+		}
+	}
+	res.write(w, msg)
+}
+
+// write sends the given response, logging possible errors.
+func (res *Resolver) write(w dns.ResponseWriter, msg *dns.Msg) {
+	if err := w.WriteMsg(msg); err != nil {
+		klog.Warningf("Failed to write the DNS response: %v", err)
+	}
+}
+
+// resolve returns the addresses associated with the given name, in the <service>.<namespace>.svc.<cluster-name>.liqo form.
+func (res *Resolver) resolve(ctx context.Context, name string) ([]net.IP, error) {
+	name = strings.ToLower(dns.Fqdn(name))
+	if !dns.IsSubDomain(Zone, name) {
+		return nil, errOutOfZone
+	}
+
+	tokens := dns.SplitDomainName(name)
+	if len(tokens) != 5 || tokens[2] != "svc" {
+		return nil, errNotFound
+	}
+	service, namespace, clusterName := tokens[0], tokens[1], tokens[3]
+
+	fc, err := res.getForeignCluster(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	remoteNamespace, err := res.getRemoteNamespace(ctx, fc, namespace)
+	if err != nil {
+		return nil, err
+	}
+	tep, err := res.getTunnelEndpoint(ctx, fc)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := res.getRemoteCache(ctx, fc, remoteNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := remote.services.Get(service)
+	switch {
+	case kerrors.IsNotFound(err):
+		return nil, errNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to retrieve remote service %s/%s: %w", remoteNamespace, service, err)
+	}
+	// Services reflected from other clusters are not considered, as resolved by the cluster hosting them.
+	if _, reflected := svc.GetLabels()[forge.LiqoOriginClusterIDKey]; reflected {
+		return nil, errNotFound
+	}
+
+	slices, err := remote.slices.List(labels.Set{discoveryv1.LabelServiceName: service}.AsSelector())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the endpoints of remote service %s/%s: %w", remoteNamespace, service, err)
+	}
+	return remap(tep, slices), nil
+}
+
+// getForeignCluster returns the ForeignCluster with the given name, provided that the outgoing peering is established.
+func (res *Resolver) getForeignCluster(ctx context.Context, clusterName string) (*discoveryv1alpha1.ForeignCluster, error) {
+	var fcs discoveryv1alpha1.ForeignClusterList
+	if err := res.List(ctx, &fcs); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the foreign clusters: %w", err)
+	}
+	for i := range fcs.Items {
+		if strings.EqualFold(fcs.Items[i].Spec.ClusterIdentity.ClusterName, clusterName) && foreignclusterutils.IsOutgoingJoined(&fcs.Items[i]) {
+			return &fcs.Items[i], nil
+		}
+	}
+	return nil, errNotFound
+}
+
+// getRemoteNamespace returns the name of the remote namespace associated with the given local one.
+func (res *Resolver) getRemoteNamespace(ctx context.Context, fc *discoveryv1alpha1.ForeignCluster, namespace string) (string, error) {
+	var nms vkv1alpha1.NamespaceMapList
+	if err := res.List(ctx, &nms, client.InNamespace(fc.Status.TenantNamespace.Local),
+		client.MatchingLabels{liqoconst.ReplicationDestinationLabel: fc.Spec.ClusterIdentity.ClusterID}); err != nil {
+		return "", fmt.Errorf("failed to retrieve the NamespaceMaps for remote cluster %q: %w", fc.Spec.ClusterIdentity, err)
+	}
+	for i := range nms.Items {
+		if mapping, ok := nms.Items[i].Status.CurrentMapping[namespace]; ok && mapping.Phase == vkv1alpha1.MappingAccepted {
+			return mapping.RemoteNamespace, nil
+		}
+	}
+	return "", errNotFound
+}
+
+// getTunnelEndpoint returns the TunnelEndpoint associated with the given remote cluster.
+func (res *Resolver) getTunnelEndpoint(ctx context.Context, fc *discoveryv1alpha1.ForeignCluster) (*netv1alpha1.TunnelEndpoint, error) {
+	var teps netv1alpha1.TunnelEndpointList
+	if err := res.List(ctx, &teps, client.MatchingLabels{liqoconst.ClusterIDLabelName: fc.Spec.ClusterIdentity.ClusterID}); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the TunnelEndpoint for remote cluster %q: %w", fc.Spec.ClusterIdentity, err)
+	}
+	if len(teps.Items) != 1 {
+		return nil, fmt.Errorf("found %d TunnelEndpoints for remote cluster %q, expected 1", len(teps.Items), fc.Spec.ClusterIdentity)
+	}
+	return &teps.Items[0], nil
+}
+
+// getRemoteClient returns the client towards the given remote cluster, creating it if not yet present.
+func (res *Resolver) getRemoteClient(fc *discoveryv1alpha1.ForeignCluster) (kubernetes.Interface, error) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	// The tenant namespace is part of the key, so that a new client is created in case the peering is re-established.
+	key := fc.Spec.ClusterIdentity.ClusterID + "/" + fc.Status.TenantNamespace.Local
+	if remote, ok := res.remoteClients[key]; ok {
+		return remote, nil
+	}
+
+	remote, err := res.newRemoteClient(fc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client towards remote cluster %q: %w", fc.Spec.ClusterIdentity, err)
+	}
+	res.remoteClients[key] = remote
+	return remote, nil
+}
+
+// getRemoteCache returns the cache of the given remote namespace, starting it if not yet present,
+// and waiting (until the given context expires) for it to be synchronized.
+func (res *Resolver) getRemoteCache(ctx context.Context, fc *discoveryv1alpha1.ForeignCluster, namespace string) (*remoteCache, error) {
+	remote, err := res.getRemoteClient(fc)
+	if err != nil {
+		return nil, err
+	}
+
+	res.mutex.Lock()
+	key := fc.Spec.ClusterIdentity.ClusterID + "/" + fc.Status.TenantNamespace.Local + "/" + namespace
+	rc, ok := res.remoteCaches[key]
+	if !ok {
+		klog.V(4).Infof("Starting the cache of remote namespace %q of cluster %q", namespace, fc.Spec.ClusterIdentity)
+		// The informers are scoped to the given namespace, as the permissions granted by the remote cluster are.
+		factory := informers.NewSharedInformerFactoryWithOptions(remote, 0, informers.WithNamespace(namespace))
+		services, slices := factory.Core().V1().Services(), factory.Discovery().V1().EndpointSlices()
+		rc = &remoteCache{
+			services:  services.Lister().Services(namespace),
+			slices:    slices.Lister().EndpointSlices(namespace),
+			hasSynced: []cache.InformerSynced{services.Informer().HasSynced, slices.Informer().HasSynced},
+		}
+
+		var cacheCtx context.Context
+		cacheCtx, rc.cancel = context.WithCancel(context.Background())
+		factory.Start(cacheCtx.Done())
+		res.remoteCaches[key] = rc
+	}
+	rc.lastUsed = time.Now()
+	res.mutex.Unlock()
+
+	if !cache.WaitForCacheSync(ctx.Done(), rc.hasSynced...) {
+		return nil, fmt.Errorf("the cache of remote namespace %q of cluster %q is not yet synchronized", namespace, fc.Spec.ClusterIdentity)
+	}
+	return rc, nil
+}
+
+// stopIdleCaches stops the caches of the remote namespaces not queried since the given time.
+func (res *Resolver) stopIdleCaches(since time.Time) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	for key, rc := range res.remoteCaches {
+		if rc.lastUsed.Before(since) {
+			klog.V(4).Infof("Stopping the cache of remote namespace %q", key)
+			rc.cancel()
+			delete(res.remoteCaches, key)
+		}
+	}
+}
+
+// forgeRemoteClient creates a new client towards the given remote cluster, leveraging the corresponding identity.
+func (res *Resolver) forgeRemoteClient(fc *discoveryv1alpha1.ForeignCluster) (kubernetes.Interface, error) {
+	cfg, err := res.identityReader.GetConfig(fc.Spec.ClusterIdentity, fc.Status.TenantNamespace.Local)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restcfg.SetRateLimiter(cfg))
+}
+
+// remap returns the addresses of the ready endpoints part of the given slices, as seen from the local cluster.
+func remap(tep *netv1alpha1.TunnelEndpoint, slices []*discoveryv1.EndpointSlice) []net.IP {
+	var ips []net.IP
+	seen := map[string]struct{}{}
+	for i := range slices {
+		if slices[i].AddressType != discoveryv1.AddressTypeIPv4 {
+			continue
+		}
+		for j := range slices[i].Endpoints {
+			endpoint := &slices[i].Endpoints[j]
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				mapped, err := remapAddress(tep, address)
+				if err != nil {
+					klog.V(4).Infof("Skipping remote endpoint %q: %v", address, err)
+					continue
+				}
+				if _, ok := seen[mapped]; !ok {
+					seen[mapped] = struct{}{}
+					ips = append(ips, net.ParseIP(mapped))
+				}
+			}
+		}
+	}
+	return ips
+}
+
+// remapAddress translates the given remote address into the one used by the local cluster to reach it.
+func remapAddress(tep *netv1alpha1.TunnelEndpoint, address string) (string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address")
+	}
+
+	for _, networks := range [][2]string{
+		{tep.Spec.RemotePodCIDR, tep.Spec.RemoteNATPodCIDR},
+		{tep.Spec.RemoteExternalCIDR, tep.Spec.RemoteNATExternalCIDR},
+	} {
+		_, cidr, err := net.ParseCIDR(networks[0])
+		if err != nil || !cidr.Contains(ip) {
+			continue
+		}
+		return liqonetutils.MapIPToNetwork(networks[1], address)
+	}
+	return "", fmt.Errorf("not part of the networks reachable through the peering")
+}
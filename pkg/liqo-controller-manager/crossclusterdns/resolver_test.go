@@ -0,0 +1,202 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crossclusterdns
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	vkv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+// fakeResponseWriter is a dns.ResponseWriter storing the written message.
+type fakeResponseWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *fakeResponseWriter) WriteMsg(msg *dns.Msg) error {
+	w.msg = msg
+	return nil
+}
+
+var _ = Describe("Resolver", func() {
+	const (
+		clusterID       = "remote-cluster-id"
+		tenantNamespace = "liqo-tenant-remote"
+		remoteNamespace = "foo-remote"
+	)
+
+	var (
+		fc       *discoveryv1alpha1.ForeignCluster
+		tep      *netv1alpha1.TunnelEndpoint
+		remote   []runtime.Object
+		resolver *Resolver
+		writer   *fakeResponseWriter
+	)
+
+	slice := func(name string, ready bool, addresses ...string) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: remoteNamespace, Labels: map[string]string{discoveryv1.LabelServiceName: "bar"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: addresses, Conditions: discoveryv1.EndpointConditions{Ready: pointer.Bool(ready)}}},
+		}
+	}
+
+	query := func(name string, qtype uint16) {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, qtype)
+		resolver.ServeDNS(writer, msg)
+		Expect(writer.msg).ToNot(BeNil())
+	}
+
+	answers := func() []string {
+		var ips []string
+		for _, rr := range writer.msg.Answer {
+			ips = append(ips, rr.(*dns.A).A.String())
+		}
+		return ips
+	}
+
+	BeforeEach(func() {
+		fc = &discoveryv1alpha1.ForeignCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "remote"},
+			Spec:       discoveryv1alpha1.ForeignClusterSpec{ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: clusterID, ClusterName: "Remote"}},
+			Status: discoveryv1alpha1.ForeignClusterStatus{
+				TenantNamespace: discoveryv1alpha1.TenantNamespaceType{Local: tenantNamespace},
+				PeeringConditions: []discoveryv1alpha1.PeeringCondition{{
+					Type: discoveryv1alpha1.OutgoingPeeringCondition, Status: discoveryv1alpha1.PeeringConditionStatusEstablished}},
+			},
+		}
+		tep = &netv1alpha1.TunnelEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "tep", Namespace: tenantNamespace, Labels: map[string]string{liqoconst.ClusterIDLabelName: clusterID}},
+			Spec: netv1alpha1.TunnelEndpointSpec{
+				RemotePodCIDR: "10.0.0.0/16", RemoteNATPodCIDR: "10.50.0.0/16",
+				RemoteExternalCIDR: "10.201.0.0/16", RemoteNATExternalCIDR: liqoconst.DefaultCIDRValue,
+			},
+		}
+		remote = []runtime.Object{
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: remoteNamespace}},
+			slice("bar-1", true, "10.0.1.5", "10.201.0.7"),
+			slice("bar-2", false, "10.0.1.6"),
+			slice("bar-3", true, "192.168.0.1"),
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "reflected", Namespace: remoteNamespace,
+				Labels: map[string]string{forge.LiqoOriginClusterIDKey: "local-cluster-id"}}},
+		}
+		writer = &fakeResponseWriter{}
+	})
+
+	JustBeforeEach(func() {
+		nm := &vkv1alpha1.NamespaceMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "nm", Namespace: tenantNamespace,
+				Labels: map[string]string{liqoconst.ReplicationDestinationLabel: clusterID}},
+			Status: vkv1alpha1.NamespaceMapStatus{CurrentMapping: map[string]vkv1alpha1.RemoteNamespaceStatus{
+				"foo": {RemoteNamespace: remoteNamespace, Phase: vkv1alpha1.MappingAccepted}}},
+		}
+		remoteClient := fake.NewSimpleClientset(remote...)
+		resolver = NewResolver(ctrlfake.NewClientBuilder().WithObjects(fc, tep, nm).Build(), nil, ":0")
+		resolver.newRemoteClient = func(*discoveryv1alpha1.ForeignCluster) (kubernetes.Interface, error) { return remoteClient, nil }
+	})
+
+	AfterEach(func() { resolver.stopIdleCaches(time.Now()) })
+
+	When("querying a remote service", func() {
+		JustBeforeEach(func() { query("bar.foo.svc.remote.liqo.", dns.TypeA) })
+
+		It("should return the remapped addresses of the ready endpoints", func() {
+			Expect(writer.msg.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(writer.msg.Authoritative).To(BeTrue())
+			Expect(answers()).To(ConsistOf("10.50.1.5", "10.201.0.7"))
+		})
+	})
+
+	When("the same remote namespace is queried multiple times", func() {
+		JustBeforeEach(func() {
+			query("bar.foo.svc.remote.liqo.", dns.TypeA)
+			query("reflected.foo.svc.remote.liqo.", dns.TypeA)
+		})
+
+		It("should leverage a single cache", func() {
+			Expect(resolver.remoteCaches).To(HaveLen(1))
+		})
+		It("should stop the cache once idle", func() {
+			resolver.stopIdleCaches(time.Now().Add(-time.Minute))
+			Expect(resolver.remoteCaches).To(HaveLen(1))
+			resolver.stopIdleCaches(time.Now())
+			Expect(resolver.remoteCaches).To(BeEmpty())
+		})
+	})
+
+	When("querying a record type different from A", func() {
+		JustBeforeEach(func() { query("bar.foo.svc.remote.liqo.", dns.TypeAAAA) })
+
+		It("should return no records", func() {
+			Expect(writer.msg.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(writer.msg.Answer).To(BeEmpty())
+		})
+	})
+
+	DescribeTable("querying a name not corresponding to any remote service",
+		func(name string) {
+			query(name, dns.TypeA)
+			Expect(writer.msg.Rcode).To(Equal(dns.RcodeNameError))
+		},
+		Entry("non existing service", "baz.foo.svc.remote.liqo."),
+		Entry("service reflected from another cluster", "reflected.foo.svc.remote.liqo."),
+		Entry("non offloaded namespace", "bar.other.svc.remote.liqo."),
+		Entry("non existing cluster", "bar.foo.svc.other.liqo."),
+		Entry("malformed name", "bar.foo.remote.liqo."),
+	)
+
+	When("the outgoing peering is not established", func() {
+		BeforeEach(func() { fc.Status.PeeringConditions = nil })
+
+		It("should return a name error", func() {
+			query("bar.foo.svc.remote.liqo.", dns.TypeA)
+			Expect(writer.msg.Rcode).To(Equal(dns.RcodeNameError))
+		})
+	})
+
+	When("querying a name outside of the served zone", func() {
+		It("should refuse the query", func() {
+			query("bar.foo.svc.cluster.local.", dns.TypeA)
+			Expect(writer.msg.Rcode).To(Equal(dns.RcodeRefused))
+		})
+	})
+
+	Describe("the remapAddress function", func() {
+		It("should translate the addresses belonging to the remote networks", func() {
+			Expect(remapAddress(tep, "10.0.3.4")).To(Equal("10.50.3.4"))
+			Expect(remapAddress(tep, "10.201.3.4")).To(Equal("10.201.3.4"))
+			_, err := remapAddress(tep, "172.16.0.1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
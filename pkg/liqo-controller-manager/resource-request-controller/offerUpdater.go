@@ -23,7 +23,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -46,6 +48,7 @@ type OfferUpdater struct {
 	scheme                    *runtime.Scheme
 	localRealStorageClassName string
 	enableStorage             bool
+	allowedPodFeatures        []sharingv1alpha1.PodFeature
 	// currentResources maps the clusters that we intend to offer resources to, to the resource list that we last used
 	// when issuing them a ResourceOffer.
 	currentResources map[string]corev1.ResourceList
@@ -58,7 +61,7 @@ type OfferUpdater struct {
 // NewOfferUpdater constructs a new OfferUpdater.
 func NewOfferUpdater(ctx context.Context, k8sClient client.Client, homeCluster discoveryv1alpha1.ClusterIdentity,
 	clusterLabels map[string]string, reader resourcemonitors.ResourceReader, updateThresholdPercentage uint,
-	localRealStorageClassName string, enableStorage bool) *OfferUpdater {
+	localRealStorageClassName string, enableStorage bool, allowedPodFeatures []sharingv1alpha1.PodFeature) *OfferUpdater {
 	updater := &OfferUpdater{
 		ResourceReader:            reader,
 		client:                    k8sClient,
@@ -67,6 +70,7 @@ func NewOfferUpdater(ctx context.Context, k8sClient client.Client, homeCluster d
 		scheme:                    k8sClient.Scheme(),
 		localRealStorageClassName: localRealStorageClassName,
 		enableStorage:             enableStorage,
+		allowedPodFeatures:        allowedPodFeatures,
 		currentResources:          map[string]corev1.ResourceList{},
 		updateThresholdPercentage: updateThresholdPercentage,
 		clusterIdentityCache:      map[string]discoveryv1alpha1.ClusterIdentity{},
@@ -124,12 +128,18 @@ func (u *OfferUpdater) CreateOrUpdateOffer(cluster discoveryv1alpha1.ClusterIden
 		offer.Spec.ClusterID = u.homeCluster.ClusterID
 		offer.Spec.ResourceQuota.Hard = resources.DeepCopy()
 		offer.Spec.Labels = u.clusterLabels
+		offer.Spec.AllowedPodFeatures = u.allowedPodFeatures
 
 		offer.Spec.StorageClasses, err = u.getStorageClasses(ctx)
 		if err != nil {
 			return err
 		}
 
+		offer.Spec.Images, err = u.getImages(ctx)
+		if err != nil {
+			return err
+		}
+
 		return controllerutil.SetControllerReference(request, offer, u.scheme)
 	})
 
@@ -190,6 +200,21 @@ func (u *OfferUpdater) getStorageClasses(ctx context.Context) ([]sharingv1alpha1
 	return storageTypes, nil
 }
 
+// getImages returns the container images available in the local cluster, aggregating those stored in the ready physical nodes.
+func (u *OfferUpdater) getImages(ctx context.Context) ([]corev1.ContainerImage, error) {
+	req, err := labels.NewRequirement(consts.TypeLabel, selection.NotEquals, []string{consts.TypeNode})
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes corev1.NodeList
+	if err := u.client.List(ctx, &nodes, client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*req)}); err != nil {
+		return nil, err
+	}
+
+	return aggregateNodeImages(nodes.Items, maxAdvertisedImages), nil
+}
+
 // SetThreshold sets the threshold for resource updates to trigger an update of the ResourceOffers.
 func (u *OfferUpdater) SetThreshold(updateThresholdPercentage uint) {
 	u.updateThresholdPercentage = updateThresholdPercentage
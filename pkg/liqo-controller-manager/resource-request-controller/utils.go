@@ -18,6 +18,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,9 +34,14 @@ import (
 	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
 	sharingv1alpha1 "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	"github.com/liqotech/liqo/pkg/discovery"
+	"github.com/liqotech/liqo/pkg/utils"
 	foreignclusterutils "github.com/liqotech/liqo/pkg/utils/foreignCluster"
 )
 
+// maxAdvertisedImages is the maximum number of container images advertised in a ResourceOffer,
+// matching the default number of images reported by the kubelet in the node status.
+const maxAdvertisedImages = 50
+
 // ensureForeignCluster ensures the ForeignCluster existence, if not exists we have to add a new one
 // with IncomingPeering discovery method.
 func (r *ResourceRequestReconciler) ensureForeignCluster(ctx context.Context,
@@ -150,3 +159,58 @@ func (r *ResourceRequestReconciler) invalidateResourceOffer(ctx context.Context,
 		return fmt.Errorf("unknown VirtualKubeletStatus %v", offer.Status.VirtualKubeletStatus)
 	}
 }
+
+// aggregateNodeImages returns the (deduplicated) container images stored in the given ready nodes, sorted by the number
+// of nodes they are available in and by size (both in descending order), and limited to the given maximum number.
+func aggregateNodeImages(nodes []corev1.Node, maxImages int) []corev1.ContainerImage {
+	type aggregate struct {
+		image corev1.ContainerImage
+		nodes int
+	}
+
+	aggregates := map[string]*aggregate{}
+	for i := range nodes {
+		if !utils.IsNodeReady(&nodes[i]) {
+			continue
+		}
+
+		for _, image := range nodes[i].Status.Images {
+			if len(image.Names) == 0 {
+				continue
+			}
+
+			names := append([]string{}, image.Names...)
+			sort.Strings(names)
+			key := strings.Join(names, ",")
+			if entry, found := aggregates[key]; found {
+				entry.nodes++
+				continue
+			}
+			aggregates[key] = &aggregate{image: corev1.ContainerImage{Names: names, SizeBytes: image.SizeBytes}, nodes: 1}
+		}
+	}
+
+	sorted := make([]*aggregate, 0, len(aggregates))
+	for _, entry := range aggregates {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].nodes != sorted[j].nodes {
+			return sorted[i].nodes > sorted[j].nodes
+		}
+		if sorted[i].image.SizeBytes != sorted[j].image.SizeBytes {
+			return sorted[i].image.SizeBytes > sorted[j].image.SizeBytes
+		}
+		return sorted[i].image.Names[0] < sorted[j].image.Names[0]
+	})
+
+	if len(sorted) > maxImages {
+		sorted = sorted[:maxImages]
+	}
+
+	images := make([]corev1.ContainerImage, len(sorted))
+	for i := range sorted {
+		images[i] = sorted[i].image
+	}
+	return images
+}
@@ -17,6 +17,7 @@ package resourcerequestoperator
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -101,4 +102,48 @@ var _ = Describe("Utility functions", func() {
 			})
 		})
 	})
+
+	Describe("The aggregateNodeImages function", func() {
+		var (
+			nodes  []corev1.Node
+			images []corev1.ContainerImage
+		)
+
+		node := func(ready bool, images ...corev1.ContainerImage) corev1.Node {
+			status := corev1.ConditionFalse
+			if ready {
+				status = corev1.ConditionTrue
+			}
+			return corev1.Node{Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+				Images:     images,
+			}}
+		}
+
+		image := func(size int64, names ...string) corev1.ContainerImage {
+			return corev1.ContainerImage{Names: names, SizeBytes: size}
+		}
+
+		BeforeEach(func() {
+			nodes = []corev1.Node{
+				node(true, image(100, "foo:v1", "foo@sha256:1"), image(200, "bar:v1"), image(50, "baz:v1")),
+				node(true, image(100, "foo@sha256:1", "foo:v1"), image(10, "qux:v1"), image(0)),
+				node(false, image(300, "bar:v1"), image(500, "unready:v1")),
+			}
+		})
+
+		JustBeforeEach(func() { images = aggregateNodeImages(nodes, 3) })
+
+		It("should deduplicate the images stored in the ready nodes, sorting them by availability and size", func() {
+			Expect(images).To(ConsistOf(image(100, "foo:v1", "foo@sha256:1"), image(200, "bar:v1"), image(50, "baz:v1")))
+			Expect(images[0].Names).To(ConsistOf("foo:v1", "foo@sha256:1"))
+			Expect(images[1].Names).To(ConsistOf("bar:v1"))
+			Expect(images[2].Names).To(ConsistOf("baz:v1"))
+		})
+
+		When("no node is ready", func() {
+			BeforeEach(func() { nodes = nodes[2:] })
+			It("should return an empty list", func() { Expect(images).To(BeEmpty()) })
+		})
+	})
 })
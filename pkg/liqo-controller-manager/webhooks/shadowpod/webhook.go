@@ -106,6 +106,18 @@ func (spv *Validator) HandleCreate(ctx context.Context, req *admission.Request)
 		return admission.Denied(err.Error())
 	}
 
+	// Check whether the privileged features required by the shadow pod (if any) are permitted by the local policy.
+	if len(pod.PrivilegedFeatures(&shadowpod.Spec.Pod)) > 0 {
+		code, err = spv.validateShadowPodFeatures(ctx, clusterID, &shadowpod.Spec.Pod)
+		if err != nil {
+			klog.Warningf("ShadowPod %q rejected: %v", klog.KObj(shadowpod), err)
+			if code == http.StatusInternalServerError {
+				return admission.Errored(code, err)
+			}
+			return admission.Denied(err.Error())
+		}
+	}
+
 	if !spv.enableResourceValidation {
 		return admission.Allowed("")
 	}
@@ -238,6 +250,22 @@ func (spv *Validator) validateShadowPodClusterID(ctx context.Context, ns, spClus
 	return http.StatusOK, nil
 }
 
+func (spv *Validator) validateShadowPodFeatures(ctx context.Context, spClusterID string, spec *corev1.PodSpec) (int32, error) {
+	// Retrieve the resource offer, which contains the privileged features permitted to the given cluster.
+	resourceoffer, err := liqogetters.GetResourceOfferByLabel(ctx, spv.client, corev1.NamespaceAll,
+		liqolabels.LocalLabelSelectorForCluster(spClusterID))
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("error getting resource offer by label: %w", err)
+	}
+
+	forbidden := pod.ForbiddenFeatures(spec, resourceoffer.Spec.AllowedPodFeatures)
+	if len(forbidden) > 0 {
+		return http.StatusForbidden, fmt.Errorf("the provider cluster does not permit offloaded pods to use %v", forbidden)
+	}
+
+	return http.StatusOK, nil
+}
+
 // DecodeShadowPod decodes a shadow pod from a given runtime object.
 func (spv *Validator) DecodeShadowPod(obj runtime.RawExtension) (shadowpod *vkv1alpha1.ShadowPod, err error) {
 	shadowpod = &vkv1alpha1.ShadowPod{}
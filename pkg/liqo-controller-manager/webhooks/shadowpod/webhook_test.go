@@ -29,6 +29,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	sharingv1alpha1 "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	vkv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
 )
 
@@ -91,6 +92,33 @@ var _ = Describe("Validating webhook", func() {
 				Expect(response.Allowed).To(BeTrue())
 			})
 		})
+		When("the shadowpod requires privileged features not permitted by the ResourceOffer", func() {
+			BeforeEach(func() {
+				fakeNewShadowPod = forgeShadowPodWithClusterID(clusterID, testNamespace)
+				fakeNewShadowPod.Spec.Pod.HostNetwork = true
+				request = forgeRequest(admissionv1.Create, fakeNewShadowPod, nil)
+			})
+			It("should return a forbidden response", func() {
+				Expect(response.Allowed).To(BeFalse())
+				Expect(response.Result.Code).To(BeNumerically("==", http.StatusForbidden))
+				Expect(string(response.Result.Reason)).To(ContainSubstring(string(sharingv1alpha1.PodFeatureHostNetwork)))
+			})
+		})
+		When("the shadowpod requires privileged features permitted by the ResourceOffer", func() {
+			BeforeEach(func() {
+				offer := forgeResourceOfferWithLabel(clusterName, tenantNamespace, clusterID)
+				Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(offer), offer)).To(Succeed())
+				offer.Spec.AllowedPodFeatures = []sharingv1alpha1.PodFeature{sharingv1alpha1.PodFeatureHostNetwork}
+				Expect(fakeClient.Update(ctx, offer)).To(Succeed())
+
+				fakeNewShadowPod = forgeShadowPodWithClusterID(clusterID, testNamespace)
+				fakeNewShadowPod.Spec.Pod.HostNetwork = true
+				request = forgeRequest(admissionv1.Create, fakeNewShadowPod, nil)
+			})
+			It("should admit the request", func() {
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
 		When("the shadowpod namespace not exists", func() {
 			BeforeEach(func() {
 				fakeNewShadowPod = forgeShadowPodWithClusterID(clusterID, testNamespaceInvalid)
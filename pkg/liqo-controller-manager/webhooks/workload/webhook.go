@@ -0,0 +1,142 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/liqotech/liqo/pkg/consts"
+	liqogetters "github.com/liqotech/liqo/pkg/utils/getters"
+	liqolabels "github.com/liqotech/liqo/pkg/utils/labels"
+	"github.com/liqotech/liqo/pkg/utils/pod"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+// cluster-role
+// +kubebuilder:rbac:groups=sharing.liqo.io,resources=resourceoffers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// Validator is the handler used by the Validating Webhook to validate the workloads reflected by remote clusters.
+type Validator struct {
+	client                   client.Client
+	decoder                  *admission.Decoder
+	enableResourceValidation bool
+}
+
+// NewValidator creates a new workload validator.
+func NewValidator(c client.Client, enableResourceValidation bool) *Validator {
+	return &Validator{
+		client:                   c,
+		enableResourceValidation: enableResourceValidation,
+	}
+}
+
+// Handle is the function in charge of handling the webhook validation request about the creation and update of workloads.
+//
+//nolint:gocritic // the signature of this method is imposed by controller runtime.
+func (wv *Validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	meta, template, err := wv.DecodeWorkload(&req)
+	if err != nil {
+		klog.Errorf("Failed decoding %v: %v", req.Kind.Kind, err)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed decoding of %v: %w", req.Kind.Kind, err))
+	}
+
+	// The origin cluster is identified by the namespace, as the labels of the object are set by the requester.
+	namespace := &corev1.Namespace{}
+	if err := wv.client.Get(ctx, client.ObjectKey{Name: req.Namespace}, namespace); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	clusterID, found := namespace.Labels[consts.RemoteClusterID]
+	if !found {
+		// The namespace is not used to host the workloads offloaded by a remote cluster.
+		return admission.Allowed("")
+	}
+
+	if meta.Labels[forge.LiqoOriginClusterIDKey] != clusterID {
+		klog.Warningf("Cluster ID %q of namespace %q does not match the %v Cluster ID %q",
+			clusterID, req.Namespace, req.Kind.Kind, meta.Labels[forge.LiqoOriginClusterIDKey])
+		return admission.Denied(fmt.Sprintf("%v Cluster ID label mismatch", req.Kind.Kind))
+	}
+
+	// Check whether the privileged features required by the pod template (if any) are permitted by the local policy.
+	if len(pod.PrivilegedFeatures(&template.Spec)) > 0 {
+		resourceoffer, err := liqogetters.GetResourceOfferByLabel(ctx, wv.client, corev1.NamespaceAll,
+			liqolabels.LocalLabelSelectorForCluster(clusterID))
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting resource offer by label: %w", err))
+		}
+
+		if forbidden := pod.ForbiddenFeatures(&template.Spec, resourceoffer.Spec.AllowedPodFeatures); len(forbidden) > 0 {
+			klog.Warningf("%v %s/%s rejected: privileged features %v not permitted", req.Kind.Kind, req.Namespace, meta.Name, forbidden)
+			return admission.Denied(fmt.Sprintf("the provider cluster does not permit offloaded pods to use %v", forbidden))
+		}
+	}
+
+	// The pods created by the workload controllers do not go through the ShadowPod validation,
+	// hence they cannot be accounted against the resource quota of the peering.
+	if wv.enableResourceValidation {
+		klog.Warningf("%v %s/%s rejected: not supported when the resource enforcement is enabled", req.Kind.Kind, req.Namespace, meta.Name)
+		return admission.Denied(fmt.Sprintf("offloading %v objects is not supported when the provider cluster enforces the resource quota",
+			req.Kind.Kind))
+	}
+
+	return admission.Allowed("")
+}
+
+// DecodeWorkload decodes the workload included in the given request, returning its metadata and pod template.
+func (wv *Validator) DecodeWorkload(req *admission.Request) (*metav1.ObjectMeta, *corev1.PodTemplateSpec, error) {
+	switch req.Kind.Kind {
+	case "DaemonSet":
+		var ds appsv1.DaemonSet
+		if err := wv.decoder.DecodeRaw(req.Object, &ds); err != nil {
+			return nil, nil, err
+		}
+		return &ds.ObjectMeta, &ds.Spec.Template, nil
+	case "Deployment":
+		var deploy appsv1.Deployment
+		if err := wv.decoder.DecodeRaw(req.Object, &deploy); err != nil {
+			return nil, nil, err
+		}
+		return &deploy.ObjectMeta, &deploy.Spec.Template, nil
+	case "StatefulSet":
+		var sts appsv1.StatefulSet
+		if err := wv.decoder.DecodeRaw(req.Object, &sts); err != nil {
+			return nil, nil, err
+		}
+		return &sts.ObjectMeta, &sts.Spec.Template, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported kind %v", req.Kind.Kind)
+	}
+}
+
+// InjectDecoder injects the decoder.
+func (wv *Validator) InjectDecoder(d *admission.Decoder) error {
+	wv.decoder = d
+	return nil
+}
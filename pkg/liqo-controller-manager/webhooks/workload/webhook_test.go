@@ -0,0 +1,152 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	sharingv1alpha1 "github.com/liqotech/liqo/apis/sharing/v1alpha1"
+	"github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/discovery"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+var _ = Describe("Validating webhook", func() {
+	const (
+		clusterID = "remote-cluster-id"
+		namespace = "offloaded"
+	)
+
+	var (
+		ctx        context.Context
+		cl         client.Client
+		ds         *appsv1.DaemonSet
+		object     client.Object
+		validator  *Validator
+		enforce    bool
+		allowed    []sharingv1alpha1.PodFeature
+		objectKind string
+		response   admission.Response
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		enforce = false
+		allowed = nil
+		objectKind = "DaemonSet"
+		ds = &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ds", Namespace: namespace, Labels: map[string]string{forge.LiqoOriginClusterIDKey: clusterID}},
+			Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "foo", Image: "foo"}}}}},
+		}
+		object = ds
+	})
+
+	JustBeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: map[string]string{consts.RemoteClusterID: clusterID}}}
+		offer := &sharingv1alpha1.ResourceOffer{
+			ObjectMeta: metav1.ObjectMeta{Name: "offer", Namespace: "tenant", Labels: map[string]string{
+				discovery.ClusterIDLabel: clusterID, consts.ReplicationDestinationLabel: clusterID, consts.ReplicationRequestedLabel: "true"}},
+			Spec: sharingv1alpha1.ResourceOfferSpec{AllowedPodFeatures: allowed},
+		}
+		cl = fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns, offer).Build()
+		validator = NewValidator(cl, enforce)
+		Expect(validator.InjectDecoder(decoder)).To(Succeed())
+
+		raw, err := json.Marshal(object)
+		Expect(err).ToNot(HaveOccurred())
+		response = validator.Handle(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create, Namespace: object.GetNamespace(), Name: object.GetName(),
+			Kind: metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: objectKind}, Object: runtime.RawExtension{Raw: raw},
+		}})
+	})
+
+	When("the daemonset does not require privileged features", func() {
+		It("should admit the request", func() { Expect(response.Allowed).To(BeTrue()) })
+	})
+
+	When("the origin cluster ID does not match the one of the namespace", func() {
+		BeforeEach(func() { ds.Labels[forge.LiqoOriginClusterIDKey] = "other" })
+		It("should deny the request", func() { Expect(response.Allowed).To(BeFalse()) })
+	})
+
+	When("the namespace is not hosting offloaded workloads", func() {
+		BeforeEach(func() { ds.Namespace = "other" })
+		It("should return a bad request response, as the namespace does not exist", func() {
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Code).To(BeNumerically("==", http.StatusBadRequest))
+		})
+	})
+
+	When("the daemonset requires privileged features not permitted by the provider", func() {
+		BeforeEach(func() { ds.Spec.Template.Spec.HostNetwork = true })
+		It("should deny the request", func() {
+			Expect(response.Allowed).To(BeFalse())
+			Expect(string(response.Result.Reason)).To(ContainSubstring("HostNetwork"))
+		})
+	})
+
+	When("the daemonset requires privileged features permitted by the provider", func() {
+		BeforeEach(func() {
+			ds.Spec.Template.Spec.HostNetwork = true
+			allowed = []sharingv1alpha1.PodFeature{sharingv1alpha1.PodFeatureHostNetwork}
+		})
+		It("should admit the request", func() { Expect(response.Allowed).To(BeTrue()) })
+	})
+
+	When("the deployment requires privileged features not permitted by the provider", func() {
+		BeforeEach(func() {
+			objectKind = "Deployment"
+			object = &appsv1.Deployment{
+				ObjectMeta: ds.ObjectMeta,
+				Spec:       appsv1.DeploymentSpec{Template: *ds.Spec.Template.DeepCopy()},
+			}
+			object.(*appsv1.Deployment).Spec.Template.Spec.HostPID = true
+		})
+		It("should deny the request", func() {
+			Expect(response.Allowed).To(BeFalse())
+			Expect(string(response.Result.Reason)).To(ContainSubstring("HostPID"))
+		})
+	})
+
+	When("the provider enforces the resource quota", func() {
+		BeforeEach(func() { enforce = true })
+		It("should deny the request", func() {
+			Expect(response.Allowed).To(BeFalse())
+			Expect(string(response.Result.Reason)).To(ContainSubstring("resource quota"))
+		})
+	})
+
+	When("the kind is not supported", func() {
+		BeforeEach(func() { objectKind = "ReplicaSet" })
+		It("should return a bad request response", func() {
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Code).To(BeNumerically("==", http.StatusBadRequest))
+		})
+	})
+})
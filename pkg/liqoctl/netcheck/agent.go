@@ -0,0 +1,138 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	"github.com/liqotech/liqo/pkg/liqonet/diagnostics"
+)
+
+// agentClient queries the diagnostics agent running in a given pod.
+type agentClient interface {
+	// Get performs a GET request to the given path of the agent, decoding the JSON response into out.
+	Get(ctx context.Context, pod *corev1.Pod, path string, params map[string]string, out interface{}) error
+}
+
+// portForwardAgentClient queries the diagnostics agents through a port-forward towards the corresponding pod,
+// as the agents only listen on the loopback interface.
+type portForwardAgentClient struct {
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+// Get performs a GET request to the given path of the agent, decoding the JSON response into out.
+func (c *portForwardAgentClient) Get(ctx context.Context, pod *corev1.Pod, path string, params map[string]string, out interface{}) error {
+	port, err := diagnosticsPort(pod)
+	if err != nil {
+		return err
+	}
+
+	local, stop, err := c.forward(pod, port)
+	if err != nil {
+		return fmt.Errorf("failed to port-forward to the diagnostics agent of pod %q: %w", pod.GetName(), err)
+	}
+	defer close(stop)
+
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	target := url.URL{Scheme: "http", Host: net.JoinHostPort("localhost", strconv.Itoa(int(local))), Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query the diagnostics agent of pod %q: %w", pod.GetName(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to query the diagnostics agent of pod %q: %s: %s",
+			pod.GetName(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the response of the diagnostics agent of pod %q: %w", pod.GetName(), err)
+	}
+	return nil
+}
+
+// forward starts forwarding a random local port to the given port of the pod, returning the local port
+// and the channel to be closed to stop the forwarding.
+func (c *portForwardAgentClient) forward(pod *corev1.Pod, port string) (local uint16, stop chan struct{}, err error) {
+	transport, upgrader, err := spdy.RoundTripperFor(c.config)
+	if err != nil {
+		return 0, nil, err
+	}
+	podURL := c.client.CoreV1().RESTClient().Post().Resource("pods").
+		Namespace(pod.GetNamespace()).Name(pod.GetName()).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, podURL)
+
+	stop, ready := make(chan struct{}), make(chan struct{})
+	pf, err := portforward.NewOnAddresses(dialer, []string{"localhost"}, []string{"0:" + port}, stop, ready, io.Discard, io.Discard)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- pf.ForwardPorts() }()
+
+	select {
+	case err = <-errs:
+		close(stop)
+		if err == nil {
+			err = fmt.Errorf("port-forward terminated unexpectedly")
+		}
+		return 0, nil, err
+	case <-ready:
+	}
+
+	ports, err := pf.GetPorts()
+	if err != nil || len(ports) != 1 {
+		close(stop)
+		return 0, nil, fmt.Errorf("failed to retrieve the forwarded port: %w", err)
+	}
+	return ports[0].Local, stop, nil
+}
+
+// diagnosticsPort returns the port the diagnostics agent of the given pod is exposed on.
+func diagnosticsPort(pod *corev1.Pod) (string, error) {
+	for i := range pod.Spec.Containers {
+		for _, port := range pod.Spec.Containers[i].Ports {
+			if port.Name == diagnostics.PortName {
+				return strconv.Itoa(int(port.ContainerPort)), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the diagnostics agent is not enabled in pod %q (set the diagnostics.enabled Helm values)", pod.GetName())
+}
@@ -0,0 +1,403 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqoctl/factory"
+	"github.com/liqotech/liqo/pkg/liqoctl/output"
+	"github.com/liqotech/liqo/pkg/liqonet/diagnostics"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+const (
+	// These labels are the ones set during the deployment of liqo using the helm chart.
+	// Any change to those labels on the helm chart has also to be reflected here.
+	podNameLabelKey        = "app.kubernetes.io/name"
+	podComponentLabelKey   = "app.kubernetes.io/component"
+	podComponentLabelValue = "networking"
+	gatewayNameLabelValue  = "gateway"
+	routeNameLabelValue    = "route"
+	gatewayLabelKey        = "net.liqo.io/gateway"
+	gatewayStatusActive    = "active"
+)
+
+// Options encapsulates the arguments of the network check command.
+type Options struct {
+	*factory.Factory
+
+	ClusterName string
+	// Node is the node the probes towards the remote cluster originate from (empty to select it automatically).
+	Node string
+	// Target is the address of the remote pod to be probed (empty to select it automatically).
+	Target string
+	// Dump is whether to output the routes, rules and NAT entries concerning the remote cluster.
+	Dump    bool
+	Timeout time.Duration
+
+	agents agentClient
+}
+
+// peeringInfo contains the information required to check the path towards a remote cluster.
+type peeringInfo struct {
+	clusterID discoveryv1alpha1.ClusterIdentity
+	tep       *netv1alpha1.TunnelEndpoint
+	// transit is the route towards the checked cluster, in case it is reached in transit through the peered one (nil otherwise).
+	transit *netv1alpha1.TransitRoute
+
+	gateway     *corev1.Pod
+	gatewayDump *diagnostics.Dump
+	route       *corev1.Pod
+	routeDump   *diagnostics.Dump
+	routeErr    error
+
+	// target is the address of the remote pod to be probed (empty if not available).
+	target string
+}
+
+// hop is the outcome of the check of a hop of the path towards the remote cluster.
+type hop struct {
+	from, to string
+	ok       bool
+	// skipped is whether the hop could not be checked (e.g., no remote pod to be probed is available).
+	skipped bool
+	detail  string
+}
+
+// Run implements the network check command.
+func (o *Options) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	if o.agents == nil {
+		o.agents = &portForwardAgentClient{config: o.RESTConfig, client: o.KubeClient}
+	}
+
+	s := o.Printer.StartSpinner("Retrieving the information about the peering")
+	info, err := o.collect(ctx)
+	if err != nil {
+		s.Fail("Failed retrieving the information about the peering: ", output.PrettyErr(err))
+		return err
+	}
+	s.Success("Information about the peering correctly retrieved")
+	if info.transit != nil {
+		o.Printer.Info.Printfln("Cluster %q (%s) is reached in transit through cluster %q",
+			o.ClusterName, strings.Join(info.transit.CIDRs, ", "), info.clusterID.ClusterName)
+	}
+
+	s = o.Printer.StartSpinner("Probing the path towards the remote cluster")
+	hops := o.check(ctx, info)
+	s.Success("Path towards the remote cluster probed")
+
+	for i := range hops {
+		o.printHop(&hops[i])
+	}
+	if o.Dump {
+		o.printDumps(info)
+	}
+
+	if broken := firstFailure(hops); broken != nil {
+		o.Printer.Error.Printfln("The path towards cluster %q breaks between %s and %s", o.ClusterName, broken.from, broken.to)
+		return fmt.Errorf("the path towards cluster %q is broken", o.ClusterName)
+	}
+	o.Printer.Success.Printfln("The path towards cluster %q is healthy", o.ClusterName)
+	return nil
+}
+
+// collect retrieves the information required to check the path towards the remote cluster.
+func (o *Options) collect(ctx context.Context) (*peeringInfo, error) {
+	info, err := o.peering(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.gateway, info.gatewayDump, err = o.gatewayAgent(ctx, info.clusterID.ClusterID); err != nil {
+		return nil, err
+	}
+	if info.route, err = o.routeAgent(ctx, info.gateway); err != nil {
+		return nil, err
+	}
+	info.routeDump = &diagnostics.Dump{}
+	info.routeErr = o.agents.Get(ctx, info.route, diagnostics.DumpPath,
+		map[string]string{diagnostics.ClusterIDParameter: info.clusterID.ClusterID}, info.routeDump)
+
+	// The pods of clusters reached in transit cannot be retrieved, as not offloaded to them.
+	if info.target = o.Target; info.target == "" && info.transit == nil {
+		if info.target, err = o.remotePodIP(ctx, info.clusterID.ClusterID); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// peering returns the information about the peering the traffic towards the checked cluster traverses, that is
+// the one with the cluster itself if directly peered, or the one with the cluster advertising a transit route otherwise.
+func (o *Options) peering(ctx context.Context) (*peeringInfo, error) {
+	var fc discoveryv1alpha1.ForeignCluster
+	err := o.CRClient.Get(ctx, types.NamespacedName{Name: o.ClusterName}, &fc)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to retrieve the foreign cluster %q: %w", o.ClusterName, err)
+	}
+
+	if err == nil {
+		var teps netv1alpha1.TunnelEndpointList
+		if err := o.CRClient.List(ctx, &teps, client.MatchingLabels{liqoconst.ClusterIDLabelName: fc.Spec.ClusterIdentity.ClusterID}); err != nil {
+			return nil, fmt.Errorf("failed to retrieve the tunnel endpoint: %w", err)
+		}
+		if len(teps.Items) != 1 {
+			return nil, fmt.Errorf("found %d tunnel endpoints for cluster %q, expected 1", len(teps.Items), o.ClusterName)
+		}
+		return &peeringInfo{clusterID: fc.Spec.ClusterIdentity, tep: &teps.Items[0]}, nil
+	}
+
+	var teps netv1alpha1.TunnelEndpointList
+	if err := o.CRClient.List(ctx, &teps); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the tunnel endpoints: %w", err)
+	}
+	for i := range teps.Items {
+		for j := range teps.Items[i].Spec.TransitRoutes {
+			if route := &teps.Items[i].Spec.TransitRoutes[j]; route.Cluster.ClusterName == o.ClusterName {
+				return &peeringInfo{clusterID: teps.Items[i].Spec.ClusterIdentity, tep: &teps.Items[i], transit: route}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cluster %q is neither peered nor reachable in transit through a peered cluster", o.ClusterName)
+}
+
+// gatewayAgent returns the active gateway replica handling the tunnel towards the given remote cluster, and the
+// corresponding configuration. In case no replica configured the tunnel, the first active one is returned.
+func (o *Options) gatewayAgent(ctx context.Context, clusterID string) (*corev1.Pod, *diagnostics.Dump, error) {
+	gateways, err := o.listPods(ctx, labels.Set{
+		podNameLabelKey: gatewayNameLabelValue, podComponentLabelKey: podComponentLabelValue, gatewayLabelKey: gatewayStatusActive})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(gateways) == 0 {
+		return nil, nil, fmt.Errorf("no active gateway replica found in namespace %q", o.LiqoNamespace)
+	}
+
+	var dumps []*diagnostics.Dump
+	for i := range gateways {
+		dump := &diagnostics.Dump{}
+		if err := o.agents.Get(ctx, &gateways[i], diagnostics.DumpPath, map[string]string{diagnostics.ClusterIDParameter: clusterID}, dump); err != nil {
+			return nil, nil, err
+		}
+		if dump.Configured {
+			return &gateways[i], dump, nil
+		}
+		dumps = append(dumps, dump)
+	}
+	return &gateways[0], dumps[0], nil
+}
+
+// routeAgent returns the route replica the probes originate from, running on the selected node if specified,
+// and preferably on a node different from the one hosting the given gateway replica otherwise.
+func (o *Options) routeAgent(ctx context.Context, gateway *corev1.Pod) (*corev1.Pod, error) {
+	routes, err := o.listPods(ctx, labels.Set{podNameLabelKey: routeNameLabelValue, podComponentLabelKey: podComponentLabelValue})
+	if err != nil {
+		return nil, err
+	}
+
+	var selected *corev1.Pod
+	for i := range routes {
+		switch {
+		case o.Node != "" && routes[i].Spec.NodeName == o.Node:
+			return &routes[i], nil
+		case o.Node == "" && routes[i].Spec.NodeName != gateway.Spec.NodeName:
+			return &routes[i], nil
+		case o.Node == "" && selected == nil:
+			selected = &routes[i]
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("no running route replica found in namespace %q on node %q", o.LiqoNamespace, o.Node)
+	}
+	return selected, nil
+}
+
+// listPods returns the running pods matching the given labels in the liqo namespace.
+func (o *Options) listPods(ctx context.Context, set labels.Set) ([]corev1.Pod, error) {
+	pods, err := o.KubeClient.CoreV1().Pods(o.LiqoNamespace).List(ctx, metav1.ListOptions{LabelSelector: set.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pods in namespace %q: %w", o.LiqoNamespace, err)
+	}
+
+	var running []corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning {
+			running = append(running, pods.Items[i])
+		}
+	}
+	return running, nil
+}
+
+// remotePodIP returns the address of a pod offloaded to the given remote cluster, as seen by the local cluster
+// (empty if none is available).
+func (o *Options) remotePodIP(ctx context.Context, clusterID string) (string, error) {
+	nodes, err := o.KubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{liqoconst.RemoteClusterID: clusterID}.String()})
+	if err != nil {
+		return "", fmt.Errorf("failed to list the virtual nodes: %w", err)
+	}
+
+	for i := range nodes.Items {
+		pods, err := o.KubeClient.CoreV1().Pods(corev1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodes.Items[i].Name).String()})
+		if err != nil {
+			return "", fmt.Errorf("failed to list the pods offloaded to cluster %q: %w", o.ClusterName, err)
+		}
+		for j := range pods.Items {
+			pod := &pods.Items[j]
+			if pod.Spec.NodeName == nodes.Items[i].Name && pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" && !pod.Spec.HostNetwork {
+				return pod.Status.PodIP, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// check verifies each hop of the path towards the remote cluster.
+func (o *Options) check(ctx context.Context, info *peeringInfo) []hop {
+	node := fmt.Sprintf("node %q", info.route.Spec.NodeName)
+	gateway := fmt.Sprintf("gateway %q", info.gateway.Name)
+	remoteGateway := "remote gateway"
+	remotePod := fmt.Sprintf("remote pod %s", info.target)
+	if info.transit != nil {
+		remoteGateway = fmt.Sprintf("transit gateway of cluster %q", info.clusterID.ClusterName)
+		remotePod = fmt.Sprintf("pod %s of cluster %q", info.target, o.ClusterName)
+	}
+
+	// The gateway is reached through the veth pair if running on the same node, and through the overlay network
+	// (or directly, if the overlay is disabled in favor of the routes configured by the CNI) otherwise.
+	gatewayIP := liqonetutils.GetOverlayIP(info.gateway.Status.PodIP)
+	switch {
+	case info.route.Spec.NodeName == info.gateway.Spec.NodeName:
+		gatewayIP = liqoconst.GatewayVethIPAddr
+	case info.routeErr == nil && info.routeDump.NativeRouting:
+		gatewayIP = info.gateway.Status.PodIP
+	}
+	hops := []hop{o.probe(ctx, node, gateway, info.route, gatewayIP)}
+
+	tunnel := hop{from: gateway, to: "tunnel"}
+	switch {
+	case !info.gatewayDump.Configured:
+		tunnel.detail = "the tunnel is not configured by the gateway"
+	case info.tep.Status.Connection.Status != netv1alpha1.Connected:
+		tunnel.detail = fmt.Sprintf("the tunnel is %s: %s", info.tep.Status.Connection.Status, info.tep.Status.Connection.StatusMessage)
+	default:
+		tunnel.ok, tunnel.detail = true, fmt.Sprintf("the tunnel is %s", netv1alpha1.Connected)
+	}
+	hops = append(hops, tunnel)
+
+	remoteProbeIP := liqonetutils.GetTunnelProbeIP(info.tep.Spec.ClusterIdentity.ClusterID)
+	hops = append(hops, o.probe(ctx, "tunnel", remoteGateway, info.gateway, remoteProbeIP.String()))
+
+	if info.target == "" {
+		detail := "no pod offloaded to the remote cluster found (use the --target flag to specify one)"
+		if info.transit != nil {
+			detail = "the pods of clusters reached in transit cannot be retrieved (use the --target flag to specify one)"
+		}
+		return append(hops,
+			hop{from: remoteGateway, to: "remote pod", skipped: true, detail: detail},
+			hop{from: node, to: "remote pod", skipped: true, detail: detail})
+	}
+	return append(hops,
+		o.probe(ctx, remoteGateway, remotePod, info.gateway, info.target),
+		o.probe(ctx, node, remotePod, info.route, info.target))
+}
+
+// probe requests the agent running in the given pod to probe the given target, and returns the corresponding hop.
+func (o *Options) probe(ctx context.Context, from, to string, pod *corev1.Pod, target string) hop {
+	var result diagnostics.ProbeResult
+	err := o.agents.Get(ctx, pod, diagnostics.ProbePath, map[string]string{diagnostics.TargetParameter: target}, &result)
+	switch {
+	case err != nil:
+		return hop{from: from, to: to, detail: err.Error()}
+	case result.Error != "":
+		return hop{from: from, to: to, detail: fmt.Sprintf("failed to probe %s: %s", target, result.Error)}
+	case !result.Reachable:
+		return hop{from: from, to: to, detail: fmt.Sprintf("no reply from %s", target)}
+	default:
+		return hop{from: from, to: to, ok: true, detail: fmt.Sprintf("%s is reachable", target)}
+	}
+}
+
+// firstFailure returns the first hop of the path which failed the check, if any.
+func firstFailure(hops []hop) *hop {
+	for i := range hops {
+		if !hops[i].ok && !hops[i].skipped {
+			return &hops[i]
+		}
+	}
+	return nil
+}
+
+// printHop outputs the outcome of the check of the given hop.
+func (o *Options) printHop(h *hop) {
+	switch {
+	case h.ok:
+		o.Printer.Success.Printfln("%s -> %s: %s", h.from, h.to, h.detail)
+	case h.skipped:
+		o.Printer.Warning.Printfln("%s -> %s: skipped, %s", h.from, h.to, h.detail)
+	default:
+		o.Printer.Error.Printfln("%s -> %s: %s", h.from, h.to, h.detail)
+	}
+}
+
+// printDumps outputs the routes, rules and NAT entries concerning the remote cluster.
+func (o *Options) printDumps(info *peeringInfo) {
+	orNone := func(values []string) []string {
+		if len(values) == 0 {
+			return []string{"none"}
+		}
+		return values
+	}
+
+	root := output.NewRootSection()
+	gateway := root.AddSectionWithDetail("Gateway", info.gateway.Name)
+	gateway.AddEntry("Routes", orNone(info.gatewayDump.Routes)...)
+	gateway.AddEntry("Rules", orNone(info.gatewayDump.Rules)...)
+	gateway.AddEntry("NAT", orNone(info.gatewayDump.NAT)...)
+	route := root.AddSectionWithDetail("Route", info.route.Name)
+	if info.routeErr != nil {
+		route.AddEntry("Error", info.routeErr.Error())
+	} else {
+		route.AddEntry("Routes", orNone(info.routeDump.Routes)...)
+		route.AddEntry("Rules", orNone(info.routeDump.Rules)...)
+	}
+
+	text, err := root.SprintForBox(o.Printer)
+	if err != nil {
+		o.Printer.Error.Printfln("Failed to output the network configuration: %s", output.PrettyErr(err))
+		return
+	}
+	o.Printer.BoxSetTitle(fmt.Sprintf("Network configuration towards cluster %q", o.ClusterName))
+	o.Printer.BoxPrintln(text)
+}
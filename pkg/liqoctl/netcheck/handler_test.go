@@ -0,0 +1,220 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netcheck
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqoctl/factory"
+	"github.com/liqotech/liqo/pkg/liqoctl/output"
+	"github.com/liqotech/liqo/pkg/liqonet/diagnostics"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// fakeAgents is a fake implementation of the agentClient interface, replying according to the configured maps.
+type fakeAgents struct {
+	// configured contains the names of the gateway pods which configured the tunnel.
+	configured map[string]bool
+	// native is whether the route pods forward the traffic through the routes configured by the CNI.
+	native bool
+	// unreachable contains the targets which do not reply to the probes, keyed by the name of the probing pod.
+	unreachable map[string]string
+	probed      []string
+}
+
+func (fa *fakeAgents) Get(_ context.Context, pod *corev1.Pod, path string, params map[string]string, out interface{}) error {
+	switch path {
+	case diagnostics.DumpPath:
+		out.(*diagnostics.Dump).Configured = fa.configured[pod.Name]
+		out.(*diagnostics.Dump).NativeRouting = fa.native
+		out.(*diagnostics.Dump).Routes = []string{fmt.Sprintf("route from %s", pod.Name)}
+	case diagnostics.ProbePath:
+		target := params[diagnostics.TargetParameter]
+		fa.probed = append(fa.probed, fmt.Sprintf("%s->%s", pod.Name, target))
+		out.(*diagnostics.ProbeResult).Target = target
+		out.(*diagnostics.ProbeResult).Reachable = fa.unreachable[pod.Name] != target
+	}
+	return nil
+}
+
+var _ = Describe("Network check", func() {
+	const (
+		namespace   = "liqo"
+		clusterName = "remote"
+		clusterID   = "remote-cluster-id"
+	)
+
+	var (
+		ctx     context.Context
+		options *Options
+		agents  *fakeAgents
+		tep     *netv1alpha1.TunnelEndpoint
+		pods    []runtime.Object
+		checked string
+		target  string
+		err     error
+	)
+
+	// probeIP is the link-local address of the remote gateway, probed through the tunnel.
+	probeIP := liqonetutils.GetTunnelProbeIP(clusterID).String()
+
+	pod := func(name, node, ip string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+		}
+	}
+	gateway := func(name, node, ip string) *corev1.Pod {
+		return pod(name, node, ip, map[string]string{podNameLabelKey: gatewayNameLabelValue,
+			podComponentLabelKey: podComponentLabelValue, gatewayLabelKey: gatewayStatusActive})
+	}
+	route := func(name, node string) *corev1.Pod {
+		return pod(name, node, "", map[string]string{podNameLabelKey: routeNameLabelValue, podComponentLabelKey: podComponentLabelValue})
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		checked, target = clusterName, ""
+		agents = &fakeAgents{configured: map[string]bool{"gateway-2": true}, unreachable: map[string]string{}}
+		tep = &netv1alpha1.TunnelEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "tep", Namespace: namespace, Labels: map[string]string{liqoconst.ClusterIDLabelName: clusterID}},
+			Spec: netv1alpha1.TunnelEndpointSpec{ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: clusterID},
+				RemotePodCIDR: "10.200.0.0/16", RemoteNATPodCIDR: liqoconst.DefaultCIDRValue},
+			Status: netv1alpha1.TunnelEndpointStatus{Connection: netv1alpha1.Connection{Status: netv1alpha1.Connected}},
+		}
+		pods = []runtime.Object{
+			gateway("gateway-1", "node-2", "10.0.2.5"), gateway("gateway-2", "node-1", "10.0.1.5"),
+			route("route-1", "node-1"), route("route-2", "node-2"),
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "virtual", Labels: map[string]string{liqoconst.RemoteClusterID: clusterID}}},
+			pod("offloaded", "virtual", "10.200.0.12", nil),
+		}
+	})
+
+	JustBeforeEach(func() {
+		fc := &discoveryv1alpha1.ForeignCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+			Spec:       discoveryv1alpha1.ForeignClusterSpec{ClusterIdentity: discoveryv1alpha1.ClusterIdentity{ClusterID: clusterID}},
+		}
+		options = &Options{
+			Factory: &factory.Factory{
+				CRClient:      ctrlfake.NewClientBuilder().WithObjects(fc, tep).Build(),
+				KubeClient:    fake.NewSimpleClientset(pods...),
+				LiqoNamespace: namespace,
+				Printer:       output.NewFakePrinter(GinkgoWriter),
+			},
+			ClusterName: checked,
+			Target:      target,
+			Timeout:     10 * time.Second,
+			Dump:        true,
+			agents:      agents,
+		}
+		err = options.Run(ctx)
+	})
+
+	When("the path towards the remote cluster is healthy", func() {
+		It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+		It("should probe each hop from the configured gateway and a route on a different node", func() {
+			Expect(agents.probed).To(Equal([]string{
+				"route-2->240.0.1.5", "gateway-2->" + probeIP, "gateway-2->10.200.0.12", "route-2->10.200.0.12"}))
+		})
+	})
+
+	When("the probes originate from the node hosting the gateway", func() {
+		BeforeEach(func() { pods = append(pods[:3], pods[4:]...) })
+		It("should probe the gateway through the veth pair", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(agents.probed).To(ContainElement("route-1->" + liqoconst.GatewayVethIPAddr))
+		})
+	})
+
+	When("the overlay network is disabled in favor of the routes configured by the CNI", func() {
+		BeforeEach(func() { agents.native = true })
+		It("should probe the gateway directly", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(agents.probed).To(ContainElement("route-2->10.0.1.5"))
+		})
+	})
+
+	When("no pod is offloaded to the remote cluster", func() {
+		BeforeEach(func() { pods = pods[:len(pods)-1] })
+		It("should skip the checks targeting the remote pod", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(agents.probed).To(Equal([]string{"route-2->240.0.1.5", "gateway-2->" + probeIP}))
+		})
+	})
+
+	When("the tunnel is not connected", func() {
+		BeforeEach(func() { tep.Status.Connection.Status = netv1alpha1.ConnectionError })
+		It("should fail", func() { Expect(err).To(HaveOccurred()) })
+	})
+
+	When("no gateway configured the tunnel", func() {
+		BeforeEach(func() { agents.configured = map[string]bool{} })
+		It("should fail", func() { Expect(err).To(HaveOccurred()) })
+	})
+
+	When("the remote pod is not reachable", func() {
+		BeforeEach(func() { agents.unreachable["gateway-2"] = "10.200.0.12" })
+		It("should fail", func() { Expect(err).To(HaveOccurred()) })
+	})
+
+	When("the tunnel endpoint does not exist", func() {
+		BeforeEach(func() { tep.Labels[liqoconst.ClusterIDLabelName] = "other" })
+		It("should fail", func() { Expect(err).To(HaveOccurred()) })
+	})
+
+	When("the cluster is reached in transit through the peered one", func() {
+		BeforeEach(func() {
+			checked = "spoke"
+			tep.Spec.TransitRoutes = []netv1alpha1.TransitRoute{{
+				Cluster: discoveryv1alpha1.ClusterIdentity{ClusterID: "spoke-cluster-id", ClusterName: checked},
+				CIDRs:   []string{"10.201.0.0/16"},
+			}}
+		})
+
+		It("should probe the path up to the transit gateway", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(agents.probed).To(Equal([]string{"route-2->240.0.1.5", "gateway-2->" + probeIP}))
+		})
+
+		When("the target pod is specified", func() {
+			BeforeEach(func() { target = "10.201.0.7" })
+			It("should probe the target pod through the transit gateway", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(agents.probed).To(Equal([]string{
+					"route-2->240.0.1.5", "gateway-2->" + probeIP, "gateway-2->10.201.0.7", "route-2->10.201.0.7"}))
+			})
+		})
+	})
+
+	When("the cluster is neither peered nor reachable in transit", func() {
+		BeforeEach(func() { checked = "unknown" })
+		It("should fail", func() { Expect(err).To(HaveOccurred()) })
+	})
+})
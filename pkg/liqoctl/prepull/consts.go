@@ -0,0 +1,32 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prepull
+
+const (
+	prepullNamePrefix = "liqo-prepull-"
+	prepullAppLabel   = "app.kubernetes.io/name"
+	prepullAppValue   = "liqo-prepull"
+
+	prepullVolumeName = "liqo-prepull"
+	prepullVolumePath = "/liqo-prepull"
+
+	busyboxImage = "busybox:1.36"
+	pauseImage   = "registry.k8s.io/pause:3.6"
+
+	prepullCPURequest    = "10m"
+	prepullCPULimit      = "50m"
+	prepullMemoryRequest = "16Mi"
+	prepullMemoryLimit   = "32Mi"
+)
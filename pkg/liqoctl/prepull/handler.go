@@ -0,0 +1,172 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prepull
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqoctl/factory"
+	"github.com/liqotech/liqo/pkg/liqoctl/output"
+)
+
+// Options encapsulates the arguments of the prepull command.
+type Options struct {
+	*factory.Factory
+
+	Images          []string
+	RemoteClusterID string
+	Timeout         time.Duration
+}
+
+// Run implements the prepull command.
+func (o *Options) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	ds := ForgeDaemonSet(o.Namespace, o.Images, o.RemoteClusterID)
+
+	s := o.Printer.StartSpinner(fmt.Sprintf("Creating the pre-pull DaemonSet in namespace %q", o.Namespace))
+	if err := o.CRClient.Create(ctx, ds); err != nil {
+		s.Fail(fmt.Sprintf("Failed creating the pre-pull DaemonSet: %v", output.PrettyErr(err)))
+		return err
+	}
+	s.Success(fmt.Sprintf("Pre-pull DaemonSet %q correctly created", ds.GetName()))
+
+	// Make sure the DaemonSet is removed in any case, using a new context in case the original one expired.
+	defer func() {
+		s := o.Printer.StartSpinner(fmt.Sprintf("Deleting the pre-pull DaemonSet %q", ds.GetName()))
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := o.CRClient.Delete(ctx, ds, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			s.Fail(fmt.Sprintf("Failed deleting the pre-pull DaemonSet: %v", output.PrettyErr(err)))
+			return
+		}
+		s.Success(fmt.Sprintf("Pre-pull DaemonSet %q correctly deleted", ds.GetName()))
+	}()
+
+	s = o.Printer.StartSpinner(fmt.Sprintf("Waiting for the images to be pulled (%s)", strings.Join(o.Images, ", ")))
+	var ready, desired int32
+	err := wait.PollImmediateUntilWithContext(ctx, 2*time.Second, func(ctx context.Context) (done bool, err error) {
+		if err := o.CRClient.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		ready, desired = ds.Status.NumberReady, ds.Status.DesiredNumberScheduled
+		return IsDaemonSetCompleted(ds), nil
+	})
+	if err != nil {
+		s.Fail(fmt.Sprintf("Failed waiting for the images to be pulled (%d/%d virtual nodes ready): %v", ready, desired, output.PrettyErr(err)))
+		return err
+	}
+	s.Success(fmt.Sprintf("Images correctly pulled through %d virtual nodes", ready))
+
+	return nil
+}
+
+// IsDaemonSetCompleted returns whether all the pods of the given pre-pull DaemonSet are ready, hence the images have been pulled.
+func IsDaemonSetCompleted(ds *appsv1.DaemonSet) bool {
+	return ds.Status.ObservedGeneration >= ds.GetGeneration() && ds.Status.DesiredNumberScheduled > 0 &&
+		ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled && ds.Status.NumberReady == ds.Status.DesiredNumberScheduled
+}
+
+// ForgeDaemonSet forges the DaemonSet pulling the given images on the virtual nodes (optionally, only that associated with
+// the given remote cluster). Each image is pulled by a dedicated init container, which executes a statically linked busybox
+// binary (shared through an emptyDir volume) to terminate immediately, independently of the content of the image itself.
+func ForgeDaemonSet(namespace string, images []string, remoteClusterID string) *appsv1.DaemonSet {
+	labels := map[string]string{prepullAppLabel: prepullAppValue}
+	nodeSelector := map[string]string{consts.TypeLabel: consts.TypeNode}
+	if remoteClusterID != "" {
+		nodeSelector[consts.RemoteClusterID] = remoteClusterID
+	}
+
+	// All containers are given small explicit resources, as they only execute trivial commands, to limit the impact on the providers.
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(prepullCPURequest),
+			corev1.ResourceMemory: resource.MustParse(prepullMemoryRequest),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(prepullCPULimit),
+			corev1.ResourceMemory: resource.MustParse(prepullMemoryLimit),
+		},
+	}
+
+	busybox := path.Join(prepullVolumePath, "busybox")
+	mounts := []corev1.VolumeMount{{Name: prepullVolumeName, MountPath: prepullVolumePath}}
+
+	initContainers := []corev1.Container{{
+		Name:            "busybox",
+		Image:           busyboxImage,
+		Command:         []string{"cp", "/bin/busybox", busybox},
+		VolumeMounts:    mounts,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Resources:       resources,
+	}}
+	for i, image := range images {
+		initContainers = append(initContainers, corev1.Container{
+			Name:            fmt.Sprintf("image-%d", i),
+			Image:           image,
+			Command:         []string{busybox, "true"},
+			VolumeMounts:    mounts,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Resources:       resources,
+		})
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: prepullNamePrefix,
+			Namespace:    namespace,
+			Labels:       labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector: nodeSelector,
+					Tolerations: []corev1.Toleration{{
+						Key:      consts.VirtualNodeTolerationKey,
+						Operator: corev1.TolerationOpExists,
+						Effect:   corev1.TaintEffectNoExecute,
+					}},
+					InitContainers: initContainers,
+					Containers: []corev1.Container{{
+						Name:            "pause",
+						Image:           pauseImage,
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Resources:       resources,
+					}},
+					Volumes: []corev1.Volume{{
+						Name:         prepullVolumeName,
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					}},
+				},
+			},
+		},
+	}
+}
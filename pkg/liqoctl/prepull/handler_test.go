@@ -0,0 +1,82 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prepull
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/liqotech/liqo/pkg/consts"
+)
+
+var _ = Describe("Pre-pull", func() {
+	Describe("The ForgeDaemonSet function", func() {
+		var (
+			remoteClusterID string
+			ds              *appsv1.DaemonSet
+		)
+
+		BeforeEach(func() { remoteClusterID = "" })
+		JustBeforeEach(func() { ds = ForgeDaemonSet("foo", []string{"nginx:1.23", "redis:7.0"}, remoteClusterID) })
+
+		It("should set the correct metadata", func() {
+			Expect(ds.GetNamespace()).To(Equal("foo"))
+			Expect(ds.GetGenerateName()).To(Equal(prepullNamePrefix))
+			Expect(ds.Spec.Selector.MatchLabels).To(Equal(ds.Spec.Template.GetLabels()))
+		})
+
+		It("should target the virtual nodes only", func() {
+			Expect(ds.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{consts.TypeLabel: consts.TypeNode}))
+			Expect(ds.Spec.Template.Spec.Tolerations).To(ConsistOf(HaveField("Key", consts.VirtualNodeTolerationKey)))
+		})
+
+		It("should configure one init container for each image", func() {
+			containers := ds.Spec.Template.Spec.InitContainers
+			Expect(containers).To(HaveLen(3))
+			Expect(containers[0].Image).To(Equal(busyboxImage))
+			Expect(containers[1].Image).To(Equal("nginx:1.23"))
+			Expect(containers[2].Image).To(Equal("redis:7.0"))
+			Expect(containers[1].Command).To(Equal([]string{"/liqo-prepull/busybox", "true"}))
+			Expect(containers).To(HaveEach(HaveField("VolumeMounts", ConsistOf(HaveField("MountPath", prepullVolumePath)))))
+		})
+
+		It("should configure explicit resources for all containers", func() {
+			var containers []corev1.Container
+			containers = append(containers, ds.Spec.Template.Spec.InitContainers...)
+			containers = append(containers, ds.Spec.Template.Spec.Containers...)
+			Expect(containers).To(HaveEach(HaveField("Resources.Requests", HaveKeyWithValue(corev1.ResourceCPU, resource.MustParse(prepullCPURequest)))))
+			Expect(containers).To(HaveEach(HaveField("Resources.Limits", HaveKeyWithValue(corev1.ResourceMemory, resource.MustParse(prepullMemoryLimit)))))
+		})
+
+		When("a remote cluster ID is specified", func() {
+			BeforeEach(func() { remoteClusterID = "remote-id" })
+			It("should target the corresponding virtual node only", func() {
+				Expect(ds.Spec.Template.Spec.NodeSelector).To(HaveKeyWithValue(consts.RemoteClusterID, "remote-id"))
+			})
+		})
+	})
+
+	DescribeTable("The IsDaemonSetCompleted function",
+		func(status appsv1.DaemonSetStatus, expected bool) {
+			Expect(IsDaemonSetCompleted(&appsv1.DaemonSet{Status: status})).To(Equal(expected))
+		},
+		Entry("no pods scheduled", appsv1.DaemonSetStatus{}, false),
+		Entry("pods not yet ready", appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, UpdatedNumberScheduled: 2, NumberReady: 1}, false),
+		Entry("all pods ready", appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, UpdatedNumberScheduled: 2, NumberReady: 2}, true),
+	)
+})
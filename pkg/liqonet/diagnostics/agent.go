@@ -0,0 +1,197 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/mtu"
+)
+
+const (
+	// probeSize is the size of the synthetic probes (i.e., the same as the default of the ping command).
+	probeSize = 84
+	// readHeaderTimeout is the amount of time allowed to read the request headers.
+	readHeaderTimeout = 10 * time.Second
+	// shutdownTimeout is the amount of time allowed to complete the pending requests at shutdown.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Options are the parameters of the diagnostics agent.
+type Options struct {
+	// Address is the address the agent listens on (expected to be a loopback one, as the API is not authenticated).
+	Address string
+	// Netns is the network namespace the probes and the dumps are executed in (nil for the current one).
+	Netns ns.NetNS
+	// Tables are the routing tables the routes towards the remote clusters are dumped from.
+	Tables []int
+	// Prober sends the synthetic probes.
+	Prober mtu.Prober
+	// IsConfigured returns whether the networking towards the given remote cluster is configured by the component
+	// (nil to infer it from the presence of the routes towards the remote cluster).
+	IsConfigured func(clusterID string) bool
+	// NATRules returns the iptables rules configured for the given remote cluster (nil if not applicable).
+	NATRules func(clusterID string) ([]string, error)
+	// NativeRouting is whether the traffic towards the gateway is forwarded through the routes configured by the CNI.
+	NativeRouting bool
+}
+
+// Agent exposes the networking diagnostics of a liqonet component through a simple HTTP API.
+type Agent struct {
+	Options
+	client client.Reader
+}
+
+// NewAgent returns a new diagnostics agent, retrieving the TunnelEndpoints through the given client.
+func NewAgent(cl client.Reader, options *Options) *Agent {
+	return &Agent{Options: *options, client: cl}
+}
+
+// Start starts the diagnostics agent, until the given context is canceled.
+func (a *Agent) Start(ctx context.Context) error {
+	if host, _, err := net.SplitHostPort(a.Address); err != nil || !net.ParseIP(host).IsLoopback() {
+		klog.Warningf("Diagnostics agent bound to the non-loopback address %q, although its API is not authenticated", a.Address)
+	}
+	server := &http.Server{Addr: a.Address, Handler: a.Handler(), ReadHeaderTimeout: readHeaderTimeout}
+
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	klog.Infof("Diagnostics agent listening on %s", a.Address)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("diagnostics agent failed: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, as the diagnostics shall be available from every replica.
+func (a *Agent) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler returns the HTTP handler serving the diagnostics API.
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ProbePath, a.probe)
+	mux.HandleFunc(DumpPath, a.dump)
+	return mux
+}
+
+// probe handles the requests to probe the reachability of a given address.
+func (a *Agent) probe(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get(TargetParameter)
+	ip := net.ParseIP(target)
+	if ip == nil || ip.To4() == nil {
+		http.Error(w, fmt.Sprintf("invalid target address %q", target), http.StatusBadRequest)
+		return
+	}
+
+	result := ProbeResult{Target: target}
+	if err := a.do(func() (err error) {
+		result.Reachable, err = a.Prober.Probe(ip, probeSize)
+		return err
+	}); err != nil {
+		result.Error = err.Error()
+	}
+	a.reply(w, &result)
+}
+
+// dump handles the requests to dump the configuration relevant for a given remote cluster.
+func (a *Agent) dump(w http.ResponseWriter, r *http.Request) {
+	clusterID := r.URL.Query().Get(ClusterIDParameter)
+	if clusterID == "" {
+		http.Error(w, "missing cluster ID", http.StatusBadRequest)
+		return
+	}
+
+	tep, err := a.getTunnelEndpoint(r.Context(), clusterID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	dump := Dump{NativeRouting: a.NativeRouting}
+	if err := a.do(func() error {
+		networks, err := remoteNetworks(tep)
+		if err != nil {
+			return err
+		}
+		if dump.Routes, err = listRoutes(a.Tables, networks); err != nil {
+			return err
+		}
+		if dump.Rules, err = listRules(a.Tables, networks); err != nil {
+			return err
+		}
+		if a.NATRules != nil {
+			if dump.NAT, err = a.NATRules(clusterID); err != nil {
+				return fmt.Errorf("failed to list the iptables rules: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if a.IsConfigured != nil {
+		dump.Configured = a.IsConfigured(clusterID)
+	} else {
+		dump.Configured = len(dump.Routes) > 0
+	}
+	a.reply(w, &dump)
+}
+
+// getTunnelEndpoint returns the TunnelEndpoint associated with the given remote cluster.
+func (a *Agent) getTunnelEndpoint(ctx context.Context, clusterID string) (*netv1alpha1.TunnelEndpoint, error) {
+	var teps netv1alpha1.TunnelEndpointList
+	if err := a.client.List(ctx, &teps, client.MatchingLabels{liqoconst.ClusterIDLabelName: clusterID}); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the TunnelEndpoint for cluster %s: %w", clusterID, err)
+	}
+	if len(teps.Items) != 1 {
+		return nil, fmt.Errorf("found %d TunnelEndpoints for cluster %s, expected 1", len(teps.Items), clusterID)
+	}
+	return &teps.Items[0], nil
+}
+
+// do executes the given function in the configured network namespace.
+func (a *Agent) do(f func() error) error {
+	if a.Netns == nil {
+		return f()
+	}
+	return a.Netns.Do(func(ns.NetNS) error { return f() })
+}
+
+// reply writes the given object as the JSON response.
+func (a *Agent) reply(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		klog.Warningf("Failed to write the diagnostics response: %v", err)
+	}
+}
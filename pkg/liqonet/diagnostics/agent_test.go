@@ -0,0 +1,157 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	discv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+)
+
+// fakeProber is a prober replying only to the given addresses.
+type fakeProber struct {
+	reachable map[string]bool
+	err       error
+}
+
+func (p *fakeProber) Probe(ip net.IP, size int) (bool, error) {
+	return p.reachable[ip.String()], p.err
+}
+
+var _ = Describe("Agent", func() {
+	var (
+		agent    *Agent
+		prober   *fakeProber
+		recorder *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(netv1alpha1.AddToScheme(scheme)).To(Succeed())
+		tep := &netv1alpha1.TunnelEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "tep", Namespace: "liqo", Labels: map[string]string{liqoconst.ClusterIDLabelName: "foo"}},
+			Spec: netv1alpha1.TunnelEndpointSpec{
+				ClusterIdentity:       discv1alpha1.ClusterIdentity{ClusterID: "foo"},
+				RemotePodCIDR:         "10.0.0.0/16",
+				RemoteNATPodCIDR:      "10.60.0.0/16",
+				RemoteExternalCIDR:    "10.201.0.0/16",
+				RemoteNATExternalCIDR: "None",
+			},
+		}
+
+		prober = &fakeProber{reachable: map[string]bool{"10.60.0.1": true}}
+		agent = NewAgent(fake.NewClientBuilder().WithScheme(scheme).WithObjects(tep).Build(), &Options{
+			Prober:       prober,
+			IsConfigured: func(clusterID string) bool { return clusterID == "foo" },
+			NATRules:     func(clusterID string) ([]string, error) { return []string{"LIQO-PSTRT-CLS-foo: -j SNAT"}, nil },
+		})
+		recorder = httptest.NewRecorder()
+	})
+
+	JustBeforeEach(func() {
+		agent.Handler().ServeHTTP(recorder, request)
+	})
+
+	Describe("the probe endpoint", func() {
+		var result ProbeResult
+
+		JustBeforeEach(func() {
+			if recorder.Code == http.StatusOK {
+				Expect(json.NewDecoder(recorder.Body).Decode(&result)).To(Succeed())
+			}
+		})
+
+		When("the target is reachable", func() {
+			BeforeEach(func() { request = httptest.NewRequest(http.MethodGet, ProbePath+"?target=10.60.0.1", http.NoBody) })
+
+			It("should report it as reachable", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(result).To(Equal(ProbeResult{Target: "10.60.0.1", Reachable: true}))
+			})
+		})
+
+		When("the target is not reachable", func() {
+			BeforeEach(func() { request = httptest.NewRequest(http.MethodGet, ProbePath+"?target=10.60.0.2", http.NoBody) })
+
+			It("should report it as unreachable", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(result).To(Equal(ProbeResult{Target: "10.60.0.2", Reachable: false}))
+			})
+		})
+
+		When("the probe fails", func() {
+			BeforeEach(func() {
+				prober.err = errors.New("failed")
+				request = httptest.NewRequest(http.MethodGet, ProbePath+"?target=10.60.0.2", http.NoBody)
+			})
+
+			It("should report the error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(result.Error).To(Equal("failed"))
+			})
+		})
+
+		When("the target is invalid", func() {
+			BeforeEach(func() { request = httptest.NewRequest(http.MethodGet, ProbePath+"?target=foo", http.NoBody) })
+
+			It("should return a bad request error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+
+	Describe("the dump endpoint", func() {
+		When("the remote cluster exists", func() {
+			BeforeEach(func() { request = httptest.NewRequest(http.MethodGet, DumpPath+"?clusterID=foo", http.NoBody) })
+
+			It("should return the configuration for the remote cluster", func() {
+				var dump Dump
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(json.NewDecoder(recorder.Body).Decode(&dump)).To(Succeed())
+				Expect(dump.Configured).To(BeTrue())
+				Expect(dump.NAT).To(ConsistOf("LIQO-PSTRT-CLS-foo: -j SNAT"))
+			})
+		})
+
+		When("the remote cluster does not exist", func() {
+			BeforeEach(func() { request = httptest.NewRequest(http.MethodGet, DumpPath+"?clusterID=bar", http.NoBody) })
+
+			It("should return a not found error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		When("the cluster ID is missing", func() {
+			BeforeEach(func() { request = httptest.NewRequest(http.MethodGet, DumpPath, http.NoBody) })
+
+			It("should return a bad request error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+})
@@ -0,0 +1,147 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// remoteNetworks returns the networks of the remote cluster described by the given TunnelEndpoint, as seen by the local cluster.
+func remoteNetworks(tep *netv1alpha1.TunnelEndpoint) ([]*net.IPNet, error) {
+	_, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, remoteExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+
+	var networks []*net.IPNet
+	for _, cidr := range append([]string{remotePodCIDR, remoteExternalCIDR}, remoteExportedCIDRs...) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CIDR %s: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// overlaps returns whether the given network overlaps with any of the given networks.
+func overlaps(network *net.IPNet, networks []*net.IPNet) bool {
+	if network == nil {
+		return false
+	}
+	for _, other := range networks {
+		if network.Contains(other.IP) || other.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// listRoutes returns the routes of the given routing tables towards any of the given networks, in a human-readable format.
+func listRoutes(tables []int, networks []*net.IPNet) ([]string, error) {
+	var routes []string
+	for _, table := range tables {
+		list, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the routes of table %d: %w", table, err)
+		}
+		for i := range list {
+			if overlaps(list[i].Dst, networks) {
+				routes = append(routes, formatRoute(&list[i]))
+			}
+		}
+	}
+	return routes, nil
+}
+
+// listRules returns the policy routing rules concerning any of the given networks, or pointing to any
+// of the given routing tables (excluding the default ones), in a human-readable format.
+func listRules(tables []int, networks []*net.IPNet) ([]string, error) {
+	list, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the policy routing rules: %w", err)
+	}
+
+	var rules []string
+	for i := range list {
+		custom := list[i].Table != unix.RT_TABLE_MAIN && list[i].Table != unix.RT_TABLE_LOCAL && list[i].Table != unix.RT_TABLE_DEFAULT
+		if overlaps(list[i].Dst, networks) || overlaps(list[i].Src, networks) || (custom && containsTable(tables, list[i].Table)) {
+			rules = append(rules, formatRule(&list[i]))
+		}
+	}
+	return rules, nil
+}
+
+// containsTable returns whether the given table is part of the given list.
+func containsTable(tables []int, table int) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// formatRoute returns the representation of the given route, in a format similar to the ip route command.
+func formatRoute(route *netlink.Route) string {
+	var builder strings.Builder
+	if route.Dst != nil {
+		builder.WriteString(route.Dst.String())
+	} else {
+		builder.WriteString("default")
+	}
+	if route.Gw != nil {
+		fmt.Fprintf(&builder, " via %s", route.Gw)
+	}
+	if link, err := netlink.LinkByIndex(route.LinkIndex); err == nil {
+		fmt.Fprintf(&builder, " dev %s", link.Attrs().Name)
+	} else if route.LinkIndex != 0 {
+		fmt.Fprintf(&builder, " dev if%d", route.LinkIndex)
+	}
+	if route.Src != nil {
+		fmt.Fprintf(&builder, " src %s", route.Src)
+	}
+	fmt.Fprintf(&builder, " table %d", route.Table)
+	if route.MTU != 0 {
+		fmt.Fprintf(&builder, " mtu %d", route.MTU)
+	}
+	return builder.String()
+}
+
+// formatRule returns the representation of the given policy routing rule, in a format similar to the ip rule command.
+func formatRule(rule *netlink.Rule) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d: from ", rule.Priority)
+	if rule.Src != nil {
+		builder.WriteString(rule.Src.String())
+	} else {
+		builder.WriteString("all")
+	}
+	if rule.Dst != nil {
+		fmt.Fprintf(&builder, " to %s", rule.Dst)
+	}
+	if rule.IifName != "" {
+		fmt.Fprintf(&builder, " iif %s", rule.IifName)
+	}
+	fmt.Fprintf(&builder, " lookup %d", rule.Table)
+	return builder.String()
+}
@@ -0,0 +1,55 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Dump", func() {
+	mustParseCIDR := func(cidr string) *net.IPNet {
+		_, network, err := net.ParseCIDR(cidr)
+		Expect(err).ToNot(HaveOccurred())
+		return network
+	}
+
+	DescribeTable("the overlaps function",
+		func(network *net.IPNet, expected bool) {
+			Expect(overlaps(network, []*net.IPNet{mustParseCIDR("10.60.0.0/16"), mustParseCIDR("10.201.0.0/16")})).To(Equal(expected))
+		},
+		Entry("with a network contained in one of the others", mustParseCIDR("10.60.3.0/24"), true),
+		Entry("with a network containing one of the others", mustParseCIDR("10.0.0.0/8"), true),
+		Entry("with a disjoint network", mustParseCIDR("192.168.0.0/16"), false),
+		Entry("with no network", nil, false),
+	)
+
+	Describe("the formatRoute function", func() {
+		It("should format the route similarly to the ip route command", func() {
+			route := netlink.Route{Dst: mustParseCIDR("10.60.0.0/16"), Gw: net.ParseIP("169.254.100.1"), Table: 18952, MTU: 1340}
+			Expect(formatRoute(&route)).To(Equal("10.60.0.0/16 via 169.254.100.1 table 18952 mtu 1340"))
+		})
+	})
+
+	Describe("the formatRule function", func() {
+		It("should format the rule similarly to the ip rule command", func() {
+			rule := netlink.Rule{Priority: 100, Dst: mustParseCIDR("10.60.0.0/16"), Table: 18952}
+			Expect(formatRule(&rule)).To(Equal("100: from all to 10.60.0.0/16 lookup 18952"))
+		})
+	})
+})
@@ -0,0 +1,55 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+const (
+	// ProbePath is the path of the endpoint probing the reachability of a given address.
+	ProbePath = "/diagnostics/probe"
+	// DumpPath is the path of the endpoint dumping the configuration relevant for a given remote cluster.
+	DumpPath = "/diagnostics/dump"
+
+	// TargetParameter is the query parameter specifying the address to be probed.
+	TargetParameter = "target"
+	// ClusterIDParameter is the query parameter specifying the remote cluster the configuration is dumped for.
+	ClusterIDParameter = "clusterID"
+
+	// PortName is the name of the container port exposing the diagnostics agent.
+	PortName = "diagnostics"
+)
+
+// ProbeResult is the outcome of a synthetic probe towards a given address.
+type ProbeResult struct {
+	// Target is the probed address.
+	Target string `json:"target"`
+	// Reachable is whether the probe got a reply from the target.
+	Reachable bool `json:"reachable"`
+	// Error is the error occurred while probing the target, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Dump is the networking configuration relevant for a given remote cluster, as configured by a liqonet component.
+type Dump struct {
+	// Configured is whether the networking towards the remote cluster is configured by the component.
+	Configured bool `json:"configured"`
+	// NativeRouting is whether the traffic towards the gateway is forwarded through the routes configured by the CNI,
+	// rather than through the overlay network (meaningful for the network fabric component only).
+	NativeRouting bool `json:"nativeRouting,omitempty"`
+	// Routes are the routes towards the networks of the remote cluster.
+	Routes []string `json:"routes,omitempty"`
+	// Rules are the policy routing rules concerning the networks of the remote cluster, or the liqo routing tables.
+	Rules []string `json:"rules,omitempty"`
+	// NAT are the iptables rules configured for the remote cluster, prefixed by the corresponding chain.
+	NAT []string `json:"nat,omitempty"`
+}
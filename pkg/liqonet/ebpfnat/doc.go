@@ -0,0 +1,18 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ebpfnat implements an eBPF (tc) datapath performing the stateless NAT translations required to interconnect
+// clusters with overlapping networks (i.e., the 1:1 remapping of the pod CIDRs and the per-endpoint mappings),
+// as an alternative to the corresponding iptables rules, which do not scale with thousands of mappings.
+package ebpfnat
@@ -0,0 +1,350 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfnat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	"github.com/liqotech/liqo/pkg/consts"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+const (
+	// filterHandle is the handle of the tc filters attaching the programs.
+	filterHandle = 0x1
+	// filterPriority is the priority of the tc filters attaching the programs.
+	filterPriority = 0x1
+)
+
+// NAT manages the eBPF programs and maps performing the stateless NAT translations towards the remote clusters.
+type NAT struct {
+	collection *ebpf.Collection
+
+	mutex sync.Mutex
+	// clusters key is a clusterID.
+	clusters map[string]*clusterEntries
+}
+
+// clusterEntries tracks the map entries configured for a given remote cluster, to remove the stale ones.
+type clusterEntries struct {
+	index     uint32
+	clusters  map[clusterKey]uint32
+	prefixes  map[prefixKey]prefixValue
+	endpoints map[endpointKey][4]byte
+}
+
+// New loads the eBPF programs and maps performing the stateless NAT translations.
+func New() (*NAT, error) {
+	// Kernels older than 5.11 account the memory of the maps against the memlock rlimit.
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, fmt.Errorf("failed to remove the memlock rlimit: %w", err)
+	}
+
+	collection, err := ebpf.NewCollection(newCollectionSpec())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the eBPF NAT programs: %w", err)
+	}
+
+	return &NAT{collection: collection, clusters: make(map[string]*clusterEntries)}, nil
+}
+
+// Attach attaches the NAT programs to the ingress and egress hooks of the given link, replacing possibly existing ones.
+// It must be executed in the network namespace the link belongs to.
+func (n *NAT) Attach(link netlink.Link) error {
+	qdisc := &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_CLSACT,
+		},
+		QdiscType: "clsact",
+	}
+	if err := netlink.QdiscAdd(qdisc); err != nil && !errors.Is(err, unix.EEXIST) {
+		return fmt.Errorf("failed to add the clsact qdisc to link %s: %w", link.Attrs().Name, err)
+	}
+
+	for parent, name := range map[uint32]string{netlink.HANDLE_MIN_EGRESS: egressProgramName, netlink.HANDLE_MIN_INGRESS: ingressProgramName} {
+		filter := &netlink.BpfFilter{
+			FilterAttrs: netlink.FilterAttrs{
+				LinkIndex: link.Attrs().Index,
+				Parent:    parent,
+				Handle:    filterHandle,
+				Priority:  filterPriority,
+				Protocol:  unix.ETH_P_ALL,
+			},
+			Fd:           n.collection.Programs[name].FD(),
+			Name:         name,
+			DirectAction: true,
+		}
+		if err := netlink.FilterReplace(filter); err != nil {
+			return fmt.Errorf("failed to attach program %s to link %s: %w", name, link.Attrs().Name, err)
+		}
+	}
+	return nil
+}
+
+// EnsureCluster makes sure that the translations of the networks remapped towards the given remote cluster are
+// configured and updated.
+func (n *NAT) EnsureCluster(tep *netv1alpha1.TunnelEndpoint) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	clusterID := tep.Spec.ClusterIdentity.ClusterID
+	entries, found := n.clusters[clusterID]
+	if !found {
+		index, err := n.allocateIndex()
+		if err != nil {
+			return err
+		}
+		entries = &clusterEntries{index: index}
+		n.clusters[clusterID] = entries
+	}
+
+	clusters, prefixes, err := forgeClusterEntries(tep, entries.index)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureEntries(n.collection.Maps[prefixesMapName], entries.prefixes, prefixes); err != nil {
+		return fmt.Errorf("failed to configure the remapped networks for cluster %s: %w", clusterID, err)
+	}
+	entries.prefixes = prefixes
+	// The cluster entries are configured last, to activate the translations only once all the other entries are in place.
+	if err := ensureEntries(n.collection.Maps[clustersMapName], entries.clusters, clusters); err != nil {
+		return fmt.Errorf("failed to configure the networks of cluster %s: %w", clusterID, err)
+	}
+	entries.clusters = clusters
+
+	klog.V(4).Infof("eBPF NAT entries for cluster %s correctly configured", clusterID)
+	return nil
+}
+
+// EnsureEndpointMappings makes sure that the translations of the given endpoints (i.e., a map from the original address to
+// the one the endpoint is known by the given remote cluster) are configured and updated.
+func (n *NAT) EnsureEndpointMappings(clusterID string, mappings map[string]string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	entries, found := n.clusters[clusterID]
+	if !found {
+		return fmt.Errorf("eBPF NAT for cluster %s not yet configured", clusterID)
+	}
+
+	endpoints, err := forgeEndpointEntries(entries.index, mappings)
+	if err != nil {
+		return err
+	}
+	if err := ensureEntries(n.collection.Maps[endpointsMapName], entries.endpoints, endpoints); err != nil {
+		return fmt.Errorf("failed to configure the endpoint mappings for cluster %s: %w", clusterID, err)
+	}
+	entries.endpoints = endpoints
+	return nil
+}
+
+// RemoveCluster removes all the translations configured for the given remote cluster.
+func (n *NAT) RemoveCluster(clusterID string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	entries, found := n.clusters[clusterID]
+	if !found {
+		return nil
+	}
+
+	// The cluster entries are removed first, to deactivate the translations before the other entries are removed.
+	if err := ensureEntries(n.collection.Maps[clustersMapName], entries.clusters, nil); err != nil {
+		return fmt.Errorf("failed to remove the networks of cluster %s: %w", clusterID, err)
+	}
+	entries.clusters = nil
+	if err := ensureEntries(n.collection.Maps[prefixesMapName], entries.prefixes, nil); err != nil {
+		return fmt.Errorf("failed to remove the remapped networks for cluster %s: %w", clusterID, err)
+	}
+	entries.prefixes = nil
+	if err := ensureEntries(n.collection.Maps[endpointsMapName], entries.endpoints, nil); err != nil {
+		return fmt.Errorf("failed to remove the endpoint mappings for cluster %s: %w", clusterID, err)
+	}
+
+	delete(n.clusters, clusterID)
+	klog.V(4).Infof("eBPF NAT entries for cluster %s correctly removed", clusterID)
+	return nil
+}
+
+// Close releases the eBPF programs and maps. The programs are detached as soon as the corresponding links are removed.
+func (n *NAT) Close() {
+	n.collection.Close()
+}
+
+// allocateIndex returns the lowest index not yet associated with a remote cluster.
+func (n *NAT) allocateIndex() (uint32, error) {
+	used := make(map[uint32]struct{}, len(n.clusters))
+	for _, entries := range n.clusters {
+		used[entries.index] = struct{}{}
+	}
+	// Indexes start from 1, to prevent ambiguities with zero-valued entries.
+	for index := uint32(1); index <= maxClusters; index++ {
+		if _, found := used[index]; !found {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("maximum number of remote clusters (%d) reached", maxClusters)
+}
+
+// ensureEntries updates the given map to contain the desired entries, removing the stale ones previously configured.
+func ensureEntries[K comparable, V any](m *ebpf.Map, current, desired map[K]V) error {
+	for key, value := range desired {
+		if err := m.Put(key, value); err != nil {
+			return err
+		}
+	}
+	for key := range current {
+		if _, found := desired[key]; found {
+			continue
+		}
+		if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgeClusterEntries returns the entries identifying the networks of the given remote cluster, and the ones
+// translating the local networks remapped by the remote cluster.
+func forgeClusterEntries(tep *netv1alpha1.TunnelEndpoint, index uint32) (map[clusterKey]uint32, map[prefixKey]prefixValue, error) {
+	if err := liqonetutils.CheckTep(tep); err != nil {
+		return nil, nil, fmt.Errorf("invalid TunnelEndpoint resource: %w", err)
+	}
+	localRemappedPodCIDR, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, remoteExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+
+	clusters := make(map[clusterKey]uint32)
+	addCluster := func(dir direction, cidr string) error {
+		ones, address, err := parseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		clusters[clusterKey{PrefixLen: clusterKeyFixedBits + ones, Direction: dir, Address: address}] = index
+		return nil
+	}
+
+	prefixes := make(map[prefixKey]prefixValue)
+	addPrefix := func(dir direction, cidr, translated string) error {
+		ones, address, err := parseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		translatedOnes, base, err := parseCIDR(translated)
+		if err != nil {
+			return err
+		}
+		if ones != translatedOnes {
+			return fmt.Errorf("network %s cannot be remapped to %s, as the sizes differ", cidr, translated)
+		}
+		var hostmask [4]byte
+		mask := net.CIDRMask(int(ones), 32)
+		for i := range hostmask {
+			hostmask[i] = ^mask[i]
+		}
+		prefixes[prefixKey{PrefixLen: prefixKeyFixedBits + ones, Cluster: index, Direction: dir, Address: address}] =
+			prefixValue{Base: base, HostMask: hostmask}
+		return nil
+	}
+
+	// The remote cluster is identified through the destination address for egress traffic (i.e., towards any of the
+	// remote networks), and through the source address for ingress traffic (since the traffic originating from
+	// the remote hosts is masqueraded with an address belonging to the remote pod CIDR).
+	for _, cidr := range append([]string{remotePodCIDR, remoteExternalCIDR}, remoteExportedCIDRs...) {
+		if err := addCluster(egress, cidr); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := addCluster(ingress, remotePodCIDR); err != nil {
+		return nil, nil, err
+	}
+
+	// The local pod CIDR has been remapped by the remote cluster.
+	if localRemappedPodCIDR != consts.DefaultCIDRValue {
+		if err := addPrefix(egress, tep.Spec.LocalPodCIDR, localRemappedPodCIDR); err != nil {
+			return nil, nil, err
+		}
+		if err := addPrefix(ingress, localRemappedPodCIDR, tep.Spec.LocalPodCIDR); err != nil {
+			return nil, nil, err
+		}
+	}
+	// The remote cluster has remapped some of the networks exported by the home cluster (the reply traffic is
+	// translated through conntrack, as the corresponding hosts are not necessarily routed through the gateway).
+	for _, exported := range tep.Spec.LocalExportedCIDRs {
+		if exported.NATCIDR == "" || exported.NATCIDR == consts.DefaultCIDRValue {
+			continue
+		}
+		if err := addPrefix(ingress, exported.NATCIDR, exported.CIDR); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return clusters, prefixes, nil
+}
+
+// forgeEndpointEntries returns the entries translating the given endpoint addresses, in both directions.
+func forgeEndpointEntries(index uint32, mappings map[string]string) (map[endpointKey][4]byte, error) {
+	endpoints := make(map[endpointKey][4]byte, 2*len(mappings))
+	for oldIP, newIP := range mappings {
+		oldAddress, err := parseIP(oldIP)
+		if err != nil {
+			return nil, err
+		}
+		newAddress, err := parseIP(newIP)
+		if err != nil {
+			return nil, err
+		}
+		endpoints[endpointKey{Cluster: index, Direction: ingress, Address: newAddress}] = oldAddress
+		endpoints[endpointKey{Cluster: index, Direction: egress, Address: oldAddress}] = newAddress
+	}
+	return endpoints, nil
+}
+
+// parseCIDR returns the prefix length and the network address of the given IPv4 CIDR.
+func parseCIDR(cidr string) (ones uint32, address [4]byte, err error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, address, fmt.Errorf("failed to parse CIDR %s: %w", cidr, err)
+	}
+	if network.IP.To4() == nil {
+		return 0, address, fmt.Errorf("CIDR %s is not an IPv4 network", cidr)
+	}
+	size, _ := network.Mask.Size()
+	copy(address[:], network.IP.To4())
+	return uint32(size), address, nil
+}
+
+// parseIP returns the given IPv4 address in network byte order.
+func parseIP(ip string) (address [4]byte, err error) {
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil {
+		return address, fmt.Errorf("%s is not a valid IPv4 address", ip)
+	}
+	copy(address[:], parsed)
+	return address, nil
+}
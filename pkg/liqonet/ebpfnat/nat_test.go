@@ -0,0 +1,160 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfnat
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	discv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+)
+
+var _ = Describe("eBPF NAT", func() {
+	var tep *netv1alpha1.TunnelEndpoint
+
+	BeforeEach(func() {
+		tep = &netv1alpha1.TunnelEndpoint{
+			Spec: netv1alpha1.TunnelEndpointSpec{
+				ClusterIdentity:       discv1alpha1.ClusterIdentity{ClusterID: "foo"},
+				LocalPodCIDR:          "10.0.0.0/16",
+				LocalNATPodCIDR:       "10.50.0.0/16",
+				LocalExternalCIDR:     "10.201.0.0/16",
+				LocalNATExternalCIDR:  "None",
+				RemotePodCIDR:         "10.0.0.0/16",
+				RemoteNATPodCIDR:      "10.60.0.0/16",
+				RemoteExternalCIDR:    "10.201.0.0/16",
+				RemoteNATExternalCIDR: "10.61.0.0/16",
+			},
+		}
+	})
+
+	Describe("the forgeClusterEntries function", func() {
+		It("should identify the remote cluster through its networks", func() {
+			clusters, _, err := forgeClusterEntries(tep, 3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(clusters).To(Equal(map[clusterKey]uint32{
+				{PrefixLen: 48, Direction: egress, Address: [4]byte{10, 60, 0, 0}}:  3,
+				{PrefixLen: 48, Direction: egress, Address: [4]byte{10, 61, 0, 0}}:  3,
+				{PrefixLen: 48, Direction: ingress, Address: [4]byte{10, 60, 0, 0}}: 3,
+			}))
+		})
+
+		It("should translate the remapped local pod CIDR", func() {
+			_, prefixes, err := forgeClusterEntries(tep, 3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(prefixes).To(Equal(map[prefixKey]prefixValue{
+				{PrefixLen: 80, Cluster: 3, Direction: egress, Address: [4]byte{10, 0, 0, 0}}: {
+					Base: [4]byte{10, 50, 0, 0}, HostMask: [4]byte{0, 0, 255, 255}},
+				{PrefixLen: 80, Cluster: 3, Direction: ingress, Address: [4]byte{10, 50, 0, 0}}: {
+					Base: [4]byte{10, 0, 0, 0}, HostMask: [4]byte{0, 0, 255, 255}},
+			}))
+		})
+
+		When("the networks are not remapped", func() {
+			BeforeEach(func() {
+				tep.Spec.LocalNATPodCIDR = "None"
+				tep.Spec.RemoteNATPodCIDR = "None"
+				tep.Spec.RemotePodCIDR = "10.1.0.0/16"
+			})
+
+			It("should not configure any translation", func() {
+				clusters, prefixes, err := forgeClusterEntries(tep, 3)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(clusters).To(HaveKeyWithValue(clusterKey{PrefixLen: 48, Direction: ingress, Address: [4]byte{10, 1, 0, 0}}, uint32(3)))
+				Expect(prefixes).To(BeEmpty())
+			})
+		})
+
+		When("the remote cluster remapped the networks exported by the home cluster", func() {
+			BeforeEach(func() {
+				tep.Spec.LocalExportedCIDRs = []netv1alpha1.ExportedCIDR{
+					{CIDR: "192.168.0.0/24", NATCIDR: "10.70.0.0/24"},
+					{CIDR: "192.168.1.0/24", NATCIDR: "None"},
+				}
+				tep.Spec.RemoteExportedCIDRs = []netv1alpha1.ExportedCIDR{{CIDR: "172.16.0.0/24", NATCIDR: "10.71.0.0/24"}}
+			})
+
+			It("should translate the remapped exported networks", func() {
+				clusters, prefixes, err := forgeClusterEntries(tep, 3)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(clusters).To(HaveKeyWithValue(clusterKey{PrefixLen: 56, Direction: egress, Address: [4]byte{10, 71, 0, 0}}, uint32(3)))
+				Expect(prefixes).To(HaveLen(3))
+				Expect(prefixes).To(HaveKeyWithValue(prefixKey{PrefixLen: 88, Cluster: 3, Direction: ingress, Address: [4]byte{10, 70, 0, 0}},
+					prefixValue{Base: [4]byte{192, 168, 0, 0}, HostMask: [4]byte{0, 0, 0, 255}}))
+			})
+		})
+
+		When("the remapped network has a different size", func() {
+			BeforeEach(func() { tep.Spec.LocalNATPodCIDR = "10.50.0.0/24" })
+
+			It("should return an error", func() {
+				_, _, err := forgeClusterEntries(tep, 3)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("the forgeEndpointEntries function", func() {
+		It("should translate the endpoints in both directions", func() {
+			endpoints, err := forgeEndpointEntries(3, map[string]string{"192.168.1.5": "10.201.3.4"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints).To(Equal(map[endpointKey][4]byte{
+				{Cluster: 3, Direction: ingress, Address: [4]byte{10, 201, 3, 4}}: {192, 168, 1, 5},
+				{Cluster: 3, Direction: egress, Address: [4]byte{192, 168, 1, 5}}: {10, 201, 3, 4},
+			}))
+		})
+
+		It("should return an error in case of invalid addresses", func() {
+			_, err := forgeEndpointEntries(3, map[string]string{"192.168.1.5": "foo"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("the NAT maps", func() {
+		var nat *NAT
+
+		BeforeEach(func() {
+			var err error
+			if nat, err = New(); err != nil {
+				Skip("unable to load the eBPF programs: " + err.Error())
+			}
+		})
+
+		AfterEach(func() {
+			if nat != nil {
+				nat.Close()
+			}
+		})
+
+		It("should configure and remove the entries of each remote cluster", func() {
+			var index uint32
+			key := clusterKey{PrefixLen: 48, Direction: egress, Address: [4]byte{10, 60, 0, 0}}
+
+			Expect(nat.EnsureCluster(tep)).To(Succeed())
+			Expect(nat.EnsureEndpointMappings("foo", map[string]string{"192.168.1.5": "10.201.3.4"})).To(Succeed())
+			Expect(nat.collection.Maps[clustersMapName].Lookup(key, &index)).To(Succeed())
+			Expect(index).To(BeNumerically("==", 1))
+
+			Expect(nat.RemoveCluster("foo")).To(Succeed())
+			Expect(nat.collection.Maps[clustersMapName].Lookup(key, &index)).ToNot(Succeed())
+			Expect(nat.clusters).To(BeEmpty())
+		})
+
+		It("should fail to configure the endpoint mappings of unknown clusters", func() {
+			Expect(nat.EnsureEndpointMappings("bar", map[string]string{"192.168.1.5": "10.201.3.4"})).ToNot(Succeed())
+		})
+	})
+})
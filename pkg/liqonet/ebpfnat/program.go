@@ -0,0 +1,273 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfnat
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"golang.org/x/sys/unix"
+)
+
+// direction identifies the direction of the traffic a program is attached to.
+type direction uint32
+
+const (
+	// egress identifies the traffic towards the remote clusters, whose source address is possibly rewritten.
+	egress direction = iota
+	// ingress identifies the traffic from the remote clusters, whose destination address is possibly rewritten.
+	ingress
+)
+
+const (
+	clustersMapName  = "liqo_nat_clusters"
+	prefixesMapName  = "liqo_nat_prefixes"
+	endpointsMapName = "liqo_nat_endpoints"
+
+	egressProgramName  = "liqo_nat_egress"
+	ingressProgramName = "liqo_nat_ingress"
+
+	maxClusters  = 1024
+	maxPrefixes  = 4096
+	maxEndpoints = 65536
+
+	// Offsets of the fields of the __sk_buff structure.
+	skbDataOffset    = 76
+	skbDataEndOffset = 80
+
+	// Offsets of the fields of the IPv4 header (the programs are attached to L3 devices, hence no L2 header is present).
+	ipv4HeaderLength  = 20
+	ipv4FragOffset    = 6
+	ipv4ProtoOffset   = 9
+	ipv4ChecksumOffet = 10
+	ipv4SourceOffset  = 12
+	ipv4DestOffset    = 16
+	ipv4FragMask      = 0x1fff
+
+	// Offsets of the checksum fields within the L4 headers.
+	tcpChecksumOffset = 16
+	udpChecksumOffset = 6
+
+	// Flags of the checksum helpers.
+	csumPseudoHeader = 0x10
+	csumMangled0     = 0x20
+	csumFieldSize    = 4
+
+	tcActOk   = 0
+	tcActShot = 2
+)
+
+// Offsets (w.r.t. the frame pointer) of the variables stored on the stack.
+const (
+	stackTarget       = -4
+	stackTranslated   = -8
+	stackHeaderLength = -12
+	stackProtocol     = -16
+	stackFragment     = -20
+	stackClusterKey   = -32
+	stackEndpointKey  = -44
+	stackPrefixKey    = -64
+)
+
+// clusterKey is the key of the map associating the remote networks to the corresponding cluster index.
+// The address is the destination one for egress traffic, and the source one for ingress traffic.
+type clusterKey struct {
+	PrefixLen uint32
+	Direction direction
+	Address   [4]byte
+}
+
+// prefixKey is the key of the map associating the networks to be remapped for a given cluster to the translated ones.
+// The address is the source one for egress traffic, and the destination one for ingress traffic.
+type prefixKey struct {
+	PrefixLen uint32
+	Cluster   uint32
+	Direction direction
+	Address   [4]byte
+}
+
+// prefixValue is the value of the map associating the networks to be remapped to the translated ones.
+type prefixValue struct {
+	Base     [4]byte
+	HostMask [4]byte
+}
+
+// endpointKey is the key of the map associating the endpoint addresses to be remapped for a given cluster to the translated ones.
+type endpointKey struct {
+	Cluster   uint32
+	Direction direction
+	Address   [4]byte
+}
+
+// clusterKeyFixedBits is the number of bits of a clusterKey always part of the prefix (i.e., the direction).
+const clusterKeyFixedBits = 32
+
+// prefixKeyFixedBits is the number of bits of a prefixKey always part of the prefix (i.e., the cluster and the direction).
+const prefixKeyFixedBits = 64
+
+// newCollectionSpec returns the specification of the maps and programs implementing the NAT datapath.
+func newCollectionSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			clustersMapName: {
+				Name: clustersMapName, Type: ebpf.LPMTrie, KeySize: 12, ValueSize: 4,
+				MaxEntries: maxClusters, Flags: unix.BPF_F_NO_PREALLOC,
+			},
+			prefixesMapName: {
+				Name: prefixesMapName, Type: ebpf.LPMTrie, KeySize: 16, ValueSize: 8,
+				MaxEntries: maxPrefixes, Flags: unix.BPF_F_NO_PREALLOC,
+			},
+			endpointsMapName: {
+				Name: endpointsMapName, Type: ebpf.Hash, KeySize: 12, ValueSize: 4,
+				MaxEntries: maxEndpoints,
+			},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			egressProgramName: {
+				Name: egressProgramName, Type: ebpf.SchedCLS, License: "Apache-2.0",
+				Instructions: forgeProgram(egress),
+			},
+			ingressProgramName: {
+				Name: ingressProgramName, Type: ebpf.SchedCLS, License: "Apache-2.0",
+				Instructions: forgeProgram(ingress),
+			},
+		},
+	}
+}
+
+// forgeProgram returns the instructions of the program rewriting the packets flowing in the given direction.
+// Specifically, the remote cluster is identified through the destination (egress) or source (ingress) address,
+// and the source (egress) or destination (ingress) address is rewritten according to the per-endpoint mappings,
+// or the remapped networks. The network address of each remapped network is excluded from the translation,
+// since it is used for the traffic handled statefully through conntrack (e.g., masqueraded by the gateway).
+func forgeProgram(dir direction) asm.Instructions {
+	matchOffset, targetOffset := int16(ipv4DestOffset), int16(ipv4SourceOffset)
+	if dir == ingress {
+		matchOffset, targetOffset = ipv4SourceOffset, ipv4DestOffset
+	}
+
+	return asm.Instructions{
+		// Retrieve the packet boundaries, and make sure the IPv4 header is present.
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R7, asm.R6, skbDataOffset, asm.Word),
+		asm.LoadMem(asm.R8, asm.R6, skbDataEndOffset, asm.Word),
+		asm.Mov.Reg(asm.R2, asm.R7),
+		asm.Add.Imm(asm.R2, ipv4HeaderLength),
+		asm.JGT.Reg(asm.R2, asm.R8, "pass"),
+		asm.LoadMem(asm.R2, asm.R7, 0, asm.Byte),
+		asm.Mov.Reg(asm.R3, asm.R2),
+		asm.RSh.Imm(asm.R3, 4),
+		asm.JNE.Imm(asm.R3, 4, "pass"),
+
+		// Store the header fields required after the helper calls, which invalidate the packet pointers.
+		asm.And.Imm(asm.R2, 0x0f),
+		asm.LSh.Imm(asm.R2, 2),
+		asm.StoreMem(asm.RFP, stackHeaderLength, asm.R2, asm.Word),
+		asm.LoadMem(asm.R2, asm.R7, ipv4ProtoOffset, asm.Byte),
+		asm.StoreMem(asm.RFP, stackProtocol, asm.R2, asm.Word),
+		asm.LoadMem(asm.R2, asm.R7, ipv4FragOffset, asm.Half),
+		asm.HostTo(asm.BE, asm.R2, asm.Half),
+		asm.And.Imm(asm.R2, ipv4FragMask),
+		asm.StoreMem(asm.RFP, stackFragment, asm.R2, asm.Word),
+		asm.LoadMem(asm.R2, asm.R7, matchOffset, asm.Word),
+		asm.LoadMem(asm.R3, asm.R7, targetOffset, asm.Word),
+		asm.StoreMem(asm.RFP, stackTarget, asm.R3, asm.Word),
+
+		// Identify the remote cluster.
+		asm.StoreImm(asm.RFP, stackClusterKey, clusterKeyFixedBits+32, asm.Word),
+		asm.StoreImm(asm.RFP, stackClusterKey+4, int64(dir), asm.Word),
+		asm.StoreMem(asm.RFP, stackClusterKey+8, asm.R2, asm.Word),
+		asm.LoadMapPtr(asm.R1, 0).WithReference(clustersMapName),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackClusterKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "pass"),
+		asm.LoadMem(asm.R9, asm.R0, 0, asm.Word),
+
+		// Look for a per-endpoint mapping.
+		asm.StoreMem(asm.RFP, stackEndpointKey, asm.R9, asm.Word),
+		asm.StoreImm(asm.RFP, stackEndpointKey+4, int64(dir), asm.Word),
+		asm.LoadMem(asm.R3, asm.RFP, stackTarget, asm.Word),
+		asm.StoreMem(asm.RFP, stackEndpointKey+8, asm.R3, asm.Word),
+		asm.LoadMapPtr(asm.R1, 0).WithReference(endpointsMapName),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackEndpointKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "prefix"),
+		asm.LoadMem(asm.R3, asm.R0, 0, asm.Word),
+		asm.StoreMem(asm.RFP, stackTranslated, asm.R3, asm.Word),
+		asm.Ja.Label("rewrite"),
+
+		// Look for a remapped network.
+		asm.StoreImm(asm.RFP, stackPrefixKey, prefixKeyFixedBits+32, asm.Word).WithSymbol("prefix"),
+		asm.StoreMem(asm.RFP, stackPrefixKey+4, asm.R9, asm.Word),
+		asm.StoreImm(asm.RFP, stackPrefixKey+8, int64(dir), asm.Word),
+		asm.LoadMem(asm.R3, asm.RFP, stackTarget, asm.Word),
+		asm.StoreMem(asm.RFP, stackPrefixKey+12, asm.R3, asm.Word),
+		asm.LoadMapPtr(asm.R1, 0).WithReference(prefixesMapName),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackPrefixKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "pass"),
+		asm.LoadMem(asm.R2, asm.R0, 0, asm.Word),
+		asm.LoadMem(asm.R3, asm.R0, 4, asm.Word),
+		asm.LoadMem(asm.R4, asm.RFP, stackTarget, asm.Word),
+		asm.And.Reg(asm.R4, asm.R3),
+		asm.JEq.Imm(asm.R4, 0, "pass"),
+		asm.Or.Reg(asm.R4, asm.R2),
+		asm.StoreMem(asm.RFP, stackTranslated, asm.R4, asm.Word),
+
+		// Update the L4 checksum (only TCP and UDP include the addresses, through the pseudo header),
+		// unless the packet is a non-first fragment, which does not include the L4 header.
+		asm.LoadMem(asm.R2, asm.RFP, stackFragment, asm.Word).WithSymbol("rewrite"),
+		asm.JNE.Imm(asm.R2, 0, "l3"),
+		asm.LoadMem(asm.R2, asm.RFP, stackHeaderLength, asm.Word),
+		asm.LoadMem(asm.R3, asm.RFP, stackProtocol, asm.Word),
+		asm.JEq.Imm(asm.R3, unix.IPPROTO_TCP, "tcp"),
+		asm.JEq.Imm(asm.R3, unix.IPPROTO_UDP, "udp"),
+		asm.Ja.Label("l3"),
+		asm.Add.Imm(asm.R2, tcpChecksumOffset).WithSymbol("tcp"),
+		asm.Mov.Imm(asm.R5, csumPseudoHeader|csumFieldSize),
+		asm.Ja.Label("l4"),
+		asm.Add.Imm(asm.R2, udpChecksumOffset).WithSymbol("udp"),
+		asm.Mov.Imm(asm.R5, csumPseudoHeader|csumMangled0|csumFieldSize),
+		asm.Mov.Reg(asm.R1, asm.R6).WithSymbol("l4"),
+		asm.LoadMem(asm.R3, asm.RFP, stackTarget, asm.Word),
+		asm.LoadMem(asm.R4, asm.RFP, stackTranslated, asm.Word),
+		asm.FnL4CsumReplace.Call(),
+		asm.JNE.Imm(asm.R0, 0, "drop"),
+
+		// Update the L3 checksum, and finally rewrite the address.
+		asm.Mov.Reg(asm.R1, asm.R6).WithSymbol("l3"),
+		asm.Mov.Imm(asm.R2, ipv4ChecksumOffet),
+		asm.LoadMem(asm.R3, asm.RFP, stackTarget, asm.Word),
+		asm.LoadMem(asm.R4, asm.RFP, stackTranslated, asm.Word),
+		asm.Mov.Imm(asm.R5, csumFieldSize),
+		asm.FnL3CsumReplace.Call(),
+		asm.JNE.Imm(asm.R0, 0, "drop"),
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.Mov.Imm(asm.R2, int32(targetOffset)),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackTranslated),
+		asm.Mov.Imm(asm.R4, 4),
+		asm.Mov.Imm(asm.R5, 0),
+		asm.FnSkbStoreBytes.Call(),
+		asm.JNE.Imm(asm.R0, 0, "drop"),
+
+		asm.Mov.Imm(asm.R0, tcActOk).WithSymbol("pass"),
+		asm.Return(),
+		asm.Mov.Imm(asm.R0, tcActShot).WithSymbol("drop"),
+		asm.Return(),
+	}
+}
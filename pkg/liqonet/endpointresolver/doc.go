@@ -0,0 +1,18 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package endpointresolver implements a set of pluggable resolvers detecting the address the local gateway is reachable
+// at from the remote clusters, in environments where it cannot be inferred from the gateway service (e.g., NodePort
+// services in bare-metal or NATted setups).
+package endpointresolver
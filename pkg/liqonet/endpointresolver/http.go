@@ -0,0 +1,89 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointresolver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxResponseSize is the maximum size of the responses read from the external services.
+	maxResponseSize = 1024
+	// awsTokenTTLHeader and awsTokenHeader are the headers to request and present the AWS session token.
+	awsTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	awsTokenHeader    = "X-aws-ec2-metadata-token"
+)
+
+// httpResolver resolves the address as the plain text body returned by an HTTP endpoint, either an echo service
+// or a cloud metadata service.
+type httpResolver struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	// tokenURL is the address the session token is retrieved from before querying the metadata (AWS only).
+	tokenURL string
+}
+
+// Resolve queries the HTTP endpoint, and returns the address contained in the response.
+func (hr *httpResolver) Resolve(ctx context.Context) (string, error) {
+	headers := hr.headers
+	if hr.tokenURL != "" {
+		token, err := hr.get(ctx, http.MethodPut, hr.tokenURL, map[string]string{awsTokenTTLHeader: "60"})
+		if err != nil {
+			return "", fmt.Errorf("failed to retrieve the metadata session token: %w", err)
+		}
+		headers = map[string]string{awsTokenHeader: token}
+	}
+
+	body, err := hr.get(ctx, http.MethodGet, hr.url, headers)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(body) == nil {
+		return "", fmt.Errorf("invalid address %q returned by %s", body, hr.url)
+	}
+	return body, nil
+}
+
+// get performs the given request, and returns the trimmed response body.
+func (hr *httpResolver) get(ctx context.Context, method, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := hr.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d returned by %s", resp.StatusCode, url)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointresolver
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// nodeResolver resolves the address as the ExternalIP of the node hosting the gateway, to be used with NodePort services.
+type nodeResolver struct {
+	clientset k8s.Interface
+	namespace string
+	name      string
+}
+
+// Resolve returns the ExternalIP of the node hosting the gateway pod.
+func (nr *nodeResolver) Resolve(ctx context.Context) (string, error) {
+	pod, err := nr.clientset.CoreV1().Pods(nr.namespace).Get(ctx, nr.name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve the gateway pod: %w", err)
+	}
+	if pod.Spec.NodeName == "" {
+		return "", fmt.Errorf("gateway pod %s/%s not yet scheduled", nr.namespace, nr.name)
+	}
+
+	node, err := nr.clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve node %s: %w", pod.Spec.NodeName, err)
+	}
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeExternalIP {
+			return address.Address, nil
+		}
+	}
+	return "", fmt.Errorf("node %s has no ExternalIP address", node.GetName())
+}
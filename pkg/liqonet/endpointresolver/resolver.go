@@ -0,0 +1,93 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointresolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// NodeResolver resolves the address as the ExternalIP of the node hosting the gateway.
+	NodeResolver = "node"
+	// EchoResolver resolves the address as the one observed by an external echo service.
+	EchoResolver = "echo"
+	// AWSResolver resolves the address through the AWS instance metadata service.
+	AWSResolver = "aws"
+	// GCPResolver resolves the address through the GCP metadata server.
+	GCPResolver = "gcp"
+	// AzureResolver resolves the address through the Azure instance metadata service.
+	AzureResolver = "azure"
+
+	// DefaultMetadataURL is the default address of the cloud metadata services.
+	DefaultMetadataURL = "http://169.254.169.254"
+	// requestTimeout is the timeout of the requests performed towards the external services.
+	requestTimeout = 10 * time.Second
+)
+
+// Kinds is the list of the supported resolvers.
+var Kinds = []string{NodeResolver, EchoResolver, AWSResolver, GCPResolver, AzureResolver}
+
+// Resolver resolves the address the local gateway is reachable at from the remote clusters.
+type Resolver interface {
+	// Resolve returns the address the local gateway is reachable at.
+	Resolve(ctx context.Context) (string, error)
+}
+
+// Options contains the parameters to configure the resolvers.
+type Options struct {
+	// Clientset, PodNamespace and PodName are used by the node resolver, to retrieve the node hosting the gateway.
+	Clientset    k8s.Interface
+	PodNamespace string
+	PodName      string
+	// URL is the address of the echo service, or the base address of the cloud metadata service (e.g., a local stand-in).
+	URL string
+}
+
+// New returns the resolver of the given kind, configured according to the given options.
+func New(kind string, opts *Options) (Resolver, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	metadataURL := opts.URL
+	if metadataURL == "" {
+		metadataURL = DefaultMetadataURL
+	}
+
+	switch kind {
+	case NodeResolver:
+		return &nodeResolver{clientset: opts.Clientset, namespace: opts.PodNamespace, name: opts.PodName}, nil
+	case EchoResolver:
+		if opts.URL == "" {
+			return nil, fmt.Errorf("the URL of the echo service is required by the %s resolver", kind)
+		}
+		return &httpResolver{client: client, url: opts.URL}, nil
+	case AWSResolver:
+		// The session token is required by IMDSv2, and it is accepted also by IMDSv1.
+		return &httpResolver{client: client, url: metadataURL + "/latest/meta-data/public-ipv4",
+			tokenURL: metadataURL + "/latest/api/token"}, nil
+	case GCPResolver:
+		return &httpResolver{client: client, url: metadataURL + "/computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip",
+			headers: map[string]string{"Metadata-Flavor": "Google"}}, nil
+	case AzureResolver:
+		return &httpResolver{client: client,
+			url:     metadataURL + "/metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress?api-version=2021-02-01&format=text",
+			headers: map[string]string{"Metadata": "true"}}, nil
+	default:
+		return nil, fmt.Errorf("unknown endpoint resolver %q", kind)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointresolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Endpoint resolvers", func() {
+	var (
+		ctx      context.Context
+		resolver Resolver
+		address  string
+		err      error
+	)
+
+	BeforeEach(func() { ctx = context.Background() })
+
+	Describe("the node resolver", func() {
+		var node *corev1.Node
+
+		BeforeEach(func() {
+			node = &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node"},
+				Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: corev1.NodeExternalIP, Address: "1.1.1.1"},
+				}},
+			}
+		})
+
+		JustBeforeEach(func() {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "gateway", Namespace: "liqo"}, Spec: corev1.PodSpec{NodeName: "node"}}
+			resolver, err = New(NodeResolver, &Options{Clientset: fake.NewSimpleClientset(pod, node), PodNamespace: "liqo", PodName: "gateway"})
+			Expect(err).ToNot(HaveOccurred())
+			address, err = resolver.Resolve(ctx)
+		})
+
+		It("should return the ExternalIP of the node hosting the gateway", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(address).To(Equal("1.1.1.1"))
+		})
+
+		When("the node has no ExternalIP", func() {
+			BeforeEach(func() { node.Status.Addresses = node.Status.Addresses[:1] })
+			It("should fail", func() { Expect(err).To(HaveOccurred()) })
+		})
+	})
+
+	Describe("the HTTP resolvers", func() {
+		var (
+			server *httptest.Server
+			body   string
+			kind   string
+		)
+
+		BeforeEach(func() {
+			body = "2.2.2.2\n"
+			// The local stand-in of the echo and metadata services replies only to the expected requests.
+			mux := http.NewServeMux()
+			mux.HandleFunc("/ip", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(body)) })
+			mux.HandleFunc("/latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.Header.Get(awsTokenTTLHeader) == "" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte("token"))
+			})
+			mux.HandleFunc("/latest/meta-data/public-ipv4", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(awsTokenHeader) != "token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(body))
+			})
+			mux.HandleFunc("/computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Metadata-Flavor") != "Google" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				_, _ = w.Write([]byte(body))
+			})
+			mux.HandleFunc("/metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("format") != "text" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(body))
+			})
+			server = httptest.NewServer(mux)
+			DeferCleanup(server.Close)
+		})
+
+		JustBeforeEach(func() {
+			url := server.URL
+			if kind == EchoResolver {
+				url += "/ip"
+			}
+			resolver, err = New(kind, &Options{URL: url})
+			Expect(err).ToNot(HaveOccurred())
+			address, err = resolver.Resolve(ctx)
+		})
+
+		for _, k := range []string{EchoResolver, AWSResolver, GCPResolver, AzureResolver} {
+			k := k
+			When("the "+k+" resolver is selected", func() {
+				BeforeEach(func() { kind = k })
+
+				It("should return the address returned by the service", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(address).To(Equal("2.2.2.2"))
+				})
+
+				When("the service returns an invalid address", func() {
+					BeforeEach(func() { body = "<html>foo</html>" })
+					It("should fail", func() { Expect(err).To(HaveOccurred()) })
+				})
+			})
+		}
+	})
+
+	Describe("the New function", func() {
+		It("should fail in case of unknown resolvers", func() {
+			_, err = New("foo", &Options{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should require the URL of the echo service", func() {
+			_, err = New(EchoResolver, &Options{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,122 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	liqoneterrors "github.com/liqotech/liqo/pkg/liqonet/errors"
+)
+
+// Garbage contains the IPAM resources no longer associated with any active remote cluster or endpoint.
+type Garbage struct {
+	// Clusters contains the IDs of the remote clusters whose networks and NAT mappings are orphaned.
+	Clusters sets.String
+	// EndpointMappings contains the leaked endpoint mappings, associating each endpoint IP to the clusters it is leaked for.
+	EndpointMappings map[string]sets.String
+}
+
+// NewGarbage returns a new empty Garbage instance.
+func NewGarbage() *Garbage {
+	return &Garbage{Clusters: sets.NewString(), EndpointMappings: make(map[string]sets.String)}
+}
+
+// CountEndpointMappings returns the number of leaked endpoint mappings.
+func (g *Garbage) CountEndpointMappings() int {
+	count := 0
+	for _, clusters := range g.EndpointMappings {
+		count += clusters.Len()
+	}
+	return count
+}
+
+// IsEmpty returns whether no garbage is present.
+func (g *Garbage) IsEmpty() bool {
+	return g.Clusters.Len() == 0 && g.CountEndpointMappings() == 0
+}
+
+// Intersect returns the garbage present in both the current and the given instance.
+func (g *Garbage) Intersect(other *Garbage) *Garbage {
+	intersection := NewGarbage()
+	intersection.Clusters = g.Clusters.Intersection(other.Clusters)
+	for ip, clusters := range g.EndpointMappings {
+		if common := clusters.Intersection(other.EndpointMappings[ip]); common.Len() > 0 {
+			intersection.EndpointMappings[ip] = common
+		}
+	}
+	return intersection
+}
+
+// FindGarbage returns the IPAM resources which are no longer in use. Specifically, the networks and NAT mappings
+// associated with remote clusters not included in the active set, as well as the endpoint mappings whose endpoint IP
+// is not included in the active set, or which refer to a non-active remote cluster.
+func (liqoIPAM *IPAM) FindGarbage(activeClusters, activeEndpoints sets.String) *Garbage {
+	liqoIPAM.mutex.Lock()
+	defer liqoIPAM.mutex.Unlock()
+
+	garbage := NewGarbage()
+	for clusterID := range liqoIPAM.ipamStorage.getClusterSubnets() {
+		if !activeClusters.Has(clusterID) {
+			garbage.Clusters.Insert(clusterID)
+		}
+	}
+	for _, clusterID := range liqoIPAM.natMappingInflater.GetClusterIDs() {
+		if !activeClusters.Has(clusterID) {
+			garbage.Clusters.Insert(clusterID)
+		}
+	}
+
+	for ip, mapping := range liqoIPAM.ipamStorage.getEndpointMappings() {
+		for clusterID := range mapping.ClusterMappings {
+			if !activeEndpoints.Has(ip) || !activeClusters.Has(clusterID) {
+				if _, found := garbage.EndpointMappings[ip]; !found {
+					garbage.EndpointMappings[ip] = sets.NewString()
+				}
+				garbage.EndpointMappings[ip].Insert(clusterID)
+			}
+		}
+	}
+	return garbage
+}
+
+// CollectGarbage frees the given IPAM resources, previously returned by FindGarbage.
+func (liqoIPAM *IPAM) CollectGarbage(garbage *Garbage) error {
+	for ip, clusters := range garbage.EndpointMappings {
+		for clusterID := range clusters {
+			// The NAT mappings might have never been initialized, in case of leaked endpoint mappings towards orphaned clusters.
+			err := liqoIPAM.unmapEndpointIPInternal(clusterID, ip)
+			if err != nil && !errors.Is(err, &liqoneterrors.MissingInit{}) {
+				return fmt.Errorf("failed to release the mapping of endpoint %s for cluster %s: %w", ip, clusterID, err)
+			}
+			klog.Infof("Leaked mapping of endpoint %s for cluster %s has been released", ip, clusterID)
+		}
+	}
+
+	for clusterID := range garbage.Clusters {
+		if err := liqoIPAM.RemoveClusterConfig(clusterID); err != nil {
+			return fmt.Errorf("failed to remove the configuration of cluster %s: %w", clusterID, err)
+		}
+		// Make sure the NatMapping resource is removed, even in case the cluster networks had already been freed.
+		if err := liqoIPAM.natMappingInflater.TerminateNatMappingsPerCluster(clusterID); err != nil {
+			return fmt.Errorf("failed to terminate the NAT mappings of cluster %s: %w", clusterID, err)
+		}
+		klog.Infof("Orphaned configuration of cluster %s has been removed", clusterID)
+	}
+	return nil
+}
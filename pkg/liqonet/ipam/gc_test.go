@@ -0,0 +1,115 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/liqotech/liqo/pkg/consts"
+)
+
+var _ = Describe("Garbage collection", func() {
+	Describe("Garbage", func() {
+		It("Should intersect the garbage of two runs", func() {
+			first := NewGarbage()
+			first.Clusters.Insert(clusterID1, clusterID2)
+			first.EndpointMappings[endpointIP] = sets.NewString(clusterID1, clusterID2)
+			first.EndpointMappings[externalEndpointIP] = sets.NewString(clusterID1)
+
+			second := NewGarbage()
+			second.Clusters.Insert(clusterID2, clusterID3)
+			second.EndpointMappings[endpointIP] = sets.NewString(clusterID2)
+
+			intersection := first.Intersect(second)
+			Expect(intersection.Clusters.List()).To(ConsistOf(clusterID2))
+			Expect(intersection.EndpointMappings).To(HaveLen(1))
+			Expect(intersection.EndpointMappings[endpointIP].List()).To(ConsistOf(clusterID2))
+			Expect(intersection.CountEndpointMappings()).To(Equal(1))
+			Expect(intersection.IsEmpty()).To(BeFalse())
+			Expect(NewGarbage().Intersect(first).IsEmpty()).To(BeTrue())
+		})
+	})
+
+	Describe("FindGarbage and CollectGarbage", func() {
+		BeforeEach(func() {
+			ipam = NewIPAM()
+			Expect(setDynClient()).To(Succeed())
+			n, err := rand.Int(rand.Reader, big.NewInt(10000))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ipam.Init(Pools, dynClient, 2000+int(n.Int64()))).To(Succeed())
+
+			Expect(ipam.SetPodCIDR(homePodCIDR)).To(Succeed())
+			_, err = ipam.GetExternalCIDR(uint8(24))
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, clusterID := range []string{clusterID1, clusterID2} {
+				_, _, err = ipam.GetSubnetsPerCluster(remotePodCIDR, remoteExternalCIDR, clusterID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ipam.AddLocalSubnetsPerCluster(consts.DefaultCIDRValue, consts.DefaultCIDRValue, clusterID)).To(Succeed())
+			}
+
+			_, err = ipam.MapEndpointIP(context.Background(), &MapRequest{ClusterID: clusterID1, Ip: endpointIP})
+			Expect(err).ToNot(HaveOccurred())
+		})
+		AfterEach(func() {
+			ipam.Terminate()
+		})
+
+		When("all the clusters and endpoints are active", func() {
+			It("Should not detect any garbage", func() {
+				garbage := ipam.FindGarbage(sets.NewString(clusterID1, clusterID2), sets.NewString(endpointIP))
+				Expect(garbage.IsEmpty()).To(BeTrue())
+			})
+		})
+
+		When("a cluster is no longer active", func() {
+			It("Should detect and free its configuration", func() {
+				garbage := ipam.FindGarbage(sets.NewString(clusterID1), sets.NewString(endpointIP))
+				Expect(garbage.Clusters.List()).To(ConsistOf(clusterID2))
+				Expect(garbage.EndpointMappings).To(BeEmpty())
+
+				Expect(ipam.CollectGarbage(garbage)).To(Succeed())
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ipamStorage.Spec.ClusterSubnets).To(HaveKey(clusterID1))
+				Expect(ipamStorage.Spec.ClusterSubnets).ToNot(HaveKey(clusterID2))
+				_, err = getNatMappingResourcePerCluster(clusterID2)
+				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+			})
+		})
+
+		When("an endpoint is no longer active", func() {
+			It("Should detect and release the corresponding mappings", func() {
+				garbage := ipam.FindGarbage(sets.NewString(clusterID1, clusterID2), sets.NewString())
+				Expect(garbage.Clusters).To(BeEmpty())
+				Expect(garbage.EndpointMappings).To(HaveKey(endpointIP))
+				Expect(garbage.EndpointMappings[endpointIP].List()).To(ConsistOf(clusterID1))
+
+				Expect(ipam.CollectGarbage(garbage)).To(Succeed())
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ipamStorage.Spec.EndpointMappings).ToNot(HaveKey(endpointIP))
+				Expect(ipamStorage.Spec.ClusterSubnets).To(HaveKey(clusterID1))
+			})
+		})
+	})
+})
@@ -25,6 +25,7 @@ import (
 	goipam "github.com/metal-stack/go-ipam"
 	grpc "google.golang.org/grpc"
 	"inet.af/netaddr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/klog/v2"
 
@@ -50,6 +51,11 @@ type Ipam interface {
 	- Both.
 	*/
 	GetSubnetsPerCluster(podCidr, externalCIDR, clusterID string) (string, string, error)
+	// GetExportedSubnetsPerCluster receives the additional networks exported by a remote cluster, and returns
+	// the networks used in the local cluster to map them, keyed by the original ones. Similarly to
+	// GetSubnetsPerCluster, each network is remapped only in case of conflicts, while the networks
+	// no longer exported by the remote cluster are freed.
+	GetExportedSubnetsPerCluster(exportedCIDRs []string, clusterID string) (map[string]string, error)
 	// RemoveClusterConfig deletes the IPAM configuration of a remote cluster,
 	// by freeing networks and removing data structures related to that cluster.
 	RemoveClusterConfig(clusterID string) error
@@ -61,6 +67,18 @@ type Ipam interface {
 	AddNetworkPool(network string) error
 	// RemoveNetworkPool removes a network from the set of network pools.
 	RemoveNetworkPool(network string) error
+	// GetNetworkPools returns the current set of network pools, including the default ones.
+	GetNetworkPools() []string
+	// ValidateNetworkPool checks whether a network can be added to the set of network pools,
+	// returning an error describing the conflict otherwise.
+	ValidateNetworkPool(network string) error
+	// SetReservedSubnets acquires the given reserved networks, and frees the ones no longer present.
+	SetReservedSubnets(subnets []string) error
+	// GetReservedSubnets returns the current set of reserved networks.
+	GetReservedSubnets() []string
+	// ValidateReservedSubnet checks whether a network can be added to the set of reserved networks,
+	// returning an error describing the conflict otherwise.
+	ValidateReservedSubnet(network string) error
 	/* AddLocalSubnetsPerCluster stores the PodCIDR and the ExternalCIDR used in the remote cluster to
 	map the local cluster subnets. Since those networks are used in the remote cluster
 	this function must not reserve it. If the remote cluster has not remapped
@@ -71,6 +89,10 @@ type Ipam interface {
 	SetPodCIDR(podCIDR string) error
 	// SetServiceCIDR sets the cluster ServiceCIDR.
 	SetServiceCIDR(serviceCIDR string) error
+	// FindGarbage returns the IPAM resources no longer associated with the given active remote clusters and endpoints.
+	FindGarbage(activeClusters, activeEndpoints sets.String) *Garbage
+	// CollectGarbage frees the given IPAM resources, previously returned by FindGarbage.
+	CollectGarbage(garbage *Garbage) error
 	// Terminate function enforces a graceful termination of the IPAM module.
 	Terminate()
 	IpamServer
@@ -265,6 +287,7 @@ func (liqoIPAM *IPAM) overlapsWithNetwork(newNetwork, network string) (overlaps
 func (liqoIPAM *IPAM) overlapsWithCluster(network string) (overlappingCluster string, overlaps bool, err error) {
 	var overlapsWithPodCIDR bool
 	var overlapsWithExternalCIDR bool
+	var overlapsWithExportedCIDR bool
 	// Get cluster subnets
 	clusterSubnets := liqoIPAM.ipamStorage.getClusterSubnets()
 	for cluster, subnets := range clusterSubnets {
@@ -276,7 +299,15 @@ func (liqoIPAM *IPAM) overlapsWithCluster(network string) (overlappingCluster st
 		if err != nil {
 			return
 		}
-		if overlapsWithPodCIDR || overlapsWithExternalCIDR {
+		for _, mappedCIDR := range subnets.RemoteExportedCIDRs {
+			if overlapsWithExportedCIDR, err = liqoIPAM.overlapsWithNetwork(network, mappedCIDR); err != nil {
+				return
+			}
+			if overlapsWithExportedCIDR {
+				break
+			}
+		}
+		if overlapsWithPodCIDR || overlapsWithExternalCIDR || overlapsWithExportedCIDR {
 			overlaps = true
 			overlappingCluster = cluster
 			return
@@ -301,6 +332,22 @@ func (liqoIPAM *IPAM) overlapsWithPool(network string) (overlappingPool string,
 	return
 }
 
+// overlapsWithLocalNetworks checks whether the given network overlaps with the PodCIDR, the ServiceCIDR
+// or the ExternalCIDR of the local cluster, returning a description of the overlapping one.
+func (liqoIPAM *IPAM) overlapsWithLocalNetworks(network string) (overlappingNetwork string, overlaps bool, err error) {
+	locals := []struct{ name, cidr string }{
+		{name: "podCIDR", cidr: liqoIPAM.ipamStorage.getPodCIDR()},
+		{name: "serviceCIDR", cidr: liqoIPAM.ipamStorage.getServiceCIDR()},
+		{name: "external CIDR", cidr: liqoIPAM.ipamStorage.getExternalCIDR()},
+	}
+	for _, local := range locals {
+		if overlaps, err = liqoIPAM.overlapsWithNetwork(network, local.cidr); err != nil || overlaps {
+			return fmt.Sprintf("%s %s", local.name, local.cidr), overlaps, err
+		}
+	}
+	return
+}
+
 func (liqoIPAM *IPAM) overlapsWithReserved(network string) (overlappingReserved string, overlaps bool, err error) {
 	reserved := liqoIPAM.ipamStorage.getReservedSubnets()
 	for _, r := range reserved {
@@ -499,6 +546,83 @@ func (liqoIPAM *IPAM) GetSubnetsPerCluster(
 	return mappedPodCIDR, mappedExternalCIDR, nil
 }
 
+/*
+GetExportedSubnetsPerCluster receives the additional networks exported by a remote cluster, and returns
+the networks used in the local cluster to map them (either the received ones or new ones, if conflicts have been found).
+Previously allocated networks are preserved, while the ones no longer exported are freed.
+*/
+func (liqoIPAM *IPAM) GetExportedSubnetsPerCluster(exportedCIDRs []string, clusterID string) (map[string]string, error) {
+	if clusterID == "" {
+		return nil, &liqoneterrors.WrongParameter{
+			Parameter: consts.ClusterIDLabelName,
+			Reason:    liqoneterrors.StringNotEmpty,
+		}
+	}
+
+	// Get subnets of clusters
+	clusterSubnets := liqoIPAM.ipamStorage.getClusterSubnets()
+	subnets := clusterSubnets[clusterID]
+
+	mappings := make(map[string]string, len(exportedCIDRs))
+	var allocated []string
+	for _, exportedCIDR := range exportedCIDRs {
+		if mappedCIDR, found := subnets.RemoteExportedCIDRs[exportedCIDR]; found {
+			mappings[exportedCIDR] = mappedCIDR
+			continue
+		}
+
+		if err := liqonetutils.IsValidCIDR(exportedCIDR); err != nil {
+			liqoIPAM.freeSubnets(allocated)
+			return nil, fmt.Errorf("exported CIDR %s is an invalid CIDR: %w", exportedCIDR, err)
+		}
+
+		mappedCIDR, err := liqoIPAM.getOrRemapNetwork(exportedCIDR)
+		if err != nil {
+			liqoIPAM.freeSubnets(allocated)
+			return nil, fmt.Errorf("cannot get a network for the exported CIDR %s of cluster %s: %w", exportedCIDR, clusterID, err)
+		}
+		klog.Infof("Network %s has been assigned to the exported CIDR %s of cluster %s", mappedCIDR, exportedCIDR, clusterID)
+		mappings[exportedCIDR] = mappedCIDR
+		allocated = append(allocated, mappedCIDR)
+	}
+
+	// Free the networks no longer exported by the remote cluster.
+	var released []string
+	for exportedCIDR, mappedCIDR := range subnets.RemoteExportedCIDRs {
+		if _, found := mappings[exportedCIDR]; !found {
+			released = append(released, mappedCIDR)
+		}
+	}
+
+	if len(allocated) == 0 && len(released) == 0 {
+		return mappings, nil
+	}
+
+	subnets.RemoteExportedCIDRs = mappings
+	if len(mappings) == 0 {
+		subnets.RemoteExportedCIDRs = nil
+	}
+	clusterSubnets[clusterID] = subnets
+	if err := liqoIPAM.eventuallyDeleteClusterSubnet(clusterID, clusterSubnets); err != nil {
+		liqoIPAM.freeSubnets(allocated)
+		return nil, fmt.Errorf("cannot update cluster subnets: %w", err)
+	}
+
+	for _, mappedCIDR := range released {
+		if err := liqoIPAM.FreeReservedSubnet(mappedCIDR); err != nil {
+			return nil, err
+		}
+	}
+	return mappings, nil
+}
+
+// freeSubnets frees the given networks, ignoring possible errors (used for cleanup purposes).
+func (liqoIPAM *IPAM) freeSubnets(networks []string) {
+	for _, network := range networks {
+		_ = liqoIPAM.FreeReservedSubnet(network)
+	}
+}
+
 // getNetworkFromPool returns a network with mask length equal to mask taken by a network pool.
 func (liqoIPAM *IPAM) getNetworkFromPool(mask uint8) (string, error) {
 	// Get network pools
@@ -589,7 +713,8 @@ func (liqoIPAM *IPAM) eventuallyDeleteClusterSubnet(clusterID string,
 	if subnets.RemotePodCIDR == "" &&
 		subnets.LocalNATPodCIDR == "" &&
 		subnets.RemoteExternalCIDR == "" &&
-		subnets.LocalNATExternalCIDR == "" {
+		subnets.LocalNATExternalCIDR == "" &&
+		len(subnets.RemoteExportedCIDRs) == 0 {
 		// Delete entry
 		delete(clusterSubnets, clusterID)
 	}
@@ -638,6 +763,13 @@ func (liqoIPAM *IPAM) RemoveClusterConfig(clusterID string) error {
 		if err := liqoIPAM.FreeReservedSubnet(subnets.RemoteExternalCIDR); err != nil {
 			return err
 		}
+
+		// Free the networks mapping the exported CIDRs
+		for _, mappedCIDR := range subnets.RemoteExportedCIDRs {
+			if err := liqoIPAM.FreeReservedSubnet(mappedCIDR); err != nil {
+				return err
+			}
+		}
 		klog.Infof("Networks assigned to cluster %s have just been freed", clusterID)
 
 		delete(clusterSubnets, clusterID)
@@ -808,6 +940,28 @@ func (liqoIPAM *IPAM) RemoveNetworkPool(network string) error {
 		return fmt.Errorf("cannot remove network pool %s because it overlaps with network %s of cluster %s",
 			network, clusterSubnets[cluster], cluster)
 	}
+	// Check overlapping with the networks of the local cluster
+	local, overlaps, err := liqoIPAM.overlapsWithLocalNetworks(network)
+	if err != nil {
+		return fmt.Errorf("cannot check if network pool %s overlaps with local networks: %w", network, err)
+	}
+	if overlaps {
+		return fmt.Errorf("cannot remove network pool %s because it is in use by the local %s", network, local)
+	}
+	// Check overlapping with reserved networks
+	reserved, overlaps, err := liqoIPAM.overlapsWithReserved(network)
+	if err != nil {
+		return fmt.Errorf("cannot check if network pool %s overlaps with reserved networks: %w", network, err)
+	}
+	if overlaps {
+		return fmt.Errorf("cannot remove network pool %s because it overlaps with the reserved network %s", network, reserved)
+	}
+	// Check that no other network has been acquired from the pool, since go-ipam does not prevent
+	// the removal of a prefix with acquired child prefixes.
+	if p := liqoIPAM.ipam.PrefixFrom(context.TODO(), network); p != nil && p.Usage().AcquiredPrefixes > 0 {
+		return fmt.Errorf("cannot remove network pool %s because %d networks are still acquired from it",
+			network, p.Usage().AcquiredPrefixes)
+	}
 	// Release it
 	_, err = liqoIPAM.ipam.DeletePrefix(context.TODO(), network)
 	if err != nil {
@@ -835,6 +989,56 @@ func (liqoIPAM *IPAM) RemoveNetworkPool(network string) error {
 	return nil
 }
 
+// GetNetworkPools returns the current set of network pools, including the default ones.
+func (liqoIPAM *IPAM) GetNetworkPools() []string {
+	return liqoIPAM.ipamStorage.getPools()
+}
+
+// ValidateNetworkPool checks whether a network can be added to the set of network pools, that is it is a valid
+// network not overlapping with the local PodCIDR, ServiceCIDR and ExternalCIDR, the existing network pools,
+// the reserved networks and the networks of remote clusters.
+func (liqoIPAM *IPAM) ValidateNetworkPool(network string) error {
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		return fmt.Errorf("network %s is not a valid CIDR: %w", network, err)
+	}
+	if slice.ContainsString(liqoIPAM.ipamStorage.getPools(), network) {
+		return fmt.Errorf("network %s is already a network pool", network)
+	}
+
+	local, overlaps, err := liqoIPAM.overlapsWithLocalNetworks(network)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return fmt.Errorf("network pool %s overlaps with the local %s", network, local)
+	}
+
+	pool, overlaps, err := liqoIPAM.overlapsWithPool(network)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return fmt.Errorf("network pool %s overlaps with the existing network pool %s", network, pool)
+	}
+
+	reserved, overlaps, err := liqoIPAM.overlapsWithReserved(network)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return fmt.Errorf("network pool %s overlaps with the reserved network %s", network, reserved)
+	}
+
+	cluster, overlaps, err := liqoIPAM.overlapsWithCluster(network)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return fmt.Errorf("network pool %s overlaps with the networks of cluster %s", network, cluster)
+	}
+	return nil
+}
+
 // AddLocalSubnetsPerCluster stores how the PodCIDR and the ExternalCIDR of local cluster
 // has been remapped in a remote cluster. If no remapping happened, then the CIDR value should be equal to "None".
 func (liqoIPAM *IPAM) AddLocalSubnetsPerCluster(podCIDR, externalCIDR, clusterID string) error {
@@ -1352,6 +1556,21 @@ func (liqoIPAM *IPAM) SetReservedSubnets(subnets []string) error {
 	return nil
 }
 
+// GetReservedSubnets returns the current set of reserved networks.
+func (liqoIPAM *IPAM) GetReservedSubnets() []string {
+	return liqoIPAM.ipamStorage.getReservedSubnets()
+}
+
+// ValidateReservedSubnet checks whether a network can be added to the set of reserved networks, that is it is a valid
+// network not overlapping with the local PodCIDR, ServiceCIDR and ExternalCIDR, the existing reserved networks
+// and the networks of remote clusters.
+func (liqoIPAM *IPAM) ValidateReservedSubnet(network string) error {
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		return fmt.Errorf("network %s is not a valid CIDR: %w", network, err)
+	}
+	return liqoIPAM.reservedSubnetOverlaps(network)
+}
+
 func (liqoIPAM *IPAM) reservedSubnetOverlaps(subnet string) error {
 	// Check if subnet overlaps with local pod CIDR.
 	podCidr := liqoIPAM.ipamStorage.getPodCIDR()
@@ -1402,7 +1621,7 @@ func (liqoIPAM *IPAM) reservedSubnetOverlaps(subnet string) error {
 		return err
 	}
 	if overlaps {
-		return fmt.Errorf("network %s cannot be reserved because it overlaps with the reserved network %s",
+		return fmt.Errorf("network %s cannot be reserved because it overlaps with the networks of cluster %s",
 			subnet, overlappingNet)
 	}
 
@@ -318,6 +318,107 @@ var _ = Describe("Ipam", func() {
 			})
 		})
 	})
+	Describe("GetExportedSubnetsPerCluster", func() {
+		Context("Passing an empty cluster ID", func() {
+			It("should return a WrongParameter error", func() {
+				_, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, "")
+				Expect(err).To(MatchError(&liqoneterrors.WrongParameter{
+					Parameter: consts.ClusterIDLabelName,
+					Reason:    liqoneterrors.StringNotEmpty,
+				}))
+			})
+		})
+		Context("Passing an invalid CIDR", func() {
+			It("should return an error", func() {
+				_, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24", "foo"}, clusterID1)
+				Expect(err).To(HaveOccurred())
+
+				// The networks allocated before the failure should have been freed
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).To(BeNil())
+				Expect(ipamStorage.Spec.Prefixes).ToNot(HaveKey("11.0.0.0/24"))
+			})
+		})
+		Context("When the exported subnets do not conflict with other networks", func() {
+			It("should allocate them without mapping", func() {
+				mappings, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24", "11.0.1.0/24"}, clusterID1)
+				Expect(err).To(BeNil())
+				Expect(mappings).To(Equal(map[string]string{"11.0.0.0/24": "11.0.0.0/24", "11.0.1.0/24": "11.0.1.0/24"}))
+
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).To(BeNil())
+				Expect(ipamStorage.Spec.ClusterSubnets[clusterID1].RemoteExportedCIDRs).To(Equal(mappings))
+			})
+		})
+		Context("When the exported subnets conflict with the networks of another cluster", func() {
+			It("should map them to a new network", func() {
+				_, _, err := ipam.GetSubnetsPerCluster("11.0.0.0/16", "11.1.0.0/16", clusterID1)
+				Expect(err).To(BeNil())
+				mappings, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, clusterID2)
+				Expect(err).To(BeNil())
+				Expect(mappings).To(HaveKey("11.0.0.0/24"))
+				Expect(mappings["11.0.0.0/24"]).ToNot(HavePrefix("11."))
+				Expect(mappings["11.0.0.0/24"]).To(HaveSuffix("/24"))
+			})
+		})
+		Context("Call func twice", func() {
+			It("should return the same mappings", func() {
+				_, _, err := ipam.GetSubnetsPerCluster("11.0.0.0/16", "11.1.0.0/16", clusterID1)
+				Expect(err).To(BeNil())
+				first, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, clusterID2)
+				Expect(err).To(BeNil())
+				second, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, clusterID2)
+				Expect(err).To(BeNil())
+				Expect(second).To(Equal(first))
+			})
+		})
+		Context("When a subnet is no longer exported", func() {
+			It("should free the corresponding network", func() {
+				_, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24", "11.0.1.0/24"}, clusterID1)
+				Expect(err).To(BeNil())
+				mappings, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, clusterID1)
+				Expect(err).To(BeNil())
+				Expect(mappings).To(Equal(map[string]string{"11.0.0.0/24": "11.0.0.0/24"}))
+
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).To(BeNil())
+				Expect(ipamStorage.Spec.Prefixes).To(HaveKey("11.0.0.0/24"))
+				Expect(ipamStorage.Spec.Prefixes).ToNot(HaveKey("11.0.1.0/24"))
+			})
+			It("should remove the cluster entry once no network is left", func() {
+				_, err := ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, clusterID1)
+				Expect(err).To(BeNil())
+				mappings, err := ipam.GetExportedSubnetsPerCluster(nil, clusterID1)
+				Expect(err).To(BeNil())
+				Expect(mappings).To(BeEmpty())
+
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).To(BeNil())
+				Expect(ipamStorage.Spec.ClusterSubnets).ToNot(HaveKey(clusterID1))
+				Expect(ipamStorage.Spec.Prefixes).ToNot(HaveKey("11.0.0.0/24"))
+			})
+		})
+		Context("When the cluster configuration is removed", func() {
+			BeforeEach(func() {
+				Expect(ipam.SetPodCIDR(homePodCIDR)).To(Succeed())
+				_, err := ipam.GetExternalCIDR(uint8(24))
+				Expect(err).To(BeNil())
+			})
+			It("should free the networks mapping the exported subnets", func() {
+				_, _, err := ipam.GetSubnetsPerCluster(remotePodCIDR, remoteExternalCIDR, clusterID1)
+				Expect(err).To(BeNil())
+				_, err = ipam.GetExportedSubnetsPerCluster([]string{"11.0.0.0/24"}, clusterID1)
+				Expect(err).To(BeNil())
+				Expect(ipam.RemoveClusterConfig(clusterID1)).To(Succeed())
+
+				ipamStorage, err := getIpamStorageResource()
+				Expect(err).To(BeNil())
+				Expect(ipamStorage.Spec.ClusterSubnets).ToNot(HaveKey(clusterID1))
+				Expect(ipamStorage.Spec.Prefixes).ToNot(HaveKey("11.0.0.0/24"))
+			})
+		})
+	})
+
 	Describe("RemoveClusterConfig", func() {
 		BeforeEach(func() {
 			err := ipam.SetPodCIDR(homePodCIDR)
@@ -760,6 +861,75 @@ var _ = Describe("Ipam", func() {
 		})
 	})
 
+	Describe("RemoveNetworkPool of a pool still in use", func() {
+		BeforeEach(func() {
+			Expect(ipam.AddNetworkPool("11.0.0.0/8")).To(Succeed())
+		})
+		Context("Remove a network pool containing a reserved network", func() {
+			It("Should generate an error", func() {
+				Expect(ipam.SetReservedSubnets([]string{"11.0.1.0/24"})).To(Succeed())
+				Expect(ipam.RemoveNetworkPool("11.0.0.0/8")).To(MatchError(ContainSubstring("reserved network 11.0.1.0/24")))
+				Expect(ipam.GetNetworkPools()).To(ContainElement("11.0.0.0/8"))
+			})
+		})
+		Context("Remove a network pool containing the PodCIDR", func() {
+			It("Should generate an error", func() {
+				Expect(ipam.SetPodCIDR("11.0.0.0/16")).To(Succeed())
+				Expect(ipam.RemoveNetworkPool("11.0.0.0/8")).To(MatchError(ContainSubstring("podCIDR 11.0.0.0/16")))
+				Expect(ipam.GetNetworkPools()).To(ContainElement("11.0.0.0/8"))
+			})
+		})
+		Context("Remove a network pool with other acquired networks", func() {
+			It("Should generate an error", func() {
+				Expect(ipam.AcquireReservedSubnet("11.0.2.0/24")).To(Succeed())
+				Expect(ipam.RemoveNetworkPool("11.0.0.0/8")).To(MatchError(ContainSubstring("still acquired")))
+				Expect(ipam.GetNetworkPools()).To(ContainElement("11.0.0.0/8"))
+			})
+		})
+	})
+
+	Describe("ValidateNetworkPool", func() {
+		BeforeEach(func() {
+			Expect(ipam.SetPodCIDR("100.64.0.0/16")).To(Succeed())
+			Expect(ipam.SetServiceCIDR("100.65.0.0/16")).To(Succeed())
+			Expect(ipam.AddNetworkPool("11.0.0.0/16")).To(Succeed())
+			Expect(ipam.SetReservedSubnets([]string{"100.66.0.0/16"})).To(Succeed())
+		})
+		DescribeTable("Validating a network pool",
+			func(network string, matcher OmegaMatcher) {
+				Expect(ipam.ValidateNetworkPool(network)).To(matcher)
+			},
+			Entry("a network not overlapping with any other", "12.0.0.0/16", Succeed()),
+			Entry("an invalid network", "12.0.0/16", MatchError(ContainSubstring("not a valid CIDR"))),
+			Entry("an existing network pool", "11.0.0.0/16", MatchError(ContainSubstring("already a network pool"))),
+			Entry("a network overlapping with the PodCIDR", "100.64.0.0/10", MatchError(ContainSubstring("podCIDR 100.64.0.0/16"))),
+			Entry("a network overlapping with the ServiceCIDR", "100.65.128.0/17", MatchError(ContainSubstring("serviceCIDR 100.65.0.0/16"))),
+			Entry("a network overlapping with an existing pool", "11.0.0.0/8", MatchError(ContainSubstring("network pool 11.0.0.0/16"))),
+			Entry("a network overlapping with a reserved network", "100.66.0.0/24", MatchError(ContainSubstring("reserved network 100.66.0.0/16"))),
+		)
+	})
+
+	Describe("ValidateReservedSubnet", func() {
+		BeforeEach(func() {
+			Expect(ipam.SetPodCIDR("10.220.0.0/16")).To(Succeed())
+			Expect(ipam.SetServiceCIDR("10.210.0.0/16")).To(Succeed())
+			Expect(ipam.SetReservedSubnets([]string{"192.168.1.0/24"})).To(Succeed())
+			_, _, err := ipam.GetSubnetsPerCluster("10.0.0.0/16", "10.1.0.0/16", clusterID1)
+			Expect(err).To(BeNil())
+		})
+		DescribeTable("Validating a reserved network",
+			func(network string, matcher OmegaMatcher) {
+				Expect(ipam.ValidateReservedSubnet(network)).To(matcher)
+			},
+			Entry("a network not overlapping with any other", "172.16.34.0/24", Succeed()),
+			Entry("an invalid network", "172.16.34/24", MatchError(ContainSubstring("not a valid CIDR"))),
+			Entry("a network overlapping with the PodCIDR", "10.220.1.0/24", MatchError(ContainSubstring("local podCIDR"))),
+			Entry("a network overlapping with the ServiceCIDR", "10.210.1.0/24", MatchError(ContainSubstring("local serviceCIDR"))),
+			Entry("a network overlapping with a reserved network", "192.168.0.0/16", MatchError(ContainSubstring("reserved network 192.168.1.0/24"))),
+			Entry("a network overlapping with a remote cluster", "10.0.1.0/24", MatchError(ContainSubstring("cluster "+clusterID1))),
+		)
+	})
+
 	Describe("AddLocalSubnetsPerCluster", func() {
 		var externalCIDR string
 		BeforeEach(func() {
@@ -0,0 +1,227 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	"github.com/liqotech/liqo/pkg/utils/slice"
+)
+
+// TrafficKey identifies the traffic generated by the pods of a given namespace towards a given remote cluster.
+type TrafficKey struct {
+	Namespace string
+	ClusterID string
+}
+
+// TrafficCounter holds the amount of traffic accounted for a given TrafficKey.
+type TrafficCounter struct {
+	Bytes   uint64
+	Packets uint64
+}
+
+// EnsureAccountingRules makes sure that the traffic generated by the local pods towards the remote clusters is accounted
+// per source namespace. The podNamespaces map associates the IP address of each local pod to its namespace, while the
+// clusterCIDRs map associates each remote cluster ID to the CIDRs it is reachable through from the home cluster.
+// Specifically, the addresses of the pods of each namespace are grouped in a dedicated ipset, and LIQO-ACCOUNTING
+// contains one rule per namespace, matching the corresponding ipset and jumping to the chain of that namespace,
+// which in turn contains one counting rule for each remote CIDR. Hence, the number of rules does not grow with the pods.
+func (h IPTHandler) EnsureAccountingRules(podNamespaces map[string]string, clusterCIDRs map[string][]string) error {
+	if err := h.createIptablesChainIfNotExists(filterTable, liqonetAccountingChain); err != nil {
+		return err
+	}
+	if err := h.insertLiqoRuleIfNotExists(liqonetForwardingChain, IPTableRule{"-j", liqonetAccountingChain}); err != nil {
+		return err
+	}
+
+	namespaceIPs := make(map[string][]string)
+	for ip, namespace := range podNamespaces {
+		namespaceIPs[namespace] = append(namespaceIPs[namespace], ip)
+	}
+
+	// The namespace sets and chains are configured first, to make sure they exist before being referenced.
+	namespaceChains := make([]string, 0, len(namespaceIPs))
+	namespaceSets := make([]string, 0, len(namespaceIPs))
+	jumpRules := make([]IPTableRule, 0, len(namespaceIPs))
+	for namespace, ips := range namespaceIPs {
+		chain, set := getAccountingNamespaceChain(namespace), getAccountingNamespaceSet(namespace)
+		namespaceChains, namespaceSets = append(namespaceChains, chain), append(namespaceSets, set)
+		if err := ensureIPSet(set, ips); err != nil {
+			return err
+		}
+		if err := h.createIptablesChainIfNotExists(filterTable, chain); err != nil {
+			return err
+		}
+		if err := h.updateRulesPerChain(chain, getAccountingRulesPerNamespace(namespace, clusterCIDRs)); err != nil {
+			return err
+		}
+		jumpRules = append(jumpRules, IPTableRule{"-m", "set", "--match-set", set, "src", "-j", chain})
+	}
+	if err := h.updateRulesPerChain(liqonetAccountingChain, jumpRules); err != nil {
+		return err
+	}
+
+	// Finally, remove the chains and the sets of the namespaces no longer hosting any pod, which are no longer referenced.
+	existingChains, err := h.ipt.ListChains(filterTable)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve chains in table -> %s : %w", filterTable, err)
+	}
+	for _, chain := range getSliceContainingString(existingChains, liqonetAccountingNamespaceChainPrefix) {
+		if slice.ContainsString(namespaceChains, chain) {
+			continue
+		}
+		if err := h.ipt.ClearAndDeleteChain(filterTable, chain); err != nil {
+			return fmt.Errorf("unable to delete chain %s (table %s): %w", chain, filterTable, err)
+		}
+		klog.Infof("Deleted chain %s (table %s)", chain, filterTable)
+	}
+	return destroyAccountingSets(namespaceSets)
+}
+
+// ensureIPSet makes sure that the given ipset exists, and that it contains exactly the given IPv4 addresses.
+func ensureIPSet(name string, ips []string) error {
+	if err := netlink.IpsetCreate(name, "hash:ip", netlink.IpsetCreateOptions{}); err != nil && !errors.Is(err, unix.EEXIST) {
+		return fmt.Errorf("unable to create ipset %s: %w", name, err)
+	}
+
+	current, err := netlink.IpsetList(name)
+	if err != nil {
+		return fmt.Errorf("unable to list ipset %s: %w", name, err)
+	}
+
+	existing := make([]string, 0, len(current.Entries))
+	for i := range current.Entries {
+		ip := current.Entries[i].IP.String()
+		existing = append(existing, ip)
+		if !slice.ContainsString(ips, ip) {
+			if err := netlink.IpsetDel(name, &netlink.IPSetEntry{IP: current.Entries[i].IP.To4()}); err != nil {
+				return fmt.Errorf("unable to remove %s from ipset %s: %w", ip, name, err)
+			}
+		}
+	}
+
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip).To4()
+		if parsed == nil || slice.ContainsString(existing, ip) {
+			continue
+		}
+		if err := netlink.IpsetAdd(name, &netlink.IPSetEntry{IP: parsed, Replace: true}); err != nil {
+			return fmt.Errorf("unable to add %s to ipset %s: %w", ip, name, err)
+		}
+	}
+	return nil
+}
+
+// destroyAccountingSets destroys the accounting ipsets not included in the given list, which shall be no longer referenced.
+func destroyAccountingSets(preserved []string) error {
+	sets, err := netlink.IpsetListAll()
+	if err != nil {
+		return fmt.Errorf("unable to list the ipsets: %w", err)
+	}
+	for i := range sets {
+		if !strings.HasPrefix(sets[i].SetName, liqonetAccountingNamespaceSetPrefix) || slice.ContainsString(preserved, sets[i].SetName) {
+			continue
+		}
+		if err := netlink.IpsetDestroy(sets[i].SetName); err != nil {
+			return fmt.Errorf("unable to destroy ipset %s: %w", sets[i].SetName, err)
+		}
+		klog.Infof("Destroyed ipset %s", sets[i].SetName)
+	}
+	return nil
+}
+
+// GetAccountingCounters returns the traffic accounted for each namespace towards each remote cluster.
+// The clusterCIDRs map associates each remote cluster ID to the CIDRs it is reachable through from the home cluster.
+func (h IPTHandler) GetAccountingCounters(clusterCIDRs map[string][]string) (map[TrafficKey]TrafficCounter, error) {
+	clusters := make(map[string]string)
+	for clusterID, cidrs := range clusterCIDRs {
+		for _, cidr := range cidrs {
+			clusters[cidr] = clusterID
+		}
+	}
+
+	existingChains, err := h.ipt.ListChains(filterTable)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve chains in table -> %s : %w", filterTable, err)
+	}
+
+	counters := make(map[TrafficKey]TrafficCounter)
+	for _, chain := range getSliceContainingString(existingChains, liqonetAccountingNamespaceChainPrefix) {
+		stats, err := h.ipt.StructuredStats(filterTable, chain)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the counters of chain %s (table %s): %w", chain, filterTable, err)
+		}
+		for i := range stats {
+			namespace := getAccountingNamespaceFromOptions(stats[i].Options)
+			clusterID, found := clusters[stats[i].Destination.String()]
+			if namespace == "" || !found {
+				continue
+			}
+			key := TrafficKey{Namespace: namespace, ClusterID: clusterID}
+			counter := counters[key]
+			counter.Bytes += stats[i].Bytes
+			counter.Packets += stats[i].Packets
+			counters[key] = counter
+		}
+	}
+	return counters, nil
+}
+
+// getAccountingRulesPerNamespace returns the rules accounting the traffic of a given namespace towards the remote clusters.
+// The namespace is stored as comment, to be retrieved when reading the counters.
+func getAccountingRulesPerNamespace(namespace string, clusterCIDRs map[string][]string) []IPTableRule {
+	rules := make([]IPTableRule, 0)
+	for _, cidrs := range clusterCIDRs {
+		for _, cidr := range cidrs {
+			rules = append(rules, IPTableRule{"-d", cidr, "-m", "comment", "--comment", namespace, "-j", RETURN})
+		}
+	}
+	return rules
+}
+
+// getAccountingNamespaceFromOptions extracts the namespace from the options of an accounting rule (e.g. "/* default */").
+func getAccountingNamespaceFromOptions(options string) string {
+	start := strings.Index(options, "/* ")
+	end := strings.Index(options, " */")
+	if start < 0 || end < start {
+		return ""
+	}
+	return options[start+len("/* ") : end]
+}
+
+// getAccountingNamespaceChain returns the name of the accounting chain of a given namespace.
+// The name of the namespace is hashed, since chain names are limited to 28 characters.
+func getAccountingNamespaceChain(namespace string) string {
+	return liqonetAccountingNamespaceChainPrefix + hashNamespace(namespace)
+}
+
+// getAccountingNamespaceSet returns the name of the ipset containing the addresses of the pods of a given namespace.
+func getAccountingNamespaceSet(namespace string) string {
+	return liqonetAccountingNamespaceSetPrefix + hashNamespace(namespace)
+}
+
+func hashNamespace(namespace string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(namespace))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
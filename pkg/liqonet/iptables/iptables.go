@@ -47,6 +47,12 @@ const (
 	liqonetInputClusterChainPrefix = "LIQO-INPT-CLS-"
 	// liqonetPreRoutingMappingClusterChainPrefix prefix used to name the prerouting mapping chain for a specific cluster.
 	liqonetPreRoutingMappingClusterChainPrefix = "LIQO-PRRT-MAP-CLS-"
+	// liqonetAccountingChain is the name of the chain accounting the traffic towards the remote clusters.
+	liqonetAccountingChain = "LIQO-ACCOUNTING"
+	// liqonetAccountingNamespaceChainPrefix prefix used to name the accounting chains for a specific namespace.
+	liqonetAccountingNamespaceChainPrefix = "LIQO-ACCT-NS-"
+	// liqonetAccountingNamespaceSetPrefix prefix used to name the ipsets containing the addresses of the pods of a specific namespace.
+	liqonetAccountingNamespaceSetPrefix = "LIQO-ACCT-SET-"
 	// natTable constant used for the "nat" table.
 	natTable = "nat"
 	// filterTable constant used for the "filter" table.
@@ -69,6 +75,8 @@ const (
 	NETMAP = "NETMAP"
 	// ACCEPT action constant.
 	ACCEPT = "ACCEPT"
+	// RETURN action constant.
+	RETURN = "RETURN"
 )
 
 // IPTableRule is a slice of string. This is the format used by module go-iptables.
@@ -77,6 +85,9 @@ type IPTableRule []string
 // IPTHandler a handler that exposes all the functions needed to configure the iptables chains and rules.
 type IPTHandler struct {
 	ipt iptables.IPTables
+	// StatelessNATOffloaded is true if the stateless NAT translations (i.e., the remapping of the networks and the
+	// per-endpoint mappings) are performed by the eBPF datapath, hence only the stateful ones are configured.
+	StatelessNATOffloaded bool
 }
 
 // NewIPTHandler return the iptables handler used to configure the iptables rules.
@@ -206,6 +217,11 @@ func (h IPTHandler) deleteLiqoChainsFromTable(liqoChains map[string]string, tabl
 		chainsToBeRemoved = append(chainsToBeRemoved,
 			getSliceContainingString(existingChains, liqonetForwardingClusterChainPrefix)...,
 		)
+		// Get accounting chains that may have been created in table
+		chainsToBeRemoved = append(chainsToBeRemoved, liqonetAccountingChain)
+		chainsToBeRemoved = append(chainsToBeRemoved,
+			getSliceContainingString(existingChains, liqonetAccountingNamespaceChainPrefix)...,
+		)
 	}
 	// Delete chains in table
 	if err := h.deleteChainsInTable(table, existingChains, chainsToBeRemoved); err != nil {
@@ -228,6 +244,12 @@ func (h IPTHandler) deleteLiqoChains() error {
 	if err := h.deleteLiqoChainsFromTable(liqoChains, filterTable); err != nil {
 		return fmt.Errorf("unable to delete LIQO Chains in table %s: %w", filterTable, err)
 	}
+
+	// Destroy the accounting ipsets, no longer referenced. Errors are not fatal, as the ipset subsystem
+	// may not be available in case the traffic accounting has never been enabled.
+	if err := destroyAccountingSets(nil); err != nil {
+		klog.Warningf("Failed to destroy the accounting ipsets: %v", err)
+	}
 	return nil
 }
 
@@ -335,7 +357,8 @@ func (h IPTHandler) EnsureChainsPerCluster(clusterID string) error {
 func getTableFromChain(chain string) string {
 	// First manage the case the chain is a cluster chain
 	if strings.Contains(chain, liqonetForwardingClusterChainPrefix) ||
-		strings.Contains(chain, liqonetInputClusterChainPrefix) {
+		strings.Contains(chain, liqonetInputClusterChainPrefix) ||
+		strings.Contains(chain, liqonetAccountingNamespaceChainPrefix) {
 		return filterTable
 	}
 	if strings.Contains(chain, liqonetPostroutingClusterChainPrefix) ||
@@ -345,7 +368,7 @@ func getTableFromChain(chain string) string {
 	}
 	// Chain is a default iptables chain or a Liqo chain
 	switch chain {
-	case forwardChain, inputChain, liqonetForwardingChain, liqonetInputChain:
+	case forwardChain, inputChain, liqonetForwardingChain, liqonetInputChain, liqonetAccountingChain:
 		return filterTable
 	case preroutingChain, postroutingChain, liqonetPreroutingChain, liqonetPostroutingChain:
 		return natTable
@@ -455,7 +478,11 @@ func (h IPTHandler) removeChainsPerCluster(clusterID string) error {
 
 // EnsurePostroutingRules makes sure that the postrouting rules for a given cluster are in place and updated.
 func (h IPTHandler) EnsurePostroutingRules(tep *netv1alpha1.TunnelEndpoint) error {
-	rules, err := getPostroutingRules(tep)
+	getRules := getPostroutingRules
+	if h.StatelessNATOffloaded {
+		getRules = getOffloadedPostroutingRules
+	}
+	rules, err := getRules(tep)
 	if err != nil {
 		return err
 	}
@@ -465,7 +492,11 @@ func (h IPTHandler) EnsurePostroutingRules(tep *netv1alpha1.TunnelEndpoint) erro
 // EnsurePreroutingRulesPerTunnelEndpoint makes sure that the prerouting rules extracted from a
 // TunnelEndpoint resource are place and updated.
 func (h IPTHandler) EnsurePreroutingRulesPerTunnelEndpoint(tep *netv1alpha1.TunnelEndpoint) error {
-	rules, err := getPreRoutingRulesPerTunnelEndpoint(tep)
+	getRules := getPreRoutingRulesPerTunnelEndpoint
+	if h.StatelessNATOffloaded {
+		getRules = getOffloadedPreRoutingRulesPerTunnelEndpoint
+	}
+	rules, err := getRules(tep)
 	if err != nil {
 		return err
 	}
@@ -480,6 +511,10 @@ func (h IPTHandler) EnsurePreroutingRulesPerNatMapping(nm *netv1alpha1.NatMappin
 	if err != nil {
 		return err
 	}
+	// The per-endpoint mappings are translated by the eBPF datapath, hence the possibly existing rules are removed.
+	if h.StatelessNATOffloaded {
+		rules = nil
+	}
 	return h.updateRulesPerChain(getClusterPreRoutingMappingChain(clusterID), rules)
 }
 
@@ -492,18 +527,59 @@ func getPreRoutingRulesPerTunnelEndpoint(tep *netv1alpha1.TunnelEndpoint) ([]IPT
 	localRemappedPodCIDR, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
 
 	rules := make([]IPTableRule, 0)
-	if localRemappedPodCIDR == consts.DefaultCIDRValue {
-		// Remote cluster has not remapped home PodCIDR,
-		// this means there is no need to NAT
-		return rules, nil
+	if localRemappedPodCIDR != consts.DefaultCIDRValue {
+		// Remote cluster has remapped home PodCIDR
+		rules = append(rules,
+			IPTableRule{"-s", remotePodCIDR, "-d", localRemappedPodCIDR, "-j", NETMAP, "--to", localPodCIDR},
+		)
+	}
+	// Remote cluster has remapped some of the networks exported by the home cluster
+	for _, exported := range getRemappedLocalExportedCIDRs(tep) {
+		rules = append(rules,
+			IPTableRule{"-s", remotePodCIDR, "-d", exported.NATCIDR, "-j", NETMAP, "--to", exported.CIDR},
+		)
+	}
+	return rules, nil
+}
+
+// getOffloadedPreRoutingRulesPerTunnelEndpoint returns the prerouting rules in case the stateless NAT translations are
+// performed by the eBPF datapath. The network address of the remapped pod CIDR is never translated statelessly,
+// as it is the one used to masquerade the traffic not originating from local pods: the new connections towards that
+// address are hence redirected to the network address of the local pod CIDR, as performed by the NETMAP rule in the non-offloaded case.
+func getOffloadedPreRoutingRulesPerTunnelEndpoint(tep *netv1alpha1.TunnelEndpoint) ([]IPTableRule, error) {
+	if err := liqonetutils.CheckTep(tep); err != nil {
+		return nil, fmt.Errorf("invalid TunnelEndpoint resource: %w", err)
+	}
+	localRemappedPodCIDR, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+
+	rules := make([]IPTableRule, 0)
+	if localRemappedPodCIDR != consts.DefaultCIDRValue {
+		natIP, err := liqonetutils.GetFirstIP(localRemappedPodCIDR)
+		if err != nil {
+			return nil, err
+		}
+		localNetworkIP, err := liqonetutils.GetFirstIP(tep.Spec.LocalPodCIDR)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules,
+			IPTableRule{"-s", remotePodCIDR, "-d", natIP, "-j", DNAT, "--to-destination", localNetworkIP},
+		)
 	}
-	// Remote cluster has remapped home PodCIDR
-	rules = append(rules,
-		IPTableRule{"-s", remotePodCIDR, "-d", localRemappedPodCIDR, "-j", NETMAP, "--to", localPodCIDR},
-	)
 	return rules, nil
 }
 
+// getRemappedLocalExportedCIDRs returns the networks exported by the home cluster which have been remapped by the remote cluster.
+func getRemappedLocalExportedCIDRs(tep *netv1alpha1.TunnelEndpoint) []netv1alpha1.ExportedCIDR {
+	var remapped []netv1alpha1.ExportedCIDR
+	for _, exported := range tep.Spec.LocalExportedCIDRs {
+		if exported.NATCIDR != "" && exported.NATCIDR != consts.DefaultCIDRValue {
+			remapped = append(remapped, exported)
+		}
+	}
+	return remapped
+}
+
 func getPreRoutingRulesPerNatMapping(nm *netv1alpha1.NatMapping) ([]IPTableRule, error) {
 	// Check tep fields
 	if nm.Spec.ClusterID == "" {
@@ -534,6 +610,29 @@ func getSliceContainingString(stringSlice []string, s string) (stringsThatContai
 	return
 }
 
+// ListRulesPerCluster returns the rules of the chains related to the given remote cluster, prefixed by the chain name.
+// The chains not yet created are skipped.
+func (h IPTHandler) ListRulesPerCluster(clusterID string) ([]string, error) {
+	var rules []string
+	for _, chain := range getChainsPerCluster(clusterID) {
+		exists, err := h.ipt.ChainExists(getTableFromChain(chain), chain)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		chainRules, err := h.ListRulesInChain(chain)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range chainRules {
+			rules = append(rules, fmt.Sprintf("%s: %s", chain, rule))
+		}
+	}
+	return rules, nil
+}
+
 // ListRulesInChain is used to adjust the result returned by List of go-iptables.
 func (h IPTHandler) ListRulesInChain(chain string) ([]string, error) {
 	existingRules, err := h.ipt.List(getTableFromChain(chain), chain)
@@ -681,6 +780,7 @@ func getPostroutingRules(tep *netv1alpha1.TunnelEndpoint) ([]IPTableRule, error)
 	localPodCIDR := tep.Spec.LocalPodCIDR
 	localRemappedPodCIDR, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
 	_, remoteExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
 	if localRemappedPodCIDR != consts.DefaultCIDRValue {
 		// Get the first IP address from the podCIDR of the local cluster
 		// in this case it is the podCIDR to which the local podCIDR has bee remapped by the remote peering cluster
@@ -690,12 +790,18 @@ func getPostroutingRules(tep *netv1alpha1.TunnelEndpoint) ([]IPTableRule, error)
 				localRemappedPodCIDR, tep.Spec.ClusterIdentity)
 			return nil, err
 		}
-		return []IPTableRule{
+		rules := []IPTableRule{
 			{"-s", localPodCIDR, "-d", remotePodCIDR, "-j", NETMAP, "--to", localRemappedPodCIDR},
 			{"-s", localPodCIDR, "-d", remoteExternalCIDR, "-j", NETMAP, "--to", localRemappedPodCIDR},
 			{"!", "-s", localPodCIDR, "-d", remotePodCIDR, "-j", SNAT, "--to-source", natIP},
 			{"!", "-s", localPodCIDR, "-d", remoteExternalCIDR, "-j", SNAT, "--to-source", natIP},
-		}, nil
+		}
+		for _, remoteExportedCIDR := range remoteExportedCIDRs {
+			rules = append(rules,
+				IPTableRule{"-s", localPodCIDR, "-d", remoteExportedCIDR, "-j", NETMAP, "--to", localRemappedPodCIDR},
+				IPTableRule{"!", "-s", localPodCIDR, "-d", remoteExportedCIDR, "-j", SNAT, "--to-source", natIP})
+		}
+		return rules, nil
 	}
 	// Get the first IP address from the podCIDR of the local cluster
 	natIP, err := liqonetutils.GetFirstIP(localPodCIDR)
@@ -704,10 +810,49 @@ func getPostroutingRules(tep *netv1alpha1.TunnelEndpoint) ([]IPTableRule, error)
 			tep.Spec.RemotePodCIDR, tep.Spec.ClusterIdentity)
 		return nil, err
 	}
-	return []IPTableRule{
+	rules := []IPTableRule{
 		{"!", "-s", localPodCIDR, "-d", remotePodCIDR, "-j", SNAT, "--to-source", natIP},
 		{"!", "-s", localPodCIDR, "-d", remoteExternalCIDR, "-j", SNAT, "--to-source", natIP},
-	}, nil
+	}
+	for _, remoteExportedCIDR := range remoteExportedCIDRs {
+		rules = append(rules, IPTableRule{"!", "-s", localPodCIDR, "-d", remoteExportedCIDR, "-j", SNAT, "--to-source", natIP})
+	}
+	return rules, nil
+}
+
+// getOffloadedPostroutingRules returns the postrouting rules in case the stateless NAT translations are performed by the
+// eBPF datapath. Hence, only the traffic not originating from local pods is masqueraded, including the one originating
+// from the network address of the local pod CIDR, which is not translated statelessly.
+func getOffloadedPostroutingRules(tep *netv1alpha1.TunnelEndpoint) ([]IPTableRule, error) {
+	if err := liqonetutils.CheckTep(tep); err != nil {
+		return nil, fmt.Errorf("invalid TunnelEndpoint resource: %w", err)
+	}
+	localPodCIDR := tep.Spec.LocalPodCIDR
+	localRemappedPodCIDR, remotePodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, remoteExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+
+	natCIDR := localPodCIDR
+	if localRemappedPodCIDR != consts.DefaultCIDRValue {
+		natCIDR = localRemappedPodCIDR
+	}
+	natIP, err := liqonetutils.GetFirstIP(natCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []IPTableRule
+	if localRemappedPodCIDR != consts.DefaultCIDRValue {
+		localNetworkIP, err := liqonetutils.GetFirstIP(localPodCIDR)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, IPTableRule{"-s", localNetworkIP, "-d", remotePodCIDR, "-j", SNAT, "--to-source", natIP})
+	}
+	for _, remoteCIDR := range append([]string{remotePodCIDR, remoteExternalCIDR}, remoteExportedCIDRs...) {
+		rules = append(rules, IPTableRule{"!", "-s", localPodCIDR, "-d", remoteCIDR, "-j", SNAT, "--to-source", natIP})
+	}
+	return rules, nil
 }
 
 // Function that returns the set of rules used in Liqo chains (e.g. LIQO-PREROUTING)
@@ -746,6 +891,18 @@ func getChainRulesPerCluster(tep *netv1alpha1.TunnelEndpoint) (map[string][]IPTa
 		chainRules[liqonetPreroutingChain] = append(chainRules[liqonetPreroutingChain],
 			IPTableRule{"-s", remotePodCIDR, "-d", localRemappedPodCIDR, "-j", getClusterPreRoutingChain(clusterID)})
 	}
+
+	// Additional networks exported by the remote cluster are handled as the remote PodCIDR,
+	// while the ones exported by the home cluster and remapped by the remote one are handled as the home PodCIDR.
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+	for _, remoteExportedCIDR := range remoteExportedCIDRs {
+		chainRules[liqonetPostroutingChain] = append(chainRules[liqonetPostroutingChain],
+			IPTableRule{"-d", remoteExportedCIDR, "-j", getClusterPostRoutingChain(clusterID)})
+	}
+	for _, exported := range getRemappedLocalExportedCIDRs(tep) {
+		chainRules[liqonetPreroutingChain] = append(chainRules[liqonetPreroutingChain],
+			IPTableRule{"-s", remotePodCIDR, "-d", exported.NATCIDR, "-j", getClusterPreRoutingChain(clusterID)})
+	}
 	return chainRules, nil
 }
 
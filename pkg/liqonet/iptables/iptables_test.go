@@ -22,6 +22,7 @@ import (
 	. "github.com/coreos/go-iptables/iptables"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
 	"k8s.io/klog/v2"
 
 	discv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
@@ -827,6 +828,30 @@ var _ = Describe("iptables", func() {
 							SNAT, mustGetFirstIP(tep.Spec.LocalPodCIDR))}
 				},
 			),
+			Entry(
+				fmt.Sprintf("RemoteExportedCIDRs remapped, LocalNATPodCIDR != %s", consts.DefaultCIDRValue),
+				func() {
+					tep.Spec.RemoteExportedCIDRs = []netv1alpha1.ExportedCIDR{{CIDR: "10.152.0.0/24", NATCIDR: "10.252.0.0/24"}}
+				},
+				func() []string {
+					return []string{
+						fmt.Sprintf("-s %s -d %s -j %s --to %s", tep.Spec.LocalPodCIDR, "10.252.0.0/24", NETMAP, tep.Spec.LocalNATPodCIDR),
+						fmt.Sprintf("! -s %s -d %s -j %s --to-source %s", tep.Spec.LocalPodCIDR, "10.252.0.0/24",
+							SNAT, mustGetFirstIP(tep.Spec.LocalNATPodCIDR)),
+					}
+				},
+			),
+			Entry(
+				fmt.Sprintf("RemoteExportedCIDRs not remapped, LocalNATPodCIDR = %s", consts.DefaultCIDRValue),
+				func() {
+					tep.Spec.LocalNATPodCIDR = consts.DefaultCIDRValue
+					tep.Spec.RemoteExportedCIDRs = []netv1alpha1.ExportedCIDR{{CIDR: "10.152.0.0/24", NATCIDR: consts.DefaultCIDRValue}}
+				},
+				func() []string {
+					return []string{fmt.Sprintf("! -s %s -d %s -j %s --to-source %s", tep.Spec.LocalPodCIDR, "10.152.0.0/24",
+						SNAT, mustGetFirstIP(tep.Spec.LocalPodCIDR))}
+				},
+			),
 		)
 	})
 	Describe("EnsurePreroutingRulesPerTunnelEndpoint", func() {
@@ -937,6 +962,16 @@ var _ = Describe("iptables", func() {
 				func() { tep.Spec.LocalNATPodCIDR = consts.DefaultCIDRValue },
 				func() []string { return []string{} },
 			),
+			Entry(
+				"LocalExportedCIDRs remapped",
+				func() {
+					tep.Spec.LocalExportedCIDRs = []netv1alpha1.ExportedCIDR{{CIDR: "10.152.0.0/24", NATCIDR: "10.252.0.0/24"}}
+				},
+				func() []string {
+					return []string{fmt.Sprintf("-s %s -d %s -j %s --to %s",
+						tep.Spec.RemoteNATPodCIDR, "10.252.0.0/24", NETMAP, "10.152.0.0/24")}
+				},
+			),
 		)
 	})
 	Describe("EnsurePreroutingRulesPerNatMapping", func() {
@@ -1032,6 +1067,152 @@ var _ = Describe("iptables", func() {
 			})
 		})
 	})
+
+	Describe("EnsureAccountingRules", func() {
+		var (
+			podNamespaces map[string]string
+			clusterCIDRs  map[string][]string
+		)
+
+		BeforeEach(func() {
+			podNamespaces = map[string]string{"192.168.0.10": "foo", "192.168.0.11": "foo", "192.168.0.12": "bar"}
+			clusterCIDRs = map[string][]string{clusterID1: {"10.0.0.0/24", "10.0.1.0/24"}}
+		})
+
+		Context("Call once", func() {
+			It("should insert the jump and accounting rules", func() {
+				Expect(h.EnsureAccountingRules(podNamespaces, clusterCIDRs)).To(Succeed())
+
+				forwardRules, err := h.ListRulesInChain(liqonetForwardingChain)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(forwardRules).To(ContainElement(fmt.Sprintf("-j %s", liqonetAccountingChain)))
+
+				accountingRules, err := h.ListRulesInChain(liqonetAccountingChain)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(accountingRules).To(ConsistOf(
+					fmt.Sprintf("-m set --match-set %s src -j %s", getAccountingNamespaceSet("foo"), getAccountingNamespaceChain("foo")),
+					fmt.Sprintf("-m set --match-set %s src -j %s", getAccountingNamespaceSet("bar"), getAccountingNamespaceChain("bar")),
+				))
+
+				set, err := netlink.IpsetList(getAccountingNamespaceSet("foo"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(set.Entries).To(HaveLen(2))
+
+				namespaceRules, err := h.ListRulesInChain(getAccountingNamespaceChain("foo"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(namespaceRules).To(ConsistOf(
+					fmt.Sprintf("-d 10.0.0.0/24 -m comment --comment foo -j %s", RETURN),
+					fmt.Sprintf("-d 10.0.1.0/24 -m comment --comment foo -j %s", RETURN),
+				))
+			})
+
+			It("should report the counters for each namespace and remote cluster", func() {
+				Expect(h.EnsureAccountingRules(podNamespaces, clusterCIDRs)).To(Succeed())
+
+				counters, err := h.GetAccountingCounters(clusterCIDRs)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(counters).To(HaveKeyWithValue(TrafficKey{Namespace: "foo", ClusterID: clusterID1}, TrafficCounter{}))
+				Expect(counters).To(HaveKeyWithValue(TrafficKey{Namespace: "bar", ClusterID: clusterID1}, TrafficCounter{}))
+			})
+		})
+
+		Context("If a pod is removed from a namespace", func() {
+			It("should remove the corresponding address from the ipset", func() {
+				Expect(h.EnsureAccountingRules(podNamespaces, clusterCIDRs)).To(Succeed())
+				delete(podNamespaces, "192.168.0.11")
+				Expect(h.EnsureAccountingRules(podNamespaces, clusterCIDRs)).To(Succeed())
+
+				set, err := netlink.IpsetList(getAccountingNamespaceSet("foo"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(set.Entries).To(HaveLen(1))
+				Expect(set.Entries[0].IP.String()).To(Equal("192.168.0.10"))
+			})
+		})
+
+		Context("If a namespace no longer hosts any pod", func() {
+			It("should remove the corresponding rules and chain", func() {
+				Expect(h.EnsureAccountingRules(podNamespaces, clusterCIDRs)).To(Succeed())
+				delete(podNamespaces, "192.168.0.12")
+				Expect(h.EnsureAccountingRules(podNamespaces, clusterCIDRs)).To(Succeed())
+
+				accountingRules, err := h.ListRulesInChain(liqonetAccountingChain)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(accountingRules).To(HaveLen(1))
+				_, err = netlink.IpsetList(getAccountingNamespaceSet("bar"))
+				Expect(err).To(HaveOccurred())
+
+				filterChains, err := ipt.ListChains(filterTable)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(filterChains).ToNot(ContainElement(getAccountingNamespaceChain("bar")))
+				Expect(filterChains).To(ContainElement(getAccountingNamespaceChain("foo")))
+			})
+		})
+	})
+
+	Describe("ListRulesPerCluster", func() {
+		BeforeEach(func() {
+			tep = validTep.DeepCopy()
+		})
+		AfterEach(func() {
+			Expect(h.RemoveIPTablesConfigurationPerCluster(tep)).To(Succeed())
+		})
+		Context("If the chains for the remote cluster do not exist", func() {
+			It("should return no rules", func() {
+				Expect(h.ListRulesPerCluster(clusterID1)).To(BeEmpty())
+			})
+		})
+		Context("If the remote cluster has an iptables configuration", func() {
+			It("should return the rules prefixed by the corresponding chain", func() {
+				Expect(h.EnsureChainsPerCluster(clusterID1)).To(Succeed())
+				Expect(h.EnsurePostroutingRules(tep)).To(Succeed())
+				rules, err := h.ListRulesPerCluster(clusterID1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rules).To(ContainElement(HavePrefix(getClusterPostRoutingChain(clusterID1) + ": ")))
+			})
+		})
+	})
+
+	Describe("the offloaded stateless NAT rules", func() {
+		BeforeEach(func() {
+			tep = validTep.DeepCopy()
+		})
+
+		Context("getOffloadedPreRoutingRulesPerTunnelEndpoint", func() {
+			It("should redirect the traffic towards the NAT IP to the network address of the local pod CIDR", func() {
+				rules, err := getOffloadedPreRoutingRulesPerTunnelEndpoint(tep)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rules).To(ConsistOf(
+					IPTableRule{"-s", "10.60.0.0/24", "-d", "192.168.1.0", "-j", DNAT, "--to-destination", "192.168.0.0"},
+				))
+			})
+
+			It("should not configure any rule if the local pod CIDR is not remapped", func() {
+				tep.Spec.LocalNATPodCIDR = consts.DefaultCIDRValue
+				Expect(getOffloadedPreRoutingRulesPerTunnelEndpoint(tep)).To(BeEmpty())
+			})
+		})
+
+		Context("getOffloadedPostroutingRules", func() {
+			It("should masquerade only the traffic not originating from local pods", func() {
+				rules, err := getOffloadedPostroutingRules(tep)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rules).To(ConsistOf(
+					IPTableRule{"-s", "192.168.0.0", "-d", "10.60.0.0/24", "-j", SNAT, "--to-source", "192.168.1.0"},
+					IPTableRule{"!", "-s", "192.168.0.0/24", "-d", "10.60.0.0/24", "-j", SNAT, "--to-source", "192.168.1.0"},
+					IPTableRule{"!", "-s", "192.168.0.0/24", "-d", "192.168.5.0/24", "-j", SNAT, "--to-source", "192.168.1.0"},
+				))
+			})
+		})
+	})
+
+	Describe("getAccountingNamespaceFromOptions", func() {
+		It("should extract the namespace from the rule comment", func() {
+			Expect(getAccountingNamespaceFromOptions("/* foo */")).To(Equal("foo"))
+		})
+		It("should return an empty string if no comment is present", func() {
+			Expect(getAccountingNamespaceFromOptions("")).To(BeEmpty())
+		})
+	})
 })
 
 func mustGetFirstIP(network string) string {
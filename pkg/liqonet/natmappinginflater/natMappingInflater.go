@@ -45,6 +45,8 @@ type Interface interface {
 	TerminateNatMappingsPerCluster(clusterID string) error
 	// GetNatMappings returns the set of mappings related to a remote cluster.
 	GetNatMappings(clusterID string) (map[string]string, error)
+	// GetClusterIDs returns the IDs of the remote clusters the NAT mappings have been initialized for.
+	GetClusterIDs() []string
 	// AddMapping adds a NAT mapping.
 	AddMapping(oldIP, newIP, clusterID string) error
 	// RemoveMapping removes a NAT mapping.
@@ -383,6 +385,15 @@ func (inflater *NatMappingInflater) GetNatMappings(clusterID string) (map[string
 	return mappings, nil
 }
 
+// GetClusterIDs returns the IDs of the remote clusters the NAT mappings have been initialized for.
+func (inflater *NatMappingInflater) GetClusterIDs() []string {
+	clusterIDs := make([]string, 0, len(inflater.natMappingsPerCluster))
+	for clusterID := range inflater.natMappingsPerCluster {
+		clusterIDs = append(clusterIDs, clusterID)
+	}
+	return clusterIDs
+}
+
 // Function that keeps a resource and removes remaining ones in case multiple resources exist.
 // Return value is the survived resource.
 func (inflater *NatMappingInflater) deleteMultipleNatMappingResources(resources []unstructured.Unstructured) (unstructured.Unstructured, error) {
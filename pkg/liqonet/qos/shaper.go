@@ -0,0 +1,532 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qos
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+)
+
+const (
+	// qdiscMajor is the major number of the HTB qdiscs shaping the traffic.
+	qdiscMajor = 0x1
+	// rootMinor is the minor number of the root class, bounded to the bandwidth of the gateway uplink.
+	rootMinor = 0x1
+	// defaultMinor is the minor number of the class of the traffic not associated with any shaped remote cluster.
+	defaultMinor = 0x2
+	// firstPeerMinor is the first minor number assigned to the classes of the remote clusters.
+	firstPeerMinor = 0x10
+	// guaranteedRateDivisor is the divisor of the uplink bandwidth to obtain the rate guaranteed to each class,
+	// while the remainder is shared according to the priorities.
+	guaranteedRateDivisor = 100
+
+	// srcAddressOffset and dstAddressOffset are the offsets of the addresses in the IPv4 header.
+	srcAddressOffset = 12
+	dstAddressOffset = 16
+
+	// samplingPeriod is the period the statistics of the classes are sampled, to compute the utilization.
+	samplingPeriod = 10 * time.Second
+)
+
+// Direction is the direction of the traffic exchanged with a remote cluster.
+type Direction string
+
+const (
+	// Egress identifies the traffic sent to the remote cluster.
+	Egress Direction = "egress"
+	// Ingress identifies the traffic received from the remote cluster.
+	Ingress Direction = "ingress"
+)
+
+var (
+	// limitDesc is the metric exposing the bandwidth limit of the traffic exchanged with a given peer.
+	limitDesc = prometheus.NewDesc(
+		"liqo_gateway_qos_limit_bits_per_second",
+		"Maximum rate of the traffic exchanged with a given peer, in bits per second.",
+		[]string{"cluster_id", "direction"},
+		nil,
+	)
+
+	// transmittedBytesDesc is the metric that counts the number of bytes exchanged with a given peer.
+	transmittedBytesDesc = prometheus.NewDesc(
+		"liqo_gateway_qos_transmit_bytes_total",
+		"Number of bytes exchanged with a given peer, through the class shaping the corresponding traffic.",
+		[]string{"cluster_id", "direction"},
+		nil,
+	)
+
+	// droppedPacketsDesc is the metric that counts the number of packets dropped while shaping the traffic with a given peer.
+	droppedPacketsDesc = prometheus.NewDesc(
+		"liqo_gateway_qos_dropped_packets_total",
+		"Number of packets exchanged with a given peer dropped while shaping the corresponding traffic.",
+		[]string{"cluster_id", "direction"},
+		nil,
+	)
+
+	// utilizationDesc is the metric exposing the rate of the traffic exchanged with a given peer, compared to the limit.
+	utilizationDesc = prometheus.NewDesc(
+		"liqo_gateway_qos_utilization_ratio",
+		"Rate of the traffic exchanged with a given peer over the corresponding limit, during the last sampling period.",
+		[]string{"cluster_id", "direction"},
+		nil,
+	)
+)
+
+// Shaper enforces the bandwidth limits and the priority of the traffic exchanged with the remote clusters, through
+// a dedicated HTB class per remote cluster and direction. The traffic is classified according to the networks
+// the remote cluster is reachable through from the local cluster, which are unique across all peers.
+type Shaper struct {
+	netns ns.NetNS
+	// bandwidth is the bandwidth of the gateway uplink, in bits per second.
+	bandwidth uint64
+
+	mutex sync.Mutex
+	// peers key is a clusterID.
+	peers map[string]*peer
+}
+
+// peer tracks the classes configured for a given remote cluster.
+type peer struct {
+	minor   uint16
+	classes map[Direction]*class
+}
+
+// class tracks the configuration and the statistics of the class shaping the traffic in a given direction.
+type class struct {
+	linkIndex int
+	limit     uint64
+	priority  uint32
+	networks  []string
+
+	bytes   uint64
+	drops   uint32
+	rate    float64
+	sampled time.Time
+}
+
+// NewShaper returns a new Shaper, assuming the given bandwidth (in bits per second) for the gateway uplink.
+// The links the traffic is shaped on are expected to belong to the given network namespace.
+func NewShaper(netns ns.NetNS, bandwidth uint64) *Shaper {
+	return &Shaper{netns: netns, bandwidth: bandwidth, peers: make(map[string]*peer)}
+}
+
+// EnsurePeer makes sure that the traffic exchanged with the given remote cluster is shaped according to the given
+// configuration, on the given link for each direction. The traffic is classified according to the given networks,
+// matching the destination address for the egress direction, and the source address for the ingress one.
+// It must be executed in the gateway network namespace.
+func (s *Shaper) EnsurePeer(clusterID string, qos *netv1alpha1.QoS, links map[Direction]netlink.Link, networks []string) error {
+	if qos == nil {
+		return s.RemovePeer(clusterID)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, found := s.peers[clusterID]
+	if !found {
+		p = &peer{minor: s.allocateMinor(), classes: make(map[Direction]*class)}
+		s.peers[clusterID] = p
+	}
+
+	for direction, link := range links {
+		desired := &class{linkIndex: link.Attrs().Index, limit: limit(qos, direction), priority: priority(qos.Priority), networks: networks}
+
+		current := p.classes[direction]
+		if current != nil && current.linkIndex != desired.linkIndex {
+			// The link changed (e.g., because of a different tunnel backend), hence the stale class is removed.
+			if err := s.removeClass(p.minor, current.linkIndex); err != nil {
+				return err
+			}
+			delete(p.classes, direction)
+			current = nil
+		}
+
+		created, err := s.ensureQdisc(link)
+		if err != nil {
+			return fmt.Errorf("failed to configure the qdisc of link %s: %w", link.Attrs().Name, err)
+		}
+		if created {
+			// The qdisc has been (re)created, hence all the classes previously configured on the link need to be restored.
+			if err := s.restoreClasses(desired.linkIndex); err != nil {
+				return fmt.Errorf("failed to restore the classes of link %s: %w", link.Attrs().Name, err)
+			}
+			current = nil
+		}
+
+		if current == nil || current.limit != desired.limit || current.priority != desired.priority {
+			if err := netlink.ClassReplace(s.forgeClass(desired.linkIndex, p.minor, desired.limit, desired.priority)); err != nil {
+				return fmt.Errorf("failed to configure the %s class of cluster %s: %w", direction, clusterID, err)
+			}
+		}
+		if current == nil || !reflect.DeepEqual(current.networks, desired.networks) {
+			if err := ensureFilters(desired.linkIndex, p.minor, direction, networks); err != nil {
+				return fmt.Errorf("failed to configure the %s filters of cluster %s: %w", direction, clusterID, err)
+			}
+		}
+
+		if current != nil {
+			desired.bytes, desired.drops, desired.rate, desired.sampled = current.bytes, current.drops, current.rate, current.sampled
+		}
+		p.classes[direction] = desired
+	}
+
+	klog.V(4).Infof("QoS configuration for cluster %s correctly enforced", clusterID)
+	return nil
+}
+
+// RemovePeer removes the configuration shaping the traffic exchanged with the given remote cluster, if present.
+// It must be executed in the gateway network namespace.
+func (s *Shaper) RemovePeer(clusterID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, found := s.peers[clusterID]
+	if !found {
+		return nil
+	}
+
+	for direction, c := range p.classes {
+		if err := s.removeClass(p.minor, c.linkIndex); err != nil {
+			return fmt.Errorf("failed to remove the %s class of cluster %s: %w", direction, clusterID, err)
+		}
+		delete(p.classes, direction)
+	}
+	delete(s.peers, clusterID)
+
+	klog.V(4).Infof("QoS configuration for cluster %s correctly removed", clusterID)
+	return nil
+}
+
+// Start periodically samples the statistics of the classes, until the given context is canceled.
+// It implements the manager.Runnable interface.
+func (s *Shaper) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.netns.Do(func(netNamespace ns.NetNS) error { return s.sample(time.Now()) }); err != nil {
+			klog.Errorf("Failed to sample the statistics of the QoS classes: %v", err)
+		}
+	}, samplingPeriod)
+	return nil
+}
+
+// sample retrieves the statistics of the classes, and computes the rate of the traffic since the previous sample.
+func (s *Shaper) sample(now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := make(map[int]map[uint32]*netlink.ClassStatistics)
+	for _, p := range s.peers {
+		for _, c := range p.classes {
+			if _, found := stats[c.linkIndex]; found {
+				continue
+			}
+			classes, err := listClasses(c.linkIndex)
+			if err != nil {
+				return err
+			}
+			stats[c.linkIndex] = classes
+		}
+	}
+
+	for _, p := range s.peers {
+		for _, c := range p.classes {
+			stat, found := stats[c.linkIndex][netlink.MakeHandle(qdiscMajor, p.minor)]
+			if !found || stat.Basic == nil || stat.Queue == nil {
+				continue
+			}
+			c.update(stat.Basic.Bytes, stat.Queue.Drops, now)
+		}
+	}
+	return nil
+}
+
+// update records the given statistics, and computes the rate of the traffic since the previous update.
+func (c *class) update(bytes uint64, drops uint32, now time.Time) {
+	if !c.sampled.IsZero() && bytes >= c.bytes && now.After(c.sampled) {
+		c.rate = float64(bytes-c.bytes) * 8 / now.Sub(c.sampled).Seconds()
+	}
+	c.bytes, c.drops, c.sampled = bytes, drops, now
+}
+
+// Describe implements prometheus.Collector.
+func (s *Shaper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- limitDesc
+	ch <- transmittedBytesDesc
+	ch <- droppedPacketsDesc
+	ch <- utilizationDesc
+}
+
+// Collect implements prometheus.Collector.
+func (s *Shaper) Collect(ch chan<- prometheus.Metric) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for clusterID, p := range s.peers {
+		for direction, c := range p.classes {
+			if c.sampled.IsZero() {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(transmittedBytesDesc, prometheus.CounterValue, float64(c.bytes), clusterID, string(direction))
+			ch <- prometheus.MustNewConstMetric(droppedPacketsDesc, prometheus.CounterValue, float64(c.drops), clusterID, string(direction))
+			if c.limit > 0 {
+				ch <- prometheus.MustNewConstMetric(limitDesc, prometheus.GaugeValue, float64(c.limit), clusterID, string(direction))
+				ch <- prometheus.MustNewConstMetric(utilizationDesc, prometheus.GaugeValue, c.rate/float64(c.limit), clusterID, string(direction))
+			}
+		}
+	}
+}
+
+// allocateMinor returns the lowest minor number not assigned to any remote cluster.
+func (s *Shaper) allocateMinor() uint16 {
+	used := make(map[uint16]struct{}, len(s.peers))
+	for _, p := range s.peers {
+		used[p.minor] = struct{}{}
+	}
+	for minor := uint16(firstPeerMinor); ; minor++ {
+		if _, found := used[minor]; !found {
+			return minor
+		}
+	}
+}
+
+// ensureQdisc makes sure that the HTB qdisc, along with the root and the default classes, is configured on the given link.
+// It returns whether the qdisc has been created, in which case the classes of the remote clusters need to be configured.
+func (s *Shaper) ensureQdisc(link netlink.Link) (created bool, err error) {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return false, err
+	}
+	for _, qdisc := range qdiscs {
+		if qdisc.Attrs().Parent == netlink.HANDLE_ROOT && qdisc.Attrs().Handle == netlink.MakeHandle(qdiscMajor, 0) && qdisc.Type() == "htb" {
+			return false, nil
+		}
+	}
+
+	htb := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    netlink.MakeHandle(qdiscMajor, 0),
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	htb.Defcls = defaultMinor
+	if err := netlink.QdiscReplace(htb); err != nil {
+		return false, err
+	}
+
+	root := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    netlink.MakeHandle(qdiscMajor, rootMinor),
+		Parent:    netlink.MakeHandle(qdiscMajor, 0),
+	}, netlink.HtbClassAttrs{Rate: s.bandwidth, Ceil: s.bandwidth})
+	if err := netlink.ClassReplace(root); err != nil {
+		return false, err
+	}
+	if err := netlink.ClassReplace(s.forgeClass(link.Attrs().Index, defaultMinor, 0, priority(netv1alpha1.NormalTrafficPriority))); err != nil {
+		return false, err
+	}
+
+	klog.Infof("HTB qdisc correctly configured on link %s", link.Attrs().Name)
+	return true, nil
+}
+
+// restoreClasses configures again the classes, along with the corresponding filters, known to be configured on the given link.
+func (s *Shaper) restoreClasses(linkIndex int) error {
+	for _, p := range s.peers {
+		for direction, c := range p.classes {
+			if c.linkIndex != linkIndex {
+				continue
+			}
+			if err := netlink.ClassReplace(s.forgeClass(linkIndex, p.minor, c.limit, c.priority)); err != nil {
+				return err
+			}
+			if err := ensureFilters(linkIndex, p.minor, direction, c.networks); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeClass removes the class with the given minor number, along with the corresponding filters, from the given link.
+// The qdisc is removed as well, in case no other remote cluster is shaped on the same link.
+func (s *Shaper) removeClass(minor uint16, linkIndex int) error {
+	if err := netlink.FilterDel(forgeFilter(linkIndex, minor)); ignoreMissing(err) != nil {
+		return err
+	}
+
+	class := &netlink.HtbClass{ClassAttrs: netlink.ClassAttrs{
+		LinkIndex: linkIndex,
+		Handle:    netlink.MakeHandle(qdiscMajor, minor),
+		Parent:    netlink.MakeHandle(qdiscMajor, rootMinor),
+	}}
+	if err := netlink.ClassDel(class); ignoreMissing(err) != nil {
+		return err
+	}
+
+	for _, p := range s.peers {
+		for _, c := range p.classes {
+			if c.linkIndex == linkIndex && p.minor != minor {
+				return nil
+			}
+		}
+	}
+
+	qdisc := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: linkIndex,
+		Handle:    netlink.MakeHandle(qdiscMajor, 0),
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	return ignoreMissing(netlink.QdiscDel(qdisc))
+}
+
+// forgeClass returns the HTB class with the given minor number, limit (0 if unlimited) and priority. Each class is
+// guaranteed a fraction of the uplink bandwidth, and can borrow the unused one up to its limit, according to its priority.
+func (s *Shaper) forgeClass(linkIndex int, minor uint16, limit uint64, prio uint32) *netlink.HtbClass {
+	ceil := s.bandwidth
+	if limit > 0 && limit < ceil {
+		ceil = limit
+	}
+	rate := s.bandwidth / guaranteedRateDivisor
+	if rate > ceil {
+		rate = ceil
+	}
+
+	return netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: linkIndex,
+		Handle:    netlink.MakeHandle(qdiscMajor, minor),
+		Parent:    netlink.MakeHandle(qdiscMajor, rootMinor),
+	}, netlink.HtbClassAttrs{Rate: rate, Ceil: ceil, Prio: prio})
+}
+
+// ensureFilters replaces the filters classifying the traffic of the given remote cluster, matching the given networks.
+// All the filters of a remote cluster share the same priority, equal to the minor number of the corresponding class.
+func ensureFilters(linkIndex int, minor uint16, direction Direction, networks []string) error {
+	if err := netlink.FilterDel(forgeFilter(linkIndex, minor)); ignoreMissing(err) != nil {
+		return err
+	}
+
+	offset := int32(dstAddressOffset)
+	if direction == Ingress {
+		offset = srcAddressOffset
+	}
+
+	for _, network := range networks {
+		key, err := forgeKey(network, offset)
+		if err != nil {
+			return err
+		}
+
+		filter := forgeFilter(linkIndex, minor)
+		filter.ClassId = netlink.MakeHandle(qdiscMajor, minor)
+		filter.Sel = &netlink.TcU32Sel{Flags: netlink.TC_U32_TERMINAL, Keys: []netlink.TcU32Key{key}}
+		if err := netlink.FilterAdd(filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgeFilter returns the u32 filter with the priority associated with the given minor number.
+func forgeFilter(linkIndex int, minor uint16) *netlink.U32 {
+	return &netlink.U32{FilterAttrs: netlink.FilterAttrs{
+		LinkIndex: linkIndex,
+		Parent:    netlink.MakeHandle(qdiscMajor, 0),
+		Priority:  minor,
+		Protocol:  unix.ETH_P_IP,
+	}}
+}
+
+// forgeKey returns the u32 key matching the addresses belonging to the given network, at the given offset of the IPv4 header.
+func forgeKey(network string, offset int32) (netlink.TcU32Key, error) {
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return netlink.TcU32Key{}, err
+	}
+	if ipnet.IP.To4() == nil {
+		return netlink.TcU32Key{}, fmt.Errorf("network %s is not an IPv4 network", network)
+	}
+	return netlink.TcU32Key{
+		Mask: binary.BigEndian.Uint32(ipnet.Mask),
+		Val:  binary.BigEndian.Uint32(ipnet.IP.To4()),
+		Off:  offset,
+	}, nil
+}
+
+// listClasses returns the statistics of the classes configured on the given link, keyed by their handle.
+func listClasses(linkIndex int) (map[uint32]*netlink.ClassStatistics, error) {
+	link, err := netlink.LinkByIndex(linkIndex)
+	if err != nil {
+		// The link may have been removed (e.g., along with the tunnel), hence no statistics are available.
+		return nil, ignoreMissing(err)
+	}
+	classes, err := netlink.ClassList(link, netlink.MakeHandle(qdiscMajor, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[uint32]*netlink.ClassStatistics, len(classes))
+	for _, class := range classes {
+		if class.Attrs().Statistics != nil {
+			stats[class.Attrs().Handle] = class.Attrs().Statistics
+		}
+	}
+	return stats, nil
+}
+
+// limit returns the bandwidth limit in the given direction, in bits per second (0 if unlimited).
+func limit(qos *netv1alpha1.QoS, direction Direction) uint64 {
+	bandwidth := qos.EgressBandwidth
+	if direction == Ingress {
+		bandwidth = qos.IngressBandwidth
+	}
+	if bandwidth == nil || bandwidth.Sign() <= 0 {
+		return 0
+	}
+	return uint64(bandwidth.Value())
+}
+
+// priority returns the HTB priority corresponding to the given traffic priority (lower values are served first).
+func priority(prio netv1alpha1.TrafficPriority) uint32 {
+	switch prio {
+	case netv1alpha1.HighTrafficPriority:
+		return 0
+	case netv1alpha1.LowTrafficPriority:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ignoreMissing returns nil in case the given error reports that the target object (or the corresponding link) does not exist.
+func ignoreMissing(err error) error {
+	var notFound netlink.LinkNotFoundError
+	if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENODEV) || errors.As(err, &notFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qos
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+)
+
+var _ = Describe("QoS shaper", func() {
+	var shaper *Shaper
+
+	BeforeEach(func() {
+		shaper = NewShaper(nil, 1000000000)
+	})
+
+	Describe("the forgeClass function", func() {
+		It("should bound the classes without limits to the uplink bandwidth", func() {
+			class := shaper.forgeClass(3, firstPeerMinor, 0, 1)
+			Expect(class.Attrs().Handle).To(Equal(netlink.MakeHandle(qdiscMajor, firstPeerMinor)))
+			Expect(class.Attrs().Parent).To(Equal(netlink.MakeHandle(qdiscMajor, rootMinor)))
+			Expect(class.Rate).To(BeNumerically("==", 1000000000/guaranteedRateDivisor/8))
+			Expect(class.Ceil).To(BeNumerically("==", 1000000000/8))
+			Expect(class.Prio).To(BeNumerically("==", 1))
+		})
+
+		It("should bound the classes with limits to the corresponding limit", func() {
+			class := shaper.forgeClass(3, firstPeerMinor, 50000000, 0)
+			Expect(class.Rate).To(BeNumerically("==", 1000000000/guaranteedRateDivisor/8))
+			Expect(class.Ceil).To(BeNumerically("==", 50000000/8))
+		})
+
+		It("should not guarantee a rate higher than the limit", func() {
+			class := shaper.forgeClass(3, firstPeerMinor, 1000000, 0)
+			Expect(class.Rate).To(BeNumerically("==", 1000000/8))
+			Expect(class.Ceil).To(BeNumerically("==", 1000000/8))
+		})
+	})
+
+	Describe("the forgeKey function", func() {
+		It("should match the destination addresses of the given network", func() {
+			key, err := forgeKey("10.60.0.0/16", dstAddressOffset)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(Equal(netlink.TcU32Key{Mask: 0xffff0000, Val: 0x0a3c0000, Off: dstAddressOffset}))
+		})
+
+		It("should fail in case of an invalid network", func() {
+			_, err := forgeKey("foo", srcAddressOffset)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail in case of an IPv6 network", func() {
+			_, err := forgeKey("fd00::/64", srcAddressOffset)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("the limit and priority functions", func() {
+		qos := &netv1alpha1.QoS{EgressBandwidth: resource.NewQuantity(100000000, resource.DecimalSI)}
+
+		It("should return the limit in the given direction", func() {
+			Expect(limit(qos, Egress)).To(BeNumerically("==", 100000000))
+			Expect(limit(qos, Ingress)).To(BeZero())
+		})
+
+		DescribeTable("should serve the high priority traffic first",
+			func(prio netv1alpha1.TrafficPriority, expected uint32) { Expect(priority(prio)).To(Equal(expected)) },
+			Entry("high priority", netv1alpha1.HighTrafficPriority, uint32(0)),
+			Entry("normal priority", netv1alpha1.NormalTrafficPriority, uint32(1)),
+			Entry("low priority", netv1alpha1.LowTrafficPriority, uint32(2)),
+			Entry("unset priority", netv1alpha1.TrafficPriority(""), uint32(1)),
+		)
+	})
+
+	Describe("the allocateMinor function", func() {
+		It("should return the lowest minor number not assigned", func() {
+			shaper.peers["foo"] = &peer{minor: firstPeerMinor}
+			shaper.peers["bar"] = &peer{minor: firstPeerMinor + 2}
+			Expect(shaper.allocateMinor()).To(BeNumerically("==", firstPeerMinor+1))
+		})
+	})
+
+	Describe("the metrics", func() {
+		var now time.Time
+
+		BeforeEach(func() {
+			now = time.Now()
+			c := &class{limit: 1000000}
+			c.update(1000, 0, now)
+			c.update(1000+62500, 3, now.Add(time.Second))
+			shaper.peers["foo"] = &peer{minor: firstPeerMinor, classes: map[Direction]*class{Egress: c, Ingress: {}}}
+		})
+
+		It("should compute the rate since the previous sample", func() {
+			Expect(shaper.peers["foo"].classes[Egress].rate).To(BeNumerically("==", 500000))
+		})
+
+		It("should expose the utilization against the limit", func() {
+			Expect(testutil.CollectAndCount(shaper)).To(Equal(4))
+			Expect(testutil.CollectAndCount(shaper, "liqo_gateway_qos_utilization_ratio")).To(Equal(1))
+
+			ch := make(chan prometheus.Metric, 10)
+			shaper.Collect(ch)
+			close(ch)
+			for metric := range ch {
+				if metric.Desc() == utilizationDesc {
+					var out dto.Metric
+					Expect(metric.Write(&out)).To(Succeed())
+					Expect(out.GetGauge().GetValue()).To(BeNumerically("==", 0.5))
+				}
+			}
+		})
+	})
+})
@@ -16,6 +16,7 @@ package routing
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"os"
 	"reflect"
@@ -86,6 +87,32 @@ func AddRoute(dstNet, gwIP string, iFaceIndex, tableID, flags int, scope netlink
 	return true, nil
 }
 
+// SetRouteMTU sets the MTU of the route towards the given destination, on the given interface and routing table.
+// A zero MTU restores the default behavior (i.e., the MTU of the interface is used).
+// Returns true if the route has been updated, false if the MTU is already correct or no route is found.
+func SetRouteMTU(dstNet string, iFaceIndex, tableID, mtu int) (bool, error) {
+	// Convert destination in *net.IPNet.
+	_, destinationNet, err := net.ParseCIDR(dstNet)
+	if err != nil {
+		return false, err
+	}
+	filter := &netlink.Route{Table: tableID, Dst: destinationNet, LinkIndex: iFaceIndex}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+	if err != nil {
+		return false, err
+	}
+	if len(routes) != 1 || routes[0].MTU == mtu {
+		return false, nil
+	}
+	route := routes[0]
+	route.MTU = mtu
+	klog.V(5).Infof("updating the mtu of route {%s}", route.String())
+	if err := netlink.RouteReplace(&route); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // DelRoute removes a route described by the given parameters.
 func DelRoute(dstNet, gwIP string, iFaceIndex, tableID int) (bool, error) {
 	var route *netlink.Route
@@ -229,6 +256,54 @@ func flushRulesForRoutingTable(routingTableID int) error {
 	return nil
 }
 
+// addExportedCIDRsRoutes adds the routes (and, if requested, the policy routing rules) towards the additional networks
+// exported by the remote cluster, with the given gateway, interface and flags. Returns true if anything has been configured.
+func addExportedCIDRsRoutes(tep *v1alpha1.TunnelEndpoint, gatewayIP string, iFaceIndex, tableID, flags int, policyRules bool) (bool, error) {
+	var configured bool
+	_, dstExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+	for _, dstExportedCIDR := range dstExportedCIDRs {
+		if policyRules {
+			policyRuleAdd, err := AddPolicyRoutingRule("", dstExportedCIDR, tableID)
+			if err != nil {
+				return configured, fmt.Errorf("unable to add policy routing rule for destination {%s} to lookup routing table with ID {%d}: %w",
+					dstExportedCIDR, tableID, err)
+			}
+			configured = configured || policyRuleAdd
+		}
+		routeAdd, err := AddRoute(dstExportedCIDR, gatewayIP, iFaceIndex, tableID, flags, DefaultScope)
+		if err != nil {
+			return configured, fmt.Errorf("unable to add route for destination {%s} with gateway {%s} in routing table with ID {%d}: %w",
+				dstExportedCIDR, gatewayIP, tableID, err)
+		}
+		configured = configured || routeAdd
+	}
+	return configured, nil
+}
+
+// delExportedCIDRsRoutes deletes the routes (and, if requested, the policy routing rules) towards the additional networks
+// exported by the remote cluster, with the given gateway and interface. Returns true if anything has been removed.
+func delExportedCIDRsRoutes(tep *v1alpha1.TunnelEndpoint, gatewayIP string, iFaceIndex, tableID int, policyRules bool) (bool, error) {
+	var configured bool
+	_, dstExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+	for _, dstExportedCIDR := range dstExportedCIDRs {
+		if policyRules {
+			policyRuleDel, err := DelPolicyRoutingRule("", dstExportedCIDR, tableID)
+			if err != nil {
+				return configured, fmt.Errorf("unable to delete policy routing rule for destination {%s} with table ID {%d}: %w",
+					dstExportedCIDR, tableID, err)
+			}
+			configured = configured || policyRuleDel
+		}
+		routeDel, err := DelRoute(dstExportedCIDR, gatewayIP, iFaceIndex, tableID)
+		if err != nil {
+			return configured, fmt.Errorf("unable to delete route for destination {%s} with gateway {%s} in routing table with ID {%d}: %w",
+				dstExportedCIDR, gatewayIP, tableID, err)
+		}
+		configured = configured || routeDel
+	}
+	return configured, nil
+}
+
 func getRouteConfig(tep *v1alpha1.TunnelEndpoint, podIP string) (dstPodCIDRNet, dstExternalCIDRNet, gatewayIP string, iFaceIndex int, err error) {
 	_, dstPodCIDRNet = liqonetutils.GetPodCIDRS(tep)
 	_, dstExternalCIDRNet = liqonetutils.GetExternalCIDRS(tep)
@@ -256,6 +256,50 @@ var _ = Describe("Common", func() {
 		})
 	})
 
+	Describe("setting the MTU of an existing route", func() {
+		Context("when input parameters are not in the correct format", func() {
+			It("should return error on wrong destination net", func() {
+				updated, err := SetRouteMTU(dstNetWrong, dummylink1.Attrs().Index, routingTableID, 1300)
+				Expect(updated).Should(Equal(false))
+				Expect(err).Should(Equal(&net.ParseError{Type: "CIDR address", Text: dstNetWrong}))
+			})
+		})
+
+		Context("when route does not exist", func() {
+			It("should return false and nil", func() {
+				updated, err := SetRouteMTU(dstNetCorrect, dummylink1.Attrs().Index, routingTableID, 1300)
+				Expect(updated).Should(Equal(false))
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when route does exist", func() {
+			JustBeforeEach(func() {
+				existingRoutesCM = setUpRoutes(routesCM)
+			})
+
+			JustAfterEach(func() {
+				tearDownRoutes(routingTableID)
+			})
+
+			It("should update the MTU, and return true and nil", func() {
+				updated, err := SetRouteMTU(existingRoutesCM[1].Dst.String(), existingRoutesCM[1].LinkIndex, existingRoutesCM[1].Table, 1300)
+				Expect(updated).Should(Equal(true))
+				Expect(err).NotTo(HaveOccurred())
+				routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, existingRoutesCM[1], netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(routes).To(HaveLen(1))
+				Expect(routes[0].MTU).To(Equal(1300))
+			})
+
+			It("should return false and nil if the MTU is already correct", func() {
+				updated, err := SetRouteMTU(existingRoutesCM[1].Dst.String(), existingRoutesCM[1].LinkIndex, existingRoutesCM[1].Table, 0)
+				Expect(updated).Should(Equal(false))
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
 	Describe("flushing custom routing table", func() {
 		JustBeforeEach(func() {
 			existingRoutesCM = setUpRoutes(routesCM)
@@ -91,7 +91,12 @@ func (drm *DirectRoutingManager) EnsureRoutesPerCluster(tep *netv1alpha1.TunnelE
 	if err != nil {
 		return routeExternalCIDRAdd, err
 	}
-	if routePodCIDRAdd || routeExternalCIDRAdd || policyRulePodCIDRAdd || policyRuleExternalCIDRAdd {
+	// Add routes and policy routing rules for the additional networks exported by the given cluster.
+	routesExportedCIDRsAdd, err := addExportedCIDRsRoutes(tep, gatewayIP, iFaceIndex, drm.routingTableID, DefaultFlags, true)
+	if err != nil {
+		return routesExportedCIDRsAdd, err
+	}
+	if routePodCIDRAdd || routeExternalCIDRAdd || policyRulePodCIDRAdd || policyRuleExternalCIDRAdd || routesExportedCIDRsAdd {
 		configured = true
 	}
 	return configured, nil
@@ -133,7 +138,12 @@ func (drm *DirectRoutingManager) RemoveRoutesPerCluster(tep *netv1alpha1.TunnelE
 	if err != nil {
 		return routeExternalCIDRDel, err
 	}
-	if routePodCIDRDel || routeExternalCIDRDel || policyRulePodCIDRDel || policyRuleExternalCIDRDel {
+	// Delete routes and policy routing rules for the additional networks exported by the given cluster.
+	routesExportedCIDRsDel, err := delExportedCIDRsRoutes(tep, gatewayIP, iFaceIndex, drm.routingTableID, true)
+	if err != nil {
+		return routesExportedCIDRsDel, err
+	}
+	if routePodCIDRDel || routeExternalCIDRDel || policyRulePodCIDRDel || policyRuleExternalCIDRDel || routesExportedCIDRsDel {
 		configured = true
 	}
 	return configured, nil
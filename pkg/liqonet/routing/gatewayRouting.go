@@ -72,7 +72,11 @@ func (grm *GatewayRoutingManager) EnsureRoutesPerCluster(tep *netv1alpha1.Tunnel
 	if err != nil {
 		return routeExternalCIDRAdd, err
 	}
-	if routePodCIDRAdd || routeExternalCIDRAdd {
+	routesExportedCIDRsAdd, err := addExportedCIDRsRoutes(tep, "", grm.tunnelDevice.Attrs().Index, grm.routingTableID, DefaultFlags, false)
+	if err != nil {
+		return routesExportedCIDRsAdd, err
+	}
+	if routePodCIDRAdd || routeExternalCIDRAdd || routesExportedCIDRsAdd {
 		configured = true
 	}
 	return configured, nil
@@ -97,7 +101,11 @@ func (grm *GatewayRoutingManager) RemoveRoutesPerCluster(tep *netv1alpha1.Tunnel
 	if err != nil {
 		return routeExternalCIDRDel, err
 	}
-	if routePodCIDRDel || routeExternalCIDRDel {
+	routesExportedCIDRsDel, err := delExportedCIDRsRoutes(tep, "", grm.tunnelDevice.Attrs().Index, grm.routingTableID, false)
+	if err != nil {
+		return routesExportedCIDRsDel, err
+	}
+	if routePodCIDRDel || routeExternalCIDRDel || routesExportedCIDRsDel {
 		configured = true
 	}
 	return configured, nil
@@ -149,6 +149,29 @@ var _ = Describe("GatewayRouting", func() {
 				Expect(routes[0].Gw).Should(BeNil())
 			})
 
+			It("route configuration for the exported CIDRs should be correctly inserted", func() {
+				tepExported := tep.DeepCopy()
+				tepExported.Spec.RemoteExportedCIDRs = []netv1alpha1.ExportedCIDR{{CIDR: "10.152.0.0/24", NATCIDR: "10.252.0.0/24"}}
+				added, err := grm.EnsureRoutesPerCluster(tepExported)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(added).Should(BeTrue())
+				_, dstExportedCIDRNet, err := net.ParseCIDR("10.252.0.0/24")
+				Expect(err).ShouldNot(HaveOccurred())
+				routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: dstExportedCIDRNet,
+					Table: routingTableIDGRM}, netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(routes).To(HaveLen(1))
+				Expect(routes[0].Gw).Should(BeNil())
+
+				deleted, err := grm.RemoveRoutesPerCluster(tepExported)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deleted).Should(BeTrue())
+				routes, err = netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: dstExportedCIDRNet,
+					Table: routingTableIDGRM}, netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(routes).To(BeEmpty())
+			})
+
 			It("route already exists, should return false and nil", func() {
 				tepGRM.Spec.RemoteNATPodCIDR = existingRoutesGRM[0].Dst.String()
 				tepGRM.Spec.RemoteNATExternalCIDR = existingRoutesGRM[1].Dst.String()
@@ -0,0 +1,205 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	"github.com/liqotech/liqo/pkg/liqonet/errors"
+	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
+)
+
+// GatewayAddressFunc returns the address which has to be resolved through the routes configured by the CNI
+// in order to reach the gateway identified by the given IP address.
+type GatewayAddressFunc func(gatewayIP string) (string, error)
+
+// NativeRoutingManager implements the routing manager interface.
+// It does not rely on any overlay network: the traffic is sent towards the gateway leveraging
+// the same next hop (and interface) the CNI uses to reach the gateway address.
+type NativeRoutingManager struct {
+	routingTableID int
+	podIP          string
+	gatewayAddress GatewayAddressFunc
+}
+
+// NewNativeRoutingManager accepts as input a routing table ID, the IP address of the pod and the function
+// used to retrieve the address to be resolved in order to reach the gateway.
+// Returns a NativeRoutingManager ready to be used or an error.
+func NewNativeRoutingManager(routingTableID int, podIP string, gatewayAddress GatewayAddressFunc) (Routing, error) {
+	klog.Infof("starting Native Routing Manager with routing table ID %d and podIP %s", routingTableID, podIP)
+	// Check the validity of input parameters.
+	if routingTableID > unix.RT_TABLE_MAX {
+		return nil, &errors.WrongParameter{Parameter: "routingTableID", Reason: errors.MinorOrEqual + strconv.Itoa(unix.RT_TABLE_MAX)}
+	}
+	if routingTableID < 0 {
+		return nil, &errors.WrongParameter{Parameter: "routingTableID", Reason: errors.GreaterOrEqual + strconv.Itoa(0)}
+	}
+	ip := net.ParseIP(podIP)
+	if ip == nil {
+		return nil, &errors.ParseIPError{
+			IPToBeParsed: podIP,
+		}
+	}
+	if gatewayAddress == nil {
+		return nil, &errors.WrongParameter{Parameter: "gatewayAddress", Reason: errors.NotNil}
+	}
+	return &NativeRoutingManager{
+		routingTableID: routingTableID,
+		podIP:          podIP,
+		gatewayAddress: gatewayAddress,
+	}, nil
+}
+
+// EnsureRoutesPerCluster accepts as input a netv1alpha.tunnelendpoint.
+// It inserts the routes if they do not exist or updates them if they are outdated.
+// Returns true if the routes have been configured, false if the routes are already configured.
+// An error if something goes wrong and the routes can not be configured.
+func (nrm *NativeRoutingManager) EnsureRoutesPerCluster(tep *netv1alpha1.TunnelEndpoint) (bool, error) {
+	var routePodCIDRAdd, routeExternalCIDRAdd, policyRulePodCIDRAdd, policyRuleExternalCIDRAdd, configured bool
+	clusterID := tep.Spec.ClusterIdentity.ClusterID
+	_, dstPodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, dstExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	// Compute the next hop towards the gateway.
+	gatewayIP, iFaceIndex, flags, err := nrm.getNextHop(tep)
+	if err != nil {
+		return false, err
+	}
+	// Add policy routing rules for the given cluster.
+	klog.Infof("%s -> adding policy routing rule for destination {%s} to lookup routing table with ID {%d}",
+		clusterID, dstPodCIDR, nrm.routingTableID)
+	if policyRulePodCIDRAdd, err = AddPolicyRoutingRule("", dstPodCIDR, nrm.routingTableID); err != nil {
+		return policyRulePodCIDRAdd, err
+	}
+	klog.Infof("%s -> adding policy routing rule for destination {%s} to lookup routing table with ID {%d}",
+		clusterID, dstExternalCIDR, nrm.routingTableID)
+	if policyRuleExternalCIDRAdd, err = AddPolicyRoutingRule("", dstExternalCIDR, nrm.routingTableID); err != nil {
+		return policyRuleExternalCIDRAdd, err
+	}
+	// Add routes for the given cluster.
+	klog.Infof("%s -> adding route for destination {%s} with gateway {%s} in routing table with ID {%d}",
+		clusterID, dstPodCIDR, gatewayIP, nrm.routingTableID)
+	routePodCIDRAdd, err = AddRoute(dstPodCIDR, gatewayIP, iFaceIndex, nrm.routingTableID, flags, DefaultScope)
+	if err != nil {
+		return routePodCIDRAdd, err
+	}
+	klog.Infof("%s -> adding route for destination {%s} with gateway {%s} in routing table with ID {%d}",
+		clusterID, dstExternalCIDR, gatewayIP, nrm.routingTableID)
+	routeExternalCIDRAdd, err = AddRoute(dstExternalCIDR, gatewayIP, iFaceIndex, nrm.routingTableID, flags, DefaultScope)
+	if err != nil {
+		return routeExternalCIDRAdd, err
+	}
+	// Add routes and policy routing rules for the additional networks exported by the given cluster.
+	routesExportedCIDRsAdd, err := addExportedCIDRsRoutes(tep, gatewayIP, iFaceIndex, nrm.routingTableID, flags, true)
+	if err != nil {
+		return routesExportedCIDRsAdd, err
+	}
+	if routePodCIDRAdd || routeExternalCIDRAdd || policyRulePodCIDRAdd || policyRuleExternalCIDRAdd || routesExportedCIDRsAdd {
+		configured = true
+	}
+	return configured, nil
+}
+
+// RemoveRoutesPerCluster accepts as input a netv1alpha.tunnelendpoint.
+// It deletes the routes if they do exist.
+// Returns true if the routes exist and have been deleted, false if nothing is removed.
+// An error if something goes wrong and the routes can not be removed.
+func (nrm *NativeRoutingManager) RemoveRoutesPerCluster(tep *netv1alpha1.TunnelEndpoint) (bool, error) {
+	var routePodCIDRDel, routeExternalCIDRDel, policyRulePodCIDRDel, policyRuleExternalCIDRDel, configured bool
+	var err error
+	clusterID := tep.Spec.ClusterIdentity.ClusterID
+	_, dstPodCIDR := liqonetutils.GetPodCIDRS(tep)
+	_, dstExternalCIDR := liqonetutils.GetExternalCIDRS(tep)
+	// Delete policy routing rules for the given cluster.
+	klog.Infof("%s -> deleting policy routing rule for destination {%s} to lookup routing table with ID {%d}",
+		clusterID, dstPodCIDR, nrm.routingTableID)
+	if policyRulePodCIDRDel, err = DelPolicyRoutingRule("", dstPodCIDR, nrm.routingTableID); err != nil {
+		return policyRulePodCIDRDel, err
+	}
+	klog.Infof("%s -> deleting policy routing rule for destination {%s} to lookup routing table with ID {%d}",
+		clusterID, dstExternalCIDR, nrm.routingTableID)
+	if policyRuleExternalCIDRDel, err = DelPolicyRoutingRule("", dstExternalCIDR, nrm.routingTableID); err != nil {
+		return policyRuleExternalCIDRDel, err
+	}
+	// Delete routes for the given cluster. The next hop is not specified, since it may have changed
+	// in the meantime, and the routing table is managed by us.
+	klog.Infof("%s -> deleting route for destination {%s} in routing table with ID {%d}",
+		clusterID, dstPodCIDR, nrm.routingTableID)
+	routePodCIDRDel, err = DelRoute(dstPodCIDR, "", 0, nrm.routingTableID)
+	if err != nil {
+		return routePodCIDRDel, err
+	}
+	klog.Infof("%s -> deleting route for destination {%s} in routing table with ID {%d}",
+		clusterID, dstExternalCIDR, nrm.routingTableID)
+	routeExternalCIDRDel, err = DelRoute(dstExternalCIDR, "", 0, nrm.routingTableID)
+	if err != nil {
+		return routeExternalCIDRDel, err
+	}
+	// Delete routes and policy routing rules for the additional networks exported by the given cluster.
+	routesExportedCIDRsDel, err := delExportedCIDRsRoutes(tep, "", 0, nrm.routingTableID, true)
+	if err != nil {
+		return routesExportedCIDRsDel, err
+	}
+	if routePodCIDRDel || routeExternalCIDRDel || policyRulePodCIDRDel || policyRuleExternalCIDRDel || routesExportedCIDRsDel {
+		configured = true
+	}
+	return configured, nil
+}
+
+// CleanRoutingTable removes all the routes from the custom routing table used by the route manager.
+func (nrm *NativeRoutingManager) CleanRoutingTable() error {
+	return flushRoutesForRoutingTable(nrm.routingTableID)
+}
+
+// CleanPolicyRules removes all the policy rules pointing to the custom routing table used by the route manager.
+func (nrm *NativeRoutingManager) CleanPolicyRules() error {
+	return flushRulesForRoutingTable(nrm.routingTableID)
+}
+
+// getNextHop returns the next hop, the interface index and the route flags to reach the gateway.
+// If running on the same host as the gateway, the veth device living on the same network namespace is used.
+// Otherwise, the route configured by the CNI towards the gateway address is looked up, and the same next hop is
+// reused with the onlink flag, since CNI tunnel devices (e.g. flannel.1, tunl0) do not have a subnet covering it.
+func (nrm *NativeRoutingManager) getNextHop(tep *netv1alpha1.TunnelEndpoint) (gatewayIP string, iFaceIndex, flags int, err error) {
+	if tep.Status.GatewayIP == nrm.podIP {
+		return "", tep.Status.VethIFaceIndex, DefaultFlags, nil
+	}
+	address, err := nrm.gatewayAddress(tep.Status.GatewayIP)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", 0, 0, &errors.ParseIPError{IPToBeParsed: address}
+	}
+	routes, err := netlink.RouteGet(ip)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if len(routes) == 0 || routes[0].LinkIndex == 0 {
+		return "", 0, 0, &errors.NoRouteFound{IPAddress: address}
+	}
+	gateway := routes[0].Gw
+	if gateway == nil {
+		// The address is directly reachable through the interface.
+		gateway = ip
+	}
+	return gateway.String(), routes[0].LinkIndex, int(netlink.FLAG_ONLINK), nil
+}
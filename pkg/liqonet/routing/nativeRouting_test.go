@@ -0,0 +1,184 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/liqotech/liqo/pkg/liqonet/errors"
+)
+
+var (
+	routingTableIDNRM = 18956
+	// The address returned by the gateway address function, belonging to the subnet of the dummy interfaces.
+	gatewayAddressNRM = "10.0.0.100"
+)
+
+var _ = Describe("NativeRouting", func() {
+	var (
+		nrm            Routing
+		gatewayAddress GatewayAddressFunc
+	)
+
+	BeforeEach(func() {
+		gatewayAddress = func(gatewayIP string) (string, error) { return gatewayAddressNRM, nil }
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		nrm, err = NewNativeRoutingManager(routingTableIDNRM, ipAddress1NoSubnet, gatewayAddress)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	JustAfterEach(func() {
+		tearDownRoutes(routingTableIDNRM)
+	})
+
+	Describe("creating new Native Route Manager", func() {
+		Context("when parameters are not valid", func() {
+			It("routingTableID parameter out of range: a negative number", func() {
+				nrm, err := NewNativeRoutingManager(-244, gwIPCorrect, gatewayAddress)
+				Expect(nrm).Should(BeNil())
+				Expect(err).Should(Equal(&errors.WrongParameter{Parameter: "routingTableID", Reason: errors.GreaterOrEqual + strconv.Itoa(0)}))
+			})
+
+			It("routingTableID parameter out of range: superior to max value ", func() {
+				nrm, err := NewNativeRoutingManager(unix.RT_TABLE_MAX+1, gwIPCorrect, gatewayAddress)
+				Expect(nrm).Should(BeNil())
+				Expect(err).Should(Equal(&errors.WrongParameter{Parameter: "routingTableID", Reason: errors.MinorOrEqual + strconv.Itoa(unix.RT_TABLE_MAX)}))
+			})
+
+			It("podIP is not in right format", func() {
+				nrm, err := NewNativeRoutingManager(244, gwIPWrong, gatewayAddress)
+				Expect(nrm).Should(BeNil())
+				Expect(err).Should(Equal(&errors.ParseIPError{IPToBeParsed: gwIPWrong}))
+			})
+
+			It("gateway address function is nil", func() {
+				nrm, err := NewNativeRoutingManager(244, gwIPCorrect, nil)
+				Expect(nrm).Should(BeNil())
+				Expect(err).Should(Equal(&errors.WrongParameter{Parameter: "gatewayAddress", Reason: errors.NotNil}))
+			})
+		})
+	})
+
+	Describe("configuring routes for a remote peering cluster", func() {
+		Context("when the gateway address cannot be retrieved", func() {
+			BeforeEach(func() {
+				gatewayAddress = func(gatewayIP string) (string, error) { return "", fmt.Errorf("fake error") }
+			})
+
+			It("route configuration fails", func() {
+				added, err := nrm.EnsureRoutesPerCluster(&tep)
+				Expect(err).To(MatchError("fake error"))
+				Expect(added).To(BeFalse())
+			})
+		})
+
+		Context("when the gateway address is malformed", func() {
+			BeforeEach(func() {
+				gatewayAddress = func(gatewayIP string) (string, error) { return gwIPWrong, nil }
+			})
+
+			It("route configuration fails", func() {
+				added, err := nrm.EnsureRoutesPerCluster(&tep)
+				Expect(err).To(Equal(&errors.ParseIPError{IPToBeParsed: gwIPWrong}))
+				Expect(added).To(BeFalse())
+			})
+		})
+
+		Context("when the gateway is running on a different node", func() {
+			It("routes should be inserted with the next hop used to reach the gateway address", func() {
+				expected, err := netlink.RouteGet(net.ParseIP(gatewayAddressNRM))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(expected).ToNot(BeEmpty())
+
+				added, err := nrm.EnsureRoutesPerCluster(&tep)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(added).To(BeTrue())
+
+				for _, cidr := range []string{tep.Spec.RemoteNATPodCIDR, tep.Spec.RemoteNATExternalCIDR} {
+					_, dst, err := net.ParseCIDR(cidr)
+					Expect(err).ToNot(HaveOccurred())
+					routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: dst, Table: routingTableIDNRM},
+						netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(routes).To(HaveLen(1))
+					Expect(routes[0].Gw.String()).To(Equal(gatewayAddressNRM))
+					Expect(routes[0].LinkIndex).To(Equal(expected[0].LinkIndex))
+					Expect(routes[0].Flags & int(netlink.FLAG_ONLINK)).ToNot(BeZero())
+				}
+
+				// A second invocation should not change anything.
+				added, err = nrm.EnsureRoutesPerCluster(&tep)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(added).To(BeFalse())
+			})
+		})
+
+		Context("when the gateway is running on the same node", func() {
+			It("routes should be inserted through the veth device", func() {
+				tepCopy := tep
+				tepCopy.Status.GatewayIP = ipAddress1NoSubnet
+				tepCopy.Status.VethIFaceIndex = dummyLink2.Attrs().Index
+				added, err := nrm.EnsureRoutesPerCluster(&tepCopy)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(added).To(BeTrue())
+
+				_, dst, err := net.ParseCIDR(tep.Spec.RemoteNATPodCIDR)
+				Expect(err).ToNot(HaveOccurred())
+				routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: dst, Table: routingTableIDNRM},
+					netlink.RT_FILTER_DST|netlink.RT_FILTER_TABLE)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(routes).To(HaveLen(1))
+				Expect(routes[0].Gw).To(BeNil())
+				Expect(routes[0].LinkIndex).To(Equal(dummyLink2.Attrs().Index))
+			})
+		})
+	})
+
+	Describe("removing route configuration for a remote peering cluster", func() {
+		It("routes should be removed even if the gateway address changed", func() {
+			added, err := nrm.EnsureRoutesPerCluster(&tep)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(added).To(BeTrue())
+
+			previous := gatewayAddressNRM
+			gatewayAddressNRM = gwIPCorrect
+			defer func() { gatewayAddressNRM = previous }()
+
+			removed, err := nrm.RemoveRoutesPerCluster(&tep)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(BeTrue())
+			routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: routingTableIDNRM}, netlink.RT_FILTER_TABLE)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(routes).To(BeEmpty())
+			exists, err := existsRuleForRoutingTable(routingTableIDNRM)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			removed, err = nrm.RemoveRoutesPerCluster(&tep)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(BeFalse())
+		})
+	})
+})
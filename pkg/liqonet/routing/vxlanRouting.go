@@ -144,7 +144,12 @@ func (vrm *VxlanRoutingManager) EnsureRoutesPerCluster(tep *netv1alpha1.TunnelEn
 			"{%s} in routing table with ID {%d} on device {%s}: %w",
 			clusterID, dstExternalCIDR, gatewayIP, vrm.routingTableID, iFaceName, err)
 	}
-	if routePodCIDRAdd || routeExternalCIDRAdd || policyRulePodCIDRAdd || policyRuleExternalCIDRAdd {
+	// Add routes and policy routing rules for the additional networks exported by the given cluster.
+	routesExportedCIDRsAdd, err := addExportedCIDRsRoutes(tep, gatewayIP, iFaceIndex, vrm.routingTableID, DefaultFlags, true)
+	if err != nil {
+		return routesExportedCIDRsAdd, fmt.Errorf("%s -> %w", clusterID, err)
+	}
+	if routePodCIDRAdd || routeExternalCIDRAdd || policyRulePodCIDRAdd || policyRuleExternalCIDRAdd || routesExportedCIDRsAdd {
 		configured = true
 	}
 	return configured, nil
@@ -215,8 +220,13 @@ func (vrm *VxlanRoutingManager) RemoveRoutesPerCluster(tep *netv1alpha1.TunnelEn
 			"in routing table with ID {%d} on device {%s}: %w",
 			clusterID, dstExternalCIDR, gatewayIP, vrm.routingTableID, iFaceName, err)
 	}
+	// Delete routes and policy routing rules for the additional networks exported by the given cluster.
+	routesExportedCIDRsDel, err := delExportedCIDRsRoutes(tep, gatewayIP, iFaceIndex, vrm.routingTableID, true)
+	if err != nil {
+		return routesExportedCIDRsDel, fmt.Errorf("%s -> %w", clusterID, err)
+	}
 
-	if policyRulePodCIDRDel || policyRuleExternalCIDRDel || routePodCIDRDel || routeExternalCIDRDel {
+	if policyRulePodCIDRDel || policyRuleExternalCIDRDel || routePodCIDRDel || routeExternalCIDRDel || routesExportedCIDRsDel {
 		configured = true
 	}
 	return configured, nil
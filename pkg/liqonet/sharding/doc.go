@@ -0,0 +1,18 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sharding implements the assignment of the remote clusters to the gateway replicas, in case multiple
+// replicas are simultaneously active (i.e., active-active mode). Each remote cluster is owned by exactly one replica,
+// which is in charge of the corresponding tunnel, and the assignment is rebalanced when replicas come or go.
+package sharding
@@ -0,0 +1,156 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	liqolabels "github.com/liqotech/liqo/pkg/utils/labels"
+)
+
+// Owner returns the member owning the given key, according to the rendezvous (i.e., highest random weight) hashing
+// algorithm. This guarantees that, when a member is added or removed, only the keys owned by that member are reassigned.
+// An empty string is returned in case no members are given.
+func Owner(key string, members []string) string {
+	var owner string
+	var maxWeight uint64
+	for _, member := range members {
+		current := weight(key, member)
+		if owner == "" || current > maxWeight || (current == maxWeight && member < owner) {
+			owner, maxWeight = member, current
+		}
+	}
+	return owner
+}
+
+// weight returns the pseudo-random weight associated with the given key/member pair.
+func weight(key, member string) uint64 {
+	hash := sha256.Sum256([]byte(key + "/" + member))
+	return binary.BigEndian.Uint64(hash[:8])
+}
+
+// IsActiveMember returns whether the given pod corresponds to an active gateway replica, eligible to own remote clusters.
+func IsActiveMember(pod *corev1.Pod) bool {
+	return pod.Labels[liqoconst.GatewayPodLabelKey] == liqoconst.GatewayActivePodLabelValue &&
+		pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" && pod.DeletionTimestamp.IsZero()
+}
+
+// Members returns the sorted list of identifiers (i.e., IP addresses) of the active gateway replicas among the given pods.
+func Members(pods []corev1.Pod) []string {
+	members := sets.NewString()
+	for i := range pods {
+		if IsActiveMember(&pods[i]) {
+			members.Insert(pods[i].Status.PodIP)
+		}
+	}
+	return members.List()
+}
+
+// IsNodeAddressable returns whether the gateway replicas can be reached individually through the IP of the hosting nodes,
+// that is the given gateway service is of type NodePort and the address is neither overridden nor detected by an endpoint resolver.
+// Otherwise, the remote clusters are necessarily directed to the shared address, and the shards cannot be enforced.
+func IsNodeAddressable(service *corev1.Service) bool {
+	_, overridden := service.GetAnnotations()[liqoconst.OverrideAddressAnnotation]
+	_, detected := service.GetAnnotations()[liqoconst.DetectedAddressAnnotation]
+	return service.Spec.Type == corev1.ServiceTypeNodePort && !overridden && !detected
+}
+
+// CheckPreconditions verifies that the gateway replicas in the given namespace can be reached individually through
+// the IP of the hosting nodes, as required to enable the active-active mode.
+func CheckPreconditions(ctx context.Context, cl client.Reader, namespace string) error {
+	selector, err := metav1.LabelSelectorAsSelector(&liqolabels.GatewayServiceLabelSelector)
+	if err != nil {
+		return err
+	}
+
+	var services corev1.ServiceList
+	if err := cl.List(ctx, &services, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return fmt.Errorf("failed to retrieve the gateway service: %w", err)
+	}
+
+	switch {
+	case len(services.Items) == 0:
+		return errors.New("gateway service not found")
+	case len(services.Items) > 1:
+		return errors.New("multiple gateway services found")
+	case !IsNodeAddressable(&services.Items[0]):
+		return fmt.Errorf("gateway service %q is not of type %s, or its address is overridden or detected",
+			services.Items[0].GetName(), corev1.ServiceTypeNodePort)
+	}
+	return nil
+}
+
+// Sharder determines which remote clusters are owned by the local gateway replica.
+type Sharder struct {
+	client    client.Client
+	namespace string
+	local     string
+}
+
+// NewSharder returns a new Sharder instance, given the namespace of the gateway pods and the IP of the local replica.
+func NewSharder(cl client.Client, namespace, local string) *Sharder {
+	return &Sharder{client: cl, namespace: namespace, local: local}
+}
+
+// Members returns the sorted list of active gateway replicas, always including the local one.
+func (s *Sharder) Members(ctx context.Context) ([]string, error) {
+	var pods corev1.PodList
+	if err := s.client.List(ctx, &pods, client.InNamespace(s.namespace),
+		client.MatchingLabels{liqoconst.GatewayPodLabelKey: liqoconst.GatewayActivePodLabelValue}); err != nil {
+		return nil, err
+	}
+
+	return sets.NewString(Members(pods.Items)...).Insert(s.local).List(), nil
+}
+
+// Namespace returns the namespace of the gateway pods.
+func (s *Sharder) Namespace() string {
+	return s.namespace
+}
+
+// IsOwnedBy returns whether the given remote cluster is owned by the given member, assuming it is active. According
+// to the rendezvous hashing properties, these are the only remote clusters reassigned when that member joins or leaves.
+func (s *Sharder) IsOwnedBy(ctx context.Context, clusterID, member string) (bool, error) {
+	members, err := s.Members(ctx)
+	if err != nil {
+		return false, err
+	}
+	return Owner(clusterID, sets.NewString(members...).Insert(member).List()) == member, nil
+}
+
+// Owner returns the identifier of the gateway replica owning the given remote cluster.
+func (s *Sharder) Owner(ctx context.Context, clusterID string) (string, error) {
+	members, err := s.Members(ctx)
+	if err != nil {
+		return "", err
+	}
+	return Owner(clusterID, members), nil
+}
+
+// IsLocal returns whether the given remote cluster is owned by the local gateway replica.
+func (s *Sharder) IsLocal(ctx context.Context, clusterID string) (bool, error) {
+	owner, err := s.Owner(ctx, clusterID)
+	return owner == s.local, err
+}
@@ -0,0 +1,173 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+)
+
+var _ = Describe("Sharding", func() {
+	const namespace = "liqo"
+
+	clusters := func() []string {
+		var ids []string
+		for i := 0; i < 1000; i++ {
+			ids = append(ids, fmt.Sprintf("cluster-%d", i))
+		}
+		return ids
+	}
+
+	forgePod := func(name, ip, status string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{liqoconst.GatewayPodLabelKey: status}},
+			Status:     corev1.PodStatus{Phase: phase, PodIP: ip},
+		}
+	}
+
+	Describe("The Owner function", func() {
+		members := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+
+		It("should return an empty string if no members are given", func() {
+			Expect(Owner("cluster", nil)).To(BeEmpty())
+		})
+
+		It("should not depend on the order of the members", func() {
+			for _, id := range clusters() {
+				Expect(Owner(id, members)).To(Equal(Owner(id, []string{members[2], members[0], members[1]})))
+			}
+		})
+
+		It("should spread the keys across all members", func() {
+			counts := map[string]int{}
+			for _, id := range clusters() {
+				counts[Owner(id, members)]++
+			}
+			for _, member := range members {
+				Expect(counts[member]).To(BeNumerically(">", 250))
+			}
+		})
+
+		It("should reassign only the keys owned by a removed member", func() {
+			for _, id := range clusters() {
+				if owner := Owner(id, members); owner != members[1] {
+					Expect(Owner(id, []string{members[0], members[2]})).To(Equal(owner))
+				}
+			}
+		})
+
+		It("should reassign only the keys acquired by an added member", func() {
+			for _, id := range clusters() {
+				if owner := Owner(id, append(members, "10.0.0.4")); owner != "10.0.0.4" {
+					Expect(Owner(id, members)).To(Equal(owner))
+				}
+			}
+		})
+	})
+
+	Describe("The Members function", func() {
+		It("should return the sorted IPs of the active and running replicas only", func() {
+			terminating := forgePod("terminating", "10.0.0.5", liqoconst.GatewayActivePodLabelValue, corev1.PodRunning)
+			terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			Expect(Members([]corev1.Pod{
+				*forgePod("second", "10.0.0.2", liqoconst.GatewayActivePodLabelValue, corev1.PodRunning),
+				*forgePod("first", "10.0.0.1", liqoconst.GatewayActivePodLabelValue, corev1.PodRunning),
+				*forgePod("standby", "10.0.0.3", "standby", corev1.PodRunning),
+				*forgePod("pending", "", liqoconst.GatewayActivePodLabelValue, corev1.PodPending),
+				*terminating,
+			})).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		})
+	})
+
+	Describe("The Sharder", func() {
+		var sharder *Sharder
+
+		BeforeEach(func() {
+			cl := fake.NewClientBuilder().WithObjects(
+				forgePod("first", "10.0.0.1", liqoconst.GatewayActivePodLabelValue, corev1.PodRunning),
+				forgePod("second", "10.0.0.2", liqoconst.GatewayActivePodLabelValue, corev1.PodRunning),
+			).Build()
+			sharder = NewSharder(cl, namespace, "10.0.0.3")
+		})
+
+		It("should include the local replica in the members", func() {
+			Expect(sharder.Members(context.Background())).To(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+		})
+
+		It("should determine whether the remote clusters are owned by the local replica", func() {
+			members := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+			for _, id := range clusters()[:100] {
+				Expect(sharder.IsLocal(context.Background(), id)).To(Equal(Owner(id, members) == "10.0.0.3"))
+			}
+		})
+
+		It("should determine whether the remote clusters are owned by a given member, assuming it is active", func() {
+			members := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+			for _, id := range clusters()[:100] {
+				Expect(sharder.IsOwnedBy(context.Background(), id, "10.0.0.4")).To(Equal(Owner(id, members) == "10.0.0.4"))
+				Expect(sharder.IsOwnedBy(context.Background(), id, "10.0.0.1")).To(Equal(Owner(id, members[:3]) == "10.0.0.1"))
+			}
+		})
+	})
+
+	Describe("The CheckPreconditions function", func() {
+		var service *corev1.Service
+
+		BeforeEach(func() {
+			service = &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "liqo-gateway", Namespace: namespace,
+					Labels: map[string]string{liqoconst.GatewayServiceLabelKey: liqoconst.GatewayServiceLabelValue}},
+				Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort},
+			}
+		})
+
+		check := func(objects ...client.Object) error {
+			return CheckPreconditions(context.Background(), fake.NewClientBuilder().WithObjects(objects...).Build(), namespace)
+		}
+
+		It("should succeed if the gateway service is of type NodePort", func() {
+			Expect(check(service)).To(Succeed())
+		})
+
+		It("should fail if the gateway service is not found", func() {
+			Expect(check()).ToNot(Succeed())
+		})
+
+		It("should fail if the gateway service is not of type NodePort", func() {
+			service.Spec.Type = corev1.ServiceTypeLoadBalancer
+			Expect(check(service)).ToNot(Succeed())
+		})
+
+		It("should fail if the address of the gateway service is overridden", func() {
+			service.SetAnnotations(map[string]string{liqoconst.OverrideAddressAnnotation: "1.1.1.1"})
+			Expect(check(service)).ToNot(Succeed())
+		})
+
+		It("should fail if the address of the gateway service is detected", func() {
+			service.SetAnnotations(map[string]string{liqoconst.DetectedAddressAnnotation: "1.1.1.1"})
+			Expect(check(service)).ToNot(Succeed())
+		})
+	})
+})
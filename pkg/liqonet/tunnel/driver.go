@@ -58,3 +58,11 @@ type Driver interface {
 
 	prometheus.Collector
 }
+
+// PeerLinkDriver is implemented by the drivers creating a distinct link towards each remote cluster,
+// rather than a single one shared by all of them (i.e., the one returned by GetLink).
+type PeerLinkDriver interface {
+	Driver
+
+	GetPeerLink(tep *netv1alpha1.TunnelEndpoint) (netlink.Link, error)
+}
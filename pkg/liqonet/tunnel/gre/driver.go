@@ -0,0 +1,258 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gre
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	discv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/metrics"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/resolver"
+)
+
+const (
+	// EndpointIP is the key of the endpointIP entry in the peer configuration.
+	EndpointIP = "endpointIP"
+	// ConnectedMessage human-readable info when the GRE tunnel is configured.
+	ConnectedMessage = "GRE tunnel established (unencrypted)"
+)
+
+// Registering the driver as available.
+func init() {
+	tunnel.AddDriver(liqoconst.GreDriverName, NewDriver)
+}
+
+// Gre is the driver establishing a plain GRE tunnel towards each remote cluster. Differently from WireGuard, traffic is
+// not encrypted, hence it shall be used only when the clusters are interconnected through a trusted private network.
+//
+// Each tunnel is created in the host network namespace, where the encapsulated packets are exchanged, and then moved to
+// the gateway network namespace (i.e., where ConnectToEndpoint and DisconnectFromEndpoint are executed).
+type Gre struct {
+	mtu          int
+	hostNetns    ns.NetNS
+	gatewayNetns ns.NetNS
+
+	mutex sync.Mutex
+	// peers key is a clusterID.
+	peers map[string]discv1alpha1.ClusterIdentity
+}
+
+// NewDriver creates a new GRE driver.
+func NewDriver(k8sClient k8s.Interface, namespace string, config tunnel.Config) (tunnel.Driver, error) {
+	return &Gre{
+		mtu:   config.MTU,
+		peers: make(map[string]discv1alpha1.ClusterIdentity),
+	}, nil
+}
+
+// Init retrieves the host network namespace, which the tunnels are created in. It shall be executed in the host network namespace.
+func (g *Gre) Init() (err error) {
+	g.hostNetns, err = ns.GetCurrentNS()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the host network namespace: %w", err)
+	}
+	return nil
+}
+
+// ConnectToEndpoint configures the GRE tunnel towards the remote cluster described by the given tep.
+func (g *Gre) ConnectToEndpoint(tep *netv1alpha1.TunnelEndpoint) (*netv1alpha1.Connection, error) {
+	address, err := resolver.Resolve(context.Background(), tep.Spec.EndpointIP)
+	if err != nil {
+		return newConnectionOnError(err.Error()), err
+	}
+
+	name := GetLinkName(tep.Spec.ClusterIdentity.ClusterID)
+	link, err := netlink.LinkByName(name)
+	if err == nil {
+		if gre, ok := link.(*netlink.Gretun); ok && gre.Remote.Equal(address.IP) {
+			return g.ensureUp(tep, link, address.IP.String())
+		}
+
+		// The remote endpoint changed, hence the tunnel is recreated.
+		klog.V(4).Infof("updating GRE tunnel for cluster %s", tep.Spec.ClusterIdentity)
+		if err = netlink.LinkDel(link); err != nil {
+			return newConnectionOnError(err.Error()), fmt.Errorf("failed to delete GRE device %q: %w", name, err)
+		}
+	} else if !isLinkNotFound(err) {
+		return newConnectionOnError(err.Error()), fmt.Errorf("failed to get GRE device %q: %w", name, err)
+	}
+
+	if g.gatewayNetns == nil {
+		if g.gatewayNetns, err = ns.GetCurrentNS(); err != nil {
+			return newConnectionOnError(err.Error()), fmt.Errorf("failed to retrieve the gateway network namespace: %w", err)
+		}
+	}
+
+	klog.V(4).Infof("Connecting cluster %s endpoint %s through a GRE tunnel", tep.Spec.ClusterIdentity, address.IP)
+	err = g.hostNetns.Do(func(netNamespace ns.NetNS) error {
+		// Delete the possible leftover device, e.g., in case of a previous crash.
+		if existing, err := netlink.LinkByName(name); err == nil {
+			if err := netlink.LinkDel(existing); err != nil {
+				return fmt.Errorf("failed to delete existing GRE device %q: %w", name, err)
+			}
+		}
+
+		la := netlink.NewLinkAttrs()
+		la.Name = name
+		la.MTU = g.mtu
+		gre := &netlink.Gretun{LinkAttrs: la, Remote: address.IP}
+		if err := netlink.LinkAdd(gre); err != nil {
+			return fmt.Errorf("failed to add GRE device %q: %w", name, err)
+		}
+		if err := netlink.LinkSetNsFd(gre, int(g.gatewayNetns.Fd())); err != nil {
+			return fmt.Errorf("failed to move GRE device %q to gateway netns: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return newConnectionOnError(err.Error()), err
+	}
+
+	if link, err = netlink.LinkByName(name); err != nil {
+		return newConnectionOnError(err.Error()), fmt.Errorf("failed to get GRE device %q: %w", name, err)
+	}
+	return g.ensureUp(tep, link, address.IP.String())
+}
+
+// ensureUp sets the given link up, and returns the resulting connection.
+func (g *Gre) ensureUp(tep *netv1alpha1.TunnelEndpoint, link netlink.Link, endpoint string) (*netv1alpha1.Connection, error) {
+	if err := netlink.LinkSetUp(link); err != nil {
+		return newConnectionOnError(err.Error()), fmt.Errorf("failed to set GRE device %q up: %w", link.Attrs().Name, err)
+	}
+
+	g.mutex.Lock()
+	g.peers[tep.Spec.ClusterIdentity.ClusterID] = tep.Spec.ClusterIdentity
+	g.mutex.Unlock()
+
+	// GRE tunnels are stateless, hence they are considered connected as soon as configured.
+	return &netv1alpha1.Connection{
+		Status:            netv1alpha1.Connected,
+		StatusMessage:     ConnectedMessage,
+		PeerConfiguration: map[string]string{EndpointIP: endpoint},
+	}, nil
+}
+
+// DisconnectFromEndpoint removes the GRE tunnel towards the remote cluster described by the given tep.
+func (g *Gre) DisconnectFromEndpoint(tep *netv1alpha1.TunnelEndpoint) error {
+	klog.V(4).Infof("Removing connection with cluster %s", tep.Spec.ClusterIdentity)
+
+	g.mutex.Lock()
+	delete(g.peers, tep.Spec.ClusterIdentity.ClusterID)
+	g.mutex.Unlock()
+
+	name := GetLinkName(tep.Spec.ClusterIdentity.ClusterID)
+	link, err := netlink.LinkByName(name)
+	if isLinkNotFound(err) {
+		klog.V(4).Infof("no tunnel configured for cluster %s, nothing to be removed", tep.Spec.ClusterIdentity)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get GRE device %q: %w", name, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("failed to delete GRE device %q: %w", name, err)
+	}
+	return nil
+}
+
+// GetLink returns nil, since a distinct link is created towards each remote cluster (see GetPeerLink).
+func (g *Gre) GetLink() netlink.Link {
+	return nil
+}
+
+// GetPeerLink returns the link of the GRE tunnel towards the remote cluster described by the given tep.
+// It must be executed in the gateway network namespace.
+func (g *Gre) GetPeerLink(tep *netv1alpha1.TunnelEndpoint) (netlink.Link, error) {
+	return netlink.LinkByName(GetLinkName(tep.Spec.ClusterIdentity.ClusterID))
+}
+
+// Close is a no-op, as the GRE devices are removed along with the gateway network namespace.
+func (g *Gre) Close() error {
+	return nil
+}
+
+// Describe implements prometheus.Collector. No descriptors are sent, making it an unchecked collector,
+// since it exposes the same metrics of the other drivers (distinguished through the driver label).
+func (g *Gre) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (g *Gre) Collect(ch chan<- prometheus.Metric) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.peers) == 0 {
+		return
+	}
+
+	err := g.gatewayNetns.Do(func(netNamespace ns.NetNS) error {
+		for clusterID, identity := range g.peers {
+			link, err := netlink.LinkByName(GetLinkName(clusterID))
+			if err != nil {
+				return err
+			}
+			if link.Attrs().Statistics == nil {
+				continue
+			}
+
+			labels := []string{liqoconst.GreDriverName, link.Attrs().Name, identity.ClusterID, identity.ClusterName}
+			ch <- prometheus.MustNewConstMetric(metrics.PeerReceivedBytes, prometheus.CounterValue,
+				float64(link.Attrs().Statistics.RxBytes), labels...)
+			ch <- prometheus.MustNewConstMetric(metrics.PeerTransmittedBytes, prometheus.CounterValue,
+				float64(link.Attrs().Statistics.TxBytes), labels...)
+		}
+		return nil
+	})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(metrics.PeerReceivedBytes, fmt.Errorf("error collecting gre metrics: %w", err))
+		ch <- prometheus.NewInvalidMetric(metrics.PeerTransmittedBytes, fmt.Errorf("error collecting gre metrics: %w", err))
+	}
+}
+
+// GetLinkName returns the name of the GRE device towards the given remote cluster. Device names are limited to 15 characters,
+// hence the suffix is derived from the hash of the full cluster ID, to prevent collisions between similar cluster IDs.
+func GetLinkName(clusterID string) string {
+	hash := sha256.Sum256([]byte(clusterID))
+	suffix := strings.ToLower(base32.StdEncoding.EncodeToString(hash[:]))
+	return liqoconst.GreDevicePrefix + suffix[:unix.IFNAMSIZ-1-len(liqoconst.GreDevicePrefix)]
+}
+
+func isLinkNotFound(err error) bool {
+	var notFound netlink.LinkNotFoundError
+	return errors.As(err, &notFound)
+}
+
+func newConnectionOnError(msg string) *netv1alpha1.Connection {
+	return &netv1alpha1.Connection{
+		Status:            netv1alpha1.ConnectionError,
+		StatusMessage:     msg,
+		PeerConfiguration: nil,
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gre
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel"
+)
+
+var _ = Describe("Gre", func() {
+	Describe("the driver registration", func() {
+		It("should register the driver as available", func() {
+			Expect(tunnel.Drivers).To(HaveKey(liqoconst.GreDriverName))
+		})
+	})
+
+	Describe("the GetLinkName function", func() {
+		const clusterID = "2fa7a8d4-6b13-4bd4-9d6a-1a1e5f6cf61b"
+
+		It("should fit the maximum length of the device names", func() {
+			Expect(GetLinkName(clusterID)).To(HavePrefix(liqoconst.GreDevicePrefix))
+			Expect(len(GetLinkName(clusterID))).To(BeNumerically("<=", 15))
+			Expect(len(GetLinkName("foo"))).To(BeNumerically("<=", 15))
+		})
+
+		It("should be deterministic", func() {
+			Expect(GetLinkName(clusterID)).To(Equal(GetLinkName(clusterID)))
+		})
+
+		It("should differ for cluster IDs sharing the same prefix", func() {
+			Expect(GetLinkName(clusterID)).ToNot(Equal(GetLinkName("2fa7a8d4-0000-4bd4-9d6a-1a1e5f6cf61b")))
+		})
+	})
+})
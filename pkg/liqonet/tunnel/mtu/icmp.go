@@ -0,0 +1,136 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtu
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// icmpHeaderLen is the length of the header of ICMP echo messages.
+const icmpHeaderLen = 8
+
+// ICMPProber probes the path towards a remote address through ICMP echo requests with the don't fragment bit set.
+type ICMPProber struct {
+	timeout  time.Duration
+	attempts int
+
+	id  int
+	seq uint32
+}
+
+// NewICMPProber returns a new ICMPProber, waiting for the given timeout the reply to each echo request,
+// and repeating each probe the given number of times before considering it failed.
+func NewICMPProber(timeout time.Duration, attempts int) *ICMPProber {
+	return &ICMPProber{
+		timeout:  timeout,
+		attempts: attempts,
+		//nolint:gosec // The identifier is only used to match the echo replies, and does not need to be cryptographically secure.
+		id: rand.Intn(1 << 16),
+	}
+}
+
+// Probe returns whether an ICMP echo request of the given size (including the IP header) got a reply from the given address.
+func (p *ICMPProber) Probe(ip net.IP, size int) (bool, error) {
+	if ip.To4() == nil {
+		return false, fmt.Errorf("address %v is not an IPv4 address", ip)
+	}
+	if size < ipv4.HeaderLen+icmpHeaderLen {
+		return false, fmt.Errorf("probe size %d is too small", size)
+	}
+
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_RAW, unix.IPPROTO_ICMP)
+	if err != nil {
+		return false, fmt.Errorf("failed to create the ICMP socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	// Set the don't fragment bit, without taking into account the path MTU possibly cached by the kernel.
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE); err != nil {
+		return false, fmt.Errorf("failed to disable fragmentation: %w", err)
+	}
+	timeout := unix.NsecToTimeval(p.timeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		return false, fmt.Errorf("failed to configure the receive timeout: %w", err)
+	}
+
+	destination := &unix.SockaddrInet4{}
+	copy(destination.Addr[:], ip.To4())
+
+	for attempt := 0; attempt < p.attempts; attempt++ {
+		seq := int(atomic.AddUint32(&p.seq, 1) & 0xffff)
+		request, err := (&icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Body: &icmp.Echo{ID: p.id, Seq: seq, Data: make([]byte, size-ipv4.HeaderLen-icmpHeaderLen)},
+		}).Marshal(nil)
+		if err != nil {
+			return false, err
+		}
+
+		if err := unix.Sendto(fd, request, 0, destination); err != nil {
+			// The probe exceeds the MTU of the local interface.
+			if errors.Is(err, unix.EMSGSIZE) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to send the ICMP echo request: %w", err)
+		}
+
+		if p.waitForReply(fd, ip, seq) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// waitForReply waits for the echo reply matching the given sequence number, until the timeout expires.
+func (p *ICMPProber) waitForReply(fd int, ip net.IP, seq int) bool {
+	buffer := make([]byte, 1<<16)
+	deadline := time.Now().Add(p.timeout)
+
+	for time.Now().Before(deadline) {
+		n, from, err := unix.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			// The receive timeout expired, or an unexpected error occurred.
+			return false
+		}
+
+		// Raw sockets receive all the ICMP messages, hence the ones not matching the echo request are discarded.
+		if source, ok := from.(*unix.SockaddrInet4); !ok || !net.IP(source.Addr[:]).Equal(ip) || n < ipv4.HeaderLen {
+			continue
+		}
+		headerLen := int(buffer[0]&0x0f) << 2
+		if headerLen > n {
+			continue
+		}
+		reply, err := icmp.ParseMessage(ipv4.ICMPTypeEchoReply.Protocol(), buffer[headerLen:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.ID == p.id && echo.Seq == seq {
+			return true
+		}
+	}
+
+	return false
+}
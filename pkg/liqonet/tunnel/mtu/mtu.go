@@ -0,0 +1,63 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtu
+
+import (
+	"fmt"
+	"net"
+)
+
+// MinPathMTU is the lower bound of the path MTU discovery (i.e., the minimum datagram size every IPv4 host must accept).
+const MinPathMTU = 576
+
+// Prober sends probes of a given size towards a remote address, with fragmentation disabled.
+type Prober interface {
+	// Probe returns whether a probe of the given size (including the IP header) successfully reached the given address.
+	Probe(ip net.IP, size int) (bool, error)
+}
+
+// Discover returns the path MTU towards the given address, through a binary search between the given bounds.
+// An error is returned in case not even a probe of the minimum size reached the destination.
+func Discover(prober Prober, ip net.IP, lower, upper int) (int, error) {
+	if lower > upper {
+		return 0, fmt.Errorf("invalid bounds for the path MTU discovery: %d > %d", lower, upper)
+	}
+
+	// The upper bound is checked first, as the path MTU is commonly not a limiting factor.
+	if ok, err := prober.Probe(ip, upper); err != nil || ok {
+		return upper, err
+	}
+	if ok, err := prober.Probe(ip, lower); err != nil || !ok {
+		if err == nil {
+			err = fmt.Errorf("destination %v unreachable with probes of %d bytes", ip, lower)
+		}
+		return 0, err
+	}
+
+	// Invariant: a probe of size lower succeeded, while a probe of size upper failed.
+	for upper-lower > 1 {
+		middle := lower + (upper-lower)/2
+		ok, err := prober.Probe(ip, middle)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lower = middle
+		} else {
+			upper = middle
+		}
+	}
+	return lower, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtu
+
+import (
+	"errors"
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeProber simulates a path with the given MTU, recording the size of the probes.
+type fakeProber struct {
+	pathMTU int
+	err     error
+	probes  []int
+}
+
+func (fp *fakeProber) Probe(_ net.IP, size int) (bool, error) {
+	fp.probes = append(fp.probes, size)
+	return size <= fp.pathMTU, fp.err
+}
+
+var _ = Describe("Path MTU discovery", func() {
+	var (
+		prober *fakeProber
+		ip     = net.ParseIP("10.0.0.1")
+	)
+
+	BeforeEach(func() { prober = &fakeProber{} })
+
+	DescribeTable("the Discover function",
+		func(pathMTU, expected int) {
+			prober.pathMTU = pathMTU
+			Expect(Discover(prober, ip, MinPathMTU, 1500)).To(Equal(expected))
+		},
+		Entry("when the path MTU is greater than the upper bound", 9000, 1500),
+		Entry("when the path MTU equals the upper bound", 1500, 1500),
+		Entry("when the path MTU is lower than the upper bound", 1420, 1420),
+		Entry("when the path MTU equals the lower bound", MinPathMTU, MinPathMTU),
+		Entry("when the path MTU is odd", 1397, 1397),
+	)
+
+	It("should probe the upper bound only, if not limiting", func() {
+		prober.pathMTU = 1500
+		Expect(Discover(prober, ip, MinPathMTU, 1500)).To(Equal(1500))
+		Expect(prober.probes).To(Equal([]int{1500}))
+	})
+
+	It("should converge in a logarithmic number of probes", func() {
+		prober.pathMTU = 1000
+		Expect(Discover(prober, ip, MinPathMTU, 1500)).To(Equal(1000))
+		Expect(len(prober.probes)).To(BeNumerically("<=", 12))
+	})
+
+	It("should return an error if the destination is unreachable", func() {
+		prober.pathMTU = 0
+		_, err := Discover(prober, ip, MinPathMTU, 1500)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error if the probe fails", func() {
+		prober.pathMTU, prober.err = 1000, errors.New("failure")
+		_, err := Discover(prober, ip, MinPathMTU, 1500)
+		Expect(err).To(MatchError(prober.err))
+	})
+
+	It("should return an error if the bounds are invalid", func() {
+		_, err := Discover(prober, ip, 1500, MinPathMTU)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,66 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+type packetKind byte
+
+const (
+	// request identifies the probes sent to the remote gateways.
+	request packetKind = iota + 1
+	// reply identifies the replies to the probes, sent back by the remote gateways.
+	reply
+
+	// headerLength is the length of the packet header, consisting of the kind and the sequence number.
+	headerLength = 1 + 8
+	// maxPacketLength is the maximum length of a packet, including the identifier of the probed cluster (echoed back verbatim).
+	maxPacketLength = 512
+)
+
+// packet represents a probe (or the corresponding reply) exchanged between two gateways.
+type packet struct {
+	kind      packetKind
+	sequence  uint64
+	clusterID string // the ID of the probed cluster, echoed back verbatim by the remote gateway
+}
+
+// marshal encodes the packet in its binary representation.
+func (p *packet) marshal() []byte {
+	buffer := make([]byte, headerLength+len(p.clusterID))
+	buffer[0] = byte(p.kind)
+	binary.BigEndian.PutUint64(buffer[1:headerLength], p.sequence)
+	copy(buffer[headerLength:], p.clusterID)
+	return buffer
+}
+
+// unmarshal decodes the packet from its binary representation.
+func (p *packet) unmarshal(buffer []byte) error {
+	if len(buffer) < headerLength {
+		return errors.New("packet too short")
+	}
+
+	p.kind = packetKind(buffer[0])
+	if p.kind != request && p.kind != reply {
+		return errors.New("unknown packet kind")
+	}
+
+	p.sequence = binary.BigEndian.Uint64(buffer[1:headerLength])
+	p.clusterID = string(buffer[headerLength:])
+	return nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+)
+
+// DefaultWindow is the default number of probes considered to compute the packet loss ratio.
+const DefaultWindow = 20
+
+// metricsLabels are the labels associated with the metrics exposed by the prober.
+var metricsLabels = []string{"cluster_id", "cluster_name"}
+
+// Prober periodically measures the round-trip time and the packet loss towards the gateways of the remote clusters,
+// sending UDP probes through the VPN tunnels. At the same time, it replies to the probes received from the remote gateways.
+type Prober struct {
+	conn     net.PacketConn
+	address  net.IP
+	port     int
+	interval time.Duration
+	window   int
+
+	mutex sync.Mutex
+	peers map[string]*peer
+
+	rtt  *prometheus.HistogramVec
+	loss *prometheus.GaugeVec
+}
+
+// peer holds the probing status concerning a given remote cluster.
+type peer struct {
+	identity discoveryv1alpha1.ClusterIdentity
+	address  *net.UDPAddr
+
+	sequence uint64
+	pending  map[uint64]time.Time
+	// outcomes tracks whether the most recent probes have been lost, up to the window size.
+	outcomes []bool
+
+	latency   time.Duration
+	timestamp time.Time
+}
+
+// New returns a new Prober, which leverages the given connection to send and receive the probes, and targets the
+// given port of the remote gateways. The given address is the one to be assigned to the local tunnel interfaces,
+// to source the probes. Probes are sent with the given interval, and considered lost if no reply is received
+// before the subsequent one is sent.
+func New(conn net.PacketConn, address net.IP, port int, interval time.Duration) *Prober {
+	return &Prober{
+		conn:     conn,
+		address:  address,
+		port:     port,
+		interval: interval,
+		window:   DefaultWindow,
+		peers:    make(map[string]*peer),
+
+		rtt: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "liqo_peer_rtt_seconds",
+			Help:    "Round-trip time of the probes towards the gateway of a given peer.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, metricsLabels),
+		loss: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "liqo_peer_packet_loss_ratio",
+			Help: "Ratio of the probes lost towards the gateway of a given peer, computed over the most recent ones.",
+		}, metricsLabels),
+	}
+}
+
+// Address returns the IP address to be assigned to the local tunnel interfaces, to source the probes.
+func (p *Prober) Address() net.IP {
+	return p.address
+}
+
+// AddPeer starts probing the gateway of the given remote cluster, reachable at the given IP address.
+// It is a no-op in case the given cluster is already probed at the same address.
+func (p *Prober) AddPeer(identity discoveryv1alpha1.ClusterIdentity, ip net.IP) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	address := &net.UDPAddr{IP: ip, Port: p.port}
+	if existing, found := p.peers[identity.ClusterID]; found && existing.address.String() == address.String() {
+		return
+	}
+
+	klog.Infof("%s -> starting to probe the remote gateway at %s", identity, address)
+	p.peers[identity.ClusterID] = &peer{identity: identity, address: address, pending: make(map[uint64]time.Time)}
+}
+
+// RemovePeer stops probing the gateway of the given remote cluster.
+func (p *Prober) RemovePeer(identity discoveryv1alpha1.ClusterIdentity) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, found := p.peers[identity.ClusterID]; !found {
+		return
+	}
+
+	klog.Infof("%s -> stopping to probe the remote gateway", identity)
+	delete(p.peers, identity.ClusterID)
+	p.rtt.DeleteLabelValues(identity.ClusterID, identity.ClusterName)
+	p.loss.DeleteLabelValues(identity.ClusterID, identity.ClusterName)
+}
+
+// Latency returns the most recent round-trip time measured towards the gateway of the given remote cluster,
+// along with the time it has been measured. The last return value is false in case no measure is available.
+func (p *Prober) Latency(clusterID string) (latency time.Duration, timestamp time.Time, found bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if peer, ok := p.peers[clusterID]; ok && !peer.timestamp.IsZero() {
+		return peer.latency, peer.timestamp, true
+	}
+	return 0, time.Time{}, false
+}
+
+// Start starts the prober, until the given context is canceled. It implements the manager.Runnable interface.
+func (p *Prober) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		if err := p.conn.Close(); err != nil {
+			klog.Errorf("Failed to close the prober connection: %v", err)
+		}
+	}()
+
+	go p.send(ctx)
+	return p.receive()
+}
+
+// send periodically sends the probes to the remote gateways, until the given context is canceled.
+func (p *Prober) send(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.probe()
+		}
+	}
+}
+
+// probe accounts for the probes not yet replied as lost, and sends a new probe to each remote gateway.
+func (p *Prober) probe() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, peer := range p.peers {
+		for sequence := range peer.pending {
+			delete(peer.pending, sequence)
+			p.record(peer, true)
+		}
+
+		peer.sequence++
+		pkt := packet{kind: request, sequence: peer.sequence, clusterID: peer.identity.ClusterID}
+		if _, err := p.conn.WriteTo(pkt.marshal(), peer.address); err != nil {
+			klog.V(4).Infof("%s -> failed to send probe to %s: %v", peer.identity, peer.address, err)
+		}
+		peer.pending[peer.sequence] = time.Now()
+	}
+}
+
+// receive handles the incoming packets, replying to the probes and processing the replies.
+func (p *Prober) receive() error {
+	buffer := make([]byte, maxPacketLength)
+	for {
+		n, address, err := p.conn.ReadFrom(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var pkt packet
+		if err := pkt.unmarshal(buffer[:n]); err != nil {
+			klog.V(4).Infof("Received invalid packet from %s: %v", address, err)
+			continue
+		}
+
+		switch pkt.kind {
+		case request:
+			pkt.kind = reply
+			if _, err := p.conn.WriteTo(pkt.marshal(), address); err != nil {
+				klog.V(4).Infof("Failed to reply to probe from %s: %v", address, err)
+			}
+		case reply:
+			p.handleReply(&pkt, time.Now())
+		}
+	}
+}
+
+// handleReply processes a reply received from a remote gateway.
+func (p *Prober) handleReply(pkt *packet, now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	peer, found := p.peers[pkt.clusterID]
+	if !found {
+		return
+	}
+
+	sent, found := peer.pending[pkt.sequence]
+	if !found {
+		// The reply is either duplicated or received too late, and the probe has already been accounted as lost.
+		return
+	}
+
+	delete(peer.pending, pkt.sequence)
+	peer.latency, peer.timestamp = now.Sub(sent), now
+	p.rtt.WithLabelValues(peer.identity.ClusterID, peer.identity.ClusterName).Observe(peer.latency.Seconds())
+	p.record(peer, false)
+}
+
+// record tracks the outcome of a probe, updating the packet loss ratio.
+func (p *Prober) record(peer *peer, lost bool) {
+	peer.outcomes = append(peer.outcomes, lost)
+	if len(peer.outcomes) > p.window {
+		peer.outcomes = peer.outcomes[len(peer.outcomes)-p.window:]
+	}
+
+	var losses int
+	for _, outcome := range peer.outcomes {
+		if outcome {
+			losses++
+		}
+	}
+	p.loss.WithLabelValues(peer.identity.ClusterID, peer.identity.ClusterName).Set(float64(losses) / float64(len(peer.outcomes)))
+}
+
+// Describe implements prometheus.Collector.
+func (p *Prober) Describe(ch chan<- *prometheus.Desc) {
+	p.rtt.Describe(ch)
+	p.loss.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (p *Prober) Collect(ch chan<- prometheus.Metric) {
+	p.rtt.Collect(ch)
+	p.loss.Collect(ch)
+}
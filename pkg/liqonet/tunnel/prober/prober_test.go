@@ -0,0 +1,119 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+)
+
+var _ = Describe("Packet", func() {
+	It("should correctly marshal and unmarshal packets", func() {
+		original := packet{kind: request, sequence: 42, clusterID: "remote-cluster-id"}
+
+		var decoded packet
+		Expect(decoded.unmarshal(original.marshal())).To(Succeed())
+		Expect(decoded).To(Equal(original))
+	})
+
+	DescribeTable("should reject invalid packets",
+		func(buffer []byte) {
+			var decoded packet
+			Expect(decoded.unmarshal(buffer)).ToNot(Succeed())
+		},
+		Entry("too short", []byte{byte(request), 0, 0}),
+		Entry("unknown kind", []byte{0xff, 0, 0, 0, 0, 0, 0, 0, 1}),
+	)
+})
+
+var _ = Describe("Prober", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		remote   discoveryv1alpha1.ClusterIdentity
+		prober   *Prober
+		listener net.PacketConn
+	)
+
+	listen := func() net.PacketConn {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		return conn
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		remote = discoveryv1alpha1.ClusterIdentity{ClusterID: "remote-cluster-id", ClusterName: "remote-cluster-name"}
+		listener = listen()
+	})
+
+	JustBeforeEach(func() {
+		prober = New(listen(), net.IPv4(169, 254, 1, 1), listener.LocalAddr().(*net.UDPAddr).Port, 10*time.Millisecond)
+		prober.AddPeer(remote, net.ParseIP("127.0.0.1"))
+		go func() { defer GinkgoRecover(); Expect(prober.Start(ctx)).To(Succeed()) }()
+	})
+
+	AfterEach(func() { cancel() })
+
+	When("the remote gateway replies to the probes", func() {
+		BeforeEach(func() {
+			responder := New(listener, net.IPv4(169, 254, 1, 2), 0, time.Hour)
+			go func() { defer GinkgoRecover(); Expect(responder.Start(ctx)).To(Succeed()) }()
+		})
+
+		It("should measure the latency", func() {
+			Eventually(func() bool { _, _, found := prober.Latency(remote.ClusterID); return found }).Should(BeTrue())
+			latency, timestamp, _ := prober.Latency(remote.ClusterID)
+			Expect(latency).To(BeNumerically(">", 0))
+			Expect(timestamp).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		It("should export the metrics", func() {
+			Eventually(func() int { return testutil.CollectAndCount(prober, "liqo_peer_rtt_seconds") }).Should(Equal(1))
+			Eventually(func() int { return testutil.CollectAndCount(prober, "liqo_peer_packet_loss_ratio") }).Should(Equal(1))
+			Consistently(func() float64 {
+				return testutil.ToFloat64(prober.loss.WithLabelValues(remote.ClusterID, remote.ClusterName))
+			}).Should(BeZero())
+		})
+
+		It("should stop probing once the peer is removed", func() {
+			Eventually(func() bool { _, _, found := prober.Latency(remote.ClusterID); return found }).Should(BeTrue())
+			prober.RemovePeer(remote)
+			_, _, found := prober.Latency(remote.ClusterID)
+			Expect(found).To(BeFalse())
+			Expect(testutil.CollectAndCount(prober)).To(BeZero())
+		})
+	})
+
+	When("the remote gateway does not reply to the probes", func() {
+		It("should not measure the latency", func() {
+			Consistently(func() bool { _, _, found := prober.Latency(remote.ClusterID); return found }).Should(BeFalse())
+		})
+
+		It("should account the probes as lost", func() {
+			Eventually(func() float64 {
+				return testutil.ToFloat64(prober.loss.WithLabelValues(remote.ClusterID, remote.ClusterName))
+			}).Should(Equal(1.))
+		})
+	})
+})
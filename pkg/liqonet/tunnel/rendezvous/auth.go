@@ -0,0 +1,121 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rendezvous
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// helloMagic prefixes the hello datagrams, distinguishing them from the WireGuard messages (whose first byte is the type).
+var helloMagic = []byte("liqo-rendezvous-hello")
+
+// helloSize is the size of the hello datagrams, composed of the magic prefix, the timestamp and the tag.
+var helloSize = len(helloMagic) + 8 + sha256.Size
+
+// sharedSecret returns the X25519 shared secret between the given keys.
+func sharedSecret(private, public wgtypes.Key) ([]byte, error) {
+	secret, err := curve25519.X25519(private[:], public[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the shared secret: %w", err)
+	}
+	return secret, nil
+}
+
+// sign returns the signature of the given join request, keyed with the X25519 shared secret between the given keys.
+func sign(sessionID, member string, req *JoinRequest, private, public wgtypes.Key) (string, error) {
+	secret, err := sharedSecret(private, public)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{sessionID, member, req.PublicKey, req.PeerPublicKey, strconv.FormatInt(req.Timestamp, 10)}, "\n")))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify checks whether the signature of the given join request is valid, given the private key of the server.
+func verify(sessionID, member string, req *JoinRequest, private wgtypes.Key) error {
+	public, err := wgtypes.ParseKey(req.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if _, err := wgtypes.ParseKey(req.PeerPublicKey); err != nil {
+		return fmt.Errorf("invalid peer public key: %w", err)
+	}
+
+	expected, err := sign(sessionID, member, req, private, public)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// sealHello returns the hello datagram sent by a member through its relay port, tagged with the X25519 shared secret
+// between the given keys, to bind the relay port to the address the traffic of the member is actually originated from.
+func sealHello(sessionID, member string, timestamp int64, private, public wgtypes.Key) ([]byte, error) {
+	secret, err := sharedSecret(private, public)
+	if err != nil {
+		return nil, err
+	}
+
+	datagram := make([]byte, len(helloMagic)+8, helloSize)
+	copy(datagram, helloMagic)
+	binary.BigEndian.PutUint64(datagram[len(helloMagic):], uint64(timestamp))
+	return append(datagram, helloTag(sessionID, member, datagram, secret)...), nil
+}
+
+// isHello returns whether the given datagram is a hello one.
+func isHello(datagram []byte) bool {
+	return len(datagram) == helloSize && bytes.HasPrefix(datagram, helloMagic)
+}
+
+// openHello verifies the tag of the given hello datagram, and returns the timestamp (in nanoseconds) it has been sent at.
+func openHello(sessionID, member string, datagram, secret []byte) (int64, error) {
+	if !isHello(datagram) {
+		return 0, fmt.Errorf("malformed hello datagram")
+	}
+
+	payload := datagram[:len(helloMagic)+8]
+	if !hmac.Equal(helloTag(sessionID, member, payload, secret), datagram[len(payload):]) {
+		return 0, fmt.Errorf("invalid tag")
+	}
+
+	timestamp := int64(binary.BigEndian.Uint64(payload[len(helloMagic):]))
+	if skew := time.Since(time.Unix(0, timestamp)); skew > maxClockSkew || skew < -maxClockSkew {
+		return 0, fmt.Errorf("timestamp outside of the allowed window")
+	}
+	return timestamp, nil
+}
+
+func helloTag(sessionID, member string, payload, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sessionID + "\n" + member + "\n"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,130 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rendezvous
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/klog/v2"
+)
+
+// clientTimeout is the timeout of the requests towards the rendezvous server.
+const clientTimeout = 5 * time.Second
+
+// Client interacts with a rendezvous server.
+type Client struct {
+	address    string
+	httpClient *http.Client
+}
+
+// NewClient returns a new client interacting with the rendezvous server at the given address (e.g., http://host:port).
+func NewClient(address string) *Client {
+	return &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		httpClient: &http.Client{Timeout: clientTimeout},
+	}
+}
+
+// Join joins the given session as the given member, and returns the corresponding allocation. The request is signed
+// with the given WireGuard private key, while peer is the WireGuard public key of the other member of the session.
+func (c *Client) Join(ctx context.Context, sessionID, member string, private, peer wgtypes.Key) (*Allocation, error) {
+	var serverKey ServerKey
+	if err := c.do(ctx, http.MethodGet, keyPath, nil, &serverKey); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the server key: %w", err)
+	}
+	public, err := wgtypes.ParseKey(serverKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server key: %w", err)
+	}
+
+	req := JoinRequest{PublicKey: private.PublicKey().String(), PeerPublicKey: peer.String(), Timestamp: time.Now().Unix()}
+	if req.Signature, err = sign(sessionID, member, &req, private, public); err != nil {
+		return nil, err
+	}
+
+	var allocation Allocation
+	path := sessionsPath + url.PathEscape(sessionID) + "/" + url.PathEscape(member)
+	if err := c.do(ctx, http.MethodPost, path, &req, &allocation); err != nil {
+		return nil, fmt.Errorf("failed to join session %q: %w", sessionID, err)
+	}
+
+	// The failure to send the hello datagram is not fatal, as the relay port falls back to the address the request originated from.
+	if err := c.hello(ctx, allocation.RelayEndpoint, sessionID, member, private, public); err != nil {
+		klog.Warningf("Failed to send the hello datagram to the relay port of session %q: %v", sessionID, err)
+	}
+	return &allocation, nil
+}
+
+// hello sends an authenticated hello datagram to the given relay endpoint, which binds the relay port to the address
+// the traffic is originated from, in case it differs from the one observed for the requests to join the session.
+func (c *Client) hello(ctx context.Context, relay, sessionID, member string, private, public wgtypes.Key) error {
+	datagram, err := sealHello(sessionID, member, time.Now().UnixNano(), private, public)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", relay)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(datagram)
+	return err
+}
+
+// do performs the given request towards the rendezvous server, decoding the response into the given object.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	body := io.Reader(http.NoBody)
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode the request: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.address+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create the request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact the rendezvous server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s (%s)", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the response: %w", err)
+	}
+	return nil
+}
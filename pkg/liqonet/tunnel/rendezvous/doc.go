@@ -0,0 +1,19 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rendezvous implements a self-hostable rendezvous service, which allows gateways with no publicly reachable
+// endpoint (e.g., both behind NAT) to establish the VPN tunnels. For each pair of peered clusters, the server allocates
+// a UDP relay port for each member, forwarding the traffic between the two, and reports the public endpoints it observes,
+// enabling the members to attempt a direct connection through hole punching.
+package rendezvous
@@ -0,0 +1,382 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rendezvous
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// keyPath is the path used to retrieve the public key of the server.
+	keyPath = "/v1/key"
+	// sessionsPath is the prefix of the path used to join the sessions, followed by <session>/<member>.
+	sessionsPath = "/v1/sessions/"
+	// maxClockSkew is the maximum difference between the timestamp of a join request and the current time.
+	maxClockSkew = time.Minute
+	// maxRequestSize is the maximum size of the body of a join request.
+	maxRequestSize = 4096
+	// maxMembers is the maximum number of members of a session.
+	maxMembers = 2
+	// bufferSize is the size of the buffer used to relay the datagrams.
+	bufferSize = 65535
+)
+
+// ErrUnauthorized is returned when a member fails to prove the ownership of the public key it joins a session with.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Server is the rendezvous server, which allocates the relay ports and forwards the traffic between the session members.
+type Server struct {
+	publicHost  string
+	idleTimeout time.Duration
+	key         wgtypes.Key
+
+	mutex    sync.Mutex
+	sessions map[string]*session
+}
+
+// session groups the relay legs of the members of a given session, keyed by member.
+type session struct {
+	legs map[string]*leg
+}
+
+// leg is the relay port allocated to a given member.
+type leg struct {
+	conn      *net.UDPConn
+	publicKey string
+	sessionID string
+	member    string
+	// secret is the X25519 shared secret between the server and the member, used to authenticate the hello datagrams.
+	secret []byte
+
+	mutex    sync.RWMutex
+	joined   net.IP
+	observed *net.UDPAddr
+	lastSeen time.Time
+	peer     *leg
+	// bound is true once the leg has been bound to the source address of an authenticated hello datagram.
+	bound     bool
+	lastHello int64
+	warned    bool
+}
+
+// NewServer returns a new rendezvous server, which advertises the relay ports through the given (publicly reachable) host,
+// and releases the sessions after the given period of inactivity.
+func NewServer(publicHost string, idleTimeout time.Duration) (*Server, error) {
+	key, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the server key: %w", err)
+	}
+
+	return &Server{
+		publicHost:  publicHost,
+		idleTimeout: idleTimeout,
+		key:         key,
+		sessions:    make(map[string]*session),
+	}, nil
+}
+
+// ServeHTTP handles the requests to retrieve the public key of the server,
+// and the ones to join a session, allocating the relay port if necessary.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == keyPath && r.Method == http.MethodGet {
+		s.encode(w, &ServerKey{PublicKey: s.key.PublicKey().String()})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, sessionsPath), "/")
+	if !strings.HasPrefix(r.URL.Path, sessionsPath) || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req JoinRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid remote address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	allocation, err := s.Join(parts[0], parts[1], &req, net.ParseIP(host))
+	if err != nil {
+		klog.Warningf("Member %q failed to join session %q: %v", parts[1], parts[0], err)
+		status := http.StatusConflict
+		if errors.Is(err, ErrUnauthorized) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	s.encode(w, allocation)
+}
+
+func (s *Server) encode(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		klog.Errorf("Failed to encode the response: %v", err)
+	}
+}
+
+// Join adds the given member to the session, allocating its relay port if not yet present,
+// and returns the corresponding allocation. The request shall be signed by the member, to prove the ownership of the
+// given public key, while the relay port only accepts the traffic originated from the given source address. Since the
+// latter might differ from the one the UDP traffic is originated from (e.g., in case of different egress NAT addresses),
+// the relay port is subsequently bound to the source address of the authenticated hello datagrams sent by the member.
+// The sessions are scoped by the pair of public keys of the members, hence preventing third parties from joining them.
+func (s *Server) Join(sessionID, member string, req *JoinRequest, source net.IP) (*Allocation, error) {
+	if skew := time.Since(time.Unix(req.Timestamp, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, fmt.Errorf("%w: request timestamp outside of the allowed window", ErrUnauthorized)
+	}
+	if err := verify(sessionID, member, req, s.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := []string{req.PublicKey, req.PeerPublicKey}
+	sort.Strings(keys)
+	scopedID := strings.Join(append([]string{sessionID}, keys...), "/")
+
+	sess, found := s.sessions[scopedID]
+	if !found {
+		sess = &session{legs: make(map[string]*leg)}
+		s.sessions[scopedID] = sess
+	}
+
+	current, found := sess.legs[member]
+	if found && current.publicKey != req.PublicKey {
+		return nil, fmt.Errorf("%w: member %q of session %q joined with a different key", ErrUnauthorized, member, sessionID)
+	}
+	if !found {
+		if len(sess.legs) >= maxMembers {
+			return nil, fmt.Errorf("session %q already has %d members", sessionID, maxMembers)
+		}
+		for name, other := range sess.legs {
+			if other.publicKey == req.PublicKey {
+				return nil, fmt.Errorf("%w: key already in use by member %q of session %q", ErrUnauthorized, name, sessionID)
+			}
+		}
+
+		public, err := wgtypes.ParseKey(req.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid public key: %v", ErrUnauthorized, err)
+		}
+		secret, err := sharedSecret(s.key, public)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate the relay port: %w", err)
+		}
+
+		current = &leg{conn: conn, publicKey: req.PublicKey, sessionID: sessionID, member: member, secret: secret, lastSeen: time.Now()}
+		for _, other := range sess.legs {
+			current.setPeer(other)
+			other.setPeer(current)
+		}
+		sess.legs[member] = current
+
+		klog.Infof("Allocated relay port %d to member %q of session %q", conn.LocalAddr().(*net.UDPAddr).Port, member, sessionID)
+		go current.relay()
+	}
+	current.pin(source)
+
+	allocation := &Allocation{
+		RelayEndpoint: net.JoinHostPort(s.publicHost, strconv.Itoa(current.conn.LocalAddr().(*net.UDPAddr).Port)),
+	}
+	if peer := current.getPeer(); peer != nil {
+		if observed, _ := peer.status(); observed != nil {
+			allocation.PeerEndpoint = observed.String()
+		}
+	}
+	return allocation, nil
+}
+
+// Sweep releases the sessions whose members did not send any traffic for longer than the idle timeout.
+func (s *Server) Sweep() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, sess := range s.sessions {
+		idle := true
+		for _, l := range sess.legs {
+			if _, lastSeen := l.status(); time.Since(lastSeen) < s.idleTimeout {
+				idle = false
+				break
+			}
+		}
+
+		if idle {
+			klog.Infof("Releasing idle session %q", id)
+			for _, l := range sess.legs {
+				l.close()
+			}
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Close releases all the sessions.
+func (s *Server) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, sess := range s.sessions {
+		for _, l := range sess.legs {
+			l.close()
+		}
+		delete(s.sessions, id)
+	}
+}
+
+// relay forwards the datagrams received on the relay port to the peer leg, recording the observed source endpoint.
+// The datagrams not originated from the address the member joined from are discarded, to prevent the hijacking of the leg.
+func (l *leg) relay() {
+	buffer := make([]byte, bufferSize)
+	for {
+		n, src, err := l.conn.ReadFromUDP(buffer)
+		if err != nil {
+			// The relay port has been closed.
+			return
+		}
+
+		if isHello(buffer[:n]) {
+			l.hello(buffer[:n], src)
+			continue
+		}
+
+		l.mutex.Lock()
+		if !src.IP.Equal(l.joined) {
+			warn := !l.warned
+			l.warned = true
+			joined := l.joined
+			l.mutex.Unlock()
+
+			if warn {
+				klog.Warningf("Discarding datagrams from %v, not matching the address %v member %q of session %q joined from",
+					src, joined, l.member, l.sessionID)
+			} else {
+				klog.V(4).Infof("Discarding datagram from %v, not matching the joined address %v", src, joined)
+			}
+			continue
+		}
+		l.observed, l.lastSeen = src, time.Now()
+		peer := l.peer
+		l.mutex.Unlock()
+
+		if peer == nil {
+			continue
+		}
+
+		// The datagram is forwarded from the relay port of the peer, which is the one the peer is sending traffic to.
+		if dst, _ := peer.status(); dst != nil {
+			if _, err := peer.conn.WriteToUDP(buffer[:n], dst); err != nil {
+				klog.V(4).Infof("Failed to relay datagram to %v: %v", dst, err)
+			}
+		}
+	}
+}
+
+func (l *leg) setPeer(peer *leg) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.peer = peer
+}
+
+// hello processes the given hello datagram, binding the leg to its source address if authenticated. The timestamps of the
+// hello datagrams shall be increasing, to prevent them from being replayed from different addresses.
+func (l *leg) hello(datagram []byte, src *net.UDPAddr) {
+	timestamp, err := openHello(l.sessionID, l.member, datagram, l.secret)
+	if err != nil {
+		klog.Warningf("Discarding hello datagram from %v for member %q of session %q: %v", src, l.member, l.sessionID, err)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if timestamp <= l.lastHello {
+		klog.V(4).Infof("Discarding replayed hello datagram from %v for member %q of session %q", src, l.member, l.sessionID)
+		return
+	}
+
+	if !src.IP.Equal(l.joined) {
+		klog.Warningf("Member %q of session %q joined from %v, but its traffic is originated from %v: binding the relay port to the latter",
+			l.member, l.sessionID, l.joined, src.IP)
+		l.joined, l.observed = src.IP, nil
+	}
+	l.lastHello, l.bound, l.warned = timestamp, true, false
+}
+
+// pin configures the address the member joined from, discarding the observed endpoint if the address changed.
+// The address is preserved if the leg has already been bound through a hello datagram.
+func (l *leg) pin(source net.IP) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.bound {
+		if !source.Equal(l.joined) {
+			klog.V(4).Infof("Member %q of session %q joined from %v, preserving the address %v bound through the hello datagrams",
+				l.member, l.sessionID, source, l.joined)
+		}
+		return
+	}
+	if !source.Equal(l.joined) {
+		l.joined, l.observed, l.warned = source, nil, false
+	}
+}
+
+func (l *leg) getPeer() *leg {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.peer
+}
+
+// status returns the observed source endpoint of the member, and the last time some traffic has been received.
+func (l *leg) status() (observed *net.UDPAddr, lastSeen time.Time) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.observed, l.lastSeen
+}
+
+func (l *leg) close() {
+	if err := l.conn.Close(); err != nil {
+		klog.Warningf("Failed to close relay port: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rendezvous
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var _ = Describe("Rendezvous", func() {
+	const session = "session"
+
+	var (
+		ctx        context.Context
+		server     *Server
+		httpServer *httptest.Server
+		client     *Client
+		memberA    *net.UDPConn
+		memberB    *net.UDPConn
+		keyA       wgtypes.Key
+		keyB       wgtypes.Key
+	)
+
+	generate := func() wgtypes.Key {
+		key, err := wgtypes.GeneratePrivateKey()
+		Expect(err).ToNot(HaveOccurred())
+		return key
+	}
+
+	must := func(allocation *Allocation, err error) *Allocation {
+		Expect(err).ToNot(HaveOccurred())
+		return allocation
+	}
+
+	joinA := func() (*Allocation, error) { return client.Join(ctx, session, "a", keyA, keyB.PublicKey()) }
+	joinB := func() (*Allocation, error) { return client.Join(ctx, session, "b", keyB, keyA.PublicKey()) }
+
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		return conn
+	}
+
+	resolve := func(endpoint string) *net.UDPAddr {
+		addr, err := net.ResolveUDPAddr("udp", endpoint)
+		Expect(err).ToNot(HaveOccurred())
+		return addr
+	}
+
+	receive := func(conn *net.UDPConn) string {
+		buffer := make([]byte, 64)
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, _, err := conn.ReadFromUDP(buffer)
+		Expect(err).ToNot(HaveOccurred())
+		return string(buffer[:n])
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		server, err = NewServer("127.0.0.1", time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		httpServer = httptest.NewServer(server)
+		client = NewClient(httpServer.URL)
+		memberA, memberB = listen(), listen()
+		keyA, keyB = generate(), generate()
+	})
+
+	AfterEach(func() {
+		httpServer.Close()
+		server.Close()
+		Expect(memberA.Close()).To(Succeed())
+		Expect(memberB.Close()).To(Succeed())
+	})
+
+	It("should relay the traffic between the members of a session, and report the observed endpoints", func() {
+		allocationA, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocationA.PeerEndpoint).To(BeEmpty())
+		allocationB, err := joinB()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocationB.RelayEndpoint).ToNot(Equal(allocationA.RelayEndpoint))
+
+		// The first datagram cannot be forwarded, as the destination endpoint is not yet known.
+		_, err = memberB.WriteToUDP([]byte("hello"), resolve(allocationB.RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() string {
+			allocation, err := joinA()
+			Expect(err).ToNot(HaveOccurred())
+			return allocation.PeerEndpoint
+		}).Should(Equal(memberB.LocalAddr().String()))
+
+		_, err = memberA.WriteToUDP([]byte("ping"), resolve(allocationA.RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(receive(memberB)).To(Equal("ping"))
+
+		_, err = memberB.WriteToUDP([]byte("pong"), resolve(allocationB.RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(receive(memberA)).To(Equal("pong"))
+
+		allocationB, err = joinB()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocationB.PeerEndpoint).To(Equal(memberA.LocalAddr().String()))
+	})
+
+	It("should return the same allocation when joining twice", func() {
+		first, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		second, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second.RelayEndpoint).To(Equal(first.RelayEndpoint))
+	})
+
+	It("should reject additional members", func() {
+		_, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = joinB()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.Join(ctx, session, "c", keyB, keyA.PublicKey())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject the members joining with a different key", func() {
+		_, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.Join(ctx, session, "a", keyB, keyA.PublicKey())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should isolate the third parties not owning the keys of the members", func() {
+		_, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = memberA.WriteToUDP([]byte("hello"), resolve(must(joinA()).RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() string { return must(joinB()).PeerEndpoint }).Should(Equal(memberA.LocalAddr().String()))
+
+		attacker, err := client.Join(ctx, session, "b", generate(), keyA.PublicKey())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(attacker.PeerEndpoint).To(BeEmpty())
+		Expect(attacker.RelayEndpoint).ToNot(Equal(must(joinB()).RelayEndpoint))
+	})
+
+	It("should reject the requests with an invalid signature", func() {
+		req := &JoinRequest{PublicKey: keyA.PublicKey().String(), PeerPublicKey: keyB.PublicKey().String(), Timestamp: time.Now().Unix()}
+		req.Signature = "invalid"
+		_, err := server.Join(session, "a", req, net.IPv4(127, 0, 0, 1))
+		Expect(err).To(MatchError(ErrUnauthorized))
+	})
+
+	It("should reject the requests with a stale timestamp", func() {
+		req := &JoinRequest{PublicKey: keyA.PublicKey().String(), PeerPublicKey: keyB.PublicKey().String(), Timestamp: time.Now().Add(-time.Hour).Unix()}
+		var err error
+		req.Signature, err = sign(session, "a", req, keyA, server.key.PublicKey())
+		Expect(err).ToNot(HaveOccurred())
+		_, err = server.Join(session, "a", req, net.IPv4(127, 0, 0, 1))
+		Expect(err).To(MatchError(ErrUnauthorized))
+	})
+
+	It("should discard the traffic not originated from the address the member joined from", func() {
+		allocationA, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+		allocationB, err := joinB()
+		Expect(err).ToNot(HaveOccurred())
+
+		other, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2)})
+		Expect(err).ToNot(HaveOccurred())
+		defer other.Close()
+
+		_, err = memberB.WriteToUDP([]byte("hello"), resolve(allocationB.RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() string { return must(joinA()).PeerEndpoint }).Should(Equal(memberB.LocalAddr().String()))
+
+		_, err = other.WriteToUDP([]byte("hijack"), resolve(allocationB.RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Consistently(func() string { return must(joinA()).PeerEndpoint }, 200*time.Millisecond).Should(Equal(memberB.LocalAddr().String()))
+
+		_, err = memberA.WriteToUDP([]byte("ping"), resolve(allocationA.RelayEndpoint))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(receive(memberB)).To(Equal("ping"))
+	})
+
+	When("the member joined from an address different from the one its traffic is originated from", func() {
+		var (
+			relayA *net.UDPAddr
+			hello  []byte
+		)
+
+		BeforeEach(func() {
+			req := &JoinRequest{PublicKey: keyA.PublicKey().String(), PeerPublicKey: keyB.PublicKey().String(), Timestamp: time.Now().Unix()}
+			var err error
+			req.Signature, err = sign(session, "a", req, keyA, server.key.PublicKey())
+			Expect(err).ToNot(HaveOccurred())
+			relayA = resolve(must(server.Join(session, "a", req, net.IPv4(127, 0, 0, 2))).RelayEndpoint)
+			_, err = joinB()
+			Expect(err).ToNot(HaveOccurred())
+
+			hello, err = sealHello(session, "a", time.Now().UnixNano(), keyA, server.key.PublicKey())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should bind the relay port to the source address of the authenticated hello datagram", func() {
+			_, err := memberA.WriteToUDP(hello, relayA)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				_, err := memberA.WriteToUDP([]byte("hello"), relayA)
+				Expect(err).ToNot(HaveOccurred())
+				return must(joinB()).PeerEndpoint
+			}).Should(Equal(memberA.LocalAddr().String()))
+		})
+
+		It("should discard the hello datagrams not tagged with the key of the member", func() {
+			hello, err := sealHello(session, "a", time.Now().UnixNano(), keyB, server.key.PublicKey())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = memberA.WriteToUDP(hello, relayA)
+			Expect(err).ToNot(HaveOccurred())
+
+			Consistently(func() string {
+				_, err := memberA.WriteToUDP([]byte("hello"), relayA)
+				Expect(err).ToNot(HaveOccurred())
+				return must(joinB()).PeerEndpoint
+			}, 200*time.Millisecond).Should(BeEmpty())
+		})
+
+		It("should discard the replayed hello datagrams", func() {
+			other, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 3)})
+			Expect(err).ToNot(HaveOccurred())
+			defer other.Close()
+
+			_, err = memberA.WriteToUDP(hello, relayA)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = memberA.WriteToUDP([]byte("hello"), relayA)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(func() string { return must(joinB()).PeerEndpoint }).Should(Equal(memberA.LocalAddr().String()))
+
+			_, err = other.WriteToUDP(hello, relayA)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = other.WriteToUDP([]byte("hijack"), relayA)
+			Expect(err).ToNot(HaveOccurred())
+			Consistently(func() string { return must(joinB()).PeerEndpoint }, 200*time.Millisecond).Should(Equal(memberA.LocalAddr().String()))
+		})
+	})
+
+	It("should release the idle sessions", func() {
+		_, err := joinA()
+		Expect(err).ToNot(HaveOccurred())
+
+		server.idleTimeout = 0
+		server.Sweep()
+		Expect(server.sessions).To(BeEmpty())
+	})
+})
@@ -0,0 +1,44 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rendezvous
+
+// ServerKey is the response returned by the rendezvous server when retrieving its public key.
+type ServerKey struct {
+	// PublicKey is the X25519 public key of the server, leveraged by the members to sign the requests to join the sessions.
+	PublicKey string `json:"publicKey"`
+}
+
+// JoinRequest is the request to join a session, authenticated through the WireGuard keys of the members.
+type JoinRequest struct {
+	// PublicKey is the WireGuard public key of the joining member.
+	PublicKey string `json:"publicKey"`
+	// PeerPublicKey is the WireGuard public key of the other member of the session.
+	PeerPublicKey string `json:"peerPublicKey"`
+	// Timestamp is the time the request has been signed at, in seconds since the epoch.
+	Timestamp int64 `json:"timestamp"`
+	// Signature is the HMAC-SHA256 of the request, keyed with the X25519 shared secret between the WireGuard private key
+	// of the joining member and the public key of the server, hence proving the ownership of the given public key.
+	Signature string `json:"signature"`
+}
+
+// Allocation is the response returned by the rendezvous server when a member joins a session.
+type Allocation struct {
+	// RelayEndpoint is the endpoint (i.e., host:port) of the relay port allocated to the member, which forwards
+	// the traffic to the other member of the session.
+	RelayEndpoint string `json:"relayEndpoint"`
+	// PeerEndpoint is the public endpoint of the other member of the session, as observed by the relay.
+	// It is empty until the other member sends any traffic through its relay port.
+	PeerEndpoint string `json:"peerEndpoint,omitempty"`
+}
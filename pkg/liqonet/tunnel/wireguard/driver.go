@@ -15,20 +15,19 @@
 package wireguard
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/vishvananda/netlink"
-	"golang.org/x/sys/unix"
 	"golang.zx2c4.com/wireguard/wgctrl"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	corev1 "k8s.io/api/core/v1"
@@ -84,6 +83,8 @@ type ResolverFunc func(address string) (*net.IPAddr, error)
 // Wireguard a wrapper for the wireguard device and its configuration.
 type Wireguard struct {
 	metrics.Metrics
+	// mutex protects the fields below, accessed concurrently by the operator and the key rotation routines.
+	mutex sync.Mutex
 	// connections key is a clusterID.
 	connections map[string]*netv1alpha1.Connection
 	// connectedClusterIdentities key is the peer's public key.
@@ -91,6 +92,12 @@ type Wireguard struct {
 	client                     *wgctrl.Client
 	link                       netlink.Link
 	conf                       wgConfig
+	// userspace is the embedded userspace implementation, used only if the kernel module is not available.
+	userspace *userspaceDevice
+	// traversals key is a clusterID, for the connections established through a rendezvous server.
+	traversals map[string]*traversal
+	// failovers key is a clusterID, for the remote clusters advertising multiple endpoints.
+	failovers map[string]*failover
 }
 
 // NewDriver creates a new WireGuard driver.
@@ -99,6 +106,8 @@ func NewDriver(k8sClient k8s.Interface, namespace string, config tunnel.Config)
 	w := Wireguard{
 		connections:                make(map[string]*netv1alpha1.Connection),
 		connectedClusterIdentities: make(map[wgtypes.Key]*discv1alpha1.ClusterIdentity),
+		traversals:                 make(map[string]*traversal),
+		failovers:                  make(map[string]*failover),
 		conf: wgConfig{
 			port:     config.ListeningPort,
 			iFaceMTU: config.MTU,
@@ -156,6 +165,13 @@ func (w *Wireguard) Init() error {
 		return fmt.Errorf("failed to set MTU for interface %s: %w", liqoconst.DeviceName, err)
 	}
 
+	if w.userspace != nil {
+		// The userspace device is brought up explicitly, as not tracking the status of the interface.
+		if err := w.userspace.Up(); err != nil {
+			return fmt.Errorf("failed to bring up userspace WireGuard device: %w", err)
+		}
+	}
+
 	klog.Infof("%s interface named %s, is up on i/f number %d, listening on port :%d, with key %s", liqoconst.DriverName,
 		w.link.Attrs().Name, w.link.Attrs().Index, w.conf.port, w.conf.pubKey)
 	return nil
@@ -163,6 +179,9 @@ func (w *Wireguard) Init() error {
 
 // ConnectToEndpoint connects to a remote cluster described by the given tep.
 func (w *Wireguard) ConnectToEndpoint(tep *netv1alpha1.TunnelEndpoint) (*netv1alpha1.Connection, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
 	// parse allowed IPs.
 	allowedIPs, stringAllowedIPs, err := getAllowedIPs(tep)
 	if err != nil {
@@ -175,34 +194,58 @@ func (w *Wireguard) ConnectToEndpoint(tep *netv1alpha1.TunnelEndpoint) (*netv1al
 		return newConnectionOnError(err.Error()), err
 	}
 
+	// parse the next remote public key, in case a key rotation is in progress.
+	nextKey, err := getNextKey(tep)
+	if err != nil {
+		return newConnectionOnError(err.Error()), err
+	}
+
+	// the peer has already switched over to the next key, although the rotation is not yet completed.
+	oldCon, found := w.connections[tep.Spec.ClusterIdentity.ClusterID]
+	if found && nextKey != nil && nextKey.String() == oldCon.PeerConfiguration[liqoconst.PublicKey] {
+		remoteKey = nextKey
+	}
+
 	// parse remote endpoint.
-	endpoint, err := getEndpoint(tep, func(address string) (*net.IPAddr, error) {
-		return resolver.Resolve(context.TODO(), address)
-	})
+	endpoint, err := w.getEndpoint(tep, remoteKey)
 	if err != nil {
 		return newConnectionOnError(err.Error()), err
 	}
 
 	// delete or update old peers for ClusterID.
-	oldCon, found := w.connections[tep.Spec.ClusterIdentity.ClusterID]
 	if found {
+		// The session is torn down when probing a failover endpoint, so that a new handshake is performed through it.
+		reset := w.consumeFailoverReset(tep)
+		sameConfig := stringAllowedIPs == oldCon.PeerConfiguration[AllowedIPs] && remoteKey.String() == oldCon.PeerConfiguration[liqoconst.PublicKey] &&
+			!reset
+		sameEndpoint := endpoint.IP.String() == oldCon.PeerConfiguration[EndpointIP] &&
+			strconv.Itoa(endpoint.Port) == oldCon.PeerConfiguration[liqoconst.ListeningPort]
+
 		// check if the peer configuration is updated.
-		if stringAllowedIPs == oldCon.PeerConfiguration[AllowedIPs] && remoteKey.String() == oldCon.PeerConfiguration[liqoconst.PublicKey] &&
-			endpoint.IP.String() == oldCon.PeerConfiguration[EndpointIP] && strconv.Itoa(endpoint.Port) == oldCon.PeerConfiguration[liqoconst.ListeningPort] {
+		if sameConfig && sameEndpoint {
+			if nextKey != nil && *nextKey != *remoteKey {
+				return w.rotatePeerKey(tep, oldCon, remoteKey, nextKey, endpoint, allowedIPs)
+			}
 			// Update connection status.
 			return w.updateConnectionStatus(oldCon)
 		}
 
-		// If the configuration has changed then remove the peer.
+		// If the configuration has changed then remove the peers. The peers are preserved if only the endpoint changed
+		// (e.g., while traversing NAT), to avoid tearing down the current session.
 		klog.V(4).Infof("updating peer configuration for cluster %s", tep.Spec.ClusterIdentity)
-		err = w.client.ConfigureDevice(liqoconst.DeviceName, wgtypes.Config{
-			ReplacePeers: false,
-			Peers: []wgtypes.PeerConfig{{PublicKey: *remoteKey,
-				Remove: true,
-			}},
-		})
-		if err != nil {
-			return newConnectionOnError(err.Error()), fmt.Errorf("failed to configure peer with cluster %s: %w", tep.Spec.ClusterIdentity, err)
+		if !sameConfig {
+			var peers []wgtypes.PeerConfig
+			for _, key := range replacedPeerKeys(oldCon, *remoteKey) {
+				peers = append(peers, wgtypes.PeerConfig{PublicKey: key, Remove: true})
+				delete(w.connectedClusterIdentities, key)
+			}
+			err = w.client.ConfigureDevice(liqoconst.DeviceName, wgtypes.Config{
+				ReplacePeers: false,
+				Peers:        peers,
+			})
+			if err != nil {
+				return newConnectionOnError(err.Error()), fmt.Errorf("failed to configure peer with cluster %s: %w", tep.Spec.ClusterIdentity, err)
+			}
 		}
 	} else {
 		klog.V(4).Infof("Connecting cluster %s endpoint %s with publicKey %s",
@@ -246,6 +289,9 @@ func (w *Wireguard) ConnectToEndpoint(tep *netv1alpha1.TunnelEndpoint) (*netv1al
 
 // DisconnectFromEndpoint disconnects a remote cluster described by the given tep.
 func (w *Wireguard) DisconnectFromEndpoint(tep *netv1alpha1.TunnelEndpoint) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
 	klog.V(4).Infof("Removing connection with cluster %s", tep.Spec.ClusterIdentity)
 
 	s, found := tep.Status.Connection.PeerConfiguration[liqoconst.PublicKey]
@@ -265,6 +311,10 @@ func (w *Wireguard) DisconnectFromEndpoint(tep *netv1alpha1.TunnelEndpoint) erro
 			Remove:    true,
 		},
 	}
+	// remove also the peer configured with the next key, in case a key rotation is in progress.
+	if nextKey, err := getNextKey(tep); err == nil && nextKey != nil && *nextKey != key {
+		peerCfg = append(peerCfg, wgtypes.PeerConfig{PublicKey: *nextKey, Remove: true})
+	}
 	err = w.client.ConfigureDevice(liqoconst.DeviceName, wgtypes.Config{
 		ReplacePeers: false,
 		Peers:        peerCfg,
@@ -282,6 +332,8 @@ func (w *Wireguard) DisconnectFromEndpoint(tep *netv1alpha1.TunnelEndpoint) erro
 	}
 
 	delete(w.connections, tep.Spec.ClusterIdentity.ClusterID)
+	delete(w.traversals, tep.Spec.ClusterIdentity.ClusterID)
+	delete(w.failovers, tep.Spec.ClusterIdentity.ClusterID)
 
 	return nil
 }
@@ -293,6 +345,12 @@ func (w *Wireguard) GetLink() netlink.Link {
 
 // Close remove the wireguard device from the host.
 func (w *Wireguard) Close() error {
+	if w.userspace != nil {
+		// closing the userspace device also removes the TUN interface.
+		w.userspace.Close()
+		return nil
+	}
+
 	// it removes the wireguard interface.
 	var err error
 	if link, err := netlink.LinkByName(liqoconst.DeviceName); err == nil {
@@ -327,27 +385,20 @@ func (w *Wireguard) setWGLink() error {
 		LinkType:  "wireguard",
 	}
 
-	if err = netlink.LinkAdd(link); err != nil && !errors.Is(err, unix.EOPNOTSUPP) {
-		return fmt.Errorf("failed to add wireguard device '%s': %w", liqoconst.DeviceName, err)
-	}
-	if errors.Is(err, unix.EOPNOTSUPP) {
-		klog.Warningf("wireguard kernel module not present, falling back to the userspace implementation")
-
-		cmd := exec.Command("/usr/bin/boringtun", liqoconst.DeviceName, "--disable-drop-privileges", "true") //nolint:gosec //we leave it as it is
-		var stdout, stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-		err = cmd.Run()
-		if err != nil {
-			outStr, errStr := stdout.String(), stderr.String()
-			fmt.Printf("out:\n%s\nerr:\n%s\n", outStr, errStr)
-			return fmt.Errorf("failed to add wireguard devices '%s': %w", liqoconst.DeviceName, err)
+	if err = netlink.LinkAdd(link); err != nil {
+		if !isKernelModuleUnavailable(err) {
+			return fmt.Errorf("failed to add wireguard device '%s': %w", liqoconst.DeviceName, err)
 		}
-		if w.link, err = netlink.LinkByName(liqoconst.DeviceName); err != nil {
-			return fmt.Errorf("failed to get wireguard device '%s': %w", liqoconst.DeviceName, err)
+
+		klog.Warningf("wireguard kernel module not present, falling back to the userspace implementation")
+		if w.userspace, err = newUserspaceDevice(liqoconst.DeviceName, w.conf.iFaceMTU); err != nil {
+			return fmt.Errorf("failed to add userspace wireguard device '%s': %w", liqoconst.DeviceName, err)
 		}
 	}
-	w.link = link
+
+	if w.link, err = netlink.LinkByName(liqoconst.DeviceName); err != nil {
+		return fmt.Errorf("failed to get wireguard device '%s': %w", liqoconst.DeviceName, err)
+	}
 	return nil
 }
 
@@ -366,7 +417,24 @@ func getAllowedIPs(tep *netv1alpha1.TunnelEndpoint) ([]net.IPNet, string, error)
 	if err != nil {
 		return nil, "", fmt.Errorf("unable to parse externalCIDR %s for cluster %s: %w", remoteExternalCIDR, tep.Spec.ClusterIdentity, err)
 	}
-	return []net.IPNet{*podCIDR, *externalCIDR}, fmt.Sprintf("%s, %s", remotePodCIDR, remoteExternalCIDR), nil
+	allowedIPs := []net.IPNet{*podCIDR, *externalCIDR}
+	allowedIPsStr := []string{remotePodCIDR, remoteExternalCIDR}
+
+	_, remoteExportedCIDRs := liqonetutils.GetExportedCIDRS(tep)
+	for _, remoteExportedCIDR := range remoteExportedCIDRs {
+		_, exportedCIDR, err := net.ParseCIDR(remoteExportedCIDR)
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to parse exported CIDR %s for cluster %s: %w", remoteExportedCIDR, tep.Spec.ClusterIdentity, err)
+		}
+		allowedIPs = append(allowedIPs, *exportedCIDR)
+		allowedIPsStr = append(allowedIPsStr, remoteExportedCIDR)
+	}
+
+	// The link-local address of the remote gateway is additionally allowed, to exchange the latency probes.
+	probeIP := net.IPNet{IP: liqonetutils.GetTunnelProbeIP(tep.Spec.ClusterIdentity.ClusterID), Mask: net.CIDRMask(32, 32)}
+	allowedIPs = append(allowedIPs, probeIP)
+	allowedIPsStr = append(allowedIPsStr, probeIP.String())
+	return allowedIPs, strings.Join(allowedIPsStr, ", "), nil
 }
 
 func getKey(tep *netv1alpha1.TunnelEndpoint) (*wgtypes.Key, error) {
@@ -383,6 +451,36 @@ func getKey(tep *netv1alpha1.TunnelEndpoint) (*wgtypes.Key, error) {
 	return &key, nil
 }
 
+// getNextKey returns the next public key of the remote peer, in case a key rotation is in progress (nil otherwise).
+func getNextKey(tep *netv1alpha1.TunnelEndpoint) (*wgtypes.Key, error) {
+	s, found := tep.Spec.BackendConfig[liqoconst.NextPublicKey]
+	if !found || s == "" {
+		return nil, nil
+	}
+
+	key, err := wgtypes.ParseKey(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse next public key %s: %w", s, err)
+	}
+
+	return &key, nil
+}
+
+// getEndpoint returns the endpoint of the remote cluster described by the given tep, possibly leveraging the rendezvous server,
+// or selecting one among the prioritised ones advertised by the remote cluster.
+func (w *Wireguard) getEndpoint(tep *netv1alpha1.TunnelEndpoint, remoteKey *wgtypes.Key) (*net.UDPAddr, error) {
+	if tep.Spec.BackendConfig[liqoconst.RendezvousAddress] != "" {
+		return w.getTraversalEndpoint(tep, remoteKey)
+	}
+	if len(tep.Spec.FallbackEndpointIPs) > 0 {
+		return w.getFailoverEndpoint(tep, remoteKey)
+	}
+
+	return getEndpoint(tep, func(address string) (*net.IPAddr, error) {
+		return resolver.Resolve(context.TODO(), address)
+	})
+}
+
 func getEndpoint(tep *netv1alpha1.TunnelEndpoint, addrResolver ResolverFunc) (*net.UDPAddr, error) {
 	// Get tunnel port.
 	tunnelPort, err := getTunnelPortFromTep(tep)
@@ -495,6 +593,110 @@ func (w *Wireguard) SetNewClient() error {
 	return nil
 }
 
+// rotatePeerKey handles the rotation of the key of a remote peer. The next key is first configured as an additional
+// peer without allowed IPs, to accept the handshakes performed by the remote peer once switched over to the next key.
+// Then, as soon as a handshake completes, the peer with the next key is promoted, inheriting the allowed IPs and the
+// endpoint of the current one, which is removed only afterwards.
+func (w *Wireguard) rotatePeerKey(tep *netv1alpha1.TunnelEndpoint, oldCon *netv1alpha1.Connection, currentKey, nextKey *wgtypes.Key,
+	endpoint *net.UDPAddr, allowedIPs []net.IPNet) (*netv1alpha1.Connection, error) {
+	wgDev, err := w.client.Device(liqoconst.DeviceName)
+	if err != nil {
+		return newConnectionOnError(err.Error()), err
+	}
+
+	var staged, current *wgtypes.Peer
+	for i := range wgDev.Peers {
+		switch wgDev.Peers[i].PublicKey {
+		case *nextKey:
+			staged = &wgDev.Peers[i]
+		case *currentKey:
+			current = &wgDev.Peers[i]
+		}
+	}
+
+	ka := KeepAliveInterval
+	var peerCfg []wgtypes.PeerConfig
+	switch {
+	case staged == nil:
+		klog.Infof("%s -> key rotation in progress, configuring the next public key %s", tep.Spec.ClusterIdentity, nextKey)
+		peerCfg = []wgtypes.PeerConfig{{PublicKey: *nextKey, Endpoint: endpoint, PersistentKeepaliveInterval: &ka}}
+	case !staged.LastHandshakeTime.IsZero():
+		klog.Infof("%s -> key rotation in progress, the remote peer switched over to the next public key %s", tep.Spec.ClusterIdentity, nextKey)
+		// The peers are processed in order, hence the allowed IPs are moved to the next key before removing the current one.
+		allowedIPs, endpoint = promotedPeerConfig(current, staged, allowedIPs, endpoint)
+		peerCfg = []wgtypes.PeerConfig{
+			{PublicKey: *nextKey, Endpoint: endpoint, PersistentKeepaliveInterval: &ka, ReplaceAllowedIPs: true, AllowedIPs: allowedIPs},
+			{PublicKey: *currentKey, Remove: true},
+		}
+	default:
+		// Waiting for the remote peer to switch over to the next key.
+		return w.updateConnectionStatus(oldCon)
+	}
+
+	if err = w.client.ConfigureDevice(liqoconst.DeviceName, wgtypes.Config{ReplacePeers: false, Peers: peerCfg}); err != nil {
+		return newConnectionOnError(err.Error()), fmt.Errorf("failed to configure peer with cluster %s: %w", tep.Spec.ClusterIdentity, err)
+	}
+
+	if staged != nil {
+		oldCon.PeerConfiguration[liqoconst.PublicKey] = nextKey.String()
+		oldCon.PeerConfiguration[EndpointIP] = endpoint.IP.String()
+		oldCon.PeerConfiguration[liqoconst.ListeningPort] = strconv.Itoa(endpoint.Port)
+		w.connectedClusterIdentities[*nextKey] = w.connectedClusterIdentities[*currentKey]
+		delete(w.connectedClusterIdentities, *currentKey)
+	}
+	return w.updateConnectionStatus(oldCon)
+}
+
+// replacedPeerKeys returns the public keys of the peers to be removed when the configuration of a connection changes. It
+// includes the key of the previously configured peer, which differs from the desired one in case the remote cluster switched
+// over to the next key before the handshake with the staged peer completed, as well as the desired key, to reset the session.
+func replacedPeerKeys(oldCon *netv1alpha1.Connection, remoteKey wgtypes.Key) []wgtypes.Key {
+	keys := []wgtypes.Key{remoteKey}
+	oldKey, err := wgtypes.ParseKey(oldCon.PeerConfiguration[liqoconst.PublicKey])
+	if err != nil {
+		klog.Warningf("failed to parse the public key of the previously configured peer: %v", err)
+		return keys
+	}
+	if oldKey != remoteKey {
+		keys = append([]wgtypes.Key{oldKey}, keys...)
+	}
+	return keys
+}
+
+// promotedPeerConfig returns the allowed IPs and the endpoint to be configured for the peer promoted during a key rotation.
+// The allowed IPs are copied from the current peer (falling back to the desired ones), while the endpoint is the one
+// the handshake with the staged peer has been observed from, if any, to preserve the NAT mappings.
+func promotedPeerConfig(current, staged *wgtypes.Peer, allowedIPs []net.IPNet, endpoint *net.UDPAddr) ([]net.IPNet, *net.UDPAddr) {
+	if current != nil && len(current.AllowedIPs) > 0 {
+		allowedIPs = current.AllowedIPs
+	}
+	switch {
+	case staged != nil && staged.Endpoint != nil:
+		endpoint = staged.Endpoint
+	case current != nil && current.Endpoint != nil:
+		endpoint = current.Endpoint
+	}
+	return allowedIPs, endpoint
+}
+
+// SetPrivateKey configures the WireGuard device with the given private key, if different from the current one.
+func (w *Wireguard) SetPrivateKey(key wgtypes.Key) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if key == w.conf.priKey {
+		return nil
+	}
+
+	if err := w.client.ConfigureDevice(liqoconst.DeviceName, wgtypes.Config{PrivateKey: &key}); err != nil {
+		return fmt.Errorf("failed to configure the private key of the WireGuard device: %w", err)
+	}
+
+	w.conf.priKey, w.conf.pubKey = key, key.PublicKey()
+	klog.Infof("%s interface named %s configured with the new publicKey %s", liqoconst.DriverName, liqoconst.DeviceName, w.conf.pubKey)
+	return nil
+}
+
 func (w *Wireguard) updateConnectionStatus(oldConn *netv1alpha1.Connection) (*netv1alpha1.Connection, error) {
 	var err error
 	var wgDev *wgtypes.Device
@@ -525,6 +727,9 @@ func (w *Wireguard) updateConnectionStatus(oldConn *netv1alpha1.Connection) (*ne
 
 // Collect implements prometheus.Collector.
 func (w *Wireguard) Collect(ch chan<- prometheus.Metric) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
 	device, err := w.client.Device(liqoconst.DeviceName)
 	if err != nil {
 		w.MetricsErrorHandler(fmt.Errorf("error collecting wireguard metrics: %w", err), ch)
@@ -532,12 +737,12 @@ func (w *Wireguard) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	for i := range device.Peers {
-		publicKey := device.Peers[i].PublicKey
-		labels := []string{
-			DriverName, device.Name,
-			w.connectedClusterIdentities[publicKey].ClusterID,
-			w.connectedClusterIdentities[publicKey].ClusterName,
+		identity, found := w.connectedClusterIdentities[device.Peers[i].PublicKey]
+		if !found {
+			// The peer is staged for a key rotation, and it is accounted once promoted.
+			continue
 		}
+		labels := []string{DriverName, device.Name, identity.ClusterID, identity.ClusterName}
 
 		// Expose last handshake of 0 unless a last handshake time is set.
 		var last float64
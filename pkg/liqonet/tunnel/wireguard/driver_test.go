@@ -17,10 +17,12 @@ package wireguard
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
 	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
 	liqoconst "github.com/liqotech/liqo/pkg/consts"
@@ -175,4 +177,82 @@ var _ = Describe("Driver", func() {
 			})
 		})
 	})
+
+	Describe("testing promotedPeerConfig", func() {
+		var (
+			desiredIPs      []net.IPNet
+			desiredEndpoint *net.UDPAddr
+			currentIPs      []net.IPNet
+			current, staged *wgtypes.Peer
+			currentEndpoint *net.UDPAddr
+			stagedEndpoint  *net.UDPAddr
+			allowedIPs      []net.IPNet
+			endpoint        *net.UDPAddr
+		)
+
+		BeforeEach(func() {
+			desiredIPs = []net.IPNet{{IP: net.ParseIP("10.0.0.0").To4(), Mask: net.CIDRMask(16, 32)}}
+			currentIPs = []net.IPNet{{IP: net.ParseIP("10.1.0.0").To4(), Mask: net.CIDRMask(16, 32)}}
+			desiredEndpoint = &net.UDPAddr{IP: net.ParseIP("1.1.1.1"), Port: 5871}
+			currentEndpoint = &net.UDPAddr{IP: net.ParseIP("2.2.2.2"), Port: 5871}
+			stagedEndpoint = &net.UDPAddr{IP: net.ParseIP("3.3.3.3"), Port: 5872}
+			current = &wgtypes.Peer{AllowedIPs: currentIPs, Endpoint: currentEndpoint}
+			staged = &wgtypes.Peer{Endpoint: stagedEndpoint}
+		})
+
+		JustBeforeEach(func() {
+			allowedIPs, endpoint = promotedPeerConfig(current, staged, desiredIPs, desiredEndpoint)
+		})
+
+		When("both peers are configured", func() {
+			It("should copy the allowed IPs of the current peer", func() { Expect(allowedIPs).To(Equal(currentIPs)) })
+			It("should use the endpoint observed for the staged peer", func() { Expect(endpoint).To(Equal(stagedEndpoint)) })
+		})
+
+		When("the staged peer has no endpoint", func() {
+			BeforeEach(func() { staged.Endpoint = nil })
+			It("should use the endpoint of the current peer", func() { Expect(endpoint).To(Equal(currentEndpoint)) })
+		})
+
+		When("the current peer is not found", func() {
+			BeforeEach(func() { current, staged.Endpoint = nil, nil })
+			It("should fallback to the desired allowed IPs", func() { Expect(allowedIPs).To(Equal(desiredIPs)) })
+			It("should fallback to the desired endpoint", func() { Expect(endpoint).To(Equal(desiredEndpoint)) })
+		})
+	})
+
+	Describe("testing replacedPeerKeys", func() {
+		var (
+			oldKey, newKey wgtypes.Key
+			oldCon         *netv1alpha1.Connection
+			keys           []wgtypes.Key
+		)
+
+		BeforeEach(func() {
+			oldPrivate, err := wgtypes.GeneratePrivateKey()
+			Expect(err).ToNot(HaveOccurred())
+			newPrivate, err := wgtypes.GeneratePrivateKey()
+			Expect(err).ToNot(HaveOccurred())
+			oldKey, newKey = oldPrivate.PublicKey(), newPrivate.PublicKey()
+			oldCon = &netv1alpha1.Connection{PeerConfiguration: map[string]string{liqoconst.PublicKey: oldKey.String()}}
+		})
+
+		When("the remote peer switched over to the next key before the handshake with the staged peer completed", func() {
+			JustBeforeEach(func() { keys = replacedPeerKeys(oldCon, newKey) })
+			It("should remove the previously configured peer before the staged one", func() {
+				Expect(keys).To(Equal([]wgtypes.Key{oldKey, newKey}))
+			})
+		})
+
+		When("the key did not change", func() {
+			JustBeforeEach(func() { keys = replacedPeerKeys(oldCon, oldKey) })
+			It("should remove the configured peer only", func() { Expect(keys).To(Equal([]wgtypes.Key{oldKey})) })
+		})
+
+		When("the previously configured key is invalid", func() {
+			BeforeEach(func() { oldCon.PeerConfiguration[liqoconst.PublicKey] = "invalid" })
+			JustBeforeEach(func() { keys = replacedPeerKeys(oldCon, newKey) })
+			It("should remove the desired peer only", func() { Expect(keys).To(Equal([]wgtypes.Key{newKey})) })
+		})
+	})
 })
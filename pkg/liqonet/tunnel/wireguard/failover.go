@@ -0,0 +1,200 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/klog/v2"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/resolver"
+)
+
+const (
+	// failoverHandshakeTimeout is the time granted to complete a handshake through a newly selected endpoint, before
+	// moving to the next one. It is longer than the keepalive interval, to ensure handshakes are attempted.
+	failoverHandshakeTimeout = 3 * KeepAliveInterval
+	// failoverStaleThreshold is the time since the last handshake after which the current endpoint is considered unreachable.
+	// Handshakes are performed every two minutes as long as traffic is exchanged (including keepalives).
+	failoverStaleThreshold = 3 * time.Minute
+	// failbackInterval is the initial interval between the probes of the endpoints with higher priority than the current one.
+	failbackInterval = 5 * time.Minute
+	// failbackMaxInterval is the maximum interval between the probes, which is doubled every time all of them failed.
+	failbackMaxInterval = time.Hour
+)
+
+// failover tracks the endpoint currently selected among the prioritised ones advertised by a given remote cluster.
+type failover struct {
+	endpoints []string
+	current   int
+	// since is the time the current endpoint has been selected.
+	since time.Time
+
+	// fallback is the endpoint restored in case the one being probed does not handshake (-1 if no probe is in progress).
+	fallback int
+	// candidate is the endpoint with higher priority than the current one to be probed next.
+	candidate int
+	// nextProbe is the time the candidate endpoint is probed.
+	nextProbe time.Time
+	// backoff is the current interval between the probes.
+	backoff time.Duration
+	// reset is whether the current session shall be torn down, so that a new handshake is performed through the probed endpoint.
+	reset bool
+}
+
+// newFailover returns a new failover, initially selecting the endpoint with the highest priority.
+func newFailover(now time.Time, endpoints []string) *failover {
+	f := &failover{endpoints: endpoints, since: now}
+	f.resetProbes(now)
+	return f
+}
+
+// endpoint returns the currently selected endpoint.
+func (f *failover) endpoint() string {
+	return f.endpoints[f.current]
+}
+
+// update configures the given endpoints, preserving the current one if still present.
+func (f *failover) update(now time.Time, endpoints []string) {
+	if reflect.DeepEqual(f.endpoints, endpoints) {
+		return
+	}
+
+	current := f.endpoint()
+	f.endpoints, f.current = endpoints, 0
+	f.resetProbes(now)
+	for i := range endpoints {
+		if endpoints[i] == current {
+			f.current = i
+			return
+		}
+	}
+	f.since = now
+}
+
+// advance moves to the next endpoint (wrapping around after the last one) in case the current one stopped handshaking,
+// depending on the status of the peer (possibly nil, if not configured). Additionally, it periodically probes the
+// endpoints with higher priority than the current one, failing back to them in case they handshake again.
+func (f *failover) advance(now time.Time, peer *wgtypes.Peer) {
+	if len(f.endpoints) < 2 || now.Sub(f.since) < failoverHandshakeTimeout {
+		return
+	}
+
+	if f.fallback >= 0 {
+		f.completeProbe(now, peer)
+		return
+	}
+
+	if peer != nil && !peer.LastHandshakeTime.IsZero() && now.Sub(peer.LastHandshakeTime) < failoverStaleThreshold {
+		if f.current > 0 && !now.Before(f.nextProbe) {
+			f.startProbe(now)
+		}
+		return
+	}
+
+	previous := f.endpoint()
+	f.current, f.since = (f.current+1)%len(f.endpoints), now
+	f.resetProbes(now)
+	klog.Warningf("Endpoint %v stopped handshaking, failing over to %v", previous, f.endpoint())
+}
+
+// startProbe tentatively selects the candidate endpoint, which is expected to complete a new handshake.
+func (f *failover) startProbe(now time.Time) {
+	previous := f.endpoint()
+	f.fallback, f.current, f.since, f.reset = f.current, f.candidate, now, true
+	klog.Infof("Probing endpoint %v, with higher priority than the current %v", f.endpoint(), previous)
+}
+
+// completeProbe fails back to the probed endpoint in case it handshaked since selected, and restores the previous one otherwise.
+func (f *failover) completeProbe(now time.Time, peer *wgtypes.Peer) {
+	if peer != nil && peer.LastHandshakeTime.After(f.since) {
+		klog.Infof("Endpoint %v handshaking again, failed back", f.endpoint())
+		f.resetProbes(now)
+		return
+	}
+
+	probed := f.endpoint()
+	f.current, f.since = f.fallback, now
+	f.fallback, f.candidate = -1, f.candidate+1
+	// The interval is doubled once all the endpoints with higher priority than the current one have been probed.
+	if f.candidate >= f.current {
+		f.candidate = 0
+		f.backoff *= 2
+		if f.backoff > failbackMaxInterval {
+			f.backoff = failbackMaxInterval
+		}
+	}
+	f.nextProbe = now.Add(f.backoff)
+	klog.Warningf("Endpoint %v did not handshake, restoring %v", probed, f.endpoint())
+}
+
+// resetProbes reverts the probes to the initial state, starting again from the endpoint with the highest priority.
+func (f *failover) resetProbes(now time.Time) {
+	f.fallback, f.candidate, f.backoff = -1, 0, failbackInterval
+	f.nextProbe = now.Add(f.backoff)
+}
+
+// consumeReset returns whether the current session shall be torn down, clearing the corresponding flag.
+func (f *failover) consumeReset() bool {
+	reset := f.reset
+	f.reset = false
+	return reset
+}
+
+// getFailoverEndpoint returns the endpoint of the remote cluster described by the given tep, in case multiple endpoints
+// are advertised. The highest priority one is selected first, and the next one is selected whenever the current one
+// stops handshaking, while periodically probing the ones with higher priority.
+func (w *Wireguard) getFailoverEndpoint(tep *netv1alpha1.TunnelEndpoint, remoteKey *wgtypes.Key) (*net.UDPAddr, error) {
+	clusterID := tep.Spec.ClusterIdentity.ClusterID
+	endpoints := append([]string{tep.Spec.EndpointIP}, tep.Spec.FallbackEndpointIPs...)
+
+	now := time.Now()
+	f, found := w.failovers[clusterID]
+	if !found {
+		f = newFailover(now, endpoints)
+		w.failovers[clusterID] = f
+	}
+	f.update(now, endpoints)
+
+	peer, found, err := w.getPeer(*remoteKey)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		peer = nil
+	}
+	f.advance(now, peer)
+
+	tunnelPort, err := getTunnelPortFromTep(tep)
+	if err != nil {
+		return nil, err
+	}
+	address, err := resolver.Resolve(context.TODO(), f.endpoint())
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: address.IP, Port: tunnelPort}, nil
+}
+
+// consumeFailoverReset returns whether the session with the remote cluster described by the given tep shall be torn down.
+func (w *Wireguard) consumeFailoverReset(tep *netv1alpha1.TunnelEndpoint) bool {
+	f, found := w.failovers[tep.Spec.ClusterIdentity.ClusterID]
+	return found && f.consumeReset()
+}
@@ -0,0 +1,148 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var _ = Describe("Endpoint failover", func() {
+	const (
+		primary   = "1.1.1.1"
+		secondary = "2.2.2.2"
+		tertiary  = "3.3.3.3"
+	)
+
+	var (
+		now time.Time
+		f   *failover
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		f = newFailover(now, []string{primary, secondary, tertiary})
+	})
+
+	handshaked := func(at time.Time) *wgtypes.Peer {
+		return &wgtypes.Peer{LastHandshakeTime: at}
+	}
+
+	It("should initially select the endpoint with the highest priority", func() {
+		Expect(f.endpoint()).To(Equal(primary))
+	})
+
+	It("should wait for the handshake timeout before evaluating the current endpoint", func() {
+		f.advance(now.Add(failoverHandshakeTimeout/2), nil)
+		Expect(f.endpoint()).To(Equal(primary))
+	})
+
+	It("should keep the current endpoint as long as it is handshaking", func() {
+		f.advance(now.Add(failoverHandshakeTimeout), handshaked(now))
+		Expect(f.endpoint()).To(Equal(primary))
+		f.advance(now.Add(10*time.Minute), handshaked(now.Add(9*time.Minute)))
+		Expect(f.endpoint()).To(Equal(primary))
+	})
+
+	It("should fail over to the next endpoint if no handshake completed", func() {
+		f.advance(now.Add(failoverHandshakeTimeout), &wgtypes.Peer{})
+		Expect(f.endpoint()).To(Equal(secondary))
+
+		By("granting the handshake timeout to the newly selected endpoint")
+		f.advance(now.Add(failoverHandshakeTimeout+time.Second), &wgtypes.Peer{})
+		Expect(f.endpoint()).To(Equal(secondary))
+	})
+
+	It("should fail over to the next endpoint if the current one stopped handshaking", func() {
+		f.advance(now.Add(failoverHandshakeTimeout), handshaked(now))
+		f.advance(now.Add(failoverStaleThreshold+time.Minute), handshaked(now))
+		Expect(f.endpoint()).To(Equal(secondary))
+	})
+
+	It("should wrap around after the last endpoint", func() {
+		for i := 1; i <= 3; i++ {
+			f.advance(now.Add(time.Duration(i)*failoverHandshakeTimeout), nil)
+		}
+		Expect(f.endpoint()).To(Equal(primary))
+	})
+
+	When("failed over to an endpoint with lower priority", func() {
+		var failed, probing time.Time
+
+		BeforeEach(func() {
+			failed = now.Add(failoverHandshakeTimeout)
+			f.advance(failed, &wgtypes.Peer{})
+			Expect(f.endpoint()).To(Equal(secondary))
+			Expect(f.consumeReset()).To(BeFalse())
+
+			probing = failed.Add(failbackInterval)
+			f.advance(probing.Add(-time.Second), handshaked(probing.Add(-time.Minute)))
+			Expect(f.endpoint()).To(Equal(secondary))
+			f.advance(probing, handshaked(probing.Add(-time.Minute)))
+		})
+
+		It("should periodically probe the endpoint with higher priority, tearing down the current session", func() {
+			Expect(f.endpoint()).To(Equal(primary))
+			Expect(f.consumeReset()).To(BeTrue())
+			Expect(f.consumeReset()).To(BeFalse())
+		})
+
+		It("should fail back to the probed endpoint if it handshaked", func() {
+			f.advance(probing.Add(failoverHandshakeTimeout), handshaked(probing.Add(time.Second)))
+			Expect(f.endpoint()).To(Equal(primary))
+
+			By("monitoring it as usual")
+			f.advance(probing.Add(failoverStaleThreshold+time.Minute), handshaked(probing.Add(time.Second)))
+			Expect(f.endpoint()).To(Equal(secondary))
+		})
+
+		It("should restore the previous endpoint if the probed one did not handshake, backing off the next probe", func() {
+			restored := probing.Add(failoverHandshakeTimeout)
+			f.advance(restored, handshaked(probing.Add(-time.Minute)))
+			Expect(f.endpoint()).To(Equal(secondary))
+
+			f.advance(restored.Add(failbackInterval), handshaked(restored.Add(failbackInterval-time.Minute)))
+			Expect(f.endpoint()).To(Equal(secondary))
+			f.advance(restored.Add(2*failbackInterval), handshaked(restored.Add(2*failbackInterval-time.Minute)))
+			Expect(f.endpoint()).To(Equal(primary))
+		})
+	})
+
+	When("the endpoints are updated", func() {
+		BeforeEach(func() { f.advance(now.Add(failoverHandshakeTimeout), nil) })
+
+		It("should preserve the current endpoint if still present", func() {
+			f.update(now, []string{tertiary, secondary})
+			Expect(f.endpoint()).To(Equal(secondary))
+		})
+
+		It("should select the endpoint with the highest priority otherwise", func() {
+			f.update(now, []string{primary, tertiary})
+			Expect(f.endpoint()).To(Equal(primary))
+		})
+	})
+
+	When("a single endpoint is configured", func() {
+		BeforeEach(func() { f = newFailover(now, []string{primary}) })
+
+		It("should never fail over", func() {
+			f.advance(now.Add(time.Hour), nil)
+			Expect(f.endpoint()).To(Equal(primary))
+		})
+	})
+})
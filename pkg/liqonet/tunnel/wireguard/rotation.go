@@ -0,0 +1,175 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+)
+
+const (
+	// NextPrivateKey is the key of the next private key for the secret containing the wireguard keys.
+	NextPrivateKey = "nextPrivateKey"
+	// KeyTimestampAnnotation is the annotation of the secret containing the wireguard keys,
+	// which stores the time the current keys have been activated.
+	KeyTimestampAnnotation = "net.liqo.io/key-timestamp"
+	// NextKeyTimestampAnnotation is the annotation of the secret containing the wireguard keys,
+	// which stores the time the next keys have been generated.
+	NextKeyTimestampAnnotation = "net.liqo.io/next-key-timestamp"
+
+	// keyRotationCheckInterval is the interval between two subsequent checks of the key rotator.
+	keyRotationCheckInterval = 30 * time.Second
+)
+
+// KeyRotator periodically rotates the keys of the WireGuard device, with an overlapping validity period.
+// First, the next keys are generated and stored in the secret containing the wireguard keys, so that the next public key
+// is advertised to the remote clusters, which in turn configure it as valid for the given peer, in addition to the current one.
+// Once the overlap period expired, the local device switches over to the next keys, which become the current ones.
+type KeyRotator struct {
+	client    k8s.Interface
+	namespace string
+
+	period  time.Duration
+	overlap time.Duration
+
+	configure func(key wgtypes.Key) error
+}
+
+// NewKeyRotator returns a new KeyRotator, which rotates the keys of the given WireGuard driver every period,
+// waiting for the given overlap period before switching over to the next keys.
+func NewKeyRotator(client k8s.Interface, namespace string, driver *Wireguard, period, overlap time.Duration) *KeyRotator {
+	return &KeyRotator{
+		client:    client,
+		namespace: namespace,
+		period:    period,
+		overlap:   overlap,
+		configure: driver.SetPrivateKey,
+	}
+}
+
+// Start starts the key rotator, until the given context is canceled. It implements the manager.Runnable interface.
+func (kr *KeyRotator) Start(ctx context.Context) error {
+	klog.Infof("Starting the %s key rotator, with period %s and overlap %s", liqoconst.DriverName, kr.period, kr.overlap)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := kr.rotate(ctx, time.Now()); err != nil {
+			klog.Errorf("Failed to rotate the %s keys: %v", liqoconst.DriverName, err)
+		}
+	}, keyRotationCheckInterval)
+	return nil
+}
+
+// rotate performs a step of the key rotation process, depending on the given current time.
+func (kr *KeyRotator) rotate(ctx context.Context, now time.Time) error {
+	secret, err := kr.client.CoreV1().Secrets(kr.namespace).Get(ctx, keysName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the secret with name %s: %w", keysName, err)
+	}
+
+	// Make sure the device is configured with the current key (e.g., in case a previous switch-over failed).
+	current, err := wgtypes.ParseKey(string(secret.Data[PrivateKey]))
+	if err != nil {
+		return fmt.Errorf("an error occurred while parsing the private key for the wireguard driver: %w", err)
+	}
+	if err := kr.configure(current); err != nil {
+		return err
+	}
+
+	if _, found := secret.Data[NextPrivateKey]; !found {
+		if now.Sub(keyTimestamp(secret, KeyTimestampAnnotation)) < kr.period {
+			return nil
+		}
+		return kr.stage(ctx, secret, now)
+	}
+
+	staged := keyTimestamp(secret, NextKeyTimestampAnnotation)
+	if staged.IsZero() {
+		// The timestamp is unexpectedly missing, hence restart the overlap period from now.
+		klog.Warningf("Missing timestamp of the next %s keys, restarting the overlap period", liqoconst.DriverName)
+		return kr.stage(ctx, secret, now)
+	}
+	if now.Sub(staged) < kr.overlap {
+		return nil
+	}
+	return kr.switchOver(ctx, secret, now)
+}
+
+// stage generates the next keys (if not already present), and stores them in the given secret.
+func (kr *KeyRotator) stage(ctx context.Context, secret *corev1.Secret, now time.Time) error {
+	if _, found := secret.Data[NextPrivateKey]; !found {
+		next, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			return fmt.Errorf("error generating private key for wireguard backend: %w", err)
+		}
+		secret.Data[NextPrivateKey] = []byte(next.String())
+		secret.Data[liqoconst.NextPublicKey] = []byte(next.PublicKey().String())
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[NextKeyTimestampAnnotation] = now.Format(time.RFC3339)
+
+	if _, err := kr.client.CoreV1().Secrets(kr.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the secret with name %s: %w", keysName, err)
+	}
+
+	klog.Infof("Next %s publicKey %s correctly generated, switching over in %s", liqoconst.DriverName,
+		secret.Data[liqoconst.NextPublicKey], kr.overlap)
+	return nil
+}
+
+// switchOver promotes the next keys as the current ones, and configures them in the WireGuard device.
+// The secret is updated first, so that the new keys are loaded in case of restarts.
+func (kr *KeyRotator) switchOver(ctx context.Context, secret *corev1.Secret, now time.Time) error {
+	next, err := wgtypes.ParseKey(string(secret.Data[NextPrivateKey]))
+	if err != nil {
+		return fmt.Errorf("an error occurred while parsing the next private key for the wireguard driver: %w", err)
+	}
+
+	secret.Data[PrivateKey] = []byte(next.String())
+	secret.Data[liqoconst.PublicKey] = []byte(next.PublicKey().String())
+	delete(secret.Data, NextPrivateKey)
+	delete(secret.Data, liqoconst.NextPublicKey)
+	secret.Annotations[KeyTimestampAnnotation] = now.Format(time.RFC3339)
+	delete(secret.Annotations, NextKeyTimestampAnnotation)
+
+	if _, err := kr.client.CoreV1().Secrets(kr.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the secret with name %s: %w", keysName, err)
+	}
+
+	return kr.configure(next)
+}
+
+// keyTimestamp returns the timestamp stored in the given annotation of the secret. In case the annotation is
+// missing (e.g., the keys have never been rotated), the creation timestamp of the secret is used for the current keys.
+func keyTimestamp(secret *corev1.Secret, annotation string) time.Time {
+	if timestamp, err := time.Parse(time.RFC3339, secret.Annotations[annotation]); err == nil {
+		return timestamp
+	}
+	if annotation == KeyTimestampAnnotation {
+		return secret.CreationTimestamp.Time
+	}
+	return time.Time{}
+}
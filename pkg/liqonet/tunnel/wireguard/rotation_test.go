@@ -0,0 +1,133 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+)
+
+var _ = Describe("Key rotation", func() {
+	const namespace = "liqo"
+
+	var (
+		ctx        context.Context
+		rotator    *KeyRotator
+		current    wgtypes.Key
+		configured []wgtypes.Key
+		created    time.Time
+	)
+
+	secret := func() *corev1.Secret {
+		s, err := rotator.client.CoreV1().Secrets(namespace).Get(ctx, keysName, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		return s
+	}
+
+	BeforeEach(func() {
+		var err error
+		ctx = context.Background()
+		current, err = wgtypes.GeneratePrivateKey()
+		Expect(err).ToNot(HaveOccurred())
+		created = time.Now().Add(-time.Hour)
+		configured = nil
+
+		rotator = &KeyRotator{
+			client: fake.NewSimpleClientset(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: keysName, Namespace: namespace, CreationTimestamp: metav1.NewTime(created)},
+				Data:       map[string][]byte{PrivateKey: []byte(current.String()), liqoconst.PublicKey: []byte(current.PublicKey().String())},
+			}),
+			namespace: namespace,
+			period:    24 * time.Hour,
+			overlap:   5 * time.Minute,
+			configure: func(key wgtypes.Key) error { configured = append(configured, key); return nil },
+		}
+	})
+
+	When("the rotation period did not expire", func() {
+		It("should not modify the keys", func() {
+			Expect(rotator.rotate(ctx, time.Now())).To(Succeed())
+			Expect(secret().Data).To(HaveLen(2))
+			Expect(configured).To(Equal([]wgtypes.Key{current}))
+		})
+	})
+
+	When("the rotation period expired", func() {
+		var now time.Time
+
+		BeforeEach(func() {
+			now = created.Add(rotator.period + time.Minute)
+			Expect(rotator.rotate(ctx, now)).To(Succeed())
+		})
+
+		It("should generate the next keys, without switching over", func() {
+			Expect(secret().Data).To(HaveKeyWithValue(PrivateKey, []byte(current.String())))
+			Expect(secret().Data).To(HaveKey(NextPrivateKey))
+			Expect(secret().Data).To(HaveKey(liqoconst.NextPublicKey))
+			Expect(secret().Annotations).To(HaveKeyWithValue(NextKeyTimestampAnnotation, now.Format(time.RFC3339)))
+			Expect(configured).To(Equal([]wgtypes.Key{current}))
+		})
+
+		It("should not switch over before the overlap period expired", func() {
+			Expect(rotator.rotate(ctx, now.Add(rotator.overlap/2))).To(Succeed())
+			Expect(secret().Data).To(HaveKeyWithValue(PrivateKey, []byte(current.String())))
+			Expect(secret().Data).To(HaveKey(NextPrivateKey))
+		})
+
+		It("should switch over once the overlap period expired", func() {
+			next, err := wgtypes.ParseKey(string(secret().Data[NextPrivateKey]))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(rotator.rotate(ctx, now.Add(rotator.overlap+time.Second))).To(Succeed())
+			Expect(secret().Data).To(HaveKeyWithValue(PrivateKey, []byte(next.String())))
+			Expect(secret().Data).To(HaveKeyWithValue(liqoconst.PublicKey, []byte(next.PublicKey().String())))
+			Expect(secret().Data).ToNot(HaveKey(NextPrivateKey))
+			Expect(secret().Data).ToNot(HaveKey(liqoconst.NextPublicKey))
+			Expect(secret().Annotations).ToNot(HaveKey(NextKeyTimestampAnnotation))
+			Expect(configured).To(HaveLen(3))
+			Expect(configured[2]).To(Equal(next))
+		})
+	})
+
+	Describe("the getNextKey function", func() {
+		It("should return nil when no rotation is in progress", func() {
+			Expect(getNextKey(&netv1alpha1.TunnelEndpoint{})).To(BeNil())
+		})
+
+		It("should return the next key when a rotation is in progress", func() {
+			tep := &netv1alpha1.TunnelEndpoint{Spec: netv1alpha1.TunnelEndpointSpec{
+				BackendConfig: map[string]string{liqoconst.NextPublicKey: current.PublicKey().String()}}}
+			Expect(getNextKey(tep)).To(PointTo(Equal(current.PublicKey())))
+		})
+
+		It("should return an error when the next key is invalid", func() {
+			tep := &netv1alpha1.TunnelEndpoint{Spec: netv1alpha1.TunnelEndpointSpec{
+				BackendConfig: map[string]string{liqoconst.NextPublicKey: "invalid"}}}
+			_, err := getNextKey(tep)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,202 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/klog/v2"
+
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/rendezvous"
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/resolver"
+)
+
+const (
+	// rendezvousTimeout is the timeout of the requests towards the rendezvous server.
+	rendezvousTimeout = 5 * time.Second
+	// rendezvousRefreshPeriod is the period after which the allocation is refreshed, to retrieve the public endpoint of the peer.
+	rendezvousRefreshPeriod = 30 * time.Second
+	// punchTimeout is the time granted to establish a direct connection through hole punching, before falling back to the relay.
+	// It is longer than the keepalive interval, to ensure packets are sent in both directions.
+	punchTimeout = 3 * KeepAliveInterval
+	// punchRetryPeriod is the period after which a new hole punching attempt is performed, in case the previous one failed.
+	punchRetryPeriod = 10 * time.Minute
+	// directStaleThreshold is the time since the last handshake after which a direct connection is considered broken.
+	// Handshakes are performed every two minutes as long as traffic is exchanged (including keepalives).
+	directStaleThreshold = 3 * time.Minute
+)
+
+// traversalPhase is the phase of the NAT traversal towards a given remote cluster.
+type traversalPhase string
+
+const (
+	// traversalRelayed means that the traffic flows through the relay port allocated by the rendezvous server.
+	traversalRelayed traversalPhase = "Relayed"
+	// traversalPunching means that a direct connection is being attempted towards the public endpoint of the peer.
+	traversalPunching traversalPhase = "Punching"
+	// traversalDirect means that the traffic flows directly towards the public endpoint of the peer.
+	traversalDirect traversalPhase = "Direct"
+)
+
+// traversal tracks the status of the NAT traversal towards a given remote cluster.
+type traversal struct {
+	allocation *rendezvous.Allocation
+	refreshed  time.Time
+
+	phase traversalPhase
+	// direct is the public endpoint of the peer targeted by the current hole punching attempt, or direct connection.
+	direct string
+	// since is the time the current phase started.
+	since time.Time
+	// retryAt is the time after which a new hole punching attempt can be performed.
+	retryAt time.Time
+	// receivedBytes is the number of bytes received from the peer when the current hole punching attempt started.
+	receivedBytes int64
+}
+
+// endpoint returns the endpoint the traffic shall be sent to, according to the current phase.
+func (t *traversal) endpoint() string {
+	if t.phase == traversalRelayed {
+		return t.allocation.RelayEndpoint
+	}
+	return t.direct
+}
+
+// advance moves the traversal to the next phase, depending on the status of the peer (possibly nil, if not configured).
+func (t *traversal) advance(now time.Time, peer *wgtypes.Peer) {
+	switch t.phase {
+	case traversalRelayed:
+		// A direct connection is attempted once connected through the relay, which allowed to discover the public endpoint of
+		// the peer. The latter starts its attempt at around the same time, as it learns the public endpoint of the local cluster.
+		if t.allocation.PeerEndpoint != "" && peer != nil && !peer.LastHandshakeTime.IsZero() && !now.Before(t.retryAt) {
+			klog.Infof("Attempting a direct connection towards %v through hole punching", t.allocation.PeerEndpoint)
+			t.transition(now, traversalPunching)
+			t.direct = t.allocation.PeerEndpoint
+			t.receivedBytes = peer.ReceiveBytes
+		}
+
+	case traversalPunching:
+		if now.Sub(t.since) < punchTimeout {
+			return
+		}
+
+		// The direct connection succeeded if traffic has been received from the public endpoint of the peer, as WireGuard
+		// updates the peer endpoint to the source of the last authenticated packet (which is the relay if the attempt failed).
+		if peer != nil && peer.Endpoint != nil && peer.Endpoint.String() == t.direct && peer.ReceiveBytes > t.receivedBytes {
+			klog.Infof("Direct connection towards %v successfully established", t.direct)
+			t.transition(now, traversalDirect)
+			return
+		}
+
+		klog.Warningf("Failed to establish a direct connection towards %v, falling back to the relay", t.direct)
+		t.transition(now, traversalRelayed)
+		t.retryAt = now.Add(punchRetryPeriod)
+
+	case traversalDirect:
+		if peer == nil || now.Sub(peer.LastHandshakeTime) > directStaleThreshold {
+			klog.Warningf("Direct connection towards %v is broken, falling back to the relay", t.direct)
+			t.transition(now, traversalRelayed)
+			t.retryAt = now.Add(punchRetryPeriod)
+		}
+	}
+}
+
+func (t *traversal) transition(now time.Time, phase traversalPhase) {
+	t.phase, t.since = phase, now
+}
+
+// getTraversalEndpoint returns the endpoint of the remote cluster described by the given tep, in case the connection
+// is established through a rendezvous server. The traffic initially flows through the allocated relay port, while
+// a direct connection is attempted as soon as the public endpoint of the peer is known.
+func (w *Wireguard) getTraversalEndpoint(tep *netv1alpha1.TunnelEndpoint, remoteKey *wgtypes.Key) (*net.UDPAddr, error) {
+	clusterID := tep.Spec.ClusterIdentity.ClusterID
+	t, found := w.traversals[clusterID]
+	if !found {
+		t = &traversal{phase: traversalRelayed}
+		w.traversals[clusterID] = t
+	}
+
+	now := time.Now()
+	if t.allocation == nil || now.Sub(t.refreshed) > rendezvousRefreshPeriod || (t.phase == traversalRelayed && t.allocation.PeerEndpoint == "") {
+		ctx, cancel := context.WithTimeout(context.Background(), rendezvousTimeout)
+		defer cancel()
+
+		client := rendezvous.NewClient(tep.Spec.BackendConfig[liqoconst.RendezvousAddress])
+		allocation, err := client.Join(ctx, tep.Spec.BackendConfig[liqoconst.RendezvousSession],
+			tep.Spec.BackendConfig[liqoconst.RendezvousMember], w.conf.priKey, *remoteKey)
+		switch {
+		case err == nil:
+			t.allocation, t.refreshed = allocation, now
+		case t.allocation == nil:
+			return nil, fmt.Errorf("failed to join the rendezvous session: %w", err)
+		default:
+			klog.Warningf("Failed to refresh the rendezvous allocation for cluster %s: %v", tep.Spec.ClusterIdentity, err)
+		}
+	}
+
+	peer, found, err := w.getPeer(*remoteKey)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		peer = nil
+	}
+	t.advance(now, peer)
+
+	return parseEndpoint(t.endpoint(), func(address string) (*net.IPAddr, error) {
+		return resolver.Resolve(context.TODO(), address)
+	})
+}
+
+// getPeer returns the configured peer with the given public key, and whether it has been found.
+func (w *Wireguard) getPeer(key wgtypes.Key) (*wgtypes.Peer, bool, error) {
+	device, err := w.client.Device(liqoconst.DeviceName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to retrieve WireGuard device: %w", err)
+	}
+
+	for i := range device.Peers {
+		if device.Peers[i].PublicKey == key {
+			return &device.Peers[i], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// parseEndpoint parses an endpoint in the host:port form, resolving the host if necessary.
+func parseEndpoint(endpoint string, addrResolver ResolverFunc) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint %q: %w", endpoint, err)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse port of endpoint %q: %w", endpoint, err)
+	}
+
+	address, err := addrResolver(host)
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: address.IP, Port: portNumber}, nil
+}
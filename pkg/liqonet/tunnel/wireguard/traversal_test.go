@@ -0,0 +1,135 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/liqotech/liqo/pkg/liqonet/tunnel/rendezvous"
+)
+
+var _ = Describe("NAT traversal", func() {
+	const (
+		relay  = "1.1.1.1:5000"
+		direct = "2.2.2.2:6000"
+	)
+
+	var (
+		now time.Time
+		t   *traversal
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		t = &traversal{phase: traversalRelayed, allocation: &rendezvous.Allocation{RelayEndpoint: relay}}
+	})
+
+	connectedPeer := func(endpoint string, received int64) *wgtypes.Peer {
+		addr, err := net.ResolveUDPAddr("udp", endpoint)
+		Expect(err).ToNot(HaveOccurred())
+		return &wgtypes.Peer{Endpoint: addr, LastHandshakeTime: now, ReceiveBytes: received}
+	}
+
+	When("the public endpoint of the peer is not known", func() {
+		It("should keep using the relay", func() {
+			t.advance(now, connectedPeer(relay, 100))
+			Expect(t.phase).To(Equal(traversalRelayed))
+			Expect(t.endpoint()).To(Equal(relay))
+		})
+	})
+
+	When("the public endpoint of the peer is known", func() {
+		BeforeEach(func() { t.allocation.PeerEndpoint = direct })
+
+		It("should wait for the connection through the relay", func() {
+			t.advance(now, &wgtypes.Peer{})
+			Expect(t.phase).To(Equal(traversalRelayed))
+		})
+
+		It("should attempt a direct connection once connected through the relay", func() {
+			t.advance(now, connectedPeer(relay, 100))
+			Expect(t.phase).To(Equal(traversalPunching))
+			Expect(t.endpoint()).To(Equal(direct))
+		})
+
+		When("hole punching is in progress", func() {
+			BeforeEach(func() { t.advance(now, connectedPeer(relay, 100)) })
+
+			It("should wait for the timeout before evaluating the attempt", func() {
+				t.advance(now.Add(punchTimeout/2), connectedPeer(relay, 200))
+				Expect(t.phase).To(Equal(traversalPunching))
+			})
+
+			It("should switch to the direct connection if traffic is received from the peer", func() {
+				t.advance(now.Add(punchTimeout), connectedPeer(direct, 200))
+				Expect(t.phase).To(Equal(traversalDirect))
+				Expect(t.endpoint()).To(Equal(direct))
+			})
+
+			It("should fall back to the relay if traffic is still received through it", func() {
+				t.advance(now.Add(punchTimeout), connectedPeer(relay, 200))
+				Expect(t.phase).To(Equal(traversalRelayed))
+				Expect(t.endpoint()).To(Equal(relay))
+
+				By("not retrying before the retry period expired")
+				t.advance(now.Add(punchTimeout+time.Minute), connectedPeer(relay, 300))
+				Expect(t.phase).To(Equal(traversalRelayed))
+
+				By("retrying once the retry period expired")
+				t.advance(now.Add(punchTimeout+punchRetryPeriod), connectedPeer(relay, 400))
+				Expect(t.phase).To(Equal(traversalPunching))
+			})
+		})
+
+		When("the direct connection is established", func() {
+			BeforeEach(func() {
+				t.advance(now, connectedPeer(relay, 100))
+				t.advance(now.Add(punchTimeout), connectedPeer(direct, 200))
+			})
+
+			It("should fall back to the relay if no handshake completed recently", func() {
+				t.advance(now.Add(directStaleThreshold+time.Minute), connectedPeer(direct, 300))
+				Expect(t.phase).To(Equal(traversalRelayed))
+			})
+		})
+	})
+
+	DescribeTable("the parseEndpoint function",
+		func(endpoint string, expected *net.UDPAddr, shouldFail bool) {
+			addr, err := parseEndpoint(endpoint, func(address string) (*net.IPAddr, error) {
+				if ip := net.ParseIP(address); ip != nil {
+					return &net.IPAddr{IP: ip}, nil
+				}
+				return nil, errors.New("unresolvable")
+			})
+			if shouldFail {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr).To(Equal(expected))
+		},
+		Entry("valid endpoint", "1.1.1.1:5000", &net.UDPAddr{IP: net.ParseIP("1.1.1.1"), Port: 5000}, false),
+		Entry("missing port", "1.1.1.1", nil, true),
+		Entry("invalid port", "1.1.1.1:foo", nil, true),
+		Entry("unresolvable host", "foo:5000", nil, true),
+	)
+})
@@ -0,0 +1,120 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/ipc"
+	"golang.zx2c4.com/wireguard/tun"
+	"k8s.io/klog/v2"
+)
+
+// userspaceDevice wraps an embedded userspace WireGuard device, driving a TUN interface. The device is configured
+// through the standard UAPI socket, hence it can be managed by wgctrl exactly as the kernel implementation.
+type userspaceDevice struct {
+	device *device.Device
+	uapi   net.Listener
+}
+
+// unmonitoredTUN wraps a TUN device, discarding the events generated by the kernel (e.g., the interface being set up
+// or down). Indeed, they are detected through a netlink socket bound to the current network namespace, which stops
+// receiving them once the interface is moved to the gateway network namespace. Hence, the device is brought up
+// explicitly, and it is kept up regardless of the status of the interface.
+type unmonitoredTUN struct {
+	tun.Device
+	events chan tun.Event
+}
+
+// isKernelModuleUnavailable returns whether the given error, returned when creating the wireguard link, signals
+// that the wireguard kernel module is not available.
+func isKernelModuleUnavailable(err error) bool {
+	return errors.Is(err, unix.EOPNOTSUPP)
+}
+
+// newUnmonitoredTUN returns a new unmonitoredTUN wrapping the given device.
+func newUnmonitoredTUN(dev tun.Device) tun.Device {
+	wrapped := &unmonitoredTUN{Device: dev, events: make(chan tun.Event)}
+	go func() {
+		// Drain the events of the underlying device, and close the channel once it is closed.
+		for event := range dev.Events() {
+			klog.V(5).Infof("Discarding event %d of TUN device", event)
+		}
+		close(wrapped.events)
+	}()
+	return wrapped
+}
+
+// Events returns the channel of the events concerning the TUN device, which is never written.
+func (ut *unmonitoredTUN) Events() chan tun.Event {
+	return ut.events
+}
+
+// newUserspaceDevice creates a new TUN interface with the given name and MTU, driven by an embedded userspace
+// WireGuard implementation, and starts serving the corresponding UAPI socket.
+func newUserspaceDevice(name string, mtu int) (*userspaceDevice, error) {
+	tunDev, err := tun.CreateTUN(name, mtu)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TUN device '%s': %w", name, err)
+	}
+
+	logger := &device.Logger{
+		Verbosef: func(format string, args ...interface{}) { klog.V(4).Infof("[userspace wireguard] "+format, args...) },
+		Errorf:   func(format string, args ...interface{}) { klog.Errorf("[userspace wireguard] "+format, args...) },
+	}
+	dev := device.NewDevice(newUnmonitoredTUN(tunDev), conn.NewDefaultBind(), logger)
+
+	uapiFile, err := ipc.UAPIOpen(name)
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("failed to open UAPI socket for device '%s': %w", name, err)
+	}
+	uapi, err := ipc.UAPIListen(name, uapiFile)
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("failed to listen on UAPI socket for device '%s': %w", name, err)
+	}
+
+	go func() {
+		for {
+			c, err := uapi.Accept()
+			if err != nil {
+				// The listener has been closed.
+				return
+			}
+			go dev.IpcHandle(c)
+		}
+	}()
+
+	return &userspaceDevice{device: dev, uapi: uapi}, nil
+}
+
+// Up brings up the userspace device, opening the UDP sockets in the current network namespace.
+func (ud *userspaceDevice) Up() error {
+	return ud.device.Up()
+}
+
+// Close stops serving the UAPI socket and tears down the userspace device, including the TUN interface.
+func (ud *userspaceDevice) Close() {
+	if err := ud.uapi.Close(); err != nil {
+		klog.Warningf("failed to close UAPI socket: %v", err)
+	}
+	ud.device.Close()
+}
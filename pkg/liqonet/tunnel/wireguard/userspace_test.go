@@ -0,0 +1,67 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/tun"
+)
+
+type fakeTUN struct {
+	tun.Device
+	events chan tun.Event
+}
+
+func (ft *fakeTUN) Events() chan tun.Event { return ft.events }
+
+var _ = Describe("Userspace fallback", func() {
+	DescribeTable("the isKernelModuleUnavailable function",
+		func(err error, expected bool) {
+			Expect(isKernelModuleUnavailable(err)).To(Equal(expected))
+		},
+		Entry("operation not supported", unix.EOPNOTSUPP, true),
+		Entry("wrapped operation not supported", fmt.Errorf("failed: %w", unix.EOPNOTSUPP), true),
+		Entry("permission denied", unix.EPERM, false),
+		Entry("generic error", errors.New("failure"), false),
+	)
+
+	Describe("the unmonitoredTUN wrapper", func() {
+		var (
+			underlying *fakeTUN
+			wrapped    tun.Device
+		)
+
+		BeforeEach(func() {
+			underlying = &fakeTUN{events: make(chan tun.Event)}
+			wrapped = newUnmonitoredTUN(underlying)
+		})
+
+		It("should discard the events of the underlying device", func() {
+			underlying.events <- tun.EventUp
+			underlying.events <- tun.EventDown
+			Consistently(wrapped.Events()).ShouldNot(Receive())
+		})
+
+		It("should close the events channel once the underlying one is closed", func() {
+			close(underlying.events)
+			Eventually(wrapped.Events()).Should(BeClosed())
+		})
+	})
+})
@@ -17,6 +17,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"net"
 	"os"
 	"strings"
@@ -77,6 +78,15 @@ func GetPodIP() (net.IP, error) {
 	return net.ParseIP(ipAddress), nil
 }
 
+// GetPodName gets the name of the pod passed as an environment variable.
+func GetPodName() (string, error) {
+	name, isSet := os.LookupEnv("POD_NAME")
+	if !isSet || name == "" {
+		return "", errors.New("the POD_NAME environment variable is not set as an environment variable")
+	}
+	return name, nil
+}
+
 // GetPodNamespace gets the namespace of the pod passed as an environment variable.
 func GetPodNamespace() (string, error) {
 	namespace, isSet := os.LookupEnv("POD_NAMESPACE")
@@ -151,6 +161,25 @@ func GetExternalCIDRS(tep *netv1alpha1.TunnelEndpoint) (localExternalCIDR, remot
 	return
 }
 
+// GetExportedCIDRS for a given tep the function retrieves the values of the additional networks exported by the local cluster
+// (as seen by the remote one) and by the remote cluster (as seen by the local one). Their values depend if the NAT is required or not.
+func GetExportedCIDRS(tep *netv1alpha1.TunnelEndpoint) (localExportedCIDRs, remoteExportedCIDRs []string) {
+	getCIDR := func(exported netv1alpha1.ExportedCIDR) string {
+		if exported.NATCIDR != "" && exported.NATCIDR != consts.DefaultCIDRValue {
+			return exported.NATCIDR
+		}
+		return exported.CIDR
+	}
+
+	for i := range tep.Spec.LocalExportedCIDRs {
+		localExportedCIDRs = append(localExportedCIDRs, getCIDR(tep.Spec.LocalExportedCIDRs[i]))
+	}
+	for i := range tep.Spec.RemoteExportedCIDRs {
+		remoteExportedCIDRs = append(remoteExportedCIDRs, getCIDR(tep.Spec.RemoteExportedCIDRs[i]))
+	}
+	return localExportedCIDRs, remoteExportedCIDRs
+}
+
 // IsValidCIDR returns an error if the received CIDR is invalid.
 func IsValidCIDR(cidr string) error {
 	_, _, err := net.ParseCIDR(cidr)
@@ -166,6 +195,16 @@ func GetFirstIP(network string) (string, error) {
 	return firstIP.String(), nil
 }
 
+// GetTunnelProbeIP returns the link-local IP address assigned to the tunnel interfaces of the gateway of the given cluster,
+// to exchange the latency probes. It is derived from the hash of the cluster ID, hence it does not depend on (and does not
+// collide with) the pod CIDRs, excluding the first and the last /24 subnets, which are reserved by RFC 3927.
+func GetTunnelProbeIP(clusterID string) net.IP {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(clusterID))
+	index := hasher.Sum32() % (254 * 256)
+	return net.IPv4(169, 254, byte(1+index/256), byte(index%256)).To4()
+}
+
 // CheckTep checks validity of TunnelEndpoint resource fields.
 func CheckTep(tep *netv1alpha1.TunnelEndpoint) error {
 	if tep.Spec.ClusterIdentity.ClusterID == "" {
@@ -22,6 +22,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	netv1alpha1 "github.com/liqotech/liqo/apis/net/v1alpha1"
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
 	liqonetutils "github.com/liqotech/liqo/pkg/liqonet/utils"
 )
 
@@ -89,6 +91,33 @@ var _ = Describe("Liqonet", func() {
 		Entry("Getting first IP of 192.168.0.0/16", "192.168.0.0/16", "192.168.0.0", nil),
 	)
 
+	Describe("GetTunnelProbeIP", func() {
+		It("should return a deterministic link-local address, outside the reserved subnets", func() {
+			ip := liqonetutils.GetTunnelProbeIP("cluster-id")
+			Expect(ip).To(Equal(liqonetutils.GetTunnelProbeIP("cluster-id")))
+			Expect(ip.IsLinkLocalUnicast()).To(BeTrue())
+			Expect(ip[2]).To(And(BeNumerically(">=", 1), BeNumerically("<=", 254)))
+		})
+
+		It("should return different addresses for different clusters", func() {
+			Expect(liqonetutils.GetTunnelProbeIP("cluster-id")).ToNot(Equal(liqonetutils.GetTunnelProbeIP("other-cluster-id")))
+		})
+	})
+
+	DescribeTable("GetExportedCIDRS",
+		func(natCIDR, expectedCIDR string) {
+			tep := &netv1alpha1.TunnelEndpoint{Spec: netv1alpha1.TunnelEndpointSpec{
+				LocalExportedCIDRs:  []netv1alpha1.ExportedCIDR{{CIDR: "10.0.0.0/24", NATCIDR: natCIDR}},
+				RemoteExportedCIDRs: []netv1alpha1.ExportedCIDR{{CIDR: "10.0.0.0/24", NATCIDR: natCIDR}}}}
+			local, remote := liqonetutils.GetExportedCIDRS(tep)
+			Expect(local).To(ConsistOf(expectedCIDR))
+			Expect(remote).To(ConsistOf(expectedCIDR))
+		},
+		Entry("Exported CIDR not remapped", liqoconst.DefaultCIDRValue, "10.0.0.0/24"),
+		Entry("Exported CIDR without NAT information", "", "10.0.0.0/24"),
+		Entry("Exported CIDR remapped", "10.1.0.0/24", "10.1.0.0/24"),
+	)
+
 	Describe("testing getOverlayIP function", func() {
 		Context("when input parameter is correct", func() {
 			It("should return a valid ip", func() {
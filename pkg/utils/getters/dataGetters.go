@@ -118,6 +118,9 @@ func RetrieveWGEPFromService(svc *corev1.Service, annotationKey, portName string
 			svc.Namespace, svc.Name, svc.Spec.Type, corev1.ServiceTypeLoadBalancer, corev1.ServiceTypeNodePort)
 	}
 
+	if detectedAddress, ok := svc.GetAnnotations()[liqoconsts.DetectedAddressAnnotation]; ok && err == nil {
+		endpointIP = detectedAddress
+	}
 	if overrideAddress, ok := svc.GetAnnotations()[liqoconsts.OverrideAddressAnnotation]; ok {
 		endpointIP = overrideAddress
 	}
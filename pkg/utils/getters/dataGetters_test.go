@@ -114,6 +114,13 @@ var _ = Describe("DataGetters", func() {
 					It("should return correct ip address", func() { Expect(epIP).To(Equal(nodeIPAddr)) })
 					It("should return correct port number", func() { Expect(epPort).To(Equal(strconv.FormatInt(int64(port.NodePort), 10))) })
 				})
+
+				Context("when the endpoint address has been detected", func() {
+					BeforeEach(func() { service.Annotations[liqoconst.DetectedAddressAnnotation] = "1.2.3.4" })
+					It("should return nil", func() { Expect(err).ShouldNot(HaveOccurred()) })
+					It("should return the detected ip address", func() { Expect(epIP).To(Equal("1.2.3.4")) })
+					It("should return correct port number", func() { Expect(epPort).To(Equal(strconv.FormatInt(int64(port.NodePort), 10))) })
+				})
 			})
 
 			Context("service is of type LoadBalancer", func() {
@@ -50,6 +50,16 @@ var (
 		},
 	}
 
+	// GatewayPodLabelSelector selector used to get the gateway pods (either active or standby).
+	GatewayPodLabelSelector = metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      liqoconst.GatewayPodLabelKey,
+				Operator: metav1.LabelSelectorOpExists,
+			},
+		},
+	}
+
 	// WireGuardSecretLabelSelector selector used to get the WireGuard secret.
 	WireGuardSecretLabelSelector = metav1.LabelSelector{
 		MatchExpressions: []metav1.LabelSelectorRequirement{
@@ -19,6 +19,8 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/pointer"
+
+	sharingv1alpha1 "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 )
 
 // IsPodReady returns true if a pod is ready; false otherwise. It also returns a reason (as provided by Kubernetes).
@@ -33,6 +35,11 @@ func IsPodReady(pod *corev1.Pod) (ready bool, reason string) {
 	return false, "no conditions in pod status"
 }
 
+// IsPodTerminated returns whether the given pod reached a terminal phase (i.e., either succeeded or failed).
+func IsPodTerminated(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
 // IsPodSpecEqual returns whether two pod specs are equal according to the fields that
 // can be modified after start-up time. Refer to the following link for more information:
 // https://kubernetes.io/docs/concepts/workloads/pods/#pod-update-and-replacement
@@ -91,3 +98,52 @@ outer:
 
 	return true
 }
+
+// PrivilegedFeatures returns the list of privileged pod features (e.g., host network) required by the given pod spec.
+func PrivilegedFeatures(spec *corev1.PodSpec) []sharingv1alpha1.PodFeature {
+	var features []sharingv1alpha1.PodFeature
+
+	if spec.HostNetwork {
+		features = append(features, sharingv1alpha1.PodFeatureHostNetwork)
+	}
+	if spec.HostIPC {
+		features = append(features, sharingv1alpha1.PodFeatureHostIPC)
+	}
+	if spec.HostPID {
+		features = append(features, sharingv1alpha1.PodFeatureHostPID)
+	}
+	if hasHostPorts(spec.Containers) || hasHostPorts(spec.InitContainers) {
+		features = append(features, sharingv1alpha1.PodFeatureHostPort)
+	}
+
+	return features
+}
+
+// ForbiddenFeatures returns the list of privileged pod features required by the given pod spec, but not included in the allowed ones.
+func ForbiddenFeatures(spec *corev1.PodSpec, allowed []sharingv1alpha1.PodFeature) []sharingv1alpha1.PodFeature {
+	var forbidden []sharingv1alpha1.PodFeature
+
+outer:
+	for _, feature := range PrivilegedFeatures(spec) {
+		for _, permitted := range allowed {
+			if feature == permitted {
+				continue outer
+			}
+		}
+		forbidden = append(forbidden, feature)
+	}
+
+	return forbidden
+}
+
+// hasHostPorts returns whether any of the given containers binds a port on the hosting node.
+func hasHostPorts(containers []corev1.Container) bool {
+	for i := range containers {
+		for j := range containers[i].Ports {
+			if containers[i].Ports[j].HostPort != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
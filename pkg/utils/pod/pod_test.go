@@ -21,6 +21,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/pointer"
 
+	sharingv1alpha1 "github.com/liqotech/liqo/apis/sharing/v1alpha1"
 	"github.com/liqotech/liqo/pkg/utils/pod"
 )
 
@@ -57,6 +58,18 @@ var _ = Describe("Pod utility functions", func() {
 		)
 	})
 
+	Describe("The IsPodTerminated function", func() {
+		DescribeTable("Should return the correct output",
+			func(phase corev1.PodPhase, expected bool) {
+				Expect(pod.IsPodTerminated(&corev1.Pod{Status: corev1.PodStatus{Phase: phase}})).To(BeIdenticalTo(expected))
+			},
+			Entry("When the pod is pending", corev1.PodPending, false),
+			Entry("When the pod is running", corev1.PodRunning, false),
+			Entry("When the pod succeeded", corev1.PodSucceeded, true),
+			Entry("When the pod failed", corev1.PodFailed, true),
+		)
+	})
+
 	Describe("The IsPodSpecEqual function", func() {
 		type TestCase struct {
 			previous corev1.PodSpec
@@ -147,4 +160,39 @@ var _ = Describe("Pod utility functions", func() {
 			}),
 		)
 	})
+
+	Describe("The ForbiddenFeatures function", func() {
+		type TestCase struct {
+			spec     corev1.PodSpec
+			allowed  []sharingv1alpha1.PodFeature
+			expected types.GomegaMatcher
+		}
+
+		hostPortContainers := []corev1.Container{{Name: "foo", Ports: []corev1.ContainerPort{{ContainerPort: 80, HostPort: 8080}}}}
+
+		DescribeTable("tests table",
+			func(c TestCase) {
+				Expect(pod.ForbiddenFeatures(&c.spec, c.allowed)).To(c.expected)
+			},
+			Entry("no privileged features are required", TestCase{
+				spec:     corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Ports: []corev1.ContainerPort{{ContainerPort: 80}}}}},
+				expected: BeEmpty(),
+			}),
+			Entry("privileged features are required, but none is allowed", TestCase{
+				spec: corev1.PodSpec{HostNetwork: true, HostIPC: true, HostPID: true, Containers: hostPortContainers},
+				expected: ConsistOf(sharingv1alpha1.PodFeatureHostNetwork, sharingv1alpha1.PodFeatureHostIPC,
+					sharingv1alpha1.PodFeatureHostPID, sharingv1alpha1.PodFeatureHostPort),
+			}),
+			Entry("privileged features are required, and some are allowed", TestCase{
+				spec:     corev1.PodSpec{HostNetwork: true, HostPID: true, InitContainers: hostPortContainers},
+				allowed:  []sharingv1alpha1.PodFeature{sharingv1alpha1.PodFeatureHostNetwork, sharingv1alpha1.PodFeatureHostIPC},
+				expected: ConsistOf(sharingv1alpha1.PodFeatureHostPID, sharingv1alpha1.PodFeatureHostPort),
+			}),
+			Entry("privileged features are required, and all are allowed", TestCase{
+				spec:     corev1.PodSpec{HostNetwork: true, Containers: hostPortContainers},
+				allowed:  []sharingv1alpha1.PodFeature{sharingv1alpha1.PodFeatureHostPort, sharingv1alpha1.PodFeatureHostNetwork},
+				expected: BeEmpty(),
+			}),
+		)
+	})
 })
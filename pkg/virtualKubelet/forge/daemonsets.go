@@ -0,0 +1,217 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forge
+
+import (
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/utils/pod"
+)
+
+const (
+	// LiqoExpandedPodKey is the key of a label identifying the remote pods originated from the expansion of a local DaemonSet pod.
+	LiqoExpandedPodKey = "virtualkubelet.liqo.io/expanded-pod"
+
+	// daemonSetKind is the kind of the DaemonSet objects.
+	daemonSetKind = "DaemonSet"
+	// daemonSetTemplateGenerationKey is the key of the label added by the DaemonSet controller to identify the template generation.
+	daemonSetTemplateGenerationKey = "pod-template-generation"
+)
+
+// IsDaemonSetPod returns whether the given pod is controlled by a DaemonSet.
+func IsDaemonSetPod(local *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(local)
+	return owner != nil && owner.Kind == daemonSetKind && owner.APIVersion == appsv1.SchemeGroupVersion.String()
+}
+
+// ExpandedPodSelector returns the label selector matching the remote pods originated from the expansion of the given local pod.
+func ExpandedPodSelector(name string) labels.Selector {
+	return labels.Set{LiqoExpandedPodKey: name}.AsSelectorPreValidated()
+}
+
+// RemoteDaemonSet forges the DaemonSet expanding a local DaemonSet pod into one pod for each remote node.
+func RemoteDaemonSet(local *corev1.Pod, remote *appsv1.DaemonSet, targetNamespace string, enableAPIServerSupport bool,
+	saSecretRetriever SASecretRetriever, kubernetesServiceIPRetriever KubernetesServiceIPGetter) *appsv1.DaemonSet {
+	if remote == nil {
+		// The remote is nil if not already created.
+		remote = &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: local.GetName(), Namespace: targetNamespace}}
+	}
+
+	// Remove the labels meaningful only locally, or which would interfere with the remote DaemonSet controller.
+	filtered := local.ObjectMeta.DeepCopy()
+	delete(filtered.GetLabels(), liqoconst.LocalPodLabelKey)
+	delete(filtered.GetLabels(), appsv1.ControllerRevisionHashLabelKey)
+	delete(filtered.GetLabels(), daemonSetTemplateGenerationKey)
+
+	selector := map[string]string{LiqoExpandedPodKey: local.GetName()}
+	spec := RemotePodSpec(local.Spec.DeepCopy(), remote.Spec.Template.Spec.DeepCopy(), enableAPIServerSupport,
+		saSecretRetriever, kubernetesServiceIPRetriever)
+	spec.Affinity = RemoteDaemonSetAffinity()
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: RemoteObjectMeta(filtered, &remote.ObjectMeta),
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels.Merge(filtered.GetLabels(), labels.Merge(ReflectionLabels(), selector)),
+					Annotations: filtered.GetAnnotations(),
+				},
+				Spec: spec,
+			},
+			UpdateStrategy:       remote.Spec.UpdateStrategy,
+			MinReadySeconds:      remote.Spec.MinReadySeconds,
+			RevisionHistoryLimit: remote.Spec.RevisionHistoryLimit,
+		},
+	}
+}
+
+// RemoteDaemonSetAffinity forges the affinity preventing expanded pods from being scheduled on the virtual nodes of the remote cluster,
+// to avoid the recursive expansion across multiple peerings.
+func RemoteDaemonSetAffinity() *corev1.Affinity {
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      liqoconst.TypeLabel,
+						Operator: corev1.NodeSelectorOpNotIn,
+						Values:   []string{liqoconst.TypeNode},
+					}},
+				}},
+			},
+		},
+	}
+}
+
+// LocalExpandedPod forges the object meta and status of the local pod, aggregating the ones of the remote expanded pods.
+func LocalExpandedPod(local *corev1.Pod, remotes []*corev1.Pod, desired int32, translator PodIPTranslator) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: *local.ObjectMeta.DeepCopy(),
+		Status:     LocalExpandedPodStatus(&local.Status, remotes, desired, translator),
+	}
+}
+
+// LocalExpandedPodStatus aggregates the statuses of the remote expanded pods into the one of the local pod.
+// The local pod is considered ready only if all the desired remote pods are ready.
+func LocalExpandedPodStatus(local *corev1.PodStatus, remotes []*corev1.Pod, desired int32, translator PodIPTranslator) corev1.PodStatus {
+	// Sort the remote pods by name, to guarantee that the outcome is deterministic.
+	sorted := make([]*corev1.Pod, len(remotes))
+	copy(sorted, remotes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].GetName() < sorted[j].GetName() })
+
+	status := corev1.PodStatus{Phase: corev1.PodPending, HostIP: LiqoNodeIP, QOSClass: local.QOSClass}
+	var ready, running int32
+	for _, remote := range sorted {
+		if remote.Status.Phase == corev1.PodRunning {
+			running++
+		}
+		if isReady, _ := pod.IsPodReady(remote); !isReady {
+			continue
+		}
+
+		ready++
+		// The IP address of the first ready pod is selected as representative of the local one.
+		if status.PodIP == "" && remote.Status.PodIP != "" {
+			status.PodIP = translator(remote.Status.PodIP)
+			status.PodIPs = []corev1.PodIP{{IP: status.PodIP}}
+		}
+	}
+
+	if running > 0 {
+		status.Phase = corev1.PodRunning
+	}
+
+	if len(sorted) > 0 {
+		status.QOSClass = sorted[0].Status.QOSClass
+		status.ContainerStatuses = LocalExpandedContainerStatuses(sorted,
+			func(s *corev1.PodStatus) []corev1.ContainerStatus { return s.ContainerStatuses })
+		status.InitContainerStatuses = LocalExpandedContainerStatuses(sorted,
+			func(s *corev1.PodStatus) []corev1.ContainerStatus { return s.InitContainerStatuses })
+	}
+	for _, remote := range sorted {
+		if remote.Status.StartTime != nil && (status.StartTime == nil || remote.Status.StartTime.Before(status.StartTime)) {
+			status.StartTime = remote.Status.StartTime
+		}
+	}
+
+	allReady := desired > 0 && ready >= desired
+	status.Conditions = []corev1.PodCondition{
+		localExpandedPodCondition(local, corev1.PodScheduled, len(sorted) > 0),
+		localExpandedPodCondition(local, corev1.PodInitialized, len(sorted) > 0),
+		localExpandedPodCondition(local, corev1.ContainersReady, allReady),
+		localExpandedPodCondition(local, corev1.PodReady, allReady),
+	}
+	status.Message = fmt.Sprintf("%d/%d remote pods ready", ready, desired)
+
+	return status
+}
+
+// LocalExpandedContainerStatuses aggregates the container statuses of the remote expanded pods.
+// The statuses of the first pod are used as reference, summing the restarts and requiring all containers to be ready.
+func LocalExpandedContainerStatuses(remotes []*corev1.Pod,
+	retriever func(*corev1.PodStatus) []corev1.ContainerStatus) []corev1.ContainerStatus {
+	reference := retriever(&remotes[0].Status)
+	if len(reference) == 0 {
+		return nil
+	}
+
+	output := make([]corev1.ContainerStatus, len(reference))
+	for idx := range reference {
+		reference[idx].DeepCopyInto(&output[idx])
+		output[idx].RestartCount = 0
+	}
+
+	for _, remote := range remotes {
+		statuses := retriever(&remote.Status)
+		for idx := range output {
+			found := false
+			for jdx := range statuses {
+				if statuses[jdx].Name == output[idx].Name {
+					output[idx].RestartCount += statuses[jdx].RestartCount
+					output[idx].Ready = output[idx].Ready && statuses[jdx].Ready
+					found = true
+					break
+				}
+			}
+			// A remote pod which does not report the container status yet is considered not ready.
+			output[idx].Ready = output[idx].Ready && found
+		}
+	}
+
+	return output
+}
+
+// localExpandedPodCondition forges a pod condition, preserving the transition time if the status did not change.
+func localExpandedPodCondition(local *corev1.PodStatus, ctype corev1.PodConditionType, value bool) corev1.PodCondition {
+	status := corev1.ConditionFalse
+	if value {
+		status = corev1.ConditionTrue
+	}
+
+	for idx := range local.Conditions {
+		if local.Conditions[idx].Type == ctype && local.Conditions[idx].Status == status {
+			return local.Conditions[idx]
+		}
+	}
+	return corev1.PodCondition{Type: ctype, Status: status, LastTransitionTime: metav1.Now()}
+}
@@ -0,0 +1,214 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forge_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+var _ = Describe("DaemonSet forging", func() {
+	Translator := func(input string) string { return input + "-reflected" }
+	SASecretRetriever := func(input string) string { return input + "-secret" }
+	KubernetesServiceIPGetter := func() string { return "k8ssvcaddr" }
+
+	Describe("the IsDaemonSetPod function", func() {
+		var local *corev1.Pod
+
+		BeforeEach(func() {
+			local = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "local-name", Namespace: "local-namespace"}}
+		})
+
+		When("the pod is controlled by a DaemonSet", func() {
+			BeforeEach(func() {
+				local.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "ds", Controller: pointer.Bool(true)}}
+			})
+			It("should return true", func() { Expect(forge.IsDaemonSetPod(local)).To(BeTrue()) })
+		})
+
+		When("the pod is controlled by a ReplicaSet", func() {
+			BeforeEach(func() {
+				local.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", Controller: pointer.Bool(true)}}
+			})
+			It("should return false", func() { Expect(forge.IsDaemonSetPod(local)).To(BeFalse()) })
+		})
+
+		When("the pod is not controlled by any object", func() {
+			It("should return false", func() { Expect(forge.IsDaemonSetPod(local)).To(BeFalse()) })
+		})
+	})
+
+	Describe("the RemoteDaemonSet function", func() {
+		var (
+			local  *corev1.Pod
+			remote *appsv1.DaemonSet
+			output *appsv1.DaemonSet
+		)
+
+		BeforeEach(func() {
+			local = &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "local-name", Namespace: "local-namespace",
+					Labels: map[string]string{"foo": "bar", consts.LocalPodLabelKey: consts.LocalPodLabelValue,
+						appsv1.ControllerRevisionHashLabelKey: "hash", "pod-template-generation": "1"},
+					Annotations: map[string]string{"bar": "baz"},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "foo/bar:v0.1-alpha"}}},
+			}
+			remote = nil
+		})
+
+		JustBeforeEach(func() {
+			output = forge.RemoteDaemonSet(local, remote, "remote-namespace", false, SASecretRetriever, KubernetesServiceIPGetter)
+		})
+
+		It("should correctly set the name and namespace", func() {
+			Expect(output.GetName()).To(Equal("local-name"))
+			Expect(output.GetNamespace()).To(Equal("remote-namespace"))
+		})
+		It("should correctly set the labels", func() {
+			Expect(output.GetLabels()).To(HaveKeyWithValue("foo", "bar"))
+			Expect(output.GetLabels()).To(HaveKeyWithValue(forge.LiqoOriginClusterIDKey, LocalClusterID))
+			Expect(output.GetLabels()).To(HaveKeyWithValue(forge.LiqoDestinationClusterIDKey, RemoteClusterID))
+			Expect(output.GetLabels()).ToNot(HaveKey(consts.LocalPodLabelKey))
+			Expect(output.GetLabels()).ToNot(HaveKey(appsv1.ControllerRevisionHashLabelKey))
+			Expect(output.GetLabels()).ToNot(HaveKey("pod-template-generation"))
+		})
+		It("should correctly set the annotations", func() { Expect(output.GetAnnotations()).To(HaveKeyWithValue("bar", "baz")) })
+		It("should correctly set the selector", func() {
+			Expect(output.Spec.Selector.MatchLabels).To(Equal(map[string]string{forge.LiqoExpandedPodKey: "local-name"}))
+			Expect(output.Spec.Template.GetLabels()).To(HaveKeyWithValue(forge.LiqoExpandedPodKey, "local-name"))
+		})
+		It("should mark the template as reflected", func() {
+			Expect(output.Spec.Template.GetLabels()).To(HaveKeyWithValue(forge.LiqoOriginClusterIDKey, LocalClusterID))
+			Expect(output.Spec.Template.GetLabels()).To(HaveKeyWithValue(forge.LiqoDestinationClusterIDKey, RemoteClusterID))
+		})
+		It("should correctly forge the pod spec", func() {
+			Expect(output.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(output.Spec.Template.Spec.Containers[0].Image).To(Equal("foo/bar:v0.1-alpha"))
+		})
+		It("should prevent the scheduling on virtual nodes", func() {
+			Expect(output.Spec.Template.Spec.Affinity).To(Equal(forge.RemoteDaemonSetAffinity()))
+		})
+
+		When("the remote object already exists", func() {
+			BeforeEach(func() {
+				remote = &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{
+					Name: "local-name", Namespace: "remote-namespace", ResourceVersion: "15", Labels: map[string]string{"existing": "label"}}}
+			})
+
+			It("should preserve the remote object metadata", func() {
+				Expect(output.GetResourceVersion()).To(Equal("15"))
+				Expect(output.GetLabels()).To(HaveKeyWithValue("existing", "label"))
+			})
+		})
+	})
+
+	Describe("the LocalExpandedPodStatus function", func() {
+		var (
+			local   *corev1.PodStatus
+			remotes []*corev1.Pod
+			desired int32
+			output  corev1.PodStatus
+		)
+
+		RemotePod := func(name, ip string, ready bool, restarts int32) *corev1.Pod {
+			status := corev1.ConditionFalse
+			if ready {
+				status = corev1.ConditionTrue
+			}
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "remote-namespace"},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodRunning, PodIP: ip,
+					Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+					ContainerStatuses: []corev1.ContainerStatus{{Name: "foo", Ready: ready, RestartCount: restarts}},
+				},
+			}
+		}
+
+		FindCondition := func(ctype corev1.PodConditionType) corev1.ConditionStatus {
+			for idx := range output.Conditions {
+				if output.Conditions[idx].Type == ctype {
+					return output.Conditions[idx].Status
+				}
+			}
+			return corev1.ConditionUnknown
+		}
+
+		BeforeEach(func() { local = &corev1.PodStatus{} })
+		JustBeforeEach(func() { output = forge.LocalExpandedPodStatus(local, remotes, desired, Translator) })
+
+		When("no remote pods exist", func() {
+			BeforeEach(func() { remotes = nil; desired = 2 })
+
+			It("should be pending", func() { Expect(output.Phase).To(Equal(corev1.PodPending)) })
+			It("should not be ready", func() { Expect(FindCondition(corev1.PodReady)).To(Equal(corev1.ConditionFalse)) })
+			It("should report the number of ready pods", func() { Expect(output.Message).To(Equal("0/2 remote pods ready")) })
+		})
+
+		When("only part of the remote pods is ready", func() {
+			BeforeEach(func() {
+				remotes = []*corev1.Pod{RemotePod("pod-b", "2.2.2.2", true, 1), RemotePod("pod-a", "1.1.1.1", false, 2)}
+				desired = 2
+			})
+
+			It("should be running", func() { Expect(output.Phase).To(Equal(corev1.PodRunning)) })
+			It("should not be ready", func() { Expect(FindCondition(corev1.PodReady)).To(Equal(corev1.ConditionFalse)) })
+			It("should select the IP of the ready pod", func() { Expect(output.PodIP).To(Equal("2.2.2.2-reflected")) })
+			It("should set the host IP", func() { Expect(output.HostIP).To(Equal(LiqoNodeIP)) })
+			It("should aggregate the container statuses", func() {
+				Expect(output.ContainerStatuses).To(HaveLen(1))
+				Expect(output.ContainerStatuses[0].Ready).To(BeFalse())
+				Expect(output.ContainerStatuses[0].RestartCount).To(BeNumerically("==", 3))
+			})
+			It("should report the number of ready pods", func() { Expect(output.Message).To(Equal("1/2 remote pods ready")) })
+		})
+
+		When("all the remote pods are ready", func() {
+			BeforeEach(func() {
+				remotes = []*corev1.Pod{RemotePod("pod-b", "2.2.2.2", true, 0), RemotePod("pod-a", "1.1.1.1", true, 0)}
+				desired = 2
+			})
+
+			It("should be running", func() { Expect(output.Phase).To(Equal(corev1.PodRunning)) })
+			It("should be ready", func() {
+				Expect(FindCondition(corev1.PodReady)).To(Equal(corev1.ConditionTrue))
+				Expect(FindCondition(corev1.ContainersReady)).To(Equal(corev1.ConditionTrue))
+			})
+			It("should select the IP of the first ready pod", func() { Expect(output.PodIP).To(Equal("1.1.1.1-reflected")) })
+			It("should mark the containers as ready", func() { Expect(output.ContainerStatuses[0].Ready).To(BeTrue()) })
+			It("should report the number of ready pods", func() { Expect(output.Message).To(Equal("2/2 remote pods ready")) })
+
+			When("the conditions were already set", func() {
+				var previous corev1.PodStatus
+
+				BeforeEach(func() {
+					previous = forge.LocalExpandedPodStatus(local, remotes, desired, Translator)
+					local = &previous
+				})
+
+				It("should preserve the transition times", func() { Expect(output.Conditions).To(Equal(previous.Conditions)) })
+			})
+		})
+	})
+})
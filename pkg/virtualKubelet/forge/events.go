@@ -28,6 +28,12 @@ const (
 
 	// EventReflectionDisabled -> the reason for the event when reflection is disabled for the given namespace/object.
 	EventReflectionDisabled = "ReflectionDisabled"
+
+	// EventSplitBrainDetected -> the reason for the event when the local and remote objects diverged (e.g., during a disconnection).
+	EventSplitBrainDetected = "SplitBrainDetected"
+
+	// EventExtraRemoteReplicas -> the reason for the event when the remote workload runs more pods than the local one.
+	EventExtraRemoteReplicas = "ExtraRemoteReplicas"
 )
 
 // EventSuccessfulReflectionMsg returns the message for the event when the outgoing reflection completes successfully.
@@ -85,3 +91,32 @@ func EventObjectReflectionDisabledMsg() string {
 func EventSAReflectionDisabledMsg() string {
 	return fmt.Sprintf("Reflection to cluster %q disabled for secrets holding service account tokens", RemoteCluster.ClusterName)
 }
+
+// EventSplitBrainRemoteVanishedMsg returns the message for the event when the remote object vanished while the local one is still running.
+func EventSplitBrainRemoteVanishedMsg() string {
+	return fmt.Sprintf("Remote object in cluster %q vanished while the local one is still running: recreating it", RemoteCluster.ClusterName)
+}
+
+// EventSplitBrainLocalVanishedMsg returns the message for the event when the remote object has been deleted, as the local one vanished.
+func EventSplitBrainLocalVanishedMsg(local string) string {
+	return fmt.Sprintf("Local object %q vanished while the remote one in cluster %q was still running: remote object deleted",
+		local, RemoteCluster.ClusterName)
+}
+
+// EventSplitBrainLocalVanishedErrorMsg returns the message for the event when the remote object could not be deleted,
+// although the local one vanished.
+func EventSplitBrainLocalVanishedErrorMsg(local string, err error) string {
+	return fmt.Sprintf("Local object %q vanished while the remote one in cluster %q is still running: error deleting it: %v",
+		local, RemoteCluster.ClusterName, err)
+}
+
+// EventSplitBrainLocalTerminatedMsg returns the message for the event when the local object terminated while the remote one is still running.
+func EventSplitBrainLocalTerminatedMsg() string {
+	return fmt.Sprintf("Local object terminated while the remote one in cluster %q is still running", RemoteCluster.ClusterName)
+}
+
+// EventExtraRemoteReplicasMsg returns the message for the event when the remote workload runs pods not bound to any local one.
+func EventExtraRemoteReplicasMsg(extra int) string {
+	return fmt.Sprintf("%d remote pods in cluster %q are not bound to any local pod (e.g., as scaled by a remote autoscaler)",
+		extra, RemoteCluster.ClusterName)
+}
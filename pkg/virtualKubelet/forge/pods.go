@@ -36,6 +36,8 @@ const (
 	PodOffloadingBackOffReason = "OffloadingBackOff"
 	// PodOffloadingAbortedReason -> the reason assigned to pods rejected by the virtual kubelet after offloading has started.
 	PodOffloadingAbortedReason = "OffloadingAborted"
+	// PodOffloadingForbiddenReason -> the reason assigned to pods rejected since requiring privileged features not permitted by the provider.
+	PodOffloadingForbiddenReason = "OffloadingForbidden"
 
 	// ServiceAccountVolumeName is the prefix name that will be added to volumes that mount ServiceAccount secrets.
 	// This constant is taken from kubernetes/kubernetes (plugin/pkg/admission/serviceaccount/admission.go).
@@ -176,10 +178,11 @@ func RemotePodSpec(local, remote *corev1.PodSpec, enableAPIServerSupport bool,
 	// present, and the remote creation would fail as the corresponding service account is not present.
 	remote.AutomountServiceAccountToken = pointer.Bool(false)
 
-	// This fields are currently forced to false, to prevent invasive settings on the remote cluster (which might not work).
-	remote.HostIPC = false
-	remote.HostNetwork = false
-	remote.HostPID = false
+	// These fields are propagated unchanged, as the provider cluster is in charge of rejecting the corresponding
+	// shadowpod at admission time in case it does not permit the given privileged features.
+	remote.HostIPC = local.HostIPC
+	remote.HostNetwork = local.HostNetwork
+	remote.HostPID = local.HostPID
 
 	return *remote
 }
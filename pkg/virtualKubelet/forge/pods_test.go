@@ -144,7 +144,7 @@ var _ = Describe("Pod forging", func() {
 			local = &corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{Name: "local-name", Namespace: "local-namespace",
 					Labels: map[string]string{"foo": "bar", consts.LocalPodLabelKey: consts.LocalPodLabelValue}},
-				Spec: corev1.PodSpec{TerminationGracePeriodSeconds: pointer.Int64(15)},
+				Spec: corev1.PodSpec{TerminationGracePeriodSeconds: pointer.Int64(15), HostNetwork: true},
 			}
 		})
 
@@ -166,6 +166,12 @@ var _ = Describe("Pod forging", func() {
 				// Here we assert only a single field, leaving the complete checks to the child functions tests.
 				Expect(output.Spec.Pod.TerminationGracePeriodSeconds).To(PointTo(BeNumerically("==", 15)))
 			})
+
+			It("should propagate the privileged pod features unchanged", func() {
+				Expect(output.Spec.Pod.HostNetwork).To(BeTrue())
+				Expect(output.Spec.Pod.HostIPC).To(BeFalse())
+				Expect(output.Spec.Pod.HostPID).To(BeFalse())
+			})
 		})
 
 		Context("the remote pod already exists", func() {
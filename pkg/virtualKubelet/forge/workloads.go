@@ -0,0 +1,227 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forge
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+)
+
+const (
+	// LiqoRemoteWorkloadKey is the key of a label identifying the remote pods belonging to a workload reflected as a whole.
+	LiqoRemoteWorkloadKey = "virtualkubelet.liqo.io/remote-workload"
+	// LiqoTemplateHashKey is the key of an annotation storing the hash of the pod template of a workload reflected as a whole.
+	LiqoTemplateHashKey = "virtualkubelet.liqo.io/template-hash"
+	// LiqoBoundRemotePodKey is the key of an annotation storing the name of the remote pod bound to a local pod belonging to a Deployment.
+	LiqoBoundRemotePodKey = "virtualkubelet.liqo.io/bound-remote-pod"
+
+	// DeploymentKind is the kind of the Deployment objects.
+	DeploymentKind = "Deployment"
+	// StatefulSetKind is the kind of the StatefulSet objects.
+	StatefulSetKind = "StatefulSet"
+	// replicaSetKind is the kind of the ReplicaSet objects.
+	replicaSetKind = "ReplicaSet"
+	// workloadControllerAnnotationPrefix is the prefix of the annotations set by the Deployment controller.
+	workloadControllerAnnotationPrefix = "deployment.kubernetes.io/"
+)
+
+// IsRemoteWorkload returns whether the given workload is marked to be reflected as a whole to the remote cluster.
+func IsRemoteWorkload(obj metav1.Object) bool {
+	value, found := obj.GetAnnotations()[liqoconst.RemoteWorkloadAnnotationKey]
+	if !found {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	return err == nil && enabled
+}
+
+// PodWorkload returns the kind and the name of the Deployment or StatefulSet controlling the given pod, if any.
+// The name of the Deployment is inferred from the one of the controlling ReplicaSet, stripping the pod template hash.
+func PodWorkload(local *corev1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(local)
+	if owner == nil || owner.APIVersion != appsv1.SchemeGroupVersion.String() {
+		return "", ""
+	}
+
+	switch owner.Kind {
+	case StatefulSetKind:
+		return StatefulSetKind, owner.Name
+	case replicaSetKind:
+		hash, found := local.GetLabels()[appsv1.DefaultDeploymentUniqueLabelKey]
+		if !found || !strings.HasSuffix(owner.Name, "-"+hash) {
+			return "", ""
+		}
+		return DeploymentKind, strings.TrimSuffix(owner.Name, "-"+hash)
+	default:
+		return "", ""
+	}
+}
+
+// RemoteWorkloadSelector returns the label selector matching the remote pods belonging to the given workload.
+func RemoteWorkloadSelector(name string) labels.Selector {
+	return labels.Set{LiqoRemoteWorkloadKey: name}.AsSelectorPreValidated()
+}
+
+// LocalPodBoundRemotePodPatch forges the merge patch to store the name of the remote pod bound to the local one.
+func LocalPodBoundRemotePodPatch(remote string) []byte {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]string{LiqoBoundRemotePodKey: remote}},
+	})
+	utilruntime.Must(err)
+	return patch
+}
+
+// BoundReplicas returns the number of active local pods belonging to the given workload and scheduled on the virtual node,
+// which corresponds to the number of replicas to be run by the remote workload.
+func BoundReplicas(kind, name string, pods []*corev1.Pod) *int32 {
+	var replicas int32
+	for _, pod := range pods {
+		if !pod.DeletionTimestamp.IsZero() || pod.Spec.NodeName != LiqoNodeName {
+			continue
+		}
+		if podKind, podName := PodWorkload(pod); podKind == kind && podName == name {
+			replicas++
+		}
+	}
+	return &replicas
+}
+
+// IsAutoscaled returns whether any of the given HorizontalPodAutoscalers targets the workload with the given kind and name.
+func IsAutoscaled(kind, name string, hpas []*autoscalingv2.HorizontalPodAutoscaler) bool {
+	for _, hpa := range hpas {
+		target := hpa.Spec.ScaleTargetRef
+		if target.APIVersion == appsv1.SchemeGroupVersion.String() && target.Kind == kind && target.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteDeployment forges the Deployment reflected as a whole to the remote cluster. The replicas are nil in case the remote
+// ones are managed by an autoscaler, hence preserving the ones of the existing remote object (or defaulting to one, if absent).
+func RemoteDeployment(local, remote *appsv1.Deployment, replicas *int32, targetNamespace string) *appsv1.Deployment {
+	if remote == nil {
+		// The remote is nil if not already created.
+		remote = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: local.GetName(), Namespace: targetNamespace}}
+	}
+	if replicas == nil {
+		replicas = remote.Spec.Replicas
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: RemoteWorkloadObjectMeta(&local.ObjectMeta, &remote.ObjectMeta, &local.Spec.Template),
+		Spec: appsv1.DeploymentSpec{
+			Replicas:                replicas,
+			Selector:                &metav1.LabelSelector{MatchLabels: map[string]string{LiqoRemoteWorkloadKey: local.GetName()}},
+			Template:                RemoteWorkloadTemplate(local.GetName(), &local.Spec.Template),
+			Strategy:                local.Spec.Strategy,
+			MinReadySeconds:         local.Spec.MinReadySeconds,
+			RevisionHistoryLimit:    local.Spec.RevisionHistoryLimit,
+			Paused:                  local.Spec.Paused,
+			ProgressDeadlineSeconds: local.Spec.ProgressDeadlineSeconds,
+		},
+	}
+}
+
+// RemoteStatefulSet forges the StatefulSet reflected as a whole to the remote cluster. The replicas are nil in case the remote
+// ones are managed by an autoscaler, hence preserving the ones of the existing remote object (or defaulting to one, if absent).
+func RemoteStatefulSet(local, remote *appsv1.StatefulSet, replicas *int32, targetNamespace string) *appsv1.StatefulSet {
+	if remote == nil {
+		// The remote is nil if not already created.
+		remote = &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: local.GetName(), Namespace: targetNamespace}}
+	}
+	if replicas == nil {
+		replicas = remote.Spec.Replicas
+	}
+
+	output := &appsv1.StatefulSet{
+		ObjectMeta: RemoteWorkloadObjectMeta(&local.ObjectMeta, &remote.ObjectMeta, &local.Spec.Template),
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:                             replicas,
+			Selector:                             &metav1.LabelSelector{MatchLabels: map[string]string{LiqoRemoteWorkloadKey: local.GetName()}},
+			Template:                             RemoteWorkloadTemplate(local.GetName(), &local.Spec.Template),
+			VolumeClaimTemplates:                 local.Spec.VolumeClaimTemplates,
+			ServiceName:                          local.Spec.ServiceName,
+			PodManagementPolicy:                  local.Spec.PodManagementPolicy,
+			UpdateStrategy:                       local.Spec.UpdateStrategy,
+			RevisionHistoryLimit:                 local.Spec.RevisionHistoryLimit,
+			MinReadySeconds:                      local.Spec.MinReadySeconds,
+			PersistentVolumeClaimRetentionPolicy: local.Spec.PersistentVolumeClaimRetentionPolicy,
+		},
+	}
+
+	// Preserve the immutable fields of an already existing remote object, as possibly defaulted by the remote API server.
+	if remote.GetResourceVersion() != "" {
+		output.Spec.VolumeClaimTemplates = remote.Spec.VolumeClaimTemplates
+		output.Spec.ServiceName = remote.Spec.ServiceName
+		output.Spec.PodManagementPolicy = remote.Spec.PodManagementPolicy
+	}
+
+	return output
+}
+
+// RemoteWorkloadObjectMeta forges the object meta of a workload reflected as a whole, including the hash of the local pod template.
+// The annotations set by the local workload controllers are not propagated, as they would conflict with the remote ones.
+func RemoteWorkloadObjectMeta(local, remote *metav1.ObjectMeta, template *corev1.PodTemplateSpec) metav1.ObjectMeta {
+	filtered := local.DeepCopy()
+	for key := range filtered.GetAnnotations() {
+		if strings.HasPrefix(key, workloadControllerAnnotationPrefix) {
+			delete(filtered.Annotations, key)
+		}
+	}
+
+	output := RemoteObjectMeta(filtered, remote)
+	output.SetAnnotations(labels.Merge(output.GetAnnotations(), map[string]string{LiqoTemplateHashKey: RemoteTemplateHash(template)}))
+	return output
+}
+
+// RemoteWorkloadTemplate forges the pod template of a workload reflected as a whole to the remote cluster.
+// Remote workloads do not support the interaction with the local API server, as the service account tokens
+// would need to be handled for pods not known in advance.
+func RemoteWorkloadTemplate(name string, local *corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	noSecret := func(string) string { return "" }
+	noAddress := func() string { return "" }
+
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      labels.Merge(local.GetLabels(), labels.Merge(ReflectionLabels(), map[string]string{LiqoRemoteWorkloadKey: name})),
+			Annotations: local.GetAnnotations(),
+		},
+		Spec: RemotePodSpec(local.Spec.DeepCopy(), &corev1.PodSpec{}, false, noSecret, noAddress),
+	}
+}
+
+// RemoteTemplateHash returns the hash of the given local pod template, to detect whether the remote one needs to be updated,
+// since the direct comparison is not effective due to the defaulting performed by the remote API server.
+func RemoteTemplateHash(template *corev1.PodTemplateSpec) string {
+	encoded, err := json.Marshal(template)
+	utilruntime.Must(err)
+
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(encoded)
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
@@ -0,0 +1,271 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forge_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+var _ = Describe("Workloads forging", func() {
+	Describe("the IsRemoteWorkload function", func() {
+		DescribeTable("should correctly detect the annotation",
+			func(annotations map[string]string, expected bool) {
+				obj := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "name", Annotations: annotations}}
+				Expect(forge.IsRemoteWorkload(obj)).To(BeIdenticalTo(expected))
+			},
+			Entry("no annotations", nil, false),
+			Entry("annotation set to true", map[string]string{consts.RemoteWorkloadAnnotationKey: "true"}, true),
+			Entry("annotation set to false", map[string]string{consts.RemoteWorkloadAnnotationKey: "false"}, false),
+			Entry("annotation set to an invalid value", map[string]string{consts.RemoteWorkloadAnnotationKey: "foo"}, false),
+		)
+	})
+
+	Describe("the PodWorkload function", func() {
+		var (
+			local      *corev1.Pod
+			kind, name string
+		)
+
+		BeforeEach(func() {
+			local = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "local-name", Namespace: "local-namespace"}}
+		})
+		JustBeforeEach(func() { kind, name = forge.PodWorkload(local) })
+
+		When("the pod is controlled by a ReplicaSet belonging to a Deployment", func() {
+			BeforeEach(func() {
+				local.Labels = map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "abcde"}
+				local.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo-abcde", Controller: pointer.Bool(true)}}
+			})
+			It("should return the deployment", func() {
+				Expect(kind).To(Equal(forge.DeploymentKind))
+				Expect(name).To(Equal("foo"))
+			})
+		})
+
+		When("the pod is controlled by a standalone ReplicaSet", func() {
+			BeforeEach(func() {
+				local.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo", Controller: pointer.Bool(true)}}
+			})
+			It("should return no workload", func() { Expect(kind).To(BeEmpty()) })
+		})
+
+		When("the pod is controlled by a StatefulSet", func() {
+			BeforeEach(func() {
+				local.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "foo", Controller: pointer.Bool(true)}}
+			})
+			It("should return the statefulset", func() {
+				Expect(kind).To(Equal(forge.StatefulSetKind))
+				Expect(name).To(Equal("foo"))
+			})
+		})
+
+		When("the pod is not controlled by any object", func() {
+			It("should return no workload", func() { Expect(kind).To(BeEmpty()) })
+		})
+	})
+
+	Describe("the LocalPodBoundRemotePodPatch function", func() {
+		It("should forge a merge patch setting the bound remote pod annotation", func() {
+			Expect(string(forge.LocalPodBoundRemotePodPatch("remote-name"))).To(MatchJSON(
+				`{"metadata":{"annotations":{"virtualkubelet.liqo.io/bound-remote-pod":"remote-name"}}}`))
+		})
+	})
+
+	Describe("the BoundReplicas function", func() {
+		var pods []*corev1.Pod
+
+		ForgePod := func(name, owner, node string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name, Labels: map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "abcde"},
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: owner, Controller: pointer.Bool(true)}},
+				},
+				Spec: corev1.PodSpec{NodeName: node},
+			}
+		}
+
+		BeforeEach(func() {
+			terminating := ForgePod("terminating", "foo-abcde", LiqoNodeName)
+			terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			pods = []*corev1.Pod{
+				ForgePod("bound-1", "foo-abcde", LiqoNodeName), ForgePod("bound-2", "foo-abcde", LiqoNodeName),
+				ForgePod("other-node", "foo-abcde", "other"), ForgePod("other-workload", "bar-abcde", LiqoNodeName), terminating,
+			}
+		})
+
+		It("should count only the active pods of the workload scheduled on the virtual node", func() {
+			Expect(forge.BoundReplicas(forge.DeploymentKind, "foo", pods)).To(PointTo(BeNumerically("==", 2)))
+			Expect(forge.BoundReplicas(forge.StatefulSetKind, "foo", pods)).To(PointTo(BeNumerically("==", 0)))
+		})
+	})
+
+	Describe("the IsAutoscaled function", func() {
+		ForgeHPA := func(kind, name string) *autoscalingv2.HorizontalPodAutoscaler {
+			return &autoscalingv2.HorizontalPodAutoscaler{Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: kind, Name: name}}}
+		}
+
+		DescribeTable("should correctly detect whether the workload is targeted by an autoscaler",
+			func(hpas []*autoscalingv2.HorizontalPodAutoscaler, expected bool) {
+				Expect(forge.IsAutoscaled(forge.DeploymentKind, "foo", hpas)).To(BeIdenticalTo(expected))
+			},
+			Entry("no autoscalers", nil, false),
+			Entry("an autoscaler targeting the workload", []*autoscalingv2.HorizontalPodAutoscaler{ForgeHPA(forge.DeploymentKind, "foo")}, true),
+			Entry("an autoscaler targeting a different kind", []*autoscalingv2.HorizontalPodAutoscaler{ForgeHPA(forge.StatefulSetKind, "foo")}, false),
+			Entry("an autoscaler targeting a different name", []*autoscalingv2.HorizontalPodAutoscaler{ForgeHPA(forge.DeploymentKind, "bar")}, false),
+		)
+	})
+
+	Describe("the RemoteDeployment function", func() {
+		var (
+			local, remote, output *appsv1.Deployment
+			replicas              *int32
+		)
+
+		BeforeEach(func() {
+			local = &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "local-name", Namespace: "local-namespace", Labels: map[string]string{"foo": "bar"},
+					Annotations: map[string]string{consts.RemoteWorkloadAnnotationKey: "true", "deployment.kubernetes.io/revision": "3"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: pointer.Int32(3),
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "foo"}},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "foo/bar:v0.1-alpha"}}},
+					},
+				},
+			}
+			remote = nil
+			replicas = pointer.Int32(1)
+		})
+
+		JustBeforeEach(func() { output = forge.RemoteDeployment(local, remote, replicas, "remote-namespace") })
+
+		It("should correctly set the name and namespace", func() {
+			Expect(output.GetName()).To(Equal("local-name"))
+			Expect(output.GetNamespace()).To(Equal("remote-namespace"))
+		})
+		It("should correctly set the labels", func() {
+			Expect(output.GetLabels()).To(HaveKeyWithValue("foo", "bar"))
+			Expect(output.GetLabels()).To(HaveKeyWithValue(forge.LiqoOriginClusterIDKey, LocalClusterID))
+			Expect(output.GetLabels()).To(HaveKeyWithValue(forge.LiqoDestinationClusterIDKey, RemoteClusterID))
+		})
+		It("should correctly set the annotations", func() {
+			Expect(output.GetAnnotations()).To(HaveKeyWithValue(consts.RemoteWorkloadAnnotationKey, "true"))
+			Expect(output.GetAnnotations()).To(HaveKeyWithValue(forge.LiqoTemplateHashKey, forge.RemoteTemplateHash(&local.Spec.Template)))
+			Expect(output.GetAnnotations()).ToNot(HaveKey("deployment.kubernetes.io/revision"))
+		})
+		It("should correctly set the replicas and the selector", func() {
+			Expect(output.Spec.Replicas).To(PointTo(BeNumerically("==", 1)))
+			Expect(output.Spec.Selector.MatchLabels).To(Equal(map[string]string{forge.LiqoRemoteWorkloadKey: "local-name"}))
+		})
+		It("should correctly forge the template", func() {
+			Expect(output.Spec.Template.GetLabels()).To(HaveKeyWithValue("app", "foo"))
+			Expect(output.Spec.Template.GetLabels()).To(HaveKeyWithValue(forge.LiqoRemoteWorkloadKey, "local-name"))
+			Expect(output.Spec.Template.GetLabels()).To(HaveKeyWithValue(forge.LiqoOriginClusterIDKey, LocalClusterID))
+			Expect(output.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(output.Spec.Template.Spec.Containers[0].Image).To(Equal("foo/bar:v0.1-alpha"))
+		})
+
+		When("the remote replicas are managed by an autoscaler", func() {
+			BeforeEach(func() {
+				replicas = nil
+				remote = &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "local-name", Namespace: "remote-namespace"},
+					Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(5)},
+				}
+			})
+
+			It("should preserve the remote replicas", func() {
+				Expect(output.Spec.Replicas).To(PointTo(BeNumerically("==", 5)))
+			})
+		})
+
+		When("the local template changes", func() {
+			var previous *appsv1.Deployment
+
+			BeforeEach(func() {
+				previous = forge.RemoteDeployment(local, nil, replicas, "remote-namespace")
+				local.Spec.Template.Spec.Containers[0].Image = "foo/bar:v0.2"
+			})
+
+			It("should change the template hash", func() {
+				Expect(output.GetAnnotations()[forge.LiqoTemplateHashKey]).ToNot(Equal(previous.GetAnnotations()[forge.LiqoTemplateHashKey]))
+			})
+		})
+	})
+
+	Describe("the RemoteStatefulSet function", func() {
+		var (
+			local, remote, output *appsv1.StatefulSet
+			replicas              *int32
+		)
+
+		BeforeEach(func() {
+			local = &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "local-name", Namespace: "local-namespace"},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:    pointer.Int32(2),
+					ServiceName: "service",
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "foo/bar:v0.1-alpha"}}},
+					},
+				},
+			}
+			remote = nil
+			replicas = pointer.Int32(2)
+		})
+
+		JustBeforeEach(func() { output = forge.RemoteStatefulSet(local, remote, replicas, "remote-namespace") })
+
+		It("should correctly set the spec", func() {
+			Expect(output.Spec.Replicas).To(PointTo(BeNumerically("==", 2)))
+			Expect(output.Spec.ServiceName).To(Equal("service"))
+			Expect(output.Spec.Selector.MatchLabels).To(Equal(map[string]string{forge.LiqoRemoteWorkloadKey: "local-name"}))
+		})
+
+		When("the remote object already exists", func() {
+			BeforeEach(func() {
+				remote = &appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "local-name", Namespace: "remote-namespace", ResourceVersion: "10"},
+					Spec:       appsv1.StatefulSetSpec{ServiceName: "previous", PodManagementPolicy: appsv1.OrderedReadyPodManagement},
+				}
+			})
+
+			It("should preserve the immutable fields", func() {
+				Expect(output.Spec.ServiceName).To(Equal("previous"))
+				Expect(output.Spec.PodManagementPolicy).To(Equal(appsv1.OrderedReadyPodManagement))
+			})
+			It("should update the mutable fields", func() {
+				Expect(output.Spec.Replicas).To(PointTo(BeNumerically("==", 2)))
+				Expect(output.GetResourceVersion()).To(Equal("10"))
+			})
+		})
+	})
+})
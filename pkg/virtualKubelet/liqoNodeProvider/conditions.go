@@ -97,11 +97,15 @@ func nodePIDPressureStatus(pressure bool) func() (corev1.ConditionStatus, string
 }
 
 // nodeNetworkUnavailableStatus returns a function containing the condition information about the networking status.
-func nodeNetworkUnavailableStatus(unavailable bool) func() (corev1.ConditionStatus, string, string) {
+// The condition explicitly reports whether the cluster interconnection is unencrypted (e.g., a plain GRE tunnel).
+func nodeNetworkUnavailableStatus(unavailable, encrypted bool) func() (corev1.ConditionStatus, string, string) {
 	return func() (status corev1.ConditionStatus, reason, message string) {
 		if unavailable {
 			return corev1.ConditionTrue, "LiqoNetworkingDown", "The Liqo cluster interconnection is down"
 		}
+		if !encrypted {
+			return corev1.ConditionFalse, "LiqoNetworkingUpUnencrypted", "The Liqo cluster interconnection is established, but it is not encrypted"
+		}
 		return corev1.ConditionFalse, "LiqoNetworkingUp", "The Liqo cluster interconnection is established"
 	}
 }
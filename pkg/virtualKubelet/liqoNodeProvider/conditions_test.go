@@ -187,17 +187,23 @@ var _ = Describe("Liqo node conditions generation", func() {
 				ExpectedMessage: "The remote cluster is advertising sufficient resources",
 			}),
 			Entry("of the network unavailable condition, when set", StatusGenerationCase{
-				Generator:       nodeNetworkUnavailableStatus(true),
+				Generator:       nodeNetworkUnavailableStatus(true, true),
 				ExpectedStatus:  corev1.ConditionTrue,
 				ExpectedReason:  "LiqoNetworkingDown",
 				ExpectedMessage: "The Liqo cluster interconnection is down",
 			}),
 			Entry("of the network unavailable condition, when unset", StatusGenerationCase{
-				Generator:       nodeNetworkUnavailableStatus(false),
+				Generator:       nodeNetworkUnavailableStatus(false, true),
 				ExpectedStatus:  corev1.ConditionFalse,
 				ExpectedReason:  "LiqoNetworkingUp",
 				ExpectedMessage: "The Liqo cluster interconnection is established",
 			}),
+			Entry("of the network unavailable condition, when unset and unencrypted", StatusGenerationCase{
+				Generator:       nodeNetworkUnavailableStatus(false, false),
+				ExpectedStatus:  corev1.ConditionFalse,
+				ExpectedReason:  "LiqoNetworkingUpUnencrypted",
+				ExpectedMessage: "The Liqo cluster interconnection is established, but it is not encrypted",
+			}),
 		)
 	})
 
@@ -0,0 +1,80 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package liqonodeprovider
+
+import (
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// NotifyReconnection registers the function to be executed when the remote API server becomes reachable again after a disconnection,
+// as well as when the tunnel towards the remote cluster is connected again after having been interrupted.
+func (p *LiqoNodeProvider) NotifyReconnection(f func()) {
+	p.updateMutex.Lock()
+	defer p.updateMutex.Unlock()
+	p.onReconnectionCallback = f
+}
+
+// handleDisconnection masks the readiness check failures as long as the disconnection is tolerated, so that the virtual node
+// is not marked as NotReady, hence preventing the eviction of the offloaded pods and freezing their local status.
+func (p *LiqoNodeProvider) handleDisconnection(err error) error {
+	p.updateMutex.Lock()
+	defer p.updateMutex.Unlock()
+
+	if p.disconnectedSince.IsZero() {
+		p.disconnectedSince = time.Now()
+	}
+
+	if elapsed := time.Since(p.disconnectedSince); elapsed < p.disconnectionTolerance {
+		klog.Warningf("Remote API server unreachable for %v, tolerating the disconnection up to %v",
+			elapsed.Round(time.Second), p.disconnectionTolerance)
+		return nil
+	}
+
+	return err
+}
+
+// handleReconnection triggers the registered callback (if any) when the remote API server becomes reachable after a disconnection.
+func (p *LiqoNodeProvider) handleReconnection() {
+	p.updateMutex.Lock()
+	defer p.updateMutex.Unlock()
+
+	if p.disconnectedSince.IsZero() {
+		return
+	}
+
+	klog.Infof("Remote API server reachable again, after a disconnection of %v", time.Since(p.disconnectedSince).Round(time.Second))
+	p.disconnectedSince = time.Time{}
+	if p.onReconnectionCallback != nil {
+		go p.onReconnectionCallback()
+	}
+}
+
+// handleNetworkRestored triggers the registered callback (if any) when the tunnel towards the remote cluster is connected again
+// after having been interrupted, so that the resynchronization is performed also in case the readiness checks are disabled.
+// It is expected to be called with the update mutex held.
+func (p *LiqoNodeProvider) handleNetworkRestored() {
+	if p.networkReady {
+		return
+	}
+
+	restored := p.networkConnected
+	p.networkConnected = true
+	if restored && p.onReconnectionCallback != nil {
+		klog.Info("Tunnel towards the remote cluster connected again, after an interruption")
+		go p.onReconnectionCallback()
+	}
+}
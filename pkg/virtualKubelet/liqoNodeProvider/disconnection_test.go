@@ -0,0 +1,92 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package liqonodeprovider
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Disconnection handling", func() {
+	var (
+		provider    *LiqoNodeProvider
+		reconnected chan struct{}
+		failure     error
+	)
+
+	BeforeEach(func() {
+		provider = &LiqoNodeProvider{}
+		reconnected = make(chan struct{}, 1)
+		failure = errors.New("unreachable")
+		provider.NotifyReconnection(func() { reconnected <- struct{}{} })
+	})
+
+	When("the disconnection is not tolerated", func() {
+		It("should return the original error", func() {
+			Expect(provider.handleDisconnection(failure)).To(MatchError(failure))
+		})
+	})
+
+	When("the disconnection is tolerated", func() {
+		BeforeEach(func() { provider.disconnectionTolerance = time.Minute })
+
+		It("should mask the error within the tolerance", func() {
+			Expect(provider.handleDisconnection(failure)).To(Succeed())
+			Expect(provider.disconnectedSince).ToNot(BeZero())
+		})
+
+		It("should return the original error once the tolerance expired", func() {
+			provider.disconnectedSince = time.Now().Add(-2 * time.Minute)
+			Expect(provider.handleDisconnection(failure)).To(MatchError(failure))
+		})
+	})
+
+	When("the remote API server becomes reachable", func() {
+		It("should not trigger the callback if no disconnection occurred", func() {
+			provider.handleReconnection()
+			Consistently(reconnected).ShouldNot(Receive())
+		})
+
+		It("should trigger the callback after a disconnection", func() {
+			Expect(provider.handleDisconnection(failure)).To(MatchError(failure))
+			provider.handleReconnection()
+			Eventually(reconnected).Should(Receive())
+			Expect(provider.disconnectedSince).To(BeZero())
+		})
+	})
+
+	When("the tunnel towards the remote cluster is connected", func() {
+		It("should not trigger the callback upon the first connection", func() {
+			provider.handleNetworkRestored()
+			Consistently(reconnected).ShouldNot(Receive())
+		})
+
+		It("should trigger the callback once connected again after an interruption", func() {
+			provider.handleNetworkRestored()
+			provider.networkReady = true
+
+			By("ignoring the updates while still connected")
+			provider.handleNetworkRestored()
+			Consistently(reconnected).ShouldNot(Receive())
+
+			provider.networkReady = false
+			provider.handleNetworkRestored()
+			Eventually(reconnected).Should(Receive())
+		})
+	})
+})
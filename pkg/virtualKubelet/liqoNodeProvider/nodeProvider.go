@@ -42,7 +42,14 @@ type LiqoNodeProvider struct {
 	resyncPeriod     time.Duration
 	pingDisabled     bool
 
-	networkReady bool
+	disconnectionTolerance time.Duration
+	disconnectedSince      time.Time
+	onReconnectionCallback func()
+
+	networkReady     bool
+	networkEncrypted bool
+	// networkConnected is whether the tunnel towards the remote cluster has ever been connected.
+	networkConnected bool
 
 	onNodeChangeCallback func(*corev1.Node)
 	updateMutex          sync.Mutex
@@ -60,10 +67,11 @@ func (p *LiqoNodeProvider) Ping(ctx context.Context) error {
 	_, err := p.remoteDiscoveryClient.RESTClient().Get().AbsPath("/livez").DoRaw(ctx)
 	if err != nil {
 		klog.Errorf("API server readiness check failed: %v", err)
-		return err
+		return p.handleDisconnection(err)
 	}
 
 	klog.V(4).Infof("Readiness check completed successfully in %v", time.Since(start))
+	p.handleReconnection()
 	return nil
 }
 
@@ -214,7 +214,10 @@ func (p *LiqoNodeProvider) updateFromTep(tep *netv1alpha1.TunnelEndpoint) error
 		p.networkReady = false
 		return p.updateNode()
 	}
+	p.handleNetworkRestored()
 	p.networkReady = true
+	// WireGuard is currently the only backend encrypting the traffic.
+	p.networkEncrypted = tep.Spec.BackendType == consts.DriverName
 	return p.updateNode()
 }
 
@@ -225,7 +228,7 @@ func (p *LiqoNodeProvider) updateNode() error {
 	UpdateNodeCondition(p.node, v1.NodeMemoryPressure, nodeMemoryPressureStatus(!resourcesReady))
 	UpdateNodeCondition(p.node, v1.NodeDiskPressure, nodeDiskPressureStatus(!resourcesReady))
 	UpdateNodeCondition(p.node, v1.NodePIDPressure, nodePIDPressureStatus(!resourcesReady))
-	UpdateNodeCondition(p.node, v1.NodeNetworkUnavailable, nodeNetworkUnavailableStatus(!p.networkReady))
+	UpdateNodeCondition(p.node, v1.NodeNetworkUnavailable, nodeNetworkUnavailableStatus(!p.networkReady, p.networkEncrypted))
 
 	p.onNodeChangeCallback(p.node.DeepCopy())
 	return nil
@@ -57,6 +57,8 @@ type InitConfig struct {
 	PodProviderStopper   chan struct{}
 	InformerResyncPeriod time.Duration
 	PingDisabled         bool
+
+	DisconnectionTolerance time.Duration
 }
 
 // NewLiqoNodeProvider creates and returns a new LiqoNodeProvider.
@@ -74,6 +76,8 @@ func NewLiqoNodeProvider(cfg *InitConfig) *LiqoNodeProvider {
 		resyncPeriod: cfg.InformerResyncPeriod,
 		pingDisabled: cfg.PingDisabled,
 
+		disconnectionTolerance: cfg.DisconnectionTolerance,
+
 		nodeName:         cfg.NodeName,
 		foreignClusterID: cfg.RemoteClusterID,
 		tenantNamespace:  cfg.Namespace,
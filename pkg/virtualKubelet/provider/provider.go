@@ -64,8 +64,11 @@ type InitConfig struct {
 	PersistenVolumeClaimWorkers uint
 	ConfigMapWorkers            uint
 	SecretWorkers               uint
+	WorkloadWorkers             uint
 
 	EnableAPIServerSupport     bool
+	EnableDaemonSetExpansion   bool
+	EnableRemoteWorkloads      bool
 	EnableStorage              bool
 	VirtualStorageClassName    string
 	RemoteRealStorageClassName string
@@ -96,7 +99,21 @@ func NewLiqoProvider(ctx context.Context, cfg *InitConfig, eb record.EventBroadc
 	ipamClient := ipam.NewIpamClient(connection)
 
 	reflectionManager := manager.New(localClient, remoteClient, localLiqoClient, remoteLiqoClient, cfg.InformerResyncPeriod, eb)
-	podreflector := workload.NewPodReflector(cfg.RemoteConfig, remoteMetricsClient, ipamClient, cfg.EnableAPIServerSupport, cfg.PodWorkers)
+	podreflector := workload.NewPodReflector(&workload.PodReflectorConfig{
+		RemoteRESTConfig:         cfg.RemoteConfig,
+		RemoteMetricsFactory:     remoteMetricsClient,
+		IpamClient:               ipamClient,
+		EnableAPIServerSupport:   cfg.EnableAPIServerSupport,
+		EnableDaemonSetExpansion: cfg.EnableDaemonSetExpansion,
+		EnableRemoteWorkloads:    cfg.EnableRemoteWorkloads,
+	}, cfg.PodWorkers)
+
+	// The workload reflectors are started only if remote workloads are enabled, as requiring additional permissions.
+	var workloadWorkers uint
+	if cfg.EnableRemoteWorkloads {
+		workloadWorkers = cfg.WorkloadWorkers
+	}
+
 	namespaceMapHandler := namespacemap.NewHandler(localLiqoClient, cfg.Namespace, cfg.InformerResyncPeriod)
 	reflectionManager.
 		With(exposition.NewServiceReflector(cfg.ServiceWorkers)).
@@ -105,6 +122,8 @@ func NewLiqoProvider(ctx context.Context, cfg *InitConfig, eb record.EventBroadc
 		With(configuration.NewConfigMapReflector(cfg.ConfigMapWorkers)).
 		With(configuration.NewSecretReflector(cfg.EnableAPIServerSupport, cfg.SecretWorkers)).
 		With(podreflector).
+		With(workload.NewDeploymentReflector(workloadWorkers)).
+		With(workload.NewStatefulSetReflector(workloadWorkers)).
 		With(storage.NewPersistentVolumeClaimReflector(cfg.PersistenVolumeClaimWorkers,
 			cfg.VirtualStorageClassName, cfg.RemoteRealStorageClassName, cfg.EnableStorage)).
 		WithNamespaceHandler(namespaceMapHandler)
@@ -117,6 +136,12 @@ func NewLiqoProvider(ctx context.Context, cfg *InitConfig, eb record.EventBroadc
 	}, nil
 }
 
+// Resync triggers the full resynchronization of the reflected objects, to recover from the divergences
+// between the local and the remote cluster possibly accumulated during a disconnection.
+func (p *LiqoProvider) Resync() {
+	p.reflectionManager.Resync()
+}
+
 // PodHandler returns an handler to interact with the pods offloaded to the remote cluster.
 func (p *LiqoProvider) PodHandler() workload.PodHandler {
 	return p.podHandler
@@ -19,7 +19,9 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	corev1clients "k8s.io/client-go/kubernetes/typed/core/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog/v2"
@@ -33,6 +35,7 @@ import (
 )
 
 var _ manager.NamespacedReflector = (*NamespacedServiceReflector)(nil)
+var _ manager.NamespacedResyncer = (*NamespacedServiceReflector)(nil)
 
 const (
 	// ServiceReflectorName -> The name associated with the Service reflector.
@@ -141,3 +144,22 @@ func (nsr *NamespacedServiceReflector) Handle(ctx context.Context, name string)
 
 	return nil
 }
+
+// Resync returns the names of the local services, as well as of the remote ones managed by the reflection, to be reconciled.
+func (nsr *NamespacedServiceReflector) Resync() []string {
+	locals, err := nsr.localServices.List(labels.Everything())
+	utilruntime.Must(err)
+	remotes, err := nsr.remoteServices.List(labels.Everything())
+	utilruntime.Must(err)
+
+	names := sets.NewString()
+	for _, local := range locals {
+		names.Insert(local.GetName())
+	}
+	for _, remote := range remotes {
+		if forge.IsReflected(remote) {
+			names.Insert(remote.GetName())
+		}
+	}
+	return names.List()
+}
@@ -22,9 +22,10 @@ import (
 
 // NamespacedReflector implements a fake NamespacedReflector for testing purposes.
 type NamespacedReflector struct {
-	Opts    options.NamespacedOpts
-	Handled int
-	ready   bool
+	Opts        options.NamespacedOpts
+	Handled     int
+	ResyncNames []string
+	ready       bool
 }
 
 // NewNamespacedReflector returns a new fake NamespacedReflector.
@@ -43,3 +44,6 @@ func (r *NamespacedReflector) Ready() bool { return r.ready }
 
 // SetReady marks the NamespacedReflector as completely initialized.
 func (r *NamespacedReflector) SetReady() { r.ready = true }
+
+// Resync returns the names configured to be reconciled upon resynchronization.
+func (r *NamespacedReflector) Resync() []string { return r.ResyncNames }
@@ -27,6 +27,7 @@ type Reflector struct {
 	NamespaceStarted map[string]*options.NamespacedOpts
 	NamespaceStopped map[string]string
 	NamespaceReady   map[string]func() bool
+	Resynced         int
 }
 
 // NewReflector returns a new fake Reflector.
@@ -52,3 +53,8 @@ func (r *Reflector) StartNamespace(opts *options.NamespacedOpts) {
 func (r *Reflector) StopNamespace(local, remote string) {
 	r.NamespaceStopped[local] = remote
 }
+
+// Resync increases the number of resynchronizations performed.
+func (r *Reflector) Resync() {
+	r.Resynced++
+}
@@ -151,6 +151,25 @@ func (gr *reflector) StopNamespace(local, remote string) {
 	klog.Infof("Reflection between local namespace %q and remote namespace %q correctly stopped", local, remote)
 }
 
+// Resync enqueues the objects managed by the namespaced reflectors supporting the full resynchronization.
+func (gr *reflector) Resync() {
+	gr.Lock()
+	defer gr.Unlock()
+
+	for namespace, reflector := range gr.reflectors {
+		resyncer, ok := reflector.(manager.NamespacedResyncer)
+		if !ok || !reflector.Ready() {
+			continue
+		}
+
+		names := resyncer.Resync()
+		klog.Infof("Resynchronizing %v %v objects in local namespace %q", len(names), gr.name, namespace)
+		for _, name := range names {
+			gr.workqueue.Add(types.NamespacedName{Namespace: namespace, Name: name})
+		}
+	}
+}
+
 // namespace returns the service reflector associated with a given namespace (if any).
 func (gr *reflector) namespace(namespace string) (manager.NamespacedReflector, bool) {
 	gr.Lock()
@@ -291,3 +310,6 @@ func (dr *dummyreflector) StopNamespace(local, remote string) {
 	klog.Infof("Skipping stopping the %v reflection between local namespace %q and remote namespace %q, as no workers are configured",
 		dr.name, local, remote)
 }
+
+// Resync resynchronizes the managed objects (no-op).
+func (dr *dummyreflector) Resync() {}
@@ -158,6 +158,40 @@ var _ = Describe("Reflector tests", func() {
 						})
 					})
 
+					Context("the reflector is resynced", func() {
+						var ready bool
+
+						BeforeEach(func() { ready = false })
+						JustBeforeEach(func() {
+							// Drain the elements enqueued by the fallback reflector.
+							key, _ := rfl.(*reflector).workqueue.Get()
+							rfl.(*reflector).workqueue.Done(key)
+							rfl.(*reflector).workqueue.Forget(key)
+
+							nsrfl.ResyncNames = []string{"foo", "bar"}
+							if ready {
+								nsrfl.SetReady()
+							}
+							rfl.Resync()
+						})
+
+						When("the namespaced reflector is not ready", func() {
+							It("should not enqueue any element", func() { Expect(rfl.(*reflector).workqueue.Len()).To(BeNumerically("==", 0)) })
+						})
+
+						When("the namespaced reflector is ready", func() {
+							BeforeEach(func() { ready = true })
+							It("should enqueue the returned elements", func() {
+								Expect(rfl.(*reflector).workqueue.Len()).To(BeNumerically("==", 2))
+								first, _ := rfl.(*reflector).workqueue.Get()
+								second, _ := rfl.(*reflector).workqueue.Get()
+								Expect([]interface{}{first, second}).To(ConsistOf(
+									types.NamespacedName{Namespace: localNamespace, Name: "foo"},
+									types.NamespacedName{Namespace: localNamespace, Name: "bar"}))
+							})
+						})
+					})
+
 					Context("a namespaced reflector is retrieved", func() {
 						var (
 							namespace string
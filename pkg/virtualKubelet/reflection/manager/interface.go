@@ -30,6 +30,8 @@ type Manager interface {
 	WithNamespaceHandler(handler NamespaceHandler) Manager
 	// Start starts the reflection manager. It panics if executed twice.
 	Start(ctx context.Context)
+	// Resync triggers the full resynchronization of the objects managed by all reflectors.
+	Resync()
 
 	NamespaceStartStopper
 }
@@ -50,6 +52,8 @@ type Reflector interface {
 	StartNamespace(opts *options.NamespacedOpts)
 	// StopNamespace stops the reflection for a given namespace.
 	StopNamespace(local, remote string)
+	// Resync triggers the full resynchronization of the objects managed by the reflector.
+	Resync()
 }
 
 // NamespacedReflector implements the reflection between a local and a remote namespace.
@@ -60,6 +64,13 @@ type NamespacedReflector interface {
 	Ready() bool
 }
 
+// NamespacedResyncer is implemented by the NamespacedReflectors supporting the full resynchronization of the managed objects.
+type NamespacedResyncer interface {
+	// Resync checks the consistency between the local and the remote objects, reporting the detected discrepancies,
+	// and returns the names of the objects to be reconciled.
+	Resync() []string
+}
+
 // FallbackReflector implements fallback reflection for "orphan" local objects not managed by namespaced reflectors.
 type FallbackReflector interface {
 	// Handle is responsible for reconciling the given "orphan" object.
@@ -226,3 +226,19 @@ func (m *manager) StopNamespace(local, remote string) {
 	}
 	klog.Infof("Reflection between local namespace %q and remote namespace %q correctly stopped", local, remote)
 }
+
+// Resync triggers the full resynchronization of the objects managed by all reflectors.
+func (m *manager) Resync() {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.started {
+		klog.Warning("Skipping the resynchronization of the reflected objects, as the manager is not running")
+		return
+	}
+
+	klog.Info("Triggering the full resynchronization of the reflected objects")
+	for _, reflector := range m.reflectors {
+		reflector.Resync()
+	}
+}
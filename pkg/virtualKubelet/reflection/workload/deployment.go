@@ -0,0 +1,170 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	appsv1clients "k8s.io/client-go/kubernetes/typed/apps/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	autoscalingv2listers "k8s.io/client-go/listers/autoscaling/v2"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"k8s.io/utils/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/generic"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/manager"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/options"
+)
+
+var _ manager.NamespacedReflector = (*NamespacedDeploymentReflector)(nil)
+
+const (
+	// DeploymentReflectorName -> The name associated with the Deployment reflector.
+	DeploymentReflectorName = "Deployment"
+)
+
+// NamespacedDeploymentReflector manages the reflection of the Deployments marked to be offloaded as a whole,
+// for a given pair of local and remote namespaces.
+type NamespacedDeploymentReflector struct {
+	generic.NamespacedReflector
+
+	localDeployments        appsv1listers.DeploymentNamespaceLister
+	remoteDeployments       appsv1listers.DeploymentNamespaceLister
+	remoteDeploymentsClient appsv1clients.DeploymentInterface
+
+	localPods         corev1listers.PodNamespaceLister
+	remoteAutoscalers autoscalingv2listers.HorizontalPodAutoscalerNamespaceLister
+}
+
+// NewDeploymentReflector returns a new DeploymentReflector instance.
+func NewDeploymentReflector(workers uint) manager.Reflector {
+	return generic.NewReflector(DeploymentReflectorName, NewNamespacedDeploymentReflector, generic.WithoutFallback(), workers)
+}
+
+// NewNamespacedDeploymentReflector returns a new NamespacedDeploymentReflector instance.
+func NewNamespacedDeploymentReflector(opts *options.NamespacedOpts) manager.NamespacedReflector {
+	local := opts.LocalFactory.Apps().V1().Deployments()
+	remote := opts.RemoteFactory.Apps().V1().Deployments()
+
+	local.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
+	remote.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
+
+	// The local pods and the remote autoscalers are mapped to the corresponding workload, as determining the remote replicas.
+	localPods := opts.LocalFactory.Core().V1().Pods()
+	localPods.Informer().AddEventHandler(opts.HandlerFactory(WorkloadPodKeyer(opts.LocalNamespace, forge.DeploymentKind)))
+	remoteAutoscalers := opts.RemoteFactory.Autoscaling().V2().HorizontalPodAutoscalers()
+	remoteAutoscalers.Informer().AddEventHandler(opts.HandlerFactory(AutoscalerKeyer(opts.LocalNamespace, forge.DeploymentKind)))
+
+	return &NamespacedDeploymentReflector{
+		NamespacedReflector:     generic.NewNamespacedReflector(opts, DeploymentReflectorName),
+		localDeployments:        local.Lister().Deployments(opts.LocalNamespace),
+		remoteDeployments:       remote.Lister().Deployments(opts.RemoteNamespace),
+		remoteDeploymentsClient: opts.RemoteClient.AppsV1().Deployments(opts.RemoteNamespace),
+
+		localPods:         localPods.Lister().Pods(opts.LocalNamespace),
+		remoteAutoscalers: remoteAutoscalers.Lister().HorizontalPodAutoscalers(opts.RemoteNamespace),
+	}
+}
+
+// Handle reconciles deployment objects.
+func (ndr *NamespacedDeploymentReflector) Handle(ctx context.Context, name string) error {
+	tracer := trace.FromContext(ctx)
+
+	// Retrieve the local and remote objects (only not found errors can occur).
+	klog.V(4).Infof("Handling reflection of local Deployment %q (remote: %q)", ndr.LocalRef(name), ndr.RemoteRef(name))
+	local, lerr := ndr.localDeployments.Get(name)
+	utilruntime.Must(client.IgnoreNotFound(lerr))
+	remote, rerr := ndr.remoteDeployments.Get(name)
+	utilruntime.Must(client.IgnoreNotFound(rerr))
+	tracer.Step("Retrieved the local and remote objects")
+
+	// Abort the reflection if the remote object is not managed by us, as we do not want to mutate others' objects.
+	if rerr == nil && !forge.IsReflected(remote) {
+		if lerr == nil && forge.IsRemoteWorkload(local) { // Do not output the warning event in case the local object is not concerned.
+			klog.Infof("Skipping reflection of local Deployment %q as remote already exists and is not managed by us", ndr.LocalRef(name))
+			ndr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionAlreadyExistsMsg())
+		}
+		return nil
+	}
+	tracer.Step("Performed the sanity checks")
+
+	// The local deployment does no longer exist, or it is not marked to be reflected. Ensure it is also absent from the remote cluster.
+	if kerrors.IsNotFound(lerr) || !forge.IsRemoteWorkload(local) {
+		defer tracer.Step("Ensured the absence of the remote object")
+		if !kerrors.IsNotFound(rerr) {
+			klog.V(4).Infof("Deleting remote Deployment %q, since local %q is no longer reflected", ndr.RemoteRef(name), ndr.LocalRef(name))
+			return ndr.DeleteRemote(ctx, ndr.remoteDeploymentsClient, DeploymentReflectorName, name, remote.GetUID())
+		}
+		return nil
+	}
+
+	// Forge the remote object (the remote one is nil in case it does not exist yet).
+	replicas := RemoteReplicas(forge.DeploymentKind, name, ndr.localPods, ndr.remoteAutoscalers)
+	target := forge.RemoteDeployment(local, remote, replicas, ndr.RemoteNamespace())
+	tracer.Step("Forged the remote object")
+
+	// If the remote deployment does not exist, then create it.
+	if kerrors.IsNotFound(rerr) {
+		defer tracer.Step("Ensured the presence of the remote object")
+		if _, err := ndr.remoteDeploymentsClient.Create(ctx, target, metav1.CreateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+			klog.Errorf("Failed to create remote Deployment %q (local: %q): %v", ndr.RemoteRef(name), ndr.LocalRef(name), err)
+			ndr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
+			return err
+		}
+
+		klog.Infof("Remote Deployment %q successfully created (local: %q)", ndr.RemoteRef(name), ndr.LocalRef(name))
+		ndr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulReflectionMsg())
+		return nil
+	}
+
+	// Do not attempt to perform an update if not necessary (the pod template is compared through the hash annotation).
+	if labels.Equals(remote.GetLabels(), target.GetLabels()) && labels.Equals(remote.GetAnnotations(), target.GetAnnotations()) &&
+		deploymentSpecSynced(&remote.Spec, &target.Spec) {
+		klog.V(4).Infof("Skipping remote Deployment %q update, as already synced", ndr.RemoteRef(name))
+		return nil
+	}
+
+	defer tracer.Step("Ensured the correctness of the remote object")
+	if _, err := ndr.remoteDeploymentsClient.Update(ctx, target, metav1.UpdateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+		klog.Errorf("Failed to update remote Deployment %q (local: %q): %v", ndr.RemoteRef(name), ndr.LocalRef(name), err)
+		if !kerrors.IsConflict(err) {
+			ndr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
+		}
+		return err
+	}
+
+	klog.Infof("Remote Deployment %q successfully updated (local: %q)", ndr.RemoteRef(name), ndr.LocalRef(name))
+	ndr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulReflectionMsg())
+	return nil
+}
+
+// deploymentSpecSynced returns whether the fields forwarded to the remote Deployment, except for the pod template, are up to date.
+func deploymentSpecSynced(remote, target *appsv1.DeploymentSpec) bool {
+	return pointer.Int32Equal(remote.Replicas, target.Replicas) && remote.Paused == target.Paused &&
+		equality.Semantic.DeepEqual(remote.Strategy, target.Strategy) && remote.MinReadySeconds == target.MinReadySeconds &&
+		pointer.Int32Equal(remote.RevisionHistoryLimit, target.RevisionHistoryLimit) &&
+		pointer.Int32Equal(remote.ProgressDeadlineSeconds, target.ProgressDeadlineSeconds)
+}
@@ -0,0 +1,239 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"k8s.io/utils/trace"
+
+	"github.com/liqotech/liqo/pkg/consts"
+	. "github.com/liqotech/liqo/pkg/utils/testutil"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/manager"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/options"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/workload"
+)
+
+var _ = Describe("Deployment Reflection", func() {
+	Describe("NewDeploymentReflector", func() {
+		It("should create a non-nil reflector", func() {
+			Expect(workload.NewDeploymentReflector(1)).NotTo(BeNil())
+		})
+	})
+
+	Describe("Handle", func() {
+		const DeploymentName = "name"
+
+		var (
+			reflector     manager.NamespacedReflector
+			client        *fake.Clientset
+			local, remote appsv1.Deployment
+			err           error
+		)
+
+		GetDeployment := func(namespace string) *appsv1.Deployment {
+			deploy, errdeploy := client.AppsV1().Deployments(namespace).Get(ctx, DeploymentName, metav1.GetOptions{})
+			ExpectWithOffset(1, errdeploy).ToNot(HaveOccurred())
+			return deploy
+		}
+
+		GetDeploymentError := func(namespace string) error {
+			_, errdeploy := client.AppsV1().Deployments(namespace).Get(ctx, DeploymentName, metav1.GetOptions{})
+			return errdeploy
+		}
+
+		CreateDeployment := func(deploy *appsv1.Deployment) {
+			_, errdeploy := client.AppsV1().Deployments(deploy.GetNamespace()).Create(ctx, deploy, metav1.CreateOptions{})
+			ExpectWithOffset(1, errdeploy).ToNot(HaveOccurred())
+		}
+
+		CreateBoundPod := func(name string) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name, Namespace: LocalNamespace, Labels: map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "abcde"},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "apps/v1", Kind: "ReplicaSet", Name: DeploymentName + "-abcde", Controller: pointer.Bool(true)}},
+				},
+				Spec: corev1.PodSpec{NodeName: LiqoNodeName},
+			}
+			_, errpod := client.CoreV1().Pods(LocalNamespace).Create(ctx, pod, metav1.CreateOptions{})
+			ExpectWithOffset(1, errpod).ToNot(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			client = fake.NewSimpleClientset()
+			local = appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: DeploymentName, Namespace: LocalNamespace, Labels: map[string]string{"foo": "bar"}},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: pointer.Int32(3),
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "foo"}}}},
+				},
+			}
+			remote = appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: DeploymentName, Namespace: RemoteNamespace}}
+		})
+
+		JustBeforeEach(func() {
+			factory := informers.NewSharedInformerFactory(client, 10*time.Hour)
+			reflector = workload.NewNamespacedDeploymentReflector(options.NewNamespaced().
+				WithLocal(LocalNamespace, client, factory).
+				WithRemote(RemoteNamespace, client, factory).
+				WithHandlerFactory(FakeEventHandler).
+				WithEventBroadcaster(record.NewBroadcaster()))
+
+			factory.Start(ctx.Done())
+			factory.WaitForCacheSync(ctx.Done())
+
+			err = reflector.Handle(trace.ContextWithTrace(ctx, trace.New("Deployment")), DeploymentName)
+		})
+
+		When("the local object is marked to be reflected as a whole", func() {
+			BeforeEach(func() {
+				local.SetAnnotations(map[string]string{consts.RemoteWorkloadAnnotationKey: "true"})
+				CreateDeployment(&local)
+				// Only two out of the three local replicas are scheduled on the virtual node.
+				CreateBoundPod("pod-1")
+				CreateBoundPod("pod-2")
+			})
+
+			When("the remote object does not exist", func() {
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should have been created", func() {
+					remoteAfter := GetDeployment(RemoteNamespace)
+					Expect(remoteAfter.Labels).To(HaveKeyWithValue(forge.LiqoOriginClusterIDKey, LocalClusterID))
+					Expect(remoteAfter.Labels).To(HaveKeyWithValue("foo", "bar"))
+					Expect(remoteAfter.Annotations).To(HaveKey(forge.LiqoTemplateHashKey))
+					Expect(remoteAfter.Spec.Replicas).To(Equal(pointer.Int32(2)))
+				})
+			})
+
+			When("the remote object already exists and needs to be updated", func() {
+				BeforeEach(func() {
+					remote.SetLabels(forge.ReflectionLabels())
+					remote.Spec.Replicas = pointer.Int32(1)
+					CreateDeployment(&remote)
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should have been updated", func() {
+					remoteAfter := GetDeployment(RemoteNamespace)
+					Expect(remoteAfter.Labels).To(HaveKeyWithValue("foo", "bar"))
+					Expect(remoteAfter.Spec.Replicas).To(Equal(pointer.Int32(2)))
+				})
+			})
+
+			When("the remote object already exists and only the rollout parameters need to be updated", func() {
+				BeforeEach(func() {
+					local.Spec.MinReadySeconds = 10
+					local.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+					_, errdeploy := client.AppsV1().Deployments(LocalNamespace).Update(ctx, &local, metav1.UpdateOptions{})
+					Expect(errdeploy).ToNot(HaveOccurred())
+
+					remote = *forge.RemoteDeployment(&local, nil, pointer.Int32(2), RemoteNamespace)
+					remote.Spec.MinReadySeconds = 0
+					remote.Spec.Strategy = appsv1.DeploymentStrategy{}
+					CreateDeployment(&remote)
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should have been updated", func() {
+					remoteAfter := GetDeployment(RemoteNamespace)
+					Expect(remoteAfter.Spec.MinReadySeconds).To(BeNumerically("==", 10))
+					Expect(remoteAfter.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+				})
+			})
+
+			When("the remote object already exists and is targeted by an autoscaler", func() {
+				BeforeEach(func() {
+					remote.SetLabels(forge.ReflectionLabels())
+					remote.Spec.Replicas = pointer.Int32(5)
+					CreateDeployment(&remote)
+
+					hpa := &autoscalingv2.HorizontalPodAutoscaler{
+						ObjectMeta: metav1.ObjectMeta{Name: "hpa", Namespace: RemoteNamespace},
+						Spec: autoscalingv2.HorizontalPodAutoscalerSpec{ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+							APIVersion: "apps/v1", Kind: forge.DeploymentKind, Name: DeploymentName}},
+					}
+					_, errhpa := client.AutoscalingV2().HorizontalPodAutoscalers(RemoteNamespace).Create(ctx, hpa, metav1.CreateOptions{})
+					Expect(errhpa).ToNot(HaveOccurred())
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should have been updated preserving the replicas", func() {
+					remoteAfter := GetDeployment(RemoteNamespace)
+					Expect(remoteAfter.Labels).To(HaveKeyWithValue("foo", "bar"))
+					Expect(remoteAfter.Spec.Replicas).To(Equal(pointer.Int32(5)))
+				})
+			})
+
+			When("the remote object already exists, but is not managed by the reflection", func() {
+				BeforeEach(func() {
+					remote.Spec.Replicas = pointer.Int32(1)
+					CreateDeployment(&remote)
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should not have been mutated", func() {
+					Expect(GetDeployment(RemoteNamespace).Spec.Replicas).To(Equal(pointer.Int32(1)))
+				})
+			})
+		})
+
+		When("the local object is not marked to be reflected as a whole", func() {
+			BeforeEach(func() { CreateDeployment(&local) })
+
+			When("the remote object does not exist", func() {
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should not be created", func() {
+					Expect(GetDeploymentError(RemoteNamespace)).To(BeNotFound())
+				})
+			})
+
+			When("the remote object does exist", func() {
+				BeforeEach(func() {
+					remote.SetLabels(forge.ReflectionLabels())
+					CreateDeployment(&remote)
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote object should have been deleted", func() {
+					Expect(GetDeploymentError(RemoteNamespace)).To(BeNotFound())
+				})
+			})
+		})
+
+		When("the local object does not exist and the remote one does", func() {
+			BeforeEach(func() {
+				remote.SetLabels(forge.ReflectionLabels())
+				CreateDeployment(&remote)
+			})
+
+			It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+			It("the remote object should have been deleted", func() {
+				Expect(GetDeploymentError(RemoteNamespace)).To(BeNotFound())
+			})
+		})
+	})
+})
@@ -82,7 +82,9 @@ type PodReflector struct {
 	ipamclient ipam.IpamClient
 	handlers   sync.Map /* implicit signature: map[string]NamespacedPodHandler */
 
-	enableAPIServerSupport bool
+	enableAPIServerSupport   bool
+	enableDaemonSetExpansion bool
+	enableRemoteWorkloads    bool
 }
 
 // FallbackPodReflector handles the "orphan" pods outside the managed namespaces.
@@ -93,18 +95,32 @@ type FallbackPodReflector struct {
 	recorder        record.EventRecorder
 }
 
+// PodReflectorConfig groups the parameters to configure the PodReflector.
+type PodReflectorConfig struct {
+	// RemoteRESTConfig is required to establish the connection to implement `kubectl exec`.
+	RemoteRESTConfig *rest.Config
+	// RemoteMetricsFactory is required to retrieve the pod metrics from the remote cluster.
+	RemoteMetricsFactory MetricsFactory
+	// IpamClient is required to translate the remote IP addresses to the corresponding local ones.
+	IpamClient ipam.IpamClient
+
+	// EnableAPIServerSupport enables the forging of the fields required to allow offloaded pods to contact the local API server.
+	EnableAPIServerSupport bool
+	// EnableDaemonSetExpansion enables the expansion of DaemonSet pods into one pod for each remote node.
+	EnableDaemonSetExpansion bool
+	// EnableRemoteWorkloads enables the binding of the pods belonging to Deployments and StatefulSets reflected as a whole.
+	EnableRemoteWorkloads bool
+}
+
 // NewPodReflector returns a new PodReflector instance.
-func NewPodReflector(
-	remoteRESTConfig *rest.Config, /* required to establish the connection to implement `kubectl exec` */
-	remoteMetricsFactory MetricsFactory, /* required to retrieve the pod metrics from the remote cluster */
-	ipamclient ipam.IpamClient, /* required to translate the remote IP addresses to the corresponding local ones */
-	enableAPIServerSupport bool, /* enables the forging of the fields required to allow offloaded pods to contact the local API server */
-	workers uint) *PodReflector {
+func NewPodReflector(cfg *PodReflectorConfig, workers uint) *PodReflector {
 	reflector := &PodReflector{
-		remoteRESTConfig:       remoteRESTConfig,
-		remoteMetricsFactory:   remoteMetricsFactory,
-		ipamclient:             ipamclient,
-		enableAPIServerSupport: enableAPIServerSupport,
+		remoteRESTConfig:         cfg.RemoteRESTConfig,
+		remoteMetricsFactory:     cfg.RemoteMetricsFactory,
+		ipamclient:               cfg.IpamClient,
+		enableAPIServerSupport:   cfg.EnableAPIServerSupport,
+		enableDaemonSetExpansion: cfg.EnableDaemonSetExpansion,
+		enableRemoteWorkloads:    cfg.EnableRemoteWorkloads,
 	}
 
 	genericReflector := generic.NewReflector(PodReflectorName, reflector.NewNamespaced, reflector.NewFallback, workers)
@@ -115,7 +131,6 @@ func NewPodReflector(
 // NewNamespaced returns a new NamespacedPodReflector instance.
 func (pr *PodReflector) NewNamespaced(opts *options.NamespacedOpts) manager.NamespacedReflector {
 	remote := opts.RemoteFactory.Core().V1().Pods()
-	remote.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
 	remoteShadow := opts.RemoteLiqoFactory.Virtualkubelet().V1alpha1().ShadowPods()
 	remoteShadow.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
 	remoteSecrets := opts.RemoteFactory.Core().V1().Secrets()
@@ -138,7 +153,29 @@ func (pr *PodReflector) NewNamespaced(opts *options.NamespacedOpts) manager.Name
 
 		ipamclient:                pr.ipamclient,
 		enableAPIServerSupport:    pr.enableAPIServerSupport,
+		enableDaemonSetExpansion:  pr.enableDaemonSetExpansion,
+		enableRemoteWorkloads:     pr.enableRemoteWorkloads,
 		kubernetesServiceIPGetter: pr.KubernetesServiceIPGetter(),
+
+		bindings:      make(map[string]string),
+		extraReplicas: make(map[string]int),
+	}
+
+	// The remote pods are mapped to the corresponding local ones, which might differ in case of expanded pods or remote workloads.
+	remote.Informer().AddEventHandler(opts.HandlerFactory(reflector.RemotePodKeyer()))
+
+	// The remote daemonsets informer is configured only if the expansion is enabled, as requiring additional permissions.
+	if pr.enableDaemonSetExpansion {
+		remoteDaemonSets := opts.RemoteFactory.Apps().V1().DaemonSets()
+		remoteDaemonSets.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
+		reflector.remoteDaemonSets = remoteDaemonSets.Lister().DaemonSets(opts.RemoteNamespace)
+		reflector.remoteDaemonSetsClient = opts.RemoteClient.AppsV1().DaemonSets(opts.RemoteNamespace)
+	}
+
+	// The local workload informers are configured only if the remote workloads are enabled, to avoid unnecessary overhead.
+	if pr.enableRemoteWorkloads {
+		reflector.localDeployments = opts.LocalFactory.Apps().V1().Deployments().Lister().Deployments(opts.LocalNamespace)
+		reflector.localStatefulSets = opts.LocalFactory.Apps().V1().StatefulSets().Lister().StatefulSets(opts.LocalNamespace)
 	}
 
 	pr.handlers.Store(opts.LocalNamespace, NamespacedPodHandler(reflector))
@@ -0,0 +1,284 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/liqotech/liqo/pkg/utils/pod"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+// ShouldExpand returns whether the given pod shall be expanded into a remote DaemonSet, rather than reflected as a ShadowPod.
+func (npr *NamespacedPodReflector) ShouldExpand(name string, local *corev1.Pod, localExists bool) bool {
+	if !npr.enableDaemonSetExpansion {
+		return false
+	}
+
+	if localExists {
+		return forge.IsDaemonSetPod(local)
+	}
+
+	// The local pod does no longer exist, hence check whether a remote DaemonSet needs to be garbage collected.
+	_, err := npr.remoteDaemonSets.Get(name)
+	utilruntime.Must(client.IgnoreNotFound(err))
+	return err == nil
+}
+
+// HandleExpanded reconciles the local pods controlled by a DaemonSet, expanding them into a remote DaemonSet
+// which runs one pod for each node of the remote cluster.
+func (npr *NamespacedPodReflector) HandleExpanded(ctx context.Context, name string, local *corev1.Pod, localExists bool) error {
+	tracer := trace.FromContext(ctx)
+
+	remote, rerr := npr.remoteDaemonSets.Get(name)
+	utilruntime.Must(client.IgnoreNotFound(rerr))
+	remoteExists := !kerrors.IsNotFound(rerr)
+
+	expanded, err := npr.remotePods.List(forge.ExpandedPodSelector(name))
+	utilruntime.Must(err)
+	tracer.Step("Retrieved the remote expanded objects")
+
+	// Abort the reflection if the remote object is not managed by us, as we do not want to mutate others' objects.
+	if remoteExists && !forge.IsReflected(remote) {
+		if localExists { // Do not output the warning event in case the event was triggered by the remote object (i.e., the local one does not exists).
+			klog.Infof("Skipping reflection of local pod %q as remote daemonset already exists and is not managed by us", npr.LocalRef(name))
+			npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionAlreadyExistsMsg())
+		}
+		return nil
+	}
+
+	// The local pod does no longer exist. Ensure the daemonset is absent from the remote cluster.
+	if !localExists {
+		defer tracer.Step("Ensured the absence of the remote object")
+		if remoteExists {
+			klog.V(4).Infof("Deleting remote daemonset %q, since local pod %q does no longer exist", npr.RemoteRef(name), npr.LocalRef(name))
+			return npr.DeleteRemote(ctx, npr.remoteDaemonSetsClient, "DaemonSet", name, remote.GetUID())
+		}
+		return nil
+	}
+
+	// The local pod is being terminated, hence mark also the remote daemonset as to be deleted.
+	if !local.DeletionTimestamp.IsZero() {
+		// All the remote pods have terminated, and we need to delete the local one.
+		if !remoteExists && len(expanded) == 0 {
+			defer tracer.Step("Ensured the absence of the local terminating object")
+
+			klog.V(4).Infof("Deleting terminating local pod %q, since remote daemonset %q does no longer exist", npr.LocalRef(name), npr.RemoteRef(name))
+			opts := metav1.NewDeleteOptions(0 /* trigger the effective deletion */)
+			opts.Preconditions = metav1.NewUIDPreconditions(string(local.GetUID()))
+			if err := npr.localPodsClient.Delete(ctx, name, *opts); err != nil && !kerrors.IsNotFound(err) {
+				klog.Errorf("Failed to delete local terminated pod %q: %v", npr.LocalRef(name), err)
+				npr.Event(local, corev1.EventTypeWarning, forge.EventFailedDeletion, forge.EventFailedDeletionMsg(err))
+				return err
+			}
+
+			npr.ForgetPodInfo(name)
+			klog.Infof("Local pod %q successfully deleted", npr.LocalRef(name))
+			return nil
+		}
+
+		if remoteExists && remote.DeletionTimestamp.IsZero() {
+			defer tracer.Step("Ensured the absence of the remote object")
+			klog.V(4).Infof("Deleting remote daemonset %q, since local pod %q is terminating", npr.RemoteRef(name), npr.LocalRef(name))
+			return npr.DeleteRemote(ctx, npr.remoteDaemonSetsClient, "DaemonSet", name, remote.GetUID())
+		}
+
+		// The remote pods are already terminating, and we wait for their termination.
+		return nil
+	}
+
+	// Ensure the local pod has the appropriate labels to mark it as offloaded.
+	if err := npr.HandleLabels(ctx, local); err != nil {
+		return err
+	}
+
+	// Retrieve the cached information about the current pod.
+	info := npr.RetrievePodInfo(local.GetName())
+
+	// Do not forge the daemonset in case it is already marked as created, but the informer did not yet see it, as creation would fail.
+	if !remoteExists && info.PreventCreationUntilSeen {
+		klog.V(4).Infof("Skipping remote daemonset %q update, as waiting for its creation to be reported", npr.RemoteRef(name))
+		return nil
+	}
+
+	info.PreventCreationUntilSeen = false
+
+	target, terr := npr.ForgeDaemonSet(ctx, local, remote, info)
+	if terr != nil {
+		klog.Errorf("Reflection of local pod %q to %q failed: %v", npr.LocalRef(name), npr.RemoteRef(name), terr)
+		npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(terr))
+		return terr
+	}
+	tracer.Step("Forged the remote daemonset")
+
+	// If the remote daemonset does not exist, then create it.
+	if !remoteExists {
+		defer tracer.Step("Ensured the presence of the remote object")
+		if _, err := npr.remoteDaemonSetsClient.Create(ctx, target, metav1.CreateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+			if kerrors.IsAlreadyExists(err) {
+				klog.Infof("Remote daemonset %q already exists (local pod: %q)", npr.RemoteRef(name), npr.LocalRef(name))
+				return nil
+			}
+			klog.Errorf("Failed to create remote daemonset %q (local pod: %q): %v", npr.RemoteRef(name), npr.LocalRef(name), err)
+			if !kerrors.IsConflict(err) {
+				npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
+			}
+			// The provider cluster refused the daemonset (e.g., due to the privileged features required), hence mark the pod as rejected.
+			if kerrors.IsForbidden(err) {
+				return npr.HandleForbidden(ctx, local, err)
+			}
+			return err
+		}
+
+		// Do not attempt to create this daemonset again until the informer has seen it.
+		info.PreventCreationUntilSeen = true
+		klog.Infof("Remote daemonset %q successfully created (local: %q)", npr.RemoteRef(name), npr.LocalRef(name))
+		npr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulReflectionMsg())
+		return nil
+	}
+
+	if npr.ShouldUpdateDaemonSet(ctx, remote, target) {
+		if _, err := npr.remoteDaemonSetsClient.Update(ctx, target, metav1.UpdateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+			klog.Errorf("Failed to update remote daemonset %q (local pod: %q): %v", npr.RemoteRef(name), npr.LocalRef(name), err)
+			if !kerrors.IsConflict(err) {
+				npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
+			}
+			return err
+		}
+
+		klog.Infof("Remote daemonset %q successfully updated (local pod: %q)", npr.RemoteRef(name), npr.LocalRef(name))
+		npr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulReflectionMsg())
+		tracer.Step("Updated the remote daemonset")
+	} else {
+		klog.V(4).Infof("Skipping remote daemonset %q update, as already synced", npr.RemoteRef(name))
+	}
+
+	// Aggregate the status of the remote expanded pods into the local one.
+	return npr.HandleExpandedStatus(ctx, local, remote, expanded, info)
+}
+
+// ForgeDaemonSet forges the DaemonSet object to be enforced by the reflection process.
+func (npr *NamespacedPodReflector) ForgeDaemonSet(ctx context.Context, local *corev1.Pod,
+	remote *appsv1.DaemonSet, info *PodInfo) (*appsv1.DaemonSet, error) {
+	var saerr, kserr error
+
+	// Wrap the secret name retrieval from the service account, so that we do not have to handle errors in the forge logic.
+	saSecretRetriever := func(saName string) (secretName string) {
+		secretName, saerr = npr.RetrieveServiceAccountSecretName(info, saName)
+		return secretName
+	}
+
+	// Wrap the kubernetes service remapped IP retrieval, so that we do not have to handle errors in the forge logic.
+	ipGetter := func() (ip string) {
+		ip, kserr = npr.kubernetesServiceIPGetter(ctx)
+		return ip
+	}
+
+	target := forge.RemoteDaemonSet(local, remote, npr.RemoteNamespace(), npr.enableAPIServerSupport, saSecretRetriever, ipGetter)
+
+	if saerr != nil {
+		return nil, saerr
+	}
+	if kserr != nil {
+		return nil, kserr
+	}
+
+	return target, nil
+}
+
+// ShouldUpdateDaemonSet checks whether it is necessary to update the remote daemonset, based on the forged one.
+func (npr *NamespacedPodReflector) ShouldUpdateDaemonSet(ctx context.Context, remote, target *appsv1.DaemonSet) bool {
+	defer trace.FromContext(ctx).Step("Checked whether a daemonset update was needed")
+	return !labels.Equals(remote.GetLabels(), target.GetLabels()) ||
+		!labels.Equals(remote.GetAnnotations(), target.GetAnnotations()) ||
+		!labels.Equals(remote.Spec.Template.GetLabels(), target.Spec.Template.GetLabels()) ||
+		!pod.IsPodSpecEqual(&remote.Spec.Template.Spec, &target.Spec.Template.Spec)
+}
+
+// HandleExpandedStatus aggregates the status of the remote expanded pods into the local one.
+func (npr *NamespacedPodReflector) HandleExpandedStatus(ctx context.Context, local *corev1.Pod,
+	remote *appsv1.DaemonSet, expanded []*corev1.Pod, info *PodInfo) error {
+	tracer := trace.FromContext(ctx)
+
+	// Wrap the address translation logic, so that we do not have to handle errors in the forge logic.
+	var terr error
+	translator := func(original string) (translation string) {
+		translation, terr = npr.MapPodIP(ctx, info, original)
+		return translation
+	}
+
+	po := forge.LocalExpandedPod(local, expanded, remote.Status.DesiredNumberScheduled, translator)
+	tracer.Step("Forged the local pod status")
+
+	// Check whether an error occurred during address translation.
+	if terr != nil {
+		klog.Errorf("Reflection of local pod %q to %q failed: %v", npr.LocalRef(local.GetName()), npr.RemoteRef(local.GetName()), terr)
+		npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedStatusReflectionMsg(terr))
+		return terr
+	}
+
+	// Do not attempt to perform an update if not necessary.
+	if reflect.DeepEqual(local.Status, po.Status) {
+		klog.V(4).Infof("Skipping local pod %q status update, as already synced", npr.LocalRef(local.GetName()))
+		return nil
+	}
+
+	if _, err := npr.localPodsClient.UpdateStatus(ctx, po, metav1.UpdateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+		klog.Errorf("Failed to update local pod status %q (remote: %q): %v", npr.LocalRef(local.GetName()), npr.RemoteRef(local.GetName()), err)
+		if !kerrors.IsConflict(err) {
+			npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedStatusReflectionMsg(err))
+		}
+		return err
+	}
+
+	klog.Infof("Local pod %q status successfully updated (remote: %q)", npr.LocalRef(local.GetName()), npr.RemoteRef(local.GetName()))
+	npr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulStatusReflectionMsg())
+	tracer.Step("Updated the local pod status")
+	return nil
+}
+
+// RemotePodName returns the name of the remote pod targeted by logs and exec requests for the given local pod.
+// In case the local pod has been expanded, the first ready remote pod is selected, as representative of the whole set.
+func (npr *NamespacedPodReflector) RemotePodName(name string) string {
+	if !npr.enableDaemonSetExpansion {
+		return name
+	}
+
+	expanded, err := npr.remotePods.List(forge.ExpandedPodSelector(name))
+	utilruntime.Must(err)
+	if len(expanded) == 0 {
+		return name
+	}
+
+	sort.Slice(expanded, func(i, j int) bool { return expanded[i].GetName() < expanded[j].GetName() })
+	for _, remote := range expanded {
+		if ready, _ := pod.IsPodReady(remote); ready {
+			return remote.GetName()
+		}
+	}
+	return expanded[0].GetName()
+}
@@ -33,7 +33,9 @@ import (
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	appsv1clients "k8s.io/client-go/kubernetes/typed/apps/v1"
 	corev1clients "k8s.io/client-go/kubernetes/typed/core/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
@@ -70,14 +72,18 @@ type NamespacedPodHandler interface {
 type NamespacedPodReflector struct {
 	generic.NamespacedReflector
 
-	localPods        corev1listers.PodNamespaceLister
-	remotePods       corev1listers.PodNamespaceLister
-	remoteShadowPods vkv1alpha1listers.ShadowPodNamespaceLister
-	remoteSecrets    corev1listers.SecretNamespaceLister
+	localPods         corev1listers.PodNamespaceLister
+	localDeployments  appsv1listers.DeploymentNamespaceLister
+	localStatefulSets appsv1listers.StatefulSetNamespaceLister
+	remotePods        corev1listers.PodNamespaceLister
+	remoteShadowPods  vkv1alpha1listers.ShadowPodNamespaceLister
+	remoteSecrets     corev1listers.SecretNamespaceLister
+	remoteDaemonSets  appsv1listers.DaemonSetNamespaceLister
 
 	localPodsClient        corev1clients.PodInterface
 	remotePodsClient       corev1clients.PodInterface
 	remoteShadowPodsClient vkv1alpha1clients.ShadowPodInterface
+	remoteDaemonSetsClient appsv1clients.DaemonSetInterface
 
 	remoteRESTClient rest.Interface
 	remoteRESTConfig *rest.Config
@@ -85,8 +91,17 @@ type NamespacedPodReflector struct {
 
 	ipamclient                ipam.IpamClient
 	enableAPIServerSupport    bool
+	enableDaemonSetExpansion  bool
+	enableRemoteWorkloads     bool
 	kubernetesServiceIPGetter func(context.Context) (string, error)
 	pods                      sync.Map /* implicit signature: map[string]*PodInfo */
+	splitBrains               sync.Map /* implicit signature: map[string]struct{} */
+
+	// bindings caches the remote pods bound to the local ones belonging to Deployments reflected as a whole, while
+	// extraReplicas tracks the number of remote pods of each Deployment not bound to any local pod.
+	bindingsMutex sync.Mutex
+	bindings      map[string]string
+	extraReplicas map[string]int
 }
 
 // PodInfo contains information about known pods.
@@ -112,6 +127,18 @@ func (npr *NamespacedPodReflector) Handle(ctx context.Context, name string) erro
 	utilruntime.Must(client.IgnoreNotFound(lerr))
 	localExists := !kerrors.IsNotFound(lerr)
 
+	// Pods controlled by a DaemonSet are expanded into a remote DaemonSet, rather than reflected as a ShadowPod.
+	if npr.ShouldExpand(name, local, localExists) {
+		return npr.HandleExpanded(ctx, name, local, localExists)
+	}
+
+	// Pods belonging to workloads reflected as a whole are bound to the remote pods managed by the remote workload.
+	if localExists {
+		if kind, workload, found := npr.RemoteWorkload(local); found {
+			return npr.HandleRemoteWorkload(ctx, local, kind, workload)
+		}
+	}
+
 	remote, rerr := npr.remotePods.Get(name)
 	utilruntime.Must(client.IgnoreNotFound(rerr))
 	remoteExists := !kerrors.IsNotFound(rerr)
@@ -137,10 +164,13 @@ func (npr *NamespacedPodReflector) Handle(ctx context.Context, name string) erro
 		defer tracer.Step("Ensured the absence of the remote object")
 		if !kerrors.IsNotFound(serr) {
 			klog.V(4).Infof("Deleting remote shadowpod %q, since local pod %q does no longer exist", npr.RemoteRef(name), npr.LocalRef(name))
-			return npr.DeleteRemote(ctx, npr.remoteShadowPodsClient, "ShadowPod", name, shadow.GetUID())
+			err := npr.DeleteRemote(ctx, npr.remoteShadowPodsClient, "ShadowPod", name, shadow.GetUID())
+			npr.ReportSplitBrainOutcome(name, err)
+			return err
 		}
 
 		klog.V(4).Infof("Local pod %q and remote shadowpod %q both vanished", npr.LocalRef(name), npr.RemoteRef(name))
+		npr.ForgetSplitBrain(name)
 		return nil
 	}
 
@@ -225,6 +255,10 @@ func (npr *NamespacedPodReflector) Handle(ctx context.Context, name string) erro
 			if !kerrors.IsConflict(err) {
 				npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
 			}
+			// The provider cluster refused the privileged features required by the pod, hence mark it as rejected.
+			if kerrors.IsForbidden(err) && len(pod.PrivilegedFeatures(&local.Spec)) > 0 {
+				return npr.HandleForbidden(ctx, local, err)
+			}
 			return err
 		}
 
@@ -259,6 +293,26 @@ func (npr *NamespacedPodReflector) Handle(ctx context.Context, name string) erro
 	return npr.HandleStatus(ctx, local, remote, info)
 }
 
+// HandleForbidden marks the local pod as rejected, since it requires privileged features not permitted by the provider cluster.
+// The original error is always returned, to retry the offloading process in case the provider policy changes.
+func (npr *NamespacedPodReflector) HandleForbidden(ctx context.Context, local *corev1.Pod, reason error) error {
+	if local.Status.Phase == corev1.PodPending && local.Status.Reason == forge.PodOffloadingForbiddenReason {
+		klog.V(4).Infof("Skipping local pod %q status update, as already marked as %v", npr.LocalRef(local.GetName()), local.Status.Reason)
+		return reason
+	}
+
+	defer trace.FromContext(ctx).Step("Updated the local pod status")
+	rejected := forge.LocalRejectedPod(local, corev1.PodPending, forge.PodOffloadingForbiddenReason)
+	rejected.Status.Message = reason.Error()
+	if _, err := npr.localPodsClient.UpdateStatus(ctx, rejected, metav1.UpdateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+		klog.Errorf("Failed to mark local pod %q as %v: %v", npr.LocalRef(local.GetName()), forge.PodOffloadingForbiddenReason, err)
+		return err
+	}
+
+	klog.Infof("Local pod %q successfully marked as %v", npr.LocalRef(local.GetName()), forge.PodOffloadingForbiddenReason)
+	return reason
+}
+
 // HandleLabels mutates the local object labels, to mark the pod as offloaded and allow filtering at the informer level.
 func (npr *NamespacedPodReflector) HandleLabels(ctx context.Context, local *corev1.Pod) error {
 	// Forge the mutation to be applied to the local pod.
@@ -388,7 +442,7 @@ func (npr *NamespacedPodReflector) Exec(ctx context.Context, po, container strin
 	request := npr.remoteRESTClient.Post().
 		Resource(corev1.ResourcePods.String()).
 		Namespace(npr.RemoteNamespace()).
-		Name(po).
+		Name(npr.RemotePodName(po)).
 		SubResource("exec").
 		VersionedParams(&corev1.PodExecOptions{
 			Container: container,
@@ -450,7 +504,7 @@ func (npr *NamespacedPodReflector) Logs(ctx context.Context, po, container strin
 		SinceTime:    TimeAsPointerOrNil(opts.SinceTime),
 	}
 
-	stream, err := npr.remotePodsClient.GetLogs(po, &logOpts).Stream(ctx)
+	stream, err := npr.remotePodsClient.GetLogs(npr.RemotePodName(po), &logOpts).Stream(ctx)
 	if err != nil {
 		klog.Errorf("Failed to retrieve logs of container %q of local pod %q (remote %q): %v", container, npr.LocalRef(po), npr.RemoteRef(po), err)
 		return nil, fmt.Errorf("could not get stream from logs request: %w", err)
@@ -464,8 +518,11 @@ func (npr *NamespacedPodReflector) Stats(ctx context.Context) ([]statsv1alpha1.P
 	klog.V(4).Infof("Requested to retrieve stats for local namespace %q (remote %q)", npr.LocalNamespace(), npr.RemoteNamespace())
 	var stats []statsv1alpha1.PodStats
 
-	// Retrieve all metrics from the remote namespace.
-	metrics, err := npr.remoteMetrics.List(ctx, metav1.ListOptions{LabelSelector: forge.ReflectedLabelSelector().String()})
+	// Retrieve all metrics from the remote namespace, excluding the ones of expanded pods, as not matching any local pod.
+	notExpanded, err := labels.NewRequirement(forge.LiqoExpandedPodKey, selection.DoesNotExist, nil)
+	utilruntime.Must(err)
+	selector := forge.ReflectedLabelSelector().Add(*notExpanded)
+	metrics, err := npr.remoteMetrics.List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
 	if err != nil {
 		return nil, errors.Wrapf(err, "error while listing remote pod metrics in namespace %q", npr.RemoteNamespace())
 	}
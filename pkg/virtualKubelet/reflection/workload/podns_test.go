@@ -21,7 +21,9 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -31,6 +33,7 @@ import (
 	"k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/record"
 	metricsv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
+	"k8s.io/utils/pointer"
 	"k8s.io/utils/trace"
 
 	vkv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
@@ -55,6 +58,7 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 			reflector  manager.NamespacedReflector
 			client     *fake.Clientset
 			liqoClient liqoclient.Interface
+			events     chan *corev1.Event
 
 			ipam *fakeipam.IPAMClient
 		)
@@ -71,8 +75,14 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 			liqoFactory := liqoinformers.NewSharedInformerFactory(liqoClient, 10*time.Hour)
 
 			broadcaster := record.NewBroadcaster()
+			events = make(chan *corev1.Event, 10)
+			broadcaster.StartEventWatcher(func(event *corev1.Event) { events <- event })
+			DeferCleanup(broadcaster.Shutdown)
 			metricsFactory := func(string) metricsv1beta1.PodMetricsInterface { return nil }
-			rfl := workload.NewPodReflector(nil, metricsFactory, ipam, true, 0)
+			rfl := workload.NewPodReflector(&workload.PodReflectorConfig{
+				RemoteMetricsFactory: metricsFactory, IpamClient: ipam,
+				EnableAPIServerSupport: true, EnableDaemonSetExpansion: true, EnableRemoteWorkloads: true,
+			}, 0)
 			rfl.Start(ctx, options.New(client, factory.Core().V1().Pods()).WithEventBroadcaster(broadcaster))
 			reflector = rfl.NewNamespaced(options.NewNamespaced().
 				WithLocal(LocalNamespace, client, factory).WithLiqoLocal(liqoClient, liqoFactory).
@@ -126,6 +136,18 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 				When("the remote object does exist", WhenBody(true))
 			})
 
+			When("the local object does not exist and the remote daemonset does exist", func() {
+				BeforeEach(func() {
+					ds := appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: PodName, Namespace: RemoteNamespace, Labels: forge.ReflectionLabels()}}
+					CreateDaemonSet(client, &ds)
+				})
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("the remote daemonset should be deleted", func() {
+					Expect(GetDaemonSetError(client, RemoteNamespace, PodName)).To(BeNotFound())
+				})
+			})
+
 			When("the local object does exist and is not terminating", func() {
 				var shouldDenyPodPatches bool
 
@@ -147,6 +169,11 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 							return true, nil, fmt.Errorf("received patch for unexpected pod %s/%s", patch.GetNamespace(), patch.GetName())
 						}
 
+						// Merge patches are handled by the default reactor.
+						if patch.GetPatchType() == types.MergePatchType {
+							return false, nil, nil
+						}
+
 						if patch.GetPatchType() != types.ApplyPatchType {
 							return true, nil, fmt.Errorf("unsupported patch type %s", patch.GetPatchType())
 						}
@@ -177,6 +204,29 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 					})
 				})
 
+				When("the remote object does not exist and the provider forbids the required privileged features", func() {
+					BeforeEach(func() {
+						local.Spec.HostNetwork = true
+						UpdatePod(client, &local)
+
+						liqoClient.(*liqoclientfake.Clientset).PrependReactor("create", "shadowpods",
+							func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+								return true, nil, kerrors.NewForbidden(vkv1alpha1.ShadowPodGroupResource, PodName, errors.New("host network not permitted"))
+							})
+					})
+
+					It("should fail", func() { Expect(err).To(HaveOccurred()) })
+					It("the remote object should not be created", func() {
+						Expect(GetShadowPodError(liqoClient, RemoteNamespace, PodName)).To(BeNotFound())
+					})
+					It("the local pod should be marked as rejected", func() {
+						localAfter := GetPod(client, LocalNamespace, PodName)
+						Expect(localAfter.Status.Phase).To(Equal(corev1.PodPending))
+						Expect(localAfter.Status.Reason).To(Equal(forge.PodOffloadingForbiddenReason))
+						Expect(localAfter.Status.Message).To(ContainSubstring("host network not permitted"))
+					})
+				})
+
 				When("the remote object already exists and needs to be updated", func() {
 					BeforeEach(func() {
 						shadow.SetLabels(forge.ReflectionLabels())
@@ -258,6 +308,149 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 					// The fake client is configured to return an error in case a patch operation on pods is attempted.
 					It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
 				})
+
+				When("the local object is controlled by a daemonset", func() {
+					BeforeEach(func() {
+						local.OwnerReferences = []metav1.OwnerReference{{
+							APIVersion: "apps/v1", Kind: "DaemonSet", Name: "ds", UID: "uid", Controller: pointer.Bool(true)}}
+						UpdatePod(client, &local)
+					})
+
+					When("the remote daemonset does not exist", func() {
+						It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+						It("the remote daemonset should have been created", func() {
+							dsAfter := GetDaemonSet(client, RemoteNamespace, PodName)
+							Expect(dsAfter.Labels).To(HaveKeyWithValue(forge.LiqoOriginClusterIDKey, LocalClusterID))
+							Expect(dsAfter.Labels).To(HaveKeyWithValue("foo", "bar"))
+							Expect(dsAfter.Spec.Selector.MatchLabels).To(HaveKeyWithValue(forge.LiqoExpandedPodKey, PodName))
+							Expect(dsAfter.Spec.Template.Spec.Containers).To(HaveLen(1))
+							Expect(dsAfter.Spec.Template.Spec.Containers[0].Image).To(BeIdenticalTo("foo"))
+						})
+						It("the remote shadowpod should not be created", func() {
+							Expect(GetShadowPodError(liqoClient, RemoteNamespace, PodName)).To(BeNotFound())
+						})
+					})
+
+					When("the remote daemonset does not exist and the provider forbids it", func() {
+						BeforeEach(func() {
+							client.PrependReactor("create", "daemonsets",
+								func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+									return true, nil, kerrors.NewForbidden(appsv1.Resource("daemonsets"), PodName, errors.New("host network not permitted"))
+								})
+						})
+
+						It("should fail", func() { Expect(err).To(HaveOccurred()) })
+						It("the local pod should be marked as rejected", func() {
+							localAfter := GetPod(client, LocalNamespace, PodName)
+							Expect(localAfter.Status.Phase).To(Equal(corev1.PodPending))
+							Expect(localAfter.Status.Reason).To(Equal(forge.PodOffloadingForbiddenReason))
+							Expect(localAfter.Status.Message).To(ContainSubstring("host network not permitted"))
+						})
+					})
+
+					When("the remote daemonset and the expanded pods already exist", func() {
+						BeforeEach(func() {
+							ds := forge.RemoteDaemonSet(&local, nil, RemoteNamespace, false,
+								func(string) string { return "" }, func() string { return "" })
+							ds.Status.DesiredNumberScheduled = 2
+							CreateDaemonSet(client, ds)
+
+							for _, name := range []string{"expanded-1", "expanded-2"} {
+								expanded := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: RemoteNamespace,
+									Labels: labels.Merge(forge.ReflectionLabels(), map[string]string{forge.LiqoExpandedPodKey: PodName})}}
+								expanded.Status.Phase = corev1.PodRunning
+								expanded.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+								CreatePod(client, &expanded)
+							}
+						})
+
+						It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+						It("should aggregate the status of the expanded pods into the local one", func() {
+							localAfter := GetPod(client, LocalNamespace, PodName)
+							Expect(localAfter.Status.Phase).To(BeIdenticalTo(corev1.PodRunning))
+							Expect(localAfter.Status.Message).To(Equal("2/2 remote pods ready"))
+						})
+					})
+				})
+
+				When("the local object is controlled by a statefulset reflected as a whole", func() {
+					BeforeEach(func() {
+						local.OwnerReferences = []metav1.OwnerReference{{
+							APIVersion: "apps/v1", Kind: "StatefulSet", Name: "sts", UID: "uid", Controller: pointer.Bool(true)}}
+						UpdatePod(client, &local)
+
+						sts := appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "sts", Namespace: LocalNamespace,
+							Annotations: map[string]string{consts.RemoteWorkloadAnnotationKey: "true"}}}
+						_, err := client.AppsV1().StatefulSets(LocalNamespace).Create(ctx, &sts, metav1.CreateOptions{})
+						Expect(err).ToNot(HaveOccurred())
+
+						remote.SetLabels(labels.Merge(forge.ReflectionLabels(), map[string]string{forge.LiqoRemoteWorkloadKey: "sts"}))
+						remote.Status.Phase = corev1.PodRunning
+						remote.Status.PodIP = "192.168.200.25"
+						CreatePod(client, &remote)
+					})
+
+					It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+					It("should mirror the status of the bound remote pod into the local one", func() {
+						localAfter := GetPod(client, LocalNamespace, PodName)
+						Expect(localAfter.Status.Phase).To(BeIdenticalTo(corev1.PodRunning))
+						Expect(localAfter.Status.PodIP).To(Equal("192.168.201.25"))
+					})
+					It("the remote shadowpod should not be created", func() {
+						Expect(GetShadowPodError(liqoClient, RemoteNamespace, PodName)).To(BeNotFound())
+					})
+				})
+				When("the local object is controlled by a deployment reflected as a whole", func() {
+					BeforeEach(func() {
+						local.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "abcde"
+						local.Spec.NodeName = LiqoNodeName
+						local.OwnerReferences = []metav1.OwnerReference{{
+							APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "dep-abcde", UID: "uid", Controller: pointer.Bool(true)}}
+						UpdatePod(client, &local)
+
+						dep := appsv1.Deployment{
+							ObjectMeta: metav1.ObjectMeta{Name: "dep", Namespace: LocalNamespace,
+								Annotations: map[string]string{consts.RemoteWorkloadAnnotationKey: "true"}},
+							Spec: appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"foo": "bar"}}},
+						}
+						_, err := client.AppsV1().Deployments(LocalNamespace).Create(ctx, &dep, metav1.CreateOptions{})
+						Expect(err).ToNot(HaveOccurred())
+
+						for idx, name := range []string{"dep-abcde-aaaaa", "dep-abcde-bbbbb"} {
+							pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: RemoteNamespace,
+								Labels: labels.Merge(forge.ReflectionLabels(), map[string]string{forge.LiqoRemoteWorkloadKey: "dep"})}}
+							pod.Status.Phase = corev1.PodRunning
+							pod.Status.PodIP = fmt.Sprintf("192.168.200.%d", 10+idx)
+							CreatePod(client, &pod)
+						}
+					})
+
+					It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+					It("should bind the local pod to the first remote pod not yet claimed", func() {
+						localAfter := GetPod(client, LocalNamespace, PodName)
+						Expect(localAfter.GetAnnotations()).To(HaveKeyWithValue(forge.LiqoBoundRemotePodKey, "dep-abcde-aaaaa"))
+						Expect(localAfter.Status.PodIP).To(Equal("192.168.201.10"))
+					})
+					It("should report the extra remote pods through an event associated with the local deployment", func() {
+						Eventually(events).Should(Receive(WithTransform(func(event *corev1.Event) string {
+							return event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name + "/" + event.Reason
+						}, Equal("Deployment/dep/"+forge.EventExtraRemoteReplicas))))
+					})
+
+					When("the local object is already bound to a remote pod", func() {
+						BeforeEach(func() {
+							local.Annotations[forge.LiqoBoundRemotePodKey] = "dep-abcde-bbbbb"
+							UpdatePod(client, &local)
+						})
+
+						It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+						It("should preserve the existing binding", func() {
+							localAfter := GetPod(client, LocalNamespace, PodName)
+							Expect(localAfter.GetAnnotations()).To(HaveKeyWithValue(forge.LiqoBoundRemotePodKey, "dep-abcde-bbbbb"))
+							Expect(localAfter.Status.PodIP).To(Equal("192.168.201.11"))
+						})
+					})
+				})
 			})
 
 			When("the local object does exist and it is terminating", func() {
@@ -340,6 +533,48 @@ var _ = Describe("Namespaced Pod Reflection Tests", func() {
 			})
 		})
 
+		Context("resynchronization", func() {
+			var names []string
+
+			BeforeEach(func() {
+				offloaded := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "offloaded", Namespace: LocalNamespace,
+					Labels: map[string]string{consts.LocalPodLabelKey: consts.LocalPodLabelValue}}}
+				offloaded.Status.Phase = corev1.PodRunning
+				CreatePod(client, &offloaded)
+
+				pending := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: LocalNamespace}}
+				CreatePod(client, &pending)
+
+				orphan := vkv1alpha1.ShadowPod{ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: RemoteNamespace, Labels: forge.ReflectionLabels()}}
+				CreateShadowPod(liqoClient, &orphan)
+
+				foreign := vkv1alpha1.ShadowPod{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: RemoteNamespace}}
+				CreateShadowPod(liqoClient, &foreign)
+			})
+
+			JustBeforeEach(func() { names = reflector.(manager.NamespacedResyncer).Resync() })
+
+			It("should return the names of the local pods and of the reflected remote shadowpods", func() {
+				Expect(names).To(ConsistOf("offloaded", "pending", "orphan"))
+			})
+
+			When("the orphan remote shadowpod is subsequently reconciled", func() {
+				var err error
+
+				JustBeforeEach(func() { err = reflector.Handle(trace.ContextWithTrace(ctx, trace.New("Pod")), "orphan") })
+
+				It("should succeed", func() { Expect(err).ToNot(HaveOccurred()) })
+				It("should delete the orphan remote shadowpod", func() {
+					Expect(GetShadowPodError(liqoClient, RemoteNamespace, "orphan")).To(BeNotFound())
+				})
+				It("should report the outcome through an event associated with the virtual node", func() {
+					Eventually(events).Should(Receive(WithTransform(func(event *corev1.Event) string {
+						return event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name + "/" + event.Reason
+					}, Equal("Node/"+LiqoNodeName+"/"+forge.EventSplitBrainDetected))))
+				})
+			})
+		})
+
 		Context("status reflection", func() {
 			const PodName = "name"
 
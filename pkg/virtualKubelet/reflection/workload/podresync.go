@@ -0,0 +1,125 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	liqoconst "github.com/liqotech/liqo/pkg/consts"
+	"github.com/liqotech/liqo/pkg/utils/pod"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/manager"
+)
+
+var _ manager.NamespacedResyncer = (*NamespacedPodReflector)(nil)
+
+// Resync checks the consistency between the local pods and the remote shadowpods (e.g., after a disconnection from the
+// remote cluster), reporting the detected split-brain situations, and returns the names of the pods to be reconciled.
+func (npr *NamespacedPodReflector) Resync() []string {
+	locals, err := npr.localPods.List(labels.Everything())
+	utilruntime.Must(err)
+	shadows, err := npr.remoteShadowPods.List(labels.Everything())
+	utilruntime.Must(err)
+
+	names := sets.NewString()
+	for _, local := range locals {
+		names.Insert(local.GetName())
+	}
+
+	for _, shadow := range shadows {
+		if !forge.IsReflected(shadow) {
+			continue
+		}
+
+		names.Insert(shadow.GetName())
+		if local, err := npr.localPods.Get(shadow.GetName()); err != nil {
+			klog.Warningf("Split-brain detected: remote shadowpod %q is still present, while local pod %q vanished",
+				npr.RemoteRef(shadow.GetName()), npr.LocalRef(shadow.GetName()))
+			// No local object is available to be associated with the events, hence the outcome is reported on the virtual node
+			// once the remote one is deleted.
+			npr.splitBrains.Store(shadow.GetName(), struct{}{})
+		} else if shadow.DeletionTimestamp.IsZero() && (!local.DeletionTimestamp.IsZero() || pod.IsPodTerminated(local)) {
+			klog.Warningf("Split-brain detected: remote shadowpod %q is still present, while local pod %q terminated",
+				npr.RemoteRef(shadow.GetName()), npr.LocalRef(shadow.GetName()))
+			npr.Event(local, corev1.EventTypeWarning, forge.EventSplitBrainDetected, forge.EventSplitBrainLocalTerminatedMsg())
+		}
+	}
+
+	for _, local := range locals {
+		if !npr.IsOffloadedAsShadowPod(local) {
+			continue
+		}
+
+		if _, err := npr.remoteShadowPods.Get(local.GetName()); err != nil {
+			klog.Warningf("Split-brain detected: local pod %q is running, while remote shadowpod %q vanished",
+				npr.LocalRef(local.GetName()), npr.RemoteRef(local.GetName()))
+			npr.Event(local, corev1.EventTypeWarning, forge.EventSplitBrainDetected, forge.EventSplitBrainRemoteVanishedMsg())
+		}
+	}
+
+	return names.List()
+}
+
+// ReportSplitBrainOutcome reports, through an event associated with the virtual node, the outcome of the deletion of a remote
+// shadowpod previously detected to be still present, while the corresponding local pod vanished. It is a no-op if the given
+// pod was not involved in a split-brain situation.
+func (npr *NamespacedPodReflector) ReportSplitBrainOutcome(name string, err error) {
+	if _, found := npr.splitBrains.Load(name); !found {
+		return
+	}
+
+	if err != nil {
+		klog.Warningf("Split-brain not yet resolved: failed to delete remote shadowpod %q, while local pod %q vanished: %v",
+			npr.RemoteRef(name), npr.LocalRef(name), err)
+		npr.Event(virtualNodeRef(), corev1.EventTypeWarning, forge.EventSplitBrainDetected,
+			forge.EventSplitBrainLocalVanishedErrorMsg(npr.LocalRef(name).String(), err))
+		return
+	}
+
+	npr.splitBrains.Delete(name)
+	klog.Infof("Split-brain resolved: remote shadowpod %q deleted, as local pod %q vanished", npr.RemoteRef(name), npr.LocalRef(name))
+	npr.Event(virtualNodeRef(), corev1.EventTypeNormal, forge.EventSplitBrainDetected,
+		forge.EventSplitBrainLocalVanishedMsg(npr.LocalRef(name).String()))
+}
+
+// ForgetSplitBrain discards the split-brain situation possibly detected for the given pod, as both the local pod
+// and the remote shadowpod vanished in the meanwhile.
+func (npr *NamespacedPodReflector) ForgetSplitBrain(name string) {
+	npr.splitBrains.Delete(name)
+}
+
+// virtualNodeRef returns the reference to the virtual node, used to associate the events concerning vanished local pods.
+func virtualNodeRef() *corev1.ObjectReference {
+	return &corev1.ObjectReference{Kind: "Node", APIVersion: corev1.SchemeGroupVersion.String(), Name: forge.LiqoNodeName}
+}
+
+// IsOffloadedAsShadowPod returns whether the given local pod is currently running as offloaded through a remote shadowpod.
+func (npr *NamespacedPodReflector) IsOffloadedAsShadowPod(local *corev1.Pod) bool {
+	if local.Labels[liqoconst.LocalPodLabelKey] != liqoconst.LocalPodLabelValue ||
+		local.Status.Phase != corev1.PodRunning || !local.DeletionTimestamp.IsZero() {
+		return false
+	}
+
+	if npr.ShouldExpand(local.GetName(), local, true) {
+		return false
+	}
+
+	_, _, found := npr.RemoteWorkload(local)
+	return !found
+}
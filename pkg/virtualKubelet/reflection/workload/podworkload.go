@@ -0,0 +1,263 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+)
+
+// RemotePodKeyer returns a keyer which maps the remote pods to the corresponding local ones. In particular, remote pods
+// originated from the expansion of a local DaemonSet pod are mapped to the latter, and the ones belonging to a remote
+// Deployment are mapped to all the local pods of the corresponding Deployment, as their binding is established lazily.
+func (npr *NamespacedPodReflector) RemotePodKeyer() func(metadata metav1.Object) []types.NamespacedName {
+	return func(metadata metav1.Object) []types.NamespacedName {
+		if name, found := metadata.GetLabels()[forge.LiqoExpandedPodKey]; found {
+			return []types.NamespacedName{{Namespace: npr.LocalNamespace(), Name: name}}
+		}
+
+		if name, found := metadata.GetLabels()[forge.LiqoRemoteWorkloadKey]; found && npr.enableRemoteWorkloads {
+			if owner := metav1.GetControllerOf(metadata); owner != nil && owner.Kind != forge.StatefulSetKind {
+				var keys []types.NamespacedName
+				for _, local := range npr.LocalWorkloadPods(name) {
+					keys = append(keys, types.NamespacedName{Namespace: npr.LocalNamespace(), Name: local.GetName()})
+				}
+				return keys
+			}
+		}
+
+		return []types.NamespacedName{{Namespace: npr.LocalNamespace(), Name: metadata.GetName()}}
+	}
+}
+
+// RemoteWorkload returns the kind and the name of the workload reflected as a whole the given pod belongs to, if any.
+func (npr *NamespacedPodReflector) RemoteWorkload(local *corev1.Pod) (kind, name string, found bool) {
+	if !npr.enableRemoteWorkloads {
+		return "", "", false
+	}
+
+	var err error
+	var workload metav1.Object
+	switch kind, name = forge.PodWorkload(local); kind {
+	case forge.DeploymentKind:
+		workload, err = npr.localDeployments.Get(name)
+	case forge.StatefulSetKind:
+		workload, err = npr.localStatefulSets.Get(name)
+	default:
+		return "", "", false
+	}
+
+	utilruntime.Must(client.IgnoreNotFound(err))
+	return kind, name, err == nil && forge.IsRemoteWorkload(workload)
+}
+
+// HandleRemoteWorkload reconciles the local pods belonging to a workload reflected as a whole. Differently from standard
+// pods, no ShadowPod is created, and the local pod mirrors the status of the bound remote pod managed by the remote workload.
+func (npr *NamespacedPodReflector) HandleRemoteWorkload(ctx context.Context, local *corev1.Pod, kind, workload string) error {
+	tracer := trace.FromContext(ctx)
+
+	// The local pod is being terminated: the remote pods are managed by the remote workload, hence it can be deleted straight away.
+	if !local.DeletionTimestamp.IsZero() {
+		defer tracer.Step("Ensured the absence of the local terminating object")
+
+		klog.V(4).Infof("Deleting terminating local pod %q, since bound to remote %v %q", npr.LocalRef(local.GetName()), kind, npr.RemoteRef(workload))
+		opts := metav1.NewDeleteOptions(0 /* trigger the effective deletion */)
+		opts.Preconditions = metav1.NewUIDPreconditions(string(local.GetUID()))
+		if err := npr.localPodsClient.Delete(ctx, local.GetName(), *opts); err != nil && !kerrors.IsNotFound(err) {
+			klog.Errorf("Failed to delete local terminated pod %q: %v", npr.LocalRef(local.GetName()), err)
+			npr.Event(local, corev1.EventTypeWarning, forge.EventFailedDeletion, forge.EventFailedDeletionMsg(err))
+			return err
+		}
+
+		npr.ForgetPodInfo(local.GetName())
+		npr.forgetBinding(local.GetName())
+		klog.Infof("Local pod %q successfully deleted", npr.LocalRef(local.GetName()))
+		return nil
+	}
+
+	// Ensure the local pod has the appropriate labels to mark it as offloaded.
+	if err := npr.HandleLabels(ctx, local); err != nil {
+		return err
+	}
+
+	local, remote, err := npr.BoundRemotePod(ctx, local, kind, workload)
+	if err != nil {
+		return err
+	}
+	tracer.Step("Retrieved the bound remote pod")
+
+	if remote == nil {
+		klog.V(4).Infof("Skipping local pod %q status update, as not yet bound to any pod of remote %v %q",
+			npr.LocalRef(local.GetName()), kind, npr.RemoteRef(workload))
+		return nil
+	}
+
+	// Reflect the status from the bound remote pod to the local one. The restarts are not increased when the bound
+	// remote pod changes, as the pods are recreated by the remote workload controller.
+	info := npr.RetrievePodInfo(local.GetName())
+	info.RemoteUID, info.Restarts = remote.GetUID(), 0
+	return npr.HandleStatus(ctx, local, remote, info)
+}
+
+// BoundRemotePod returns the remote pod bound to the given local one, if any. The pods of StatefulSets are bound according to
+// their name (i.e., ordinal index), while the ones of Deployments are bound to an active remote pod not yet claimed by any
+// other local pod. The latter binding is stored in an annotation of the local pod, and preserved until the remote pod vanishes.
+// The local pod is returned as well, since updated in case a new binding is established.
+func (npr *NamespacedPodReflector) BoundRemotePod(ctx context.Context, local *corev1.Pod, kind, workload string) (
+	bound, remote *corev1.Pod, err error) {
+	if kind == forge.StatefulSetKind {
+		remote, err := npr.remotePods.Get(local.GetName())
+		utilruntime.Must(client.IgnoreNotFound(err))
+		if err != nil || remote.GetLabels()[forge.LiqoRemoteWorkloadKey] != workload {
+			return local, nil, nil
+		}
+		return local, remote, nil
+	}
+
+	// Serialize the binding operations, to prevent multiple local pods from claiming the same remote one.
+	npr.bindingsMutex.Lock()
+	defer npr.bindingsMutex.Unlock()
+
+	remotes, err := npr.remotePods.List(forge.RemoteWorkloadSelector(workload))
+	utilruntime.Must(err)
+	remotes = activePods(remotes)
+
+	locals := npr.LocalWorkloadPods(workload)
+	npr.reportExtraReplicas(workload, len(remotes)-len(locals))
+
+	claimed := make(map[string]string, len(locals))
+	for _, pod := range locals {
+		if name := npr.boundRemotePodName(pod); name != "" {
+			claimed[name] = pod.GetName()
+		}
+	}
+
+	// Preserve the existing binding, as long as the remote pod is still active.
+	for _, remote := range remotes {
+		if claimed[remote.GetName()] == local.GetName() {
+			return local, remote, nil
+		}
+	}
+
+	for _, remote := range remotes {
+		if _, found := claimed[remote.GetName()]; !found {
+			bound, err := npr.bindRemotePod(ctx, local, remote.GetName())
+			return bound, remote, err
+		}
+	}
+	return local, nil, nil
+}
+
+// boundRemotePodName returns the name of the remote pod bound to the given local one, if any.
+func (npr *NamespacedPodReflector) boundRemotePodName(local *corev1.Pod) string {
+	if name, found := npr.bindings[local.GetName()]; found {
+		return name
+	}
+	return local.GetAnnotations()[forge.LiqoBoundRemotePodKey]
+}
+
+// bindRemotePod stores the binding between the given local pod and the remote one, and returns the updated local pod.
+func (npr *NamespacedPodReflector) bindRemotePod(ctx context.Context, local *corev1.Pod, remote string) (*corev1.Pod, error) {
+	defer trace.FromContext(ctx).Step("Updated the local pod binding")
+	patch := forge.LocalPodBoundRemotePodPatch(remote)
+	bound, err := npr.localPodsClient.Patch(ctx, local.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		klog.Errorf("Failed to bind local pod %q to remote pod %q: %v", npr.LocalRef(local.GetName()), npr.RemoteRef(remote), err)
+		npr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedStatusReflectionMsg(err))
+		return nil, err
+	}
+
+	npr.bindings[local.GetName()] = remote
+	klog.Infof("Local pod %q successfully bound to remote pod %q", npr.LocalRef(local.GetName()), npr.RemoteRef(remote))
+	return bound, nil
+}
+
+// forgetBinding forgets about the remote pod bound to the given local one.
+func (npr *NamespacedPodReflector) forgetBinding(local string) {
+	npr.bindingsMutex.Lock()
+	defer npr.bindingsMutex.Unlock()
+	delete(npr.bindings, local)
+}
+
+// reportExtraReplicas reports the remote pods not bound to any local one (e.g., as scaled by a remote autoscaler) through an
+// event on the local Deployment. The event is output only when the number of extra replicas changes, to prevent flooding.
+func (npr *NamespacedPodReflector) reportExtraReplicas(workload string, extra int) {
+	if extra < 0 {
+		extra = 0
+	}
+	if npr.extraReplicas[workload] == extra {
+		return
+	}
+
+	npr.extraReplicas[workload] = extra
+	if extra == 0 {
+		return
+	}
+
+	klog.Warningf("Remote Deployment %q runs %d pods not bound to any local one", npr.RemoteRef(workload), extra)
+	if deployment, err := npr.localDeployments.Get(workload); err == nil {
+		npr.Event(deployment, corev1.EventTypeWarning, forge.EventExtraRemoteReplicas, forge.EventExtraRemoteReplicasMsg(extra))
+	}
+}
+
+// LocalWorkloadPods returns the active local pods belonging to the given Deployment and scheduled on the virtual node, sorted by name.
+func (npr *NamespacedPodReflector) LocalWorkloadPods(workload string) []*corev1.Pod {
+	deployment, err := npr.localDeployments.Get(workload)
+	utilruntime.Must(client.IgnoreNotFound(err))
+	if err != nil {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		klog.Warningf("Failed to parse the selector of local Deployment %q: %v", npr.LocalRef(workload), err)
+		return nil
+	}
+
+	pods, err := npr.localPods.List(selector)
+	utilruntime.Must(err)
+
+	var output []*corev1.Pod
+	for _, pod := range activePods(pods) {
+		if kind, name := forge.PodWorkload(pod); kind == forge.DeploymentKind && name == workload && pod.Spec.NodeName == forge.LiqoNodeName {
+			output = append(output, pod)
+		}
+	}
+	return output
+}
+
+// activePods filters the pods not yet terminating, and returns them sorted by name.
+func activePods(pods []*corev1.Pod) []*corev1.Pod {
+	var output []*corev1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp.IsZero() {
+			output = append(output, pod)
+		}
+	}
+
+	sort.Slice(output, func(i, j int) bool { return output[i].GetName() < output[j].GetName() })
+	return output
+}
@@ -0,0 +1,70 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	autoscalingv2listers "k8s.io/client-go/listers/autoscaling/v2"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/options"
+)
+
+// WorkloadPodKeyer returns a keyer which maps the local pods to the workload of the given kind they belong to, if any.
+func WorkloadPodKeyer(namespace, kind string) options.Keyer {
+	return func(metadata metav1.Object) []types.NamespacedName {
+		pod, ok := metadata.(*corev1.Pod)
+		if !ok {
+			return nil
+		}
+
+		if podKind, name := forge.PodWorkload(pod); podKind == kind {
+			return []types.NamespacedName{{Namespace: namespace, Name: name}}
+		}
+		return nil
+	}
+}
+
+// AutoscalerKeyer returns a keyer which maps the remote HorizontalPodAutoscalers to the workload of the given kind they target, if any.
+func AutoscalerKeyer(namespace, kind string) options.Keyer {
+	return func(metadata metav1.Object) []types.NamespacedName {
+		hpa, ok := metadata.(*autoscalingv2.HorizontalPodAutoscaler)
+		if !ok || hpa.Spec.ScaleTargetRef.Kind != kind {
+			return nil
+		}
+		return []types.NamespacedName{{Namespace: namespace, Name: hpa.Spec.ScaleTargetRef.Name}}
+	}
+}
+
+// RemoteReplicas returns the number of replicas of the given remote workload, which matches the one of the local pods
+// scheduled on the virtual node. It returns nil in case the remote workload is targeted by an autoscaler, as owning its replicas.
+func RemoteReplicas(kind, name string, localPods corev1listers.PodNamespaceLister,
+	remoteAutoscalers autoscalingv2listers.HorizontalPodAutoscalerNamespaceLister) *int32 {
+	hpas, err := remoteAutoscalers.List(labels.Everything())
+	utilruntime.Must(err)
+	if forge.IsAutoscaled(kind, name, hpas) {
+		return nil
+	}
+
+	pods, err := localPods.List(labels.Everything())
+	utilruntime.Must(err)
+	return forge.BoundReplicas(kind, name, pods)
+}
@@ -0,0 +1,172 @@
+// Copyright 2019-2022 The Liqo Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	appsv1clients "k8s.io/client-go/kubernetes/typed/apps/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	autoscalingv2listers "k8s.io/client-go/listers/autoscaling/v2"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"k8s.io/utils/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/liqotech/liqo/pkg/virtualKubelet/forge"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/generic"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/manager"
+	"github.com/liqotech/liqo/pkg/virtualKubelet/reflection/options"
+)
+
+var _ manager.NamespacedReflector = (*NamespacedStatefulSetReflector)(nil)
+
+const (
+	// StatefulSetReflectorName -> The name associated with the StatefulSet reflector.
+	StatefulSetReflectorName = "StatefulSet"
+)
+
+// NamespacedStatefulSetReflector manages the reflection of the StatefulSets marked to be offloaded as a whole,
+// for a given pair of local and remote namespaces.
+type NamespacedStatefulSetReflector struct {
+	generic.NamespacedReflector
+
+	localStatefulSets        appsv1listers.StatefulSetNamespaceLister
+	remoteStatefulSets       appsv1listers.StatefulSetNamespaceLister
+	remoteStatefulSetsClient appsv1clients.StatefulSetInterface
+
+	localPods         corev1listers.PodNamespaceLister
+	remoteAutoscalers autoscalingv2listers.HorizontalPodAutoscalerNamespaceLister
+}
+
+// NewStatefulSetReflector returns a new StatefulSetReflector instance.
+func NewStatefulSetReflector(workers uint) manager.Reflector {
+	return generic.NewReflector(StatefulSetReflectorName, NewNamespacedStatefulSetReflector, generic.WithoutFallback(), workers)
+}
+
+// NewNamespacedStatefulSetReflector returns a new NamespacedStatefulSetReflector instance.
+func NewNamespacedStatefulSetReflector(opts *options.NamespacedOpts) manager.NamespacedReflector {
+	local := opts.LocalFactory.Apps().V1().StatefulSets()
+	remote := opts.RemoteFactory.Apps().V1().StatefulSets()
+
+	local.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
+	remote.Informer().AddEventHandler(opts.HandlerFactory(generic.NamespacedKeyer(opts.LocalNamespace)))
+
+	// The local pods and the remote autoscalers are mapped to the corresponding workload, as determining the remote replicas.
+	localPods := opts.LocalFactory.Core().V1().Pods()
+	localPods.Informer().AddEventHandler(opts.HandlerFactory(WorkloadPodKeyer(opts.LocalNamespace, forge.StatefulSetKind)))
+	remoteAutoscalers := opts.RemoteFactory.Autoscaling().V2().HorizontalPodAutoscalers()
+	remoteAutoscalers.Informer().AddEventHandler(opts.HandlerFactory(AutoscalerKeyer(opts.LocalNamespace, forge.StatefulSetKind)))
+
+	return &NamespacedStatefulSetReflector{
+		NamespacedReflector:      generic.NewNamespacedReflector(opts, StatefulSetReflectorName),
+		localStatefulSets:        local.Lister().StatefulSets(opts.LocalNamespace),
+		remoteStatefulSets:       remote.Lister().StatefulSets(opts.RemoteNamespace),
+		remoteStatefulSetsClient: opts.RemoteClient.AppsV1().StatefulSets(opts.RemoteNamespace),
+
+		localPods:         localPods.Lister().Pods(opts.LocalNamespace),
+		remoteAutoscalers: remoteAutoscalers.Lister().HorizontalPodAutoscalers(opts.RemoteNamespace),
+	}
+}
+
+// Handle reconciles statefulset objects.
+func (nsr *NamespacedStatefulSetReflector) Handle(ctx context.Context, name string) error {
+	tracer := trace.FromContext(ctx)
+
+	// Retrieve the local and remote objects (only not found errors can occur).
+	klog.V(4).Infof("Handling reflection of local StatefulSet %q (remote: %q)", nsr.LocalRef(name), nsr.RemoteRef(name))
+	local, lerr := nsr.localStatefulSets.Get(name)
+	utilruntime.Must(client.IgnoreNotFound(lerr))
+	remote, rerr := nsr.remoteStatefulSets.Get(name)
+	utilruntime.Must(client.IgnoreNotFound(rerr))
+	tracer.Step("Retrieved the local and remote objects")
+
+	// Abort the reflection if the remote object is not managed by us, as we do not want to mutate others' objects.
+	if rerr == nil && !forge.IsReflected(remote) {
+		if lerr == nil && forge.IsRemoteWorkload(local) { // Do not output the warning event in case the local object is not concerned.
+			klog.Infof("Skipping reflection of local StatefulSet %q as remote already exists and is not managed by us", nsr.LocalRef(name))
+			nsr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionAlreadyExistsMsg())
+		}
+		return nil
+	}
+	tracer.Step("Performed the sanity checks")
+
+	// The local statefulset does no longer exist, or it is not marked to be reflected. Ensure it is also absent from the remote cluster.
+	if kerrors.IsNotFound(lerr) || !forge.IsRemoteWorkload(local) {
+		defer tracer.Step("Ensured the absence of the remote object")
+		if !kerrors.IsNotFound(rerr) {
+			klog.V(4).Infof("Deleting remote StatefulSet %q, since local %q is no longer reflected", nsr.RemoteRef(name), nsr.LocalRef(name))
+			return nsr.DeleteRemote(ctx, nsr.remoteStatefulSetsClient, StatefulSetReflectorName, name, remote.GetUID())
+		}
+		return nil
+	}
+
+	// Forge the remote object (the remote one is nil in case it does not exist yet).
+	replicas := RemoteReplicas(forge.StatefulSetKind, name, nsr.localPods, nsr.remoteAutoscalers)
+	target := forge.RemoteStatefulSet(local, remote, replicas, nsr.RemoteNamespace())
+	tracer.Step("Forged the remote object")
+
+	// If the remote statefulset does not exist, then create it.
+	if kerrors.IsNotFound(rerr) {
+		defer tracer.Step("Ensured the presence of the remote object")
+		if _, err := nsr.remoteStatefulSetsClient.Create(ctx, target, metav1.CreateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+			klog.Errorf("Failed to create remote StatefulSet %q (local: %q): %v", nsr.RemoteRef(name), nsr.LocalRef(name), err)
+			nsr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
+			return err
+		}
+
+		klog.Infof("Remote StatefulSet %q successfully created (local: %q)", nsr.RemoteRef(name), nsr.LocalRef(name))
+		nsr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulReflectionMsg())
+		return nil
+	}
+
+	// Do not attempt to perform an update if not necessary (the pod template is compared through the hash annotation).
+	if labels.Equals(remote.GetLabels(), target.GetLabels()) && labels.Equals(remote.GetAnnotations(), target.GetAnnotations()) &&
+		statefulSetSpecSynced(&remote.Spec, &target.Spec) {
+		klog.V(4).Infof("Skipping remote StatefulSet %q update, as already synced", nsr.RemoteRef(name))
+		return nil
+	}
+
+	defer tracer.Step("Ensured the correctness of the remote object")
+	if _, err := nsr.remoteStatefulSetsClient.Update(ctx, target, metav1.UpdateOptions{FieldManager: forge.ReflectionFieldManager}); err != nil {
+		klog.Errorf("Failed to update remote StatefulSet %q (local: %q): %v", nsr.RemoteRef(name), nsr.LocalRef(name), err)
+		if !kerrors.IsConflict(err) {
+			nsr.Event(local, corev1.EventTypeWarning, forge.EventFailedReflection, forge.EventFailedReflectionMsg(err))
+		}
+		return err
+	}
+
+	klog.Infof("Remote StatefulSet %q successfully updated (local: %q)", nsr.RemoteRef(name), nsr.LocalRef(name))
+	nsr.Event(local, corev1.EventTypeNormal, forge.EventSuccessfulReflection, forge.EventSuccessfulReflectionMsg())
+	return nil
+}
+
+// statefulSetSpecSynced returns whether the mutable fields forwarded to the remote StatefulSet, except for the pod template, are up to date.
+// The retention policy is not compared if unset locally, as possibly defaulted by the remote API server only (depending on the feature gates).
+func statefulSetSpecSynced(remote, target *appsv1.StatefulSetSpec) bool {
+	return pointer.Int32Equal(remote.Replicas, target.Replicas) &&
+		equality.Semantic.DeepEqual(remote.UpdateStrategy, target.UpdateStrategy) && remote.MinReadySeconds == target.MinReadySeconds &&
+		pointer.Int32Equal(remote.RevisionHistoryLimit, target.RevisionHistoryLimit) &&
+		(target.PersistentVolumeClaimRetentionPolicy == nil ||
+			equality.Semantic.DeepEqual(remote.PersistentVolumeClaimRetentionPolicy, target.PersistentVolumeClaimRetentionPolicy))
+}
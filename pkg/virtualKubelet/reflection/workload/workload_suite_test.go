@@ -21,6 +21,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -125,6 +126,23 @@ func UpdatePod(client kubernetes.Interface, pod *corev1.Pod) *corev1.Pod {
 	return pod
 }
 
+func GetDaemonSet(client kubernetes.Interface, namespace, name string) *appsv1.DaemonSet {
+	ds, errds := client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	ExpectWithOffset(1, errds).ToNot(HaveOccurred())
+	return ds
+}
+
+func GetDaemonSetError(client kubernetes.Interface, namespace, name string) error {
+	_, errds := client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	return errds
+}
+
+func CreateDaemonSet(client kubernetes.Interface, ds *appsv1.DaemonSet) *appsv1.DaemonSet {
+	ds, errds := client.AppsV1().DaemonSets(ds.GetNamespace()).Create(ctx, ds, metav1.CreateOptions{})
+	ExpectWithOffset(1, errds).ToNot(HaveOccurred())
+	return ds
+}
+
 func CreateServiceAccountSecret(client kubernetes.Interface, namespace, name, saName string) *corev1.Secret {
 	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
 		Name: name, Namespace: namespace, Labels: map[string]string{corev1.ServiceAccountNameKey: saName}}}
@@ -155,7 +155,7 @@ func initNatMappingController() error {
 		return err
 	}
 
-	controller, err = tunneloperator.NewNatMappingController(mgr.GetClient(), &readyClustersMutex, readyClusters, iptNetns)
+	controller, err = tunneloperator.NewNatMappingController(mgr.GetClient(), &readyClustersMutex, readyClusters, iptNetns, nil, nil)
 	if err != nil {
 		return err
 	}